@@ -7,9 +7,14 @@ import (
 	"strings"
 )
 
+// Node is implemented by every AST node. Pos and End give the source range
+// the node was parsed from: the position of its first character and the
+// position just past its last one.
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() lexer.Position
+	End() lexer.Position
 }
 
 type Stmt interface {
@@ -22,8 +27,11 @@ type Expr interface {
 	expressionNode()
 }
 
+// Program is a parsed module. Doc is its docstring, the string literal
+// opening the module, if any.
 type Program struct {
 	Stmts []Stmt
+	Doc string
 }
 
 func (p *Program) TokenLiteral() string {
@@ -34,6 +42,20 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() lexer.Position {
+	if len(p.Stmts) == 0 {
+		return lexer.Position{}
+	}
+	return p.Stmts[0].Pos()
+}
+
+func (p *Program) End() lexer.Position {
+	if len(p.Stmts) == 0 {
+		return lexer.Position{}
+	}
+	return p.Stmts[len(p.Stmts)-1].End()
+}
+
 func (p *Program) String() string {
 	var result bytes.Buffer
 	for _, stmt := range p.Stmts {
@@ -50,6 +72,8 @@ type VarStmt struct {
 
 func (vs *VarStmt) statementNode() {}
 func (vs *VarStmt) TokenLiteral() string { return vs.Token.Val }
+func (vs *VarStmt) Pos() lexer.Position { return vs.Token.Start() }
+func (vs *VarStmt) End() lexer.Position { return vs.Value.End() }
 func (vs *VarStmt) String() string {
 	var result bytes.Buffer
 	result.WriteString(vs.TokenLiteral() + " ")
@@ -68,6 +92,8 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Val }
+func (i *Identifier) Pos() lexer.Position { return i.Token.Start() }
+func (i *Identifier) End() lexer.Position { return i.Token.End() }
 func (i *Identifier) String() string { return i.Val }
 
 type ExprStmt struct {
@@ -77,6 +103,8 @@ type ExprStmt struct {
 
 func (es *ExprStmt) statementNode() {}
 func (es *ExprStmt) TokenLiteral() string { return es.Token.Val }
+func (es *ExprStmt) Pos() lexer.Position { return es.Expr.Pos() }
+func (es *ExprStmt) End() lexer.Position { return es.Expr.End() }
 func (es *ExprStmt) String() string {
 	if es.Expr != nil {
 		return es.Expr.String()
@@ -91,8 +119,22 @@ type IntLiteral struct {
 
 func (il *IntLiteral) expressionNode() {}
 func (il *IntLiteral) TokenLiteral() string { return il.Token.Val }
+func (il *IntLiteral) Pos() lexer.Position { return il.Token.Start() }
+func (il *IntLiteral) End() lexer.Position { return il.Token.End() }
 func (il *IntLiteral) String() string { return strconv.Itoa(int(il.Value)) }
 
+// FloatLiteral keeps the source text in Token so it prints as written.
+type FloatLiteral struct {
+	Token lexer.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Val }
+func (fl *FloatLiteral) Pos() lexer.Position { return fl.Token.Start() }
+func (fl *FloatLiteral) End() lexer.Position { return fl.Token.End() }
+func (fl *FloatLiteral) String() string { return fl.Token.Val }
+
 type StrLiteral struct {
 	Token lexer.Token
 	Value string
@@ -100,8 +142,46 @@ type StrLiteral struct {
 
 func (sl *StrLiteral) expressionNode() {}
 func (sl *StrLiteral) TokenLiteral() string { return sl.Token.Val }
+func (sl *StrLiteral) Pos() lexer.Position { return sl.Token.Start() }
+func (sl *StrLiteral) End() lexer.Position { return sl.Token.End() }
 func (sl *StrLiteral) String() string { return sl.Value }
 
+// BytesLiteral is a b"..." literal. Value holds its bytes, with escapes
+// decoded.
+type BytesLiteral struct {
+	Token lexer.Token
+	Value string
+}
+
+func (bl *BytesLiteral) expressionNode() {}
+func (bl *BytesLiteral) TokenLiteral() string { return bl.Token.Val }
+func (bl *BytesLiteral) Pos() lexer.Position { return bl.Token.Start() }
+func (bl *BytesLiteral) End() lexer.Position { return bl.Token.End() }
+func (bl *BytesLiteral) String() string { return "b\"" + bl.Token.Val + "\"" }
+
+// BoolLiteral is `True` or `False`.
+type BoolLiteral struct {
+	Token lexer.Token
+	Value bool
+}
+
+func (bl *BoolLiteral) expressionNode() {}
+func (bl *BoolLiteral) TokenLiteral() string { return bl.Token.Val }
+func (bl *BoolLiteral) Pos() lexer.Position { return bl.Token.Start() }
+func (bl *BoolLiteral) End() lexer.Position { return bl.Token.End() }
+func (bl *BoolLiteral) String() string { return bl.Token.Val }
+
+// NoneLiteral is `None`.
+type NoneLiteral struct {
+	Token lexer.Token
+}
+
+func (nl *NoneLiteral) expressionNode() {}
+func (nl *NoneLiteral) TokenLiteral() string { return nl.Token.Val }
+func (nl *NoneLiteral) Pos() lexer.Position { return nl.Token.Start() }
+func (nl *NoneLiteral) End() lexer.Position { return nl.Token.End() }
+func (nl *NoneLiteral) String() string { return "None" }
+
 type PrefixExpr struct {
 	Token lexer.Token
 	Op string
@@ -110,10 +190,15 @@ type PrefixExpr struct {
 
 func (pe *PrefixExpr) expressionNode() {}
 func (pe *PrefixExpr) TokenLiteral() string { return pe.Token.Val }
+func (pe *PrefixExpr) Pos() lexer.Position { return pe.Token.Start() }
+func (pe *PrefixExpr) End() lexer.Position { return pe.Expr.End() }
 func (pe *PrefixExpr) String() string {
 	var result bytes.Buffer
 	result.WriteString("(")
 	result.WriteString(pe.Op)
+	if pe.Op == "not" {
+		result.WriteString(" ")
+	}
 	result.WriteString(pe.Expr.String())
 	result.WriteString(")")
 	return result.String()
@@ -128,6 +213,8 @@ type InfixExpr struct {
 
 func (ie *InfixExpr) expressionNode() {}
 func (ie *InfixExpr) TokenLiteral() string { return ie.Token.Val }
+func (ie *InfixExpr) Pos() lexer.Position { return ie.Left.Pos() }
+func (ie *InfixExpr) End() lexer.Position { return ie.Right.End() }
 func (ie *InfixExpr) String() string {
 	var result bytes.Buffer
 	result.WriteString("(")
@@ -147,6 +234,13 @@ type IfExpr struct {
 
 func (ie *IfExpr) expressionNode()      {}
 func (ie *IfExpr) TokenLiteral() string { return ie.Token.Val }
+func (ie *IfExpr) Pos() lexer.Position { return ie.Token.Start() }
+func (ie *IfExpr) End() lexer.Position {
+	if ie.Fail != nil {
+		return ie.Fail.End()
+	}
+	return ie.Pass.End()
+}
 func (ie *IfExpr) String() string {
 	var result bytes.Buffer
 	result.WriteString("if")
@@ -160,6 +254,54 @@ func (ie *IfExpr) String() string {
 	return result.String()
 }
 
+// CompareExpr is a chain of comparisons: Left Ops[0] Comparators[0] Ops[1]
+// Comparators[1] ...
+type CompareExpr struct {
+	Token lexer.Token
+	Left Expr
+	Ops []string
+	Comparators []Expr
+}
+
+func (ce *CompareExpr) expressionNode() {}
+func (ce *CompareExpr) TokenLiteral() string { return ce.Token.Val }
+func (ce *CompareExpr) Pos() lexer.Position { return ce.Left.Pos() }
+func (ce *CompareExpr) End() lexer.Position { return ce.Comparators[len(ce.Comparators)-1].End() }
+func (ce *CompareExpr) String() string {
+	var result bytes.Buffer
+	result.WriteString("(")
+	result.WriteString(ce.Left.String())
+	for i, op := range ce.Ops {
+		result.WriteString(" " + op + " ")
+		result.WriteString(ce.Comparators[i].String())
+	}
+	result.WriteString(")")
+	return result.String()
+}
+
+type TernaryExpr struct {
+	Token lexer.Token
+	Cond Expr
+	Pass Expr
+	Fail Expr
+}
+
+func (te *TernaryExpr) expressionNode() {}
+func (te *TernaryExpr) TokenLiteral() string { return te.Token.Val }
+func (te *TernaryExpr) Pos() lexer.Position { return te.Pass.Pos() }
+func (te *TernaryExpr) End() lexer.Position { return te.Fail.End() }
+func (te *TernaryExpr) String() string {
+	var result bytes.Buffer
+	result.WriteString("(")
+	result.WriteString(te.Pass.String())
+	result.WriteString(" if ")
+	result.WriteString(te.Cond.String())
+	result.WriteString(" else ")
+	result.WriteString(te.Fail.String())
+	result.WriteString(")")
+	return result.String()
+}
+
 type BlockStmt struct {
 	Token lexer.Token
 	Stmts []Stmt
@@ -167,6 +309,18 @@ type BlockStmt struct {
 
 func (bs *BlockStmt) expressionNode()      {}
 func (bs *BlockStmt) TokenLiteral() string { return bs.Token.Val }
+func (bs *BlockStmt) Pos() lexer.Position {
+	if len(bs.Stmts) == 0 {
+		return bs.Token.Start()
+	}
+	return bs.Stmts[0].Pos()
+}
+func (bs *BlockStmt) End() lexer.Position {
+	if len(bs.Stmts) == 0 {
+		return bs.Token.End()
+	}
+	return bs.Stmts[len(bs.Stmts)-1].End()
+}
 func (bs *BlockStmt) String() string {
 	var result bytes.Buffer
 	for _, stmt := range bs.Stmts {
@@ -175,20 +329,36 @@ func (bs *BlockStmt) String() string {
 	return result.String()
 }
 
+// CallExpr is a call. Args may contain StarredExprs to spread. Keywords
+// names the arguments passed by keyword and KeywordValues holds their
+// values; a nil name marks a **mapping to spread. Rparen is the closing
+// parenthesis.
 type CallExpr struct {
 	Token lexer.Token
 	Func Expr
 	Args []Expr
+	Keywords []*Identifier
+	KeywordValues []Expr
+	Rparen lexer.Token
 }
 
 func (ce *CallExpr) expressionNode() {}
 func (ce *CallExpr) TokenLiteral() string { return ce.Token.Val }
+func (ce *CallExpr) Pos() lexer.Position { return ce.Func.Pos() }
+func (ce *CallExpr) End() lexer.Position { return ce.Rparen.End() }
 func (ce *CallExpr) String() string {
 	var result bytes.Buffer
 	var args []string
 	for _, arg := range ce.Args {
 		args = append(args, arg.String())
 	}
+	for i, keyword := range ce.Keywords {
+		if keyword == nil {
+			args = append(args, "**"+ce.KeywordValues[i].String())
+		} else {
+			args = append(args, keyword.String()+"="+ce.KeywordValues[i].String())
+		}
+	}
 	result.WriteString(ce.Func.String())
 	result.WriteString("(")
 	result.WriteString(strings.Join(args, ", "))
@@ -196,14 +366,23 @@ func (ce *CallExpr) String() string {
 	return result.String()
 }
 
+// WhileExpr is a while loop. Else is nil when the loop has no else clause.
 type WhileExpr struct {
 	Token lexer.Token
 	Cond Expr
 	Body *BlockStmt
+	Else *BlockStmt
 }
 
 func (we *WhileExpr) expressionNode() {}
 func (we *WhileExpr) TokenLiteral() string { return we.Token.Val }
+func (we *WhileExpr) Pos() lexer.Position { return we.Token.Start() }
+func (we *WhileExpr) End() lexer.Position {
+	if we.Else != nil {
+		return we.Else.End()
+	}
+	return we.Body.End()
+}
 func (we *WhileExpr) String() string {
 	var result bytes.Buffer
 	result.WriteString(we.Token.Val)
@@ -211,4 +390,778 @@ func (we *WhileExpr) String() string {
 	result.WriteString(we.Cond.String())
 	result.WriteString(")")
 	return result.String()
-}
\ No newline at end of file
+}
+// ForStmt is `for Target in Iter:`. Else is nil when the loop has no else
+// clause.
+type ForStmt struct {
+	Token lexer.Token
+	Target Expr
+	Iter Expr
+	Body *BlockStmt
+	Else *BlockStmt
+}
+
+func (fs *ForStmt) statementNode() {}
+func (fs *ForStmt) TokenLiteral() string { return fs.Token.Val }
+func (fs *ForStmt) Pos() lexer.Position { return fs.Token.Start() }
+func (fs *ForStmt) End() lexer.Position {
+	if fs.Else != nil {
+		return fs.Else.End()
+	}
+	return fs.Body.End()
+}
+func (fs *ForStmt) String() string {
+	var result bytes.Buffer
+	result.WriteString("for ")
+	result.WriteString(fs.Target.String())
+	result.WriteString(" in ")
+	result.WriteString(fs.Iter.String())
+	result.WriteString(": ")
+	result.WriteString(fs.Body.String())
+	if fs.Else != nil {
+		result.WriteString(" else: ")
+		result.WriteString(fs.Else.String())
+	}
+	return result.String()
+}
+
+type ListLiteral struct {
+	Token lexer.Token
+	Elements []Expr
+	Rbracket lexer.Token
+}
+
+func (ll *ListLiteral) expressionNode() {}
+func (ll *ListLiteral) TokenLiteral() string { return ll.Token.Val }
+func (ll *ListLiteral) Pos() lexer.Position { return ll.Token.Start() }
+func (ll *ListLiteral) End() lexer.Position { return ll.Rbracket.End() }
+func (ll *ListLiteral) String() string {
+	var result bytes.Buffer
+	result.WriteString("[")
+	result.WriteString(joinExprs(ll.Elements))
+	result.WriteString("]")
+	return result.String()
+}
+
+// TupleLiteral is a tuple display. Rparen is the closing parenthesis, or
+// the zero Token for a tuple written without parentheses.
+type TupleLiteral struct {
+	Token lexer.Token
+	Elements []Expr
+	Rparen lexer.Token
+}
+
+func (tl *TupleLiteral) expressionNode() {}
+func (tl *TupleLiteral) TokenLiteral() string { return tl.Token.Val }
+func (tl *TupleLiteral) Pos() lexer.Position {
+	if tl.Rparen.Name == "" {
+		return tl.Elements[0].Pos()
+	}
+	return tl.Token.Start()
+}
+func (tl *TupleLiteral) End() lexer.Position {
+	if tl.Rparen.Name == "" {
+		return tl.Elements[len(tl.Elements)-1].End()
+	}
+	return tl.Rparen.End()
+}
+func (tl *TupleLiteral) String() string {
+	var result bytes.Buffer
+	result.WriteString("(")
+	result.WriteString(joinExprs(tl.Elements))
+	if len(tl.Elements) == 1 {
+		result.WriteString(",")
+	}
+	result.WriteString(")")
+	return result.String()
+}
+
+type DictLiteral struct {
+	Token lexer.Token
+	Keys []Expr
+	Values []Expr
+	Rbrace lexer.Token
+}
+
+func (dl *DictLiteral) expressionNode() {}
+func (dl *DictLiteral) TokenLiteral() string { return dl.Token.Val }
+func (dl *DictLiteral) Pos() lexer.Position { return dl.Token.Start() }
+func (dl *DictLiteral) End() lexer.Position { return dl.Rbrace.End() }
+func (dl *DictLiteral) String() string {
+	var result bytes.Buffer
+	var pairs []string
+	for i, key := range dl.Keys {
+		pairs = append(pairs, key.String()+": "+dl.Values[i].String())
+	}
+	result.WriteString("{")
+	result.WriteString(strings.Join(pairs, ", "))
+	result.WriteString("}")
+	return result.String()
+}
+
+type IndexExpr struct {
+	Token lexer.Token
+	Left Expr
+	Index Expr
+	Rbracket lexer.Token
+}
+
+func (ie *IndexExpr) expressionNode() {}
+func (ie *IndexExpr) TokenLiteral() string { return ie.Token.Val }
+func (ie *IndexExpr) Pos() lexer.Position { return ie.Left.Pos() }
+func (ie *IndexExpr) End() lexer.Position { return ie.Rbracket.End() }
+func (ie *IndexExpr) String() string {
+	var result bytes.Buffer
+	result.WriteString(ie.Left.String())
+	result.WriteString("[")
+	result.WriteString(ie.Index.String())
+	result.WriteString("]")
+	return result.String()
+}
+
+type SliceExpr struct {
+	Token lexer.Token
+	Start Expr
+	Stop Expr
+	Step Expr
+}
+
+func (se *SliceExpr) expressionNode() {}
+func (se *SliceExpr) TokenLiteral() string { return se.Token.Val }
+func (se *SliceExpr) Pos() lexer.Position {
+	if se.Start != nil {
+		return se.Start.Pos()
+	}
+	return se.Token.Start()
+}
+func (se *SliceExpr) End() lexer.Position {
+	switch {
+	case se.Step != nil:
+		return se.Step.End()
+	case se.Stop != nil:
+		return se.Stop.End()
+	}
+	return se.Token.End()
+}
+func (se *SliceExpr) String() string {
+	var result bytes.Buffer
+	if se.Start != nil {
+		result.WriteString(se.Start.String())
+	}
+	result.WriteString(":")
+	if se.Stop != nil {
+		result.WriteString(se.Stop.String())
+	}
+	if se.Step != nil {
+		result.WriteString(":")
+		result.WriteString(se.Step.String())
+	}
+	return result.String()
+}
+
+type IndexAssignStmt struct {
+	Token lexer.Token
+	Target *IndexExpr
+	Value Expr
+}
+
+func (ia *IndexAssignStmt) statementNode() {}
+func (ia *IndexAssignStmt) TokenLiteral() string { return ia.Token.Val }
+func (ia *IndexAssignStmt) Pos() lexer.Position { return ia.Target.Pos() }
+func (ia *IndexAssignStmt) End() lexer.Position { return ia.Value.End() }
+func (ia *IndexAssignStmt) String() string {
+	var result bytes.Buffer
+	result.WriteString(ia.Target.String())
+	result.WriteString(" = ")
+	if ia.Value != nil {
+		result.WriteString(ia.Value.String())
+	}
+	return result.String()
+}
+
+// AssignStmt binds Value to each of its targets in turn, as in
+// `a = b = 0` or `a, b = b, a`. Tuple and list targets are unpacked.
+type AssignStmt struct {
+	Token lexer.Token
+	Targets []Expr
+	Value Expr
+}
+
+func (as *AssignStmt) statementNode() {}
+func (as *AssignStmt) TokenLiteral() string { return as.Token.Val }
+func (as *AssignStmt) Pos() lexer.Position { return as.Targets[0].Pos() }
+func (as *AssignStmt) End() lexer.Position { return as.Value.End() }
+func (as *AssignStmt) String() string {
+	var result bytes.Buffer
+	for _, target := range as.Targets {
+		result.WriteString(target.String())
+		result.WriteString(" = ")
+	}
+	if as.Value != nil {
+		result.WriteString(as.Value.String())
+	}
+	return result.String()
+}
+
+// AugAssignStmt is an assignment like x += 1. Op holds the binary operator
+// that is applied, without the trailing "=".
+type AugAssignStmt struct {
+	Token lexer.Token
+	Target Expr
+	Op string
+	Value Expr
+}
+
+func (as *AugAssignStmt) statementNode() {}
+func (as *AugAssignStmt) TokenLiteral() string { return as.Token.Val }
+func (as *AugAssignStmt) Pos() lexer.Position { return as.Target.Pos() }
+func (as *AugAssignStmt) End() lexer.Position { return as.Value.End() }
+func (as *AugAssignStmt) String() string {
+	var result bytes.Buffer
+	result.WriteString(as.Target.String())
+	result.WriteString(" " + as.Op + "= ")
+	if as.Value != nil {
+		result.WriteString(as.Value.String())
+	}
+	return result.String()
+}
+
+type PassStmt struct {
+	Token lexer.Token
+}
+
+func (ps *PassStmt) statementNode() {}
+func (ps *PassStmt) TokenLiteral() string { return ps.Token.Val }
+func (ps *PassStmt) Pos() lexer.Position { return ps.Token.Start() }
+func (ps *PassStmt) End() lexer.Position { return ps.Token.End() }
+func (ps *PassStmt) String() string { return "pass" }
+
+type BreakStmt struct {
+	Token lexer.Token
+}
+
+func (bs *BreakStmt) statementNode() {}
+func (bs *BreakStmt) TokenLiteral() string { return bs.Token.Val }
+func (bs *BreakStmt) Pos() lexer.Position { return bs.Token.Start() }
+func (bs *BreakStmt) End() lexer.Position { return bs.Token.End() }
+func (bs *BreakStmt) String() string { return "break" }
+
+type ContinueStmt struct {
+	Token lexer.Token
+}
+
+func (cs *ContinueStmt) statementNode() {}
+func (cs *ContinueStmt) TokenLiteral() string { return cs.Token.Val }
+func (cs *ContinueStmt) Pos() lexer.Position { return cs.Token.Start() }
+func (cs *ContinueStmt) End() lexer.Position { return cs.Token.End() }
+func (cs *ContinueStmt) String() string { return "continue" }
+
+// FunctionDef declares a function. Defaults holds the default value of
+// each parameter, nil where there is none. VarArgs and KwArgs name the
+// *args and **kwargs parameters when present. Locals lists the names the
+// body binds, which are local to every call. Generator is set when yield
+// appears in the body.
+type FunctionDef struct {
+	Token lexer.Token
+	Name *Identifier
+	Params []*Identifier
+	Defaults []Expr
+	VarArgs *Identifier
+	KwArgs *Identifier
+	Body *BlockStmt
+	Doc string
+	Locals []string
+	Generator bool
+}
+
+func (fd *FunctionDef) statementNode() {}
+func (fd *FunctionDef) TokenLiteral() string { return fd.Token.Val }
+func (fd *FunctionDef) Pos() lexer.Position { return fd.Token.Start() }
+func (fd *FunctionDef) End() lexer.Position { return fd.Body.End() }
+func (fd *FunctionDef) String() string {
+	var result bytes.Buffer
+	var params []string
+	for i, param := range fd.Params {
+		if fd.Defaults[i] != nil {
+			params = append(params, param.String()+"="+fd.Defaults[i].String())
+		} else {
+			params = append(params, param.String())
+		}
+	}
+	if fd.VarArgs != nil {
+		params = append(params, "*"+fd.VarArgs.String())
+	}
+	if fd.KwArgs != nil {
+		params = append(params, "**"+fd.KwArgs.String())
+	}
+	result.WriteString("def ")
+	result.WriteString(fd.Name.String())
+	result.WriteString("(")
+	result.WriteString(strings.Join(params, ", "))
+	result.WriteString("): ")
+	result.WriteString(fd.Body.String())
+	return result.String()
+}
+
+type ReturnStmt struct {
+	Token lexer.Token
+	Value Expr
+}
+
+func (rs *ReturnStmt) statementNode() {}
+func (rs *ReturnStmt) TokenLiteral() string { return rs.Token.Val }
+func (rs *ReturnStmt) Pos() lexer.Position { return rs.Token.Start() }
+func (rs *ReturnStmt) End() lexer.Position {
+	if rs.Value != nil {
+		return rs.Value.End()
+	}
+	return rs.Token.End()
+}
+func (rs *ReturnStmt) String() string {
+	if rs.Value != nil {
+		return "return " + rs.Value.String()
+	}
+	return "return"
+}
+
+// ClassDef declares a class. Base is nil when the class has no parent.
+type ClassDef struct {
+	Token lexer.Token
+	Name *Identifier
+	Base Expr
+	Body *BlockStmt
+	Doc string
+}
+
+func (cd *ClassDef) statementNode() {}
+func (cd *ClassDef) TokenLiteral() string { return cd.Token.Val }
+func (cd *ClassDef) Pos() lexer.Position { return cd.Token.Start() }
+func (cd *ClassDef) End() lexer.Position { return cd.Body.End() }
+func (cd *ClassDef) String() string {
+	var result bytes.Buffer
+	result.WriteString("class ")
+	result.WriteString(cd.Name.String())
+	if cd.Base != nil {
+		result.WriteString("(")
+		result.WriteString(cd.Base.String())
+		result.WriteString(")")
+	}
+	result.WriteString(": ")
+	result.WriteString(cd.Body.String())
+	return result.String()
+}
+
+type AttributeExpr struct {
+	Token lexer.Token
+	Object Expr
+	Attr *Identifier
+}
+
+func (ae *AttributeExpr) expressionNode() {}
+func (ae *AttributeExpr) TokenLiteral() string { return ae.Token.Val }
+func (ae *AttributeExpr) Pos() lexer.Position { return ae.Object.Pos() }
+func (ae *AttributeExpr) End() lexer.Position { return ae.Attr.End() }
+func (ae *AttributeExpr) String() string {
+	return ae.Object.String() + "." + ae.Attr.String()
+}
+
+// ImportStmt is `import a, b as c`. Aliases holds nil where no alias was
+// given.
+type ImportStmt struct {
+	Token lexer.Token
+	Names []*Identifier
+	Aliases []*Identifier
+}
+
+func (is *ImportStmt) statementNode() {}
+func (is *ImportStmt) TokenLiteral() string { return is.Token.Val }
+func (is *ImportStmt) Pos() lexer.Position { return is.Token.Start() }
+func (is *ImportStmt) End() lexer.Position { return lastImportName(is.Names, is.Aliases).End() }
+func (is *ImportStmt) String() string {
+	return "import " + joinImportNames(is.Names, is.Aliases)
+}
+
+// FromImportStmt is `from module import a, b as c`.
+type FromImportStmt struct {
+	Token lexer.Token
+	Module *Identifier
+	Names []*Identifier
+	Aliases []*Identifier
+}
+
+func (fs *FromImportStmt) statementNode() {}
+func (fs *FromImportStmt) TokenLiteral() string { return fs.Token.Val }
+func (fs *FromImportStmt) Pos() lexer.Position { return fs.Token.Start() }
+func (fs *FromImportStmt) End() lexer.Position { return lastImportName(fs.Names, fs.Aliases).End() }
+func (fs *FromImportStmt) String() string {
+	return "from " + fs.Module.String() + " import " + joinImportNames(fs.Names, fs.Aliases)
+}
+
+// TryStmt is try/except/else/finally. Else and Finally are nil when the
+// clause is absent.
+type TryStmt struct {
+	Token lexer.Token
+	Body *BlockStmt
+	Handlers []*ExceptClause
+	Else *BlockStmt
+	Finally *BlockStmt
+}
+
+func (ts *TryStmt) statementNode() {}
+func (ts *TryStmt) TokenLiteral() string { return ts.Token.Val }
+func (ts *TryStmt) Pos() lexer.Position { return ts.Token.Start() }
+func (ts *TryStmt) End() lexer.Position {
+	switch {
+	case ts.Finally != nil:
+		return ts.Finally.End()
+	case ts.Else != nil:
+		return ts.Else.End()
+	case len(ts.Handlers) > 0:
+		return ts.Handlers[len(ts.Handlers)-1].End()
+	}
+	return ts.Body.End()
+}
+func (ts *TryStmt) String() string {
+	var result bytes.Buffer
+	result.WriteString("try: ")
+	result.WriteString(ts.Body.String())
+	for _, handler := range ts.Handlers {
+		result.WriteString(" ")
+		result.WriteString(handler.String())
+	}
+	if ts.Else != nil {
+		result.WriteString(" else: ")
+		result.WriteString(ts.Else.String())
+	}
+	if ts.Finally != nil {
+		result.WriteString(" finally: ")
+		result.WriteString(ts.Finally.String())
+	}
+	return result.String()
+}
+
+// ExceptClause is one `except Type as name:` handler of a TryStmt. Type is
+// nil for a bare except and Name is nil when the exception is not bound.
+type ExceptClause struct {
+	Token lexer.Token
+	Type Expr
+	Name *Identifier
+	Body *BlockStmt
+}
+
+func (ec *ExceptClause) TokenLiteral() string { return ec.Token.Val }
+func (ec *ExceptClause) Pos() lexer.Position { return ec.Token.Start() }
+func (ec *ExceptClause) End() lexer.Position { return ec.Body.End() }
+func (ec *ExceptClause) String() string {
+	var result bytes.Buffer
+	result.WriteString("except")
+	if ec.Type != nil {
+		result.WriteString(" ")
+		result.WriteString(ec.Type.String())
+	}
+	if ec.Name != nil {
+		result.WriteString(" as ")
+		result.WriteString(ec.Name.String())
+	}
+	result.WriteString(": ")
+	result.WriteString(ec.Body.String())
+	return result.String()
+}
+
+// RaiseStmt is `raise exc`. Exception is nil for a bare raise.
+// RaiseStmt is `raise`, `raise exc` or `raise exc from cause`.
+type RaiseStmt struct {
+	Token lexer.Token
+	Exception Expr
+	Cause Expr
+}
+
+func (rs *RaiseStmt) statementNode() {}
+func (rs *RaiseStmt) TokenLiteral() string { return rs.Token.Val }
+func (rs *RaiseStmt) Pos() lexer.Position { return rs.Token.Start() }
+func (rs *RaiseStmt) End() lexer.Position {
+	if rs.Cause != nil {
+		return rs.Cause.End()
+	}
+	if rs.Exception != nil {
+		return rs.Exception.End()
+	}
+	return rs.Token.End()
+}
+func (rs *RaiseStmt) String() string {
+	if rs.Cause != nil {
+		return "raise " + rs.Exception.String() + " from " + rs.Cause.String()
+	}
+	if rs.Exception != nil {
+		return "raise " + rs.Exception.String()
+	}
+	return "raise"
+}
+
+// WithStmt is `with a as x, b:`. Targets holds nil where a context has no
+// as clause.
+type WithStmt struct {
+	Token lexer.Token
+	Contexts []Expr
+	Targets []Expr
+	Body *BlockStmt
+}
+
+func (ws *WithStmt) statementNode() {}
+func (ws *WithStmt) TokenLiteral() string { return ws.Token.Val }
+func (ws *WithStmt) Pos() lexer.Position { return ws.Token.Start() }
+func (ws *WithStmt) End() lexer.Position { return ws.Body.End() }
+func (ws *WithStmt) String() string {
+	var items []string
+	for i, context := range ws.Contexts {
+		if ws.Targets[i] != nil {
+			items = append(items, context.String()+" as "+ws.Targets[i].String())
+		} else {
+			items = append(items, context.String())
+		}
+	}
+	return "with " + strings.Join(items, ", ") + ": " + ws.Body.String()
+}
+
+// AssertStmt is `assert cond, msg`. Msg is nil when omitted.
+type AssertStmt struct {
+	Token lexer.Token
+	Cond Expr
+	Msg Expr
+}
+
+func (as *AssertStmt) statementNode() {}
+func (as *AssertStmt) TokenLiteral() string { return as.Token.Val }
+func (as *AssertStmt) Pos() lexer.Position { return as.Token.Start() }
+func (as *AssertStmt) End() lexer.Position {
+	if as.Msg != nil {
+		return as.Msg.End()
+	}
+	return as.Cond.End()
+}
+func (as *AssertStmt) String() string {
+	if as.Msg != nil {
+		return "assert " + as.Cond.String() + ", " + as.Msg.String()
+	}
+	return "assert " + as.Cond.String()
+}
+
+type DelStmt struct {
+	Token lexer.Token
+	Targets []Expr
+}
+
+func (ds *DelStmt) statementNode() {}
+func (ds *DelStmt) TokenLiteral() string { return ds.Token.Val }
+func (ds *DelStmt) Pos() lexer.Position { return ds.Token.Start() }
+func (ds *DelStmt) End() lexer.Position { return ds.Targets[len(ds.Targets)-1].End() }
+func (ds *DelStmt) String() string { return "del " + joinExprs(ds.Targets) }
+
+type GlobalStmt struct {
+	Token lexer.Token
+	Names []*Identifier
+}
+
+func (gs *GlobalStmt) statementNode() {}
+func (gs *GlobalStmt) TokenLiteral() string { return gs.Token.Val }
+func (gs *GlobalStmt) Pos() lexer.Position { return gs.Token.Start() }
+func (gs *GlobalStmt) End() lexer.Position { return gs.Names[len(gs.Names)-1].End() }
+func (gs *GlobalStmt) String() string { return "global " + joinIdents(gs.Names) }
+
+type NonlocalStmt struct {
+	Token lexer.Token
+	Names []*Identifier
+}
+
+func (ns *NonlocalStmt) statementNode() {}
+func (ns *NonlocalStmt) TokenLiteral() string { return ns.Token.Val }
+func (ns *NonlocalStmt) Pos() lexer.Position { return ns.Token.Start() }
+func (ns *NonlocalStmt) End() lexer.Position { return ns.Names[len(ns.Names)-1].End() }
+func (ns *NonlocalStmt) String() string { return "nonlocal " + joinIdents(ns.Names) }
+
+func joinIdents(idents []*Identifier) string {
+	var parts []string
+	for _, ident := range idents {
+		parts = append(parts, ident.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// YieldExpr is `yield value`. Value is nil for a bare yield.
+type YieldExpr struct {
+	Token lexer.Token
+	Value Expr
+}
+
+func (ye *YieldExpr) expressionNode() {}
+func (ye *YieldExpr) TokenLiteral() string { return ye.Token.Val }
+func (ye *YieldExpr) Pos() lexer.Position { return ye.Token.Start() }
+func (ye *YieldExpr) End() lexer.Position {
+	if ye.Value != nil {
+		return ye.Value.End()
+	}
+	return ye.Token.End()
+}
+func (ye *YieldExpr) String() string {
+	if ye.Value != nil {
+		return "(yield " + ye.Value.String() + ")"
+	}
+	return "(yield)"
+}
+
+// StarredExpr is `*value`, spreading an iterable into call arguments or,
+// as an assignment target, collecting the values left over by the other
+// targets into a list.
+type StarredExpr struct {
+	Token lexer.Token
+	Value Expr
+}
+
+func (se *StarredExpr) expressionNode() {}
+func (se *StarredExpr) TokenLiteral() string { return se.Token.Val }
+func (se *StarredExpr) Pos() lexer.Position { return se.Token.Start() }
+func (se *StarredExpr) End() lexer.Position { return se.Value.End() }
+func (se *StarredExpr) String() string { return "*" + se.Value.String() }
+
+// Pattern is the pattern of a case clause in a match statement.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// MatchStmt is `match Subject:` followed by its case clauses.
+type MatchStmt struct {
+	Token lexer.Token
+	Subject Expr
+	Cases []*MatchCase
+}
+
+func (ms *MatchStmt) statementNode() {}
+func (ms *MatchStmt) TokenLiteral() string { return ms.Token.Val }
+func (ms *MatchStmt) Pos() lexer.Position { return ms.Token.Start() }
+func (ms *MatchStmt) End() lexer.Position { return ms.Cases[len(ms.Cases)-1].End() }
+func (ms *MatchStmt) String() string {
+	var result bytes.Buffer
+	result.WriteString("match ")
+	result.WriteString(ms.Subject.String())
+	result.WriteString(":")
+	for _, c := range ms.Cases {
+		result.WriteString(" ")
+		result.WriteString(c.String())
+	}
+	return result.String()
+}
+
+// MatchCase is one `case Pattern:` clause of a match statement.
+type MatchCase struct {
+	Token lexer.Token
+	Pattern Pattern
+	Body *BlockStmt
+}
+
+func (mc *MatchCase) TokenLiteral() string { return mc.Token.Val }
+func (mc *MatchCase) Pos() lexer.Position { return mc.Token.Start() }
+func (mc *MatchCase) End() lexer.Position { return mc.Body.End() }
+func (mc *MatchCase) String() string {
+	return "case " + mc.Pattern.String() + ": " + mc.Body.String()
+}
+
+// LiteralPattern matches values equal to a number or string literal.
+type LiteralPattern struct {
+	Value Expr
+}
+
+func (lp *LiteralPattern) patternNode() {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Value.TokenLiteral() }
+func (lp *LiteralPattern) Pos() lexer.Position { return lp.Value.Pos() }
+func (lp *LiteralPattern) End() lexer.Position { return lp.Value.End() }
+func (lp *LiteralPattern) String() string { return lp.Value.String() }
+
+// CapturePattern matches any value and binds it to Name.
+type CapturePattern struct {
+	Name *Identifier
+}
+
+func (cp *CapturePattern) patternNode() {}
+func (cp *CapturePattern) TokenLiteral() string { return cp.Name.TokenLiteral() }
+func (cp *CapturePattern) Pos() lexer.Position { return cp.Name.Pos() }
+func (cp *CapturePattern) End() lexer.Position { return cp.Name.End() }
+func (cp *CapturePattern) String() string { return cp.Name.String() }
+
+// WildcardPattern is `_`, which matches any value without binding it.
+type WildcardPattern struct {
+	Token lexer.Token
+}
+
+func (wp *WildcardPattern) patternNode() {}
+func (wp *WildcardPattern) TokenLiteral() string { return wp.Token.Val }
+func (wp *WildcardPattern) Pos() lexer.Position { return wp.Token.Start() }
+func (wp *WildcardPattern) End() lexer.Position { return wp.Token.End() }
+func (wp *WildcardPattern) String() string { return "_" }
+
+// SequencePattern matches a list or tuple element by element, as in
+// `[a, b]` or `(first, *rest)`. Close is the closing bracket, zero when
+// the pattern is written without brackets.
+type SequencePattern struct {
+	Token lexer.Token
+	Patterns []Pattern
+	Close lexer.Token
+}
+
+func (sp *SequencePattern) patternNode() {}
+func (sp *SequencePattern) TokenLiteral() string { return sp.Token.Val }
+func (sp *SequencePattern) Pos() lexer.Position { return sp.Token.Start() }
+func (sp *SequencePattern) End() lexer.Position {
+	if sp.Close.Name == "" {
+		return sp.Patterns[len(sp.Patterns)-1].End()
+	}
+	return sp.Close.End()
+}
+func (sp *SequencePattern) String() string {
+	var parts []string
+	for _, pattern := range sp.Patterns {
+		parts = append(parts, pattern.String())
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// StarPattern is `*Name` in a sequence pattern, matching the elements the
+// other patterns leave over. `*_` matches them without binding a name.
+type StarPattern struct {
+	Token lexer.Token
+	Name *Identifier
+}
+
+func (sp *StarPattern) patternNode() {}
+func (sp *StarPattern) TokenLiteral() string { return sp.Token.Val }
+func (sp *StarPattern) Pos() lexer.Position { return sp.Token.Start() }
+func (sp *StarPattern) End() lexer.Position { return sp.Name.End() }
+func (sp *StarPattern) String() string { return "*" + sp.Name.String() }
+
+// lastImportName returns the last identifier of an import list.
+func lastImportName(names []*Identifier, aliases []*Identifier) *Identifier {
+	if alias := aliases[len(aliases)-1]; alias != nil {
+		return alias
+	}
+	return names[len(names)-1]
+}
+
+func joinImportNames(names []*Identifier, aliases []*Identifier) string {
+	var parts []string
+	for i, name := range names {
+		if aliases[i] != nil {
+			parts = append(parts, name.String()+" as "+aliases[i].String())
+		} else {
+			parts = append(parts, name.String())
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func joinExprs(exprs []Expr) string {
+	var parts []string
+	for _, expr := range exprs {
+		parts = append(parts, expr.String())
+	}
+	return strings.Join(parts, ", ")
+}
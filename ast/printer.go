@@ -0,0 +1,441 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Fprint writes node to w as gopy source. Statements are written one per
+// line with their blocks indented by tabs, and parentheses are added only
+// where precedence requires them, so parsing the output gives back an
+// equivalent tree. Tuples are always parenthesized.
+func Fprint(w io.Writer, node Node) error {
+	p := &printer{}
+	switch node := node.(type) {
+	case *Program:
+		p.stmts(node.Stmts)
+	case *BlockStmt:
+		p.stmts(node.Stmts)
+	case Stmt:
+		p.stmt(node)
+	case Expr:
+		p.expr(node, precYield)
+	case Pattern:
+		p.pattern(node)
+	case *MatchCase:
+		p.matchCase(node)
+	default:
+		return fmt.Errorf("ast.Fprint: unsupported node type %T", node)
+	}
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+// Binding strengths of expressions, mirroring the parser's precedence
+// table. precYield is below everything because a yield must be
+// parenthesized anywhere but on its own or as an assigned value.
+const (
+	precYield = iota
+	precLowest
+	precTernary
+	precOr
+	precAnd
+	precNot
+	precCompare
+	precBitOr
+	precBitXor
+	precBitAnd
+	precShift
+	precSum
+	precProduct
+	precPrefix
+	precPower
+	precAtom
+)
+
+var binaryPrec = map[string]int{
+	"or": precOr,
+	"and": precAnd,
+	"|": precBitOr,
+	"^": precBitXor,
+	"&": precBitAnd,
+	"<<": precShift,
+	">>": precShift,
+	"+": precSum,
+	"-": precSum,
+	"*": precProduct,
+	"/": precProduct,
+	"//": precProduct,
+	"%": precProduct,
+	"**": precPower,
+}
+
+func exprPrec(expr Expr) int {
+	switch expr := expr.(type) {
+	case *YieldExpr:
+		return precYield
+	case *TernaryExpr:
+		return precTernary
+	case *InfixExpr:
+		return binaryPrec[expr.Op]
+	case *CompareExpr:
+		return precCompare
+	case *PrefixExpr:
+		if expr.Op == "not" {
+			return precNot
+		}
+		return precPrefix
+	}
+	return precAtom
+}
+
+type printer struct {
+	buf bytes.Buffer
+	indent int
+}
+
+func (p *printer) print(args ...interface{}) {
+	for _, arg := range args {
+		fmt.Fprint(&p.buf, arg)
+	}
+}
+
+// line starts a new line at the current indentation.
+func (p *printer) line() {
+	p.buf.WriteString(strings.Repeat("\t", p.indent))
+}
+
+func (p *printer) stmts(stmts []Stmt) {
+	for _, stmt := range stmts {
+		p.stmt(stmt)
+	}
+}
+
+// block writes header, which ends in a colon, followed by the indented
+// statements of body.
+func (p *printer) block(body *BlockStmt, header ...interface{}) {
+	p.line()
+	p.print(header...)
+	p.print(":\n")
+	p.indent++
+	if len(body.Stmts) == 0 {
+		p.line()
+		p.print("pass\n")
+	}
+	p.stmts(body.Stmts)
+	p.indent--
+}
+
+func (p *printer) stmt(stmt Stmt) {
+	switch stmt := stmt.(type) {
+	case *ExprStmt:
+		switch expr := stmt.Expr.(type) {
+		case *IfExpr:
+			p.ifStmt(expr, "if")
+			return
+		case *WhileExpr:
+			p.block(expr.Body, "while ", p.exprString(expr.Cond, precLowest))
+			if expr.Else != nil {
+				p.block(expr.Else, "else")
+			}
+			return
+		}
+		p.simple(p.exprString(stmt.Expr, precYield))
+	case *VarStmt:
+		p.simple(stmt.Ident.Val, " = ", p.exprString(stmt.Value, precYield))
+	case *AssignStmt:
+		var parts []string
+		for _, target := range stmt.Targets {
+			parts = append(parts, p.exprString(target, precLowest))
+		}
+		parts = append(parts, p.exprString(stmt.Value, precYield))
+		p.simple(strings.Join(parts, " = "))
+	case *IndexAssignStmt:
+		p.simple(p.exprString(stmt.Target, precLowest), " = ", p.exprString(stmt.Value, precYield))
+	case *AugAssignStmt:
+		p.simple(p.exprString(stmt.Target, precLowest), " ", stmt.Op, "= ", p.exprString(stmt.Value, precYield))
+	case *PassStmt:
+		p.simple("pass")
+	case *BreakStmt:
+		p.simple("break")
+	case *ContinueStmt:
+		p.simple("continue")
+	case *ReturnStmt:
+		if stmt.Value == nil {
+			p.simple("return")
+		} else {
+			p.simple("return ", p.exprString(stmt.Value, precLowest))
+		}
+	case *FunctionDef:
+		var params []string
+		for i, param := range stmt.Params {
+			if stmt.Defaults[i] != nil {
+				params = append(params, param.Val+"="+p.exprString(stmt.Defaults[i], precLowest))
+			} else {
+				params = append(params, param.Val)
+			}
+		}
+		if stmt.VarArgs != nil {
+			params = append(params, "*"+stmt.VarArgs.Val)
+		}
+		if stmt.KwArgs != nil {
+			params = append(params, "**"+stmt.KwArgs.Val)
+		}
+		p.block(stmt.Body, "def ", stmt.Name.Val, "(", strings.Join(params, ", "), ")")
+	case *ClassDef:
+		if stmt.Base == nil {
+			p.block(stmt.Body, "class ", stmt.Name.Val)
+		} else {
+			p.block(stmt.Body, "class ", stmt.Name.Val, "(", p.exprString(stmt.Base, precLowest), ")")
+		}
+	case *ForStmt:
+		p.block(stmt.Body, "for ", p.exprString(stmt.Target, precBitOr), " in ", p.exprString(stmt.Iter, precLowest))
+		if stmt.Else != nil {
+			p.block(stmt.Else, "else")
+		}
+	case *ImportStmt:
+		p.simple("import ", joinImportNames(stmt.Names, stmt.Aliases))
+	case *FromImportStmt:
+		p.simple("from ", stmt.Module.Val, " import ", joinImportNames(stmt.Names, stmt.Aliases))
+	case *TryStmt:
+		p.block(stmt.Body, "try")
+		for _, handler := range stmt.Handlers {
+			p.exceptClause(handler)
+		}
+		if stmt.Else != nil {
+			p.block(stmt.Else, "else")
+		}
+		if stmt.Finally != nil {
+			p.block(stmt.Finally, "finally")
+		}
+	case *RaiseStmt:
+		switch {
+		case stmt.Exception == nil:
+			p.simple("raise")
+		case stmt.Cause == nil:
+			p.simple("raise ", p.exprString(stmt.Exception, precLowest))
+		default:
+			p.simple("raise ", p.exprString(stmt.Exception, precLowest), " from ", p.exprString(stmt.Cause, precLowest))
+		}
+	case *WithStmt:
+		var items []string
+		for i, context := range stmt.Contexts {
+			item := p.exprString(context, precLowest)
+			if stmt.Targets[i] != nil {
+				item += " as " + p.exprString(stmt.Targets[i], precBitOr)
+			}
+			items = append(items, item)
+		}
+		p.block(stmt.Body, "with ", strings.Join(items, ", "))
+	case *AssertStmt:
+		if stmt.Msg == nil {
+			p.simple("assert ", p.exprString(stmt.Cond, precLowest))
+		} else {
+			p.simple("assert ", p.exprString(stmt.Cond, precLowest), ", ", p.exprString(stmt.Msg, precLowest))
+		}
+	case *DelStmt:
+		p.simple("del ", p.exprList(stmt.Targets))
+	case *GlobalStmt:
+		p.simple("global ", joinIdents(stmt.Names))
+	case *NonlocalStmt:
+		p.simple("nonlocal ", joinIdents(stmt.Names))
+	case *MatchStmt:
+		p.line()
+		p.print("match ", p.exprString(stmt.Subject, precLowest), ":\n")
+		p.indent++
+		for _, c := range stmt.Cases {
+			p.matchCase(c)
+		}
+		p.indent--
+	}
+}
+
+// simple writes a statement that fits on one line.
+func (p *printer) simple(parts ...interface{}) {
+	p.line()
+	p.print(parts...)
+	p.print("\n")
+}
+
+// ifStmt writes an if statement, turning an else block holding only
+// another if statement into an elif clause.
+func (p *printer) ifStmt(expr *IfExpr, keyword string) {
+	p.block(expr.Pass, keyword, " ", p.exprString(expr.Cond, precLowest))
+	if expr.Fail == nil {
+		return
+	}
+	if len(expr.Fail.Stmts) == 1 {
+		if stmt, ok := expr.Fail.Stmts[0].(*ExprStmt); ok {
+			if elif, ok := stmt.Expr.(*IfExpr); ok {
+				p.ifStmt(elif, "elif")
+				return
+			}
+		}
+	}
+	p.block(expr.Fail, "else")
+}
+
+func (p *printer) exceptClause(ec *ExceptClause) {
+	switch {
+	case ec.Type == nil:
+		p.block(ec.Body, "except")
+	case ec.Name == nil:
+		p.block(ec.Body, "except ", p.exprString(ec.Type, precLowest))
+	default:
+		p.block(ec.Body, "except ", p.exprString(ec.Type, precLowest), " as ", ec.Name.Val)
+	}
+}
+
+func (p *printer) matchCase(c *MatchCase) {
+	header := &printer{}
+	header.pattern(c.Pattern)
+	p.block(c.Body, "case ", header.buf.String())
+}
+
+func (p *printer) pattern(pattern Pattern) {
+	switch pattern := pattern.(type) {
+	case *LiteralPattern:
+		p.expr(pattern.Value, precLowest)
+	case *CapturePattern:
+		p.print(pattern.Name.Val)
+	case *WildcardPattern:
+		p.print("_")
+	case *StarPattern:
+		p.print("*", pattern.Name.Val)
+	case *SequencePattern:
+		p.print("[")
+		for i, elem := range pattern.Patterns {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.pattern(elem)
+		}
+		p.print("]")
+	}
+}
+
+// exprString returns expr as source, parenthesized if it binds more
+// loosely than prec.
+func (p *printer) exprString(expr Expr, prec int) string {
+	sub := &printer{}
+	sub.expr(expr, prec)
+	return sub.buf.String()
+}
+
+func (p *printer) exprList(exprs []Expr) string {
+	var parts []string
+	for _, expr := range exprs {
+		parts = append(parts, p.exprString(expr, precLowest))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (p *printer) expr(expr Expr, prec int) {
+	if exprPrec(expr) < prec {
+		p.print("(")
+		defer p.print(")")
+	}
+	switch expr := expr.(type) {
+	case *Identifier:
+		p.print(expr.Val)
+	case *IntLiteral:
+		p.print(strconv.FormatInt(expr.Value, 10))
+	case *StrLiteral:
+		p.print(`"`, expr.Value, `"`)
+	case *FloatLiteral, *BytesLiteral, *BoolLiteral, *NoneLiteral:
+		p.print(expr.String())
+	case *PrefixExpr:
+		if expr.Op == "not" {
+			p.print("not ")
+			p.expr(expr.Expr, precNot)
+		} else {
+			p.print(expr.Op)
+			p.expr(expr.Expr, precPrefix)
+		}
+	case *InfixExpr:
+		// ** groups to the right and every other operator to the left.
+		left, right := binaryPrec[expr.Op], binaryPrec[expr.Op]+1
+		if expr.Op == "**" {
+			left, right = right, left
+		}
+		p.expr(expr.Left, left)
+		p.print(" ", expr.Op, " ")
+		p.expr(expr.Right, right)
+	case *CompareExpr:
+		p.expr(expr.Left, precBitOr)
+		for i, op := range expr.Ops {
+			p.print(" ", op, " ")
+			p.expr(expr.Comparators[i], precBitOr)
+		}
+	case *TernaryExpr:
+		p.expr(expr.Pass, precOr)
+		p.print(" if ")
+		p.expr(expr.Cond, precOr)
+		p.print(" else ")
+		p.expr(expr.Fail, precTernary)
+	case *CallExpr:
+		p.expr(expr.Func, precAtom)
+		args := []string{}
+		for _, arg := range expr.Args {
+			args = append(args, p.exprString(arg, precLowest))
+		}
+		for i, keyword := range expr.Keywords {
+			value := p.exprString(expr.KeywordValues[i], precLowest)
+			if keyword == nil {
+				args = append(args, "**"+value)
+			} else {
+				args = append(args, keyword.Val+"="+value)
+			}
+		}
+		p.print("(", strings.Join(args, ", "), ")")
+	case *ListLiteral:
+		p.print("[", p.exprList(expr.Elements), "]")
+	case *TupleLiteral:
+		if len(expr.Elements) == 1 {
+			p.print("(", p.exprString(expr.Elements[0], precLowest), ",)")
+		} else {
+			p.print("(", p.exprList(expr.Elements), ")")
+		}
+	case *DictLiteral:
+		var items []string
+		for i, key := range expr.Keys {
+			items = append(items, p.exprString(key, precLowest)+": "+p.exprString(expr.Values[i], precLowest))
+		}
+		p.print("{", strings.Join(items, ", "), "}")
+	case *IndexExpr:
+		p.expr(expr.Left, precAtom)
+		p.print("[")
+		p.expr(expr.Index, precLowest)
+		p.print("]")
+	case *SliceExpr:
+		if expr.Start != nil {
+			p.expr(expr.Start, precLowest)
+		}
+		p.print(":")
+		if expr.Stop != nil {
+			p.expr(expr.Stop, precLowest)
+		}
+		if expr.Step != nil {
+			p.print(":")
+			p.expr(expr.Step, precLowest)
+		}
+	case *AttributeExpr:
+		p.expr(expr.Object, precAtom)
+		p.print(".", expr.Attr.Val)
+	case *YieldExpr:
+		p.print("yield")
+		if expr.Value != nil {
+			p.print(" ")
+			p.expr(expr.Value, precLowest)
+		}
+	case *StarredExpr:
+		p.print("*")
+		p.expr(expr.Value, precBitOr)
+	case *IfExpr, *WhileExpr:
+		// Compound statements have no expression form.
+		p.print(expr.String())
+	}
+}
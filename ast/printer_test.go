@@ -0,0 +1,130 @@
+package ast_test
+
+import (
+	"bytes"
+	"gopy/ast"
+	"gopy/parser"
+	"testing"
+)
+
+func fprint(t *testing.T, node ast.Node) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := ast.Fprint(&buf, node); err != nil {
+		t.Fatalf("Fprint: unexpected error: %v", err)
+	}
+	return buf.String()
+}
+
+func TestFprint(t *testing.T) {
+	tests := []struct {
+		input string
+		want string
+	}{
+		{"x = (1 + 2) * 3\n", "x = (1 + 2) * 3\n"},
+		{"x = 1 + (2 * 3)\n", "x = 1 + 2 * 3\n"},
+		{"x = 1 - (2 - 3)\n", "x = 1 - (2 - 3)\n"},
+		{"x = (2 ** 3) ** 2\n", "x = (2 ** 3) ** 2\n"},
+		{"x = 2 ** (3 ** 2)\n", "x = 2 ** 3 ** 2\n"},
+		{"x = -(1 + 2)\n", "x = -(1 + 2)\n"},
+		{"x = not (a and b)\n", "x = not (a and b)\n"},
+		{"x = (a if b else c) if d else e\n", "x = (a if b else c) if d else e\n"},
+		{"a, b = 1, 2\n", "(a, b) = (1, 2)\n"},
+		{"t = (1,)\n", "t = (1,)\n"},
+		{"x = [True, False, None]\n", "x = [True, False, None]\n"},
+		{"x = b\"ab\" + \"c\"\n", "x = b\"ab\" + \"c\"\n"},
+		{"raise ValueError(x) from None\n", "raise ValueError(x) from None\n"},
+		{"x = 1.5 % .5e1\n", "x = 1.5 % .5e1\n"},
+		{"f(1, k=2, **d)\n", "f(1, k=2, **d)\n"},
+		{"x = y[1:2]\n", "x = y[1:2]\n"},
+		{"while x:\n\tx -= 1\nelse:\n\tpass\n", "while x:\n\tx -= 1\nelse:\n\tpass\n"},
+		{
+			"if a:\n\tb = 1\nelif c:\n\tb = 2\nelse:\n\tb = 3\n",
+			"if a:\n\tb = 1\nelif c:\n\tb = 2\nelse:\n\tb = 3\n",
+		},
+		{
+			"def f(a, b=1, *args, **kw):\n\tif a:\n\t\treturn b\n\treturn\n",
+			"def f(a, b=1, *args, **kw):\n\tif a:\n\t\treturn b\n\treturn\n",
+		},
+		{
+			"match p:\n\tcase [1, *rest]:\n\t\tpass\n\tcase _:\n\t\tpass\n",
+			"match p:\n\tcase [1, *rest]:\n\t\tpass\n\tcase _:\n\t\tpass\n",
+		},
+	}
+
+	for _, tt := range tests {
+		program, err := parser.Parse([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("Parse(%q): unexpected error: %v", tt.input, err)
+		}
+		if got := fprint(t, program); got != tt.want {
+			t.Errorf("Fprint(%q); want %q; got %q", tt.input, tt.want, got)
+		}
+	}
+}
+
+// TestFprintRoundTrip checks that printed source parses back to a tree
+// that prints the same way.
+func TestFprintRoundTrip(t *testing.T) {
+	src := `"Module."
+import os, sys as system
+from m import a, b as c
+class A(B):
+	"Class."
+	def __init__(self, x):
+		self.x = x
+		self.items = [x, {1: "one"}, ()]
+		self.items[0] += 1
+def gen(n):
+	global total
+	yield n
+	x = yield
+	y = (yield n) + 1
+	for i, *rest in [(1, 2, 3)]:
+		if i:
+			continue
+		break
+	else:
+		total = 0
+	try:
+		assert n < 3 == n, "small"
+	except ValueError as e:
+		raise e
+	except:
+		raise
+	else:
+		del x, y
+	finally:
+		pass
+	with open("f") as f, g:
+		def inner():
+			nonlocal n
+			n = n | 1 & 2 ^ 3 << 4 // 2 % 5
+			return lambda_ if not n else -~n
+`
+	program, err := parser.Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	first := fprint(t, program)
+	reparsed, err := parser.Parse([]byte(first))
+	if err != nil {
+		t.Fatalf("Parse(Fprint output): unexpected error: %v\n%s", err, first)
+	}
+	if second := fprint(t, reparsed); second != first {
+		t.Errorf("Fprint not stable across a round trip;\nfirst:\n%s\nsecond:\n%s", first, second)
+	}
+	if got, want := reparsed.String(), program.String(); got != want {
+		t.Errorf("reparsed tree differs;\nwant %s\ngot  %s", want, got)
+	}
+}
+
+func TestFprintExpr(t *testing.T) {
+	expr, err := parser.ParseExpr("a * (b + c)")
+	if err != nil {
+		t.Fatalf("ParseExpr: unexpected error: %v", err)
+	}
+	if got, want := fprint(t, expr), "a * (b + c)"; got != want {
+		t.Errorf("Fprint; want %q; got %q", want, got)
+	}
+}
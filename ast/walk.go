@@ -0,0 +1,226 @@
+package ast
+
+// A Visitor's Visit method is called for each node encountered by Walk. If
+// the result w is not nil, Walk visits each of the children of node with w,
+// followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, children in source order. It
+// starts by calling v.Visit(node); node must not be nil.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	switch n := node.(type) {
+	case *Program:
+		walkStmts(v, n.Stmts)
+	case *BlockStmt:
+		walkStmts(v, n.Stmts)
+	case *VarStmt:
+		Walk(v, n.Ident)
+		walkExpr(v, n.Value)
+	case *ExprStmt:
+		walkExpr(v, n.Expr)
+	case *IndexAssignStmt:
+		Walk(v, n.Target)
+		walkExpr(v, n.Value)
+	case *AssignStmt:
+		walkExprs(v, n.Targets)
+		walkExpr(v, n.Value)
+	case *AugAssignStmt:
+		walkExpr(v, n.Target)
+		walkExpr(v, n.Value)
+	case *FunctionDef:
+		Walk(v, n.Name)
+		for i, param := range n.Params {
+			Walk(v, param)
+			walkExpr(v, n.Defaults[i])
+		}
+		walkIdent(v, n.VarArgs)
+		walkIdent(v, n.KwArgs)
+		Walk(v, n.Body)
+	case *ReturnStmt:
+		walkExpr(v, n.Value)
+	case *ClassDef:
+		Walk(v, n.Name)
+		walkExpr(v, n.Base)
+		Walk(v, n.Body)
+	case *ImportStmt:
+		walkImportNames(v, n.Names, n.Aliases)
+	case *FromImportStmt:
+		Walk(v, n.Module)
+		walkImportNames(v, n.Names, n.Aliases)
+	case *TryStmt:
+		Walk(v, n.Body)
+		for _, handler := range n.Handlers {
+			Walk(v, handler)
+		}
+		if n.Else != nil {
+			Walk(v, n.Else)
+		}
+		if n.Finally != nil {
+			Walk(v, n.Finally)
+		}
+	case *ExceptClause:
+		walkExpr(v, n.Type)
+		walkIdent(v, n.Name)
+		Walk(v, n.Body)
+	case *RaiseStmt:
+		walkExpr(v, n.Exception)
+		walkExpr(v, n.Cause)
+	case *WithStmt:
+		for i, context := range n.Contexts {
+			walkExpr(v, context)
+			walkExpr(v, n.Targets[i])
+		}
+		Walk(v, n.Body)
+	case *AssertStmt:
+		walkExpr(v, n.Cond)
+		walkExpr(v, n.Msg)
+	case *DelStmt:
+		walkExprs(v, n.Targets)
+	case *GlobalStmt:
+		walkIdents(v, n.Names)
+	case *NonlocalStmt:
+		walkIdents(v, n.Names)
+	case *PrefixExpr:
+		walkExpr(v, n.Expr)
+	case *InfixExpr:
+		walkExpr(v, n.Left)
+		walkExpr(v, n.Right)
+	case *CompareExpr:
+		walkExpr(v, n.Left)
+		walkExprs(v, n.Comparators)
+	case *TernaryExpr:
+		walkExpr(v, n.Pass)
+		walkExpr(v, n.Cond)
+		walkExpr(v, n.Fail)
+	case *IfExpr:
+		walkExpr(v, n.Cond)
+		Walk(v, n.Pass)
+		if n.Fail != nil {
+			Walk(v, n.Fail)
+		}
+	case *WhileExpr:
+		walkExpr(v, n.Cond)
+		Walk(v, n.Body)
+		if n.Else != nil {
+			Walk(v, n.Else)
+		}
+	case *ForStmt:
+		walkExpr(v, n.Target)
+		walkExpr(v, n.Iter)
+		Walk(v, n.Body)
+		if n.Else != nil {
+			Walk(v, n.Else)
+		}
+	case *CallExpr:
+		walkExpr(v, n.Func)
+		walkExprs(v, n.Args)
+		for i, keyword := range n.Keywords {
+			walkIdent(v, keyword)
+			walkExpr(v, n.KeywordValues[i])
+		}
+	case *ListLiteral:
+		walkExprs(v, n.Elements)
+	case *TupleLiteral:
+		walkExprs(v, n.Elements)
+	case *DictLiteral:
+		for i, key := range n.Keys {
+			walkExpr(v, key)
+			walkExpr(v, n.Values[i])
+		}
+	case *IndexExpr:
+		walkExpr(v, n.Left)
+		walkExpr(v, n.Index)
+	case *SliceExpr:
+		walkExpr(v, n.Start)
+		walkExpr(v, n.Stop)
+		walkExpr(v, n.Step)
+	case *AttributeExpr:
+		walkExpr(v, n.Object)
+		Walk(v, n.Attr)
+	case *YieldExpr:
+		walkExpr(v, n.Value)
+	case *StarredExpr:
+		walkExpr(v, n.Value)
+	case *MatchStmt:
+		walkExpr(v, n.Subject)
+		for _, c := range n.Cases {
+			Walk(v, c)
+		}
+	case *MatchCase:
+		Walk(v, n.Pattern)
+		Walk(v, n.Body)
+	case *LiteralPattern:
+		walkExpr(v, n.Value)
+	case *CapturePattern:
+		Walk(v, n.Name)
+	case *SequencePattern:
+		for _, pattern := range n.Patterns {
+			Walk(v, pattern)
+		}
+	case *StarPattern:
+		Walk(v, n.Name)
+	}
+	v.Visit(nil)
+}
+
+// The helpers below skip the optional children that are nil.
+
+func walkExpr(v Visitor, expr Expr) {
+	if expr != nil {
+		Walk(v, expr)
+	}
+}
+
+func walkIdent(v Visitor, ident *Identifier) {
+	if ident != nil {
+		Walk(v, ident)
+	}
+}
+
+func walkExprs(v Visitor, exprs []Expr) {
+	for _, expr := range exprs {
+		walkExpr(v, expr)
+	}
+}
+
+func walkIdents(v Visitor, idents []*Identifier) {
+	for _, ident := range idents {
+		walkIdent(v, ident)
+	}
+}
+
+func walkStmts(v Visitor, stmts []Stmt) {
+	for _, stmt := range stmts {
+		if stmt != nil {
+			Walk(v, stmt)
+		}
+	}
+}
+
+func walkImportNames(v Visitor, names []*Identifier, aliases []*Identifier) {
+	for i, name := range names {
+		Walk(v, name)
+		walkIdent(v, aliases[i])
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order, calling f(node) for each
+// node. If f returns true, Inspect visits the children of node, followed
+// by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
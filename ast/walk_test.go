@@ -0,0 +1,81 @@
+package ast_test
+
+import (
+	"gopy/ast"
+	"gopy/parser"
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	src := "def f(a, b=1):\n\treturn g(a, k=b)[0]\nx = f(2) + len([3, 4])\n"
+	program, err := parser.Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	var idents []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			idents = append(idents, ident.Val)
+		}
+		return true
+	})
+	want := "f a b g a k b x f len"
+	if got := strings.Join(idents, " "); got != want {
+		t.Errorf("identifiers; want %s; got %s", want, got)
+	}
+}
+
+func TestInspectPrune(t *testing.T) {
+	src := "def f():\n\treturn 1\ny = 2\n"
+	program, err := parser.Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	ints := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if _, ok := node.(*ast.IntLiteral); ok {
+			ints++
+		}
+		_, isDef := node.(*ast.FunctionDef)
+		return !isDef
+	})
+	if ints != 1 {
+		t.Errorf("want 1 int literal outside the function; got %d", ints)
+	}
+}
+
+// depthCounter records the deepest nesting it is walked to and checks that
+// every visit of a node is balanced by Visit(nil).
+type depthCounter struct {
+	depth *int
+	max *int
+}
+
+func (d depthCounter) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		*d.depth--
+		return nil
+	}
+	*d.depth++
+	if *d.depth > *d.max {
+		*d.max = *d.depth
+	}
+	return d
+}
+
+func TestWalk(t *testing.T) {
+	program, err := parser.Parse([]byte("x = (1 + 2) * 3\n"))
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	depth, max := 0, 0
+	ast.Walk(depthCounter{&depth, &max}, program)
+	if depth != 0 {
+		t.Errorf("unbalanced walk; depth %d after walking", depth)
+	}
+	// Program > VarStmt > * > + > 1
+	if max != 5 {
+		t.Errorf("want max depth 5; got %d", max)
+	}
+}
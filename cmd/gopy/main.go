@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gopy/evaluator"
+	"gopy/interpreter"
+	"gopy/parser"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	optimize := flag.Bool("O", false, "skip assert statements")
+	flag.Parse()
+
+	env := evaluator.NewEnv()
+	path := "parser/test.py"
+	if flag.NArg() > 0 {
+		path = flag.Arg(0)
+		evaluator.SetArgv(env, flag.Args())
+	} else {
+		evaluator.SetArgv(env, []string{path})
+	}
+	// Modules are looked up next to the script first, then on GOPYPATH.
+	searchPath := []string{filepath.Dir(path)}
+	searchPath = append(searchPath, filepath.SplitList(os.Getenv("GOPYPATH"))...)
+	evaluator.SetSearchPath(env, searchPath)
+	evaluator.SetAssertions(env, !*optimize)
+
+	program, err := parser.ParseFile(path)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for _, stmt := range program.Stmts {
+		item := evaluator.Evaluate(stmt, env)
+		if err, ok := item.(*interpreter.Error); ok {
+			if status, ok := evaluator.ExitStatus(err, env); ok {
+				os.Exit(status)
+			}
+			fmt.Fprint(os.Stderr, evaluator.FormatTraceback(err))
+			os.Exit(1)
+		}
+	}
+
+	//w := bufio.NewWriter(os.Stdout)
+	//r := bufio.NewReader(os.Stdin)
+	//repl.Run(w, r)
+}
\ No newline at end of file
@@ -0,0 +1,87 @@
+package gopy
+
+import (
+	"fmt"
+	"gopy/interpreter"
+	"gopy/lexer"
+	"gopy/parser"
+	"strings"
+)
+
+// ErrorKind says how a script failed.
+type ErrorKind int
+
+const (
+	// KindSyntax is a script that failed to parse.
+	KindSyntax ErrorKind = iota + 1
+	// KindRuntime is a script that raised an exception it didn't handle.
+	KindRuntime
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindSyntax:
+		return "syntax"
+	case KindRuntime:
+		return "runtime"
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+// Error is how a script's failure is reported to the host.
+type Error struct {
+	Kind ErrorKind
+	// Type names the exception raised, such as "ValueError", or is
+	// "SyntaxError" for a script that failed to parse.
+	Type string
+	// Message is the exception's message, or the parser's.
+	Message string
+	// Pos is where the exception was raised, or where the first syntax
+	// error was found when the parser reports it.
+	Pos lexer.Position
+	// Traceback lists the calls the exception passed through, most recent
+	// last. It is empty for syntax errors.
+	Traceback []interpreter.TraceEntry
+	// Err is the *parser.SyntaxError or *interpreter.Error behind this one.
+	Err error
+}
+
+// Error returns the message Python would print last: the exception type
+// and message, or the syntax errors.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error behind e, for errors.As.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// syntaxError returns the Error for a script that failed to parse.
+func syntaxError(err *parser.SyntaxError) *Error {
+	msgs := make([]string, len(err.Errors))
+	for i, perr := range err.Errors {
+		msgs[i] = perr.Msg
+	}
+	e := &Error{Kind: KindSyntax, Type: "SyntaxError", Message: strings.Join(msgs, "; "), Err: err}
+	if len(err.Errors) != 0 {
+		e.Pos = err.Errors[0].Pos
+	}
+	return e
+}
+
+// runtimeError returns the Error for an exception a script didn't handle.
+func runtimeError(err *interpreter.Error) *Error {
+	e := &Error{Kind: KindRuntime, Type: "Exception", Message: err.Err, Err: err}
+	if err.Exception != nil {
+		e.Type = err.Exception.Class.Name
+		e.Message = strings.TrimPrefix(strings.TrimPrefix(err.Err, e.Type), ": ")
+	}
+	for i := len(err.Traceback) - 1; i >= 0; i-- {
+		if entry := err.Traceback[i]; entry.Pos.IsValid() {
+			e.Traceback = append(e.Traceback, entry)
+			e.Pos = entry.Pos
+		}
+	}
+	return e
+}
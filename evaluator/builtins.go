@@ -0,0 +1,929 @@
+package evaluator
+
+import (
+	"bufio"
+	"gopy/interpreter"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// builtins holds the builtin functions. It is the default table that
+// Builtins copies, and is never changed once initialised.
+var builtins map[string]*interpreter.Builtin
+
+// Stdout is where print writes when no file is given, for interpreters
+// not given a writer of their own by SetStdout. It must not be changed
+// while scripts run.
+var Stdout io.Writer = os.Stdout
+
+// Stdin is where input reads lines from, for interpreters not given a
+// reader of their own by SetStdin. It must not be changed while scripts
+// run.
+var Stdin io.Reader = os.Stdin
+
+// stdin buffers Stdin between calls to input, for all the interpreters
+// reading it, which take turns holding mu. It is replaced whenever Stdin
+// is.
+var stdin struct {
+	mu sync.Mutex
+	source io.Reader
+	reader *bufio.Reader
+}
+
+// Builtins are registered in init because several of them call back into
+// the evaluator, which itself looks names up in this table.
+func init() {
+	builtins = map[string]*interpreter.Builtin{
+		"print": {
+			KwFn: builtinPrint,
+		},
+		"super": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 2 {
+					return newException(typeErrorClass, "super() takes 0 or 2 arguments (%d given)", len(args))
+				}
+				class, ok := args[0].(*interpreter.Class)
+				if !ok {
+					return newException(typeErrorClass, "super() argument 1 must be a class, not %s", typeName(args[0]))
+				}
+				return &interpreter.Super{Class: class, Self: args[1]}
+			},
+		},
+		"len": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "len() takes exactly one argument (%d given)", len(args))
+				}
+				return length(args[0], env)
+			},
+		},
+		"format": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 && len(args) != 2 {
+					return newException(typeErrorClass, "format expected 1 or 2 arguments, got %d", len(args))
+				}
+				if len(args) == 1 {
+					return formatValue(args[0], "", env)
+				}
+				spec, ok := args[1].(*interpreter.Str)
+				if !ok {
+					return newException(typeErrorClass, "format() argument 2 must be str, not %s", typeName(args[1]))
+				}
+				return formatValue(args[0], spec.Val, env)
+			},
+		},
+		"hash": {
+			Fn: builtinHash,
+		},
+		"id": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "id() takes exactly one argument (%d given)", len(args))
+				}
+				return &interpreter.Int{Val: identity(args[0])}
+			},
+		},
+		"isinstance": {
+			Fn: builtinIsinstance,
+		},
+		"issubclass": {
+			Fn: builtinIssubclass,
+		},
+		"abs": {
+			Fn: builtinAbs,
+		},
+		"min": {
+			KwFn: func(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+				return extremum("min", "<", args, kwargs, env)
+			},
+		},
+		"max": {
+			KwFn: func(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+				return extremum("max", ">", args, kwargs, env)
+			},
+		},
+		"sum": {
+			Fn: builtinSum,
+		},
+		"round": {
+			Fn: builtinRound,
+		},
+		"sorted": {
+			KwFn: builtinSorted,
+		},
+		"reversed": {
+			Fn: builtinReversed,
+		},
+		"enumerate": {
+			KwFn: builtinEnumerate,
+		},
+		"zip": {
+			Fn: builtinZip,
+		},
+		"map": {
+			Fn: builtinMap,
+		},
+		"filter": {
+			Fn: builtinFilter,
+		},
+		"input": {
+			Fn: builtinInput,
+		},
+		"help": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "help() takes exactly one argument (%d given)", len(args))
+				}
+				if _, err := io.WriteString(stdout(env), help(args[0])+"\n"); err != nil {
+					return newErr("%s", err)
+				}
+				return NONE
+			},
+		},
+		"open": {
+			KwFn: builtinOpen,
+		},
+		"iter": {
+			Fn: builtinIter,
+		},
+		"next": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 && len(args) != 2 {
+					return newException(typeErrorClass, "next expected 1 or 2 arguments, got %d", len(args))
+				}
+				result := next(args[0], env)
+				if err, ok := result.(*interpreter.Error); ok && len(args) == 2 {
+					if isSubclass(exceptionOf(err).Class, stopIterationClass) {
+						return args[1]
+					}
+				}
+				return result
+			},
+		},
+	}
+	for name, builtin := range builtins {
+		builtin.Name = name
+	}
+}
+
+// Builtins returns a new table of every builtin name: the builtin
+// functions, types and exception classes. Embedders may add or remove
+// entries before passing it to SetBuiltins on a global scope, without
+// affecting any other scope.
+func Builtins() map[string]interpreter.Item {
+	table := make(map[string]interpreter.Item, len(builtins)+len(builtinTypes)+len(exceptionClasses))
+	for name, class := range exceptionClasses {
+		table[name] = class
+	}
+	for name, t := range builtinTypes {
+		table[name] = t
+	}
+	for name, builtin := range builtins {
+		table[name] = builtin
+	}
+	return table
+}
+
+// NewEnv returns a global scope for a new interpreter, with its own table
+// of builtins.
+func NewEnv() *interpreter.Environment {
+	env := interpreter.NewEnv()
+	env.SetBuiltins(Builtins())
+	env.SetState(newState())
+	return env
+}
+
+// lookupBuiltin returns the builtin called name in env's table, or in the
+// default tables when env has none.
+func lookupBuiltin(name string, env *interpreter.Environment) (interpreter.Item, bool) {
+	if table := env.Builtins(); table != nil {
+		item, ok := table[name]
+		return item, ok
+	}
+	if builtin, ok := builtins[name]; ok {
+		return builtin, true
+	}
+	if t, ok := builtinTypes[name]; ok {
+		return t, true
+	}
+	if class, ok := exceptionClasses[name]; ok {
+		return class, true
+	}
+	return nil, false
+}
+
+// builtinIter returns an iterator over an iterable. Iterators are
+// returned as they are, and an instance's __iter__ result is used
+// directly.
+func builtinIter(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "iter expected 1 argument, got %d", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *interpreter.Generator, *interpreter.Iterator, *interpreter.File:
+		return arg
+	case *interpreter.Instance:
+		if it, ok := callMethod(env, arg, "__iter__"); ok {
+			if it.Type() != interpreter.ERR && !isIterator(it) {
+				return newException(typeErrorClass, "iter() returned non-iterator of type '%s'", typeName(it))
+			}
+			return it
+		}
+	}
+	next, err := iterator(args[0], env)
+	if err != nil {
+		return err
+	}
+	name := typeName(args[0]) + "_iterator"
+	if args[0].Type() == interpreter.DICT {
+		name = "dict_keyiterator"
+	}
+	return &interpreter.Iterator{Name: name, Next: next}
+}
+
+// builtinRange implements range(stop), range(start, stop) and
+// range(start, stop, step).
+func builtinRange(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) == 0 {
+		return newException(typeErrorClass, "range expected at least 1 argument, got 0")
+	}
+	if len(args) > 3 {
+		return newException(typeErrorClass, "range expected at most 3 arguments, got %d", len(args))
+	}
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		n, ok := arg.(*interpreter.Int)
+		if !ok {
+			return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(arg))
+		}
+		bounds[i] = n.Val
+	}
+	r := &interpreter.Range{Step: 1}
+	switch len(bounds) {
+	case 1:
+		r.Stop = bounds[0]
+	case 3:
+		if bounds[2] == 0 {
+			return newException(valueErrorClass, "range() arg 3 must not be zero")
+		}
+		r.Step = bounds[2]
+		fallthrough
+	case 2:
+		r.Start, r.Stop = bounds[0], bounds[1]
+	}
+	return r
+}
+
+// builtinSet implements set() and set(iterable).
+func builtinSet(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) > 1 {
+		return newException(typeErrorClass, "set expected at most 1 argument, got %d", len(args))
+	}
+	set := interpreter.NewSet()
+	if len(args) == 0 {
+		return set
+	}
+	items, err := iterate(args[0], env)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		hash, _, err := setKey(set, item, env)
+		if err != nil {
+			return err
+		}
+		set.Add(hash, item)
+	}
+	return set
+}
+
+// builtinHash returns the hash of a hashable value, the one dicts and
+// sets use.
+func builtinHash(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "hash() takes exactly one argument (%d given)", len(args))
+	}
+	hash, err := hashOf(args[0], env)
+	if err != nil {
+		return err
+	}
+	return &interpreter.Int{Val: int64(hash)}
+}
+
+// identity returns the address of item, which id reports. Every item is
+// a pointer, so distinct live objects have distinct identities.
+func identity(item interpreter.Item) int64 {
+	return int64(reflect.ValueOf(item).Pointer())
+}
+
+func builtinAbs(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "abs() takes exactly one argument (%d given)", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *interpreter.Int:
+		if arg.Val == math.MinInt64 {
+			return intOverflow()
+		}
+		if arg.Val < 0 {
+			return &interpreter.Int{Val: -arg.Val}
+		}
+		return arg
+	case *interpreter.Bool:
+		return boolToInt(arg)
+	case *interpreter.Float:
+		return &interpreter.Float{Val: math.Abs(arg.Val)}
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, arg, "__abs__"); ok {
+			return result
+		}
+	}
+	return newException(typeErrorClass, "bad operand type for abs(): '%s'", typeName(args[0]))
+}
+
+// extremum implements min and max, which differ only in name and in the
+// operator that decides whether an item beats the best one so far. Like
+// Python, the first of several equal items wins.
+func extremum(name string, op string, args []interpreter.Item, kwargs map[string]interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	var key, dflt interpreter.Item
+	for kw, val := range kwargs {
+		switch kw {
+		case "key":
+			if val != NONE {
+				key = val
+			}
+		case "default":
+			dflt = val
+		default:
+			return newException(typeErrorClass, "'%s' is an invalid keyword argument for %s()", kw, name)
+		}
+	}
+	var best, bestKey interpreter.Item
+	consider := func(item interpreter.Item) *interpreter.Error {
+		itemKey := item
+		if key != nil {
+			if itemKey = applyFn(key, []interpreter.Item{item}, nil, env); itemKey.Type() == interpreter.ERR {
+				return itemKey.(*interpreter.Error)
+			}
+		}
+		if best == nil {
+			best, bestKey = item, itemKey
+			return nil
+		}
+		beats := evaluateInfixExpr(op, itemKey, bestKey, env)
+		if beats.Type() == interpreter.ERR {
+			return beats.(*interpreter.Error)
+		}
+		if isTrue(beats) {
+			best, bestKey = item, itemKey
+		}
+		return nil
+	}
+	switch {
+	case len(args) == 0:
+		return newException(typeErrorClass, "%s expected at least 1 argument, got 0", name)
+	case len(args) == 1:
+		// A single iterable is stepped through rather than copied, so
+		// ranges and iterators of any length take constant memory.
+		if err := forEach(args[0], env, consider); err != nil {
+			return err
+		}
+	case dflt != nil:
+		return newException(typeErrorClass, "Cannot specify a default for %s() with multiple positional arguments", name)
+	default:
+		for _, item := range args {
+			if err := consider(item); err != nil {
+				return err
+			}
+		}
+	}
+	if best == nil {
+		if dflt != nil {
+			return dflt
+		}
+		return newException(valueErrorClass, "%s() arg is an empty sequence", name)
+	}
+	return best
+}
+
+// builtinSum adds up the items of an iterable, starting from start or 0.
+func builtinSum(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) == 0 || len(args) > 2 {
+		return newException(typeErrorClass, "sum() takes at most 2 arguments (%d given)", len(args))
+	}
+	var total interpreter.Item = &interpreter.Int{Val: 0}
+	if len(args) == 2 {
+		if args[1].Type() == interpreter.STR {
+			return newException(typeErrorClass, "sum() can't sum strings [use ''.join(seq) instead]")
+		}
+		total = args[1]
+	}
+	err := forEach(args[0], env, func(item interpreter.Item) *interpreter.Error {
+		if total = evaluateInfixExpr("+", total, item, env); total.Type() == interpreter.ERR {
+			return total.(*interpreter.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return total
+}
+
+// builtinRound rounds halfway cases to the nearest even number, as Python
+// does. Without ndigits the result is an int.
+func builtinRound(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) == 0 || len(args) > 2 {
+		return newException(typeErrorClass, "round() takes at most 2 arguments (%d given)", len(args))
+	}
+	var ndigits *interpreter.Int
+	if len(args) == 2 && args[1] != NONE {
+		n, ok := args[1].(*interpreter.Int)
+		if !ok {
+			return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(args[1]))
+		}
+		ndigits = n
+	}
+	switch number := args[0].(type) {
+	case *interpreter.Bool:
+		return boolToInt(number)
+	case *interpreter.Int:
+		if ndigits == nil || ndigits.Val >= 0 {
+			return number
+		}
+		if ndigits.Val < -18 {
+			// Every int64 is nearer to zero than to 10**19.
+			return &interpreter.Int{Val: 0}
+		}
+		pow := int64(math.Pow10(int(-ndigits.Val)))
+		quotient, remainder := number.Val/pow, number.Val%pow
+		if remainder < 0 {
+			quotient, remainder = quotient-1, remainder+pow
+		}
+		if 2*remainder > pow || 2*remainder == pow && quotient%2 != 0 {
+			quotient++
+		}
+		rounded, ok := mulInt(quotient, pow)
+		if !ok {
+			return intOverflow()
+		}
+		return &interpreter.Int{Val: rounded}
+	case *interpreter.Float:
+		if ndigits == nil {
+			return builtinInt(env, &interpreter.Float{Val: math.RoundToEven(number.Val)})
+		}
+		pow := math.Pow10(int(ndigits.Val))
+		return &interpreter.Float{Val: math.RoundToEven(number.Val*pow) / pow}
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, number, "__round__", args[1:]...); ok {
+			return result
+		}
+	}
+	return newException(typeErrorClass, "type %s doesn't define __round__ method", typeName(args[0]))
+}
+
+// builtinSorted returns a new list of the items of an iterable in
+// ascending order, or descending if reverse is true. The sort is stable,
+// and items are compared by the result of calling key on them if given.
+func builtinSorted(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "sorted expected 1 argument, got %d", len(args))
+	}
+	var key interpreter.Item
+	reverse := false
+	for kw, val := range kwargs {
+		switch kw {
+		case "key":
+			if val != NONE {
+				key = val
+			}
+		case "reverse":
+			reverse = isTrue(val)
+		default:
+			return newException(typeErrorClass, "'%s' is an invalid keyword argument for sort()", kw)
+		}
+	}
+	items, err := iterate(args[0], env)
+	if err != nil {
+		return err
+	}
+	keys := items
+	if key != nil {
+		keys = make([]interpreter.Item, len(items))
+		for i, item := range items {
+			if err := stepInterrupted(env); err != nil {
+				return err
+			}
+			if keys[i] = applyFn(key, []interpreter.Item{item}, nil, env); keys[i].Type() == interpreter.ERR {
+				return keys[i]
+			}
+		}
+	}
+	// Sort indices so that items and keys stay paired.
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	failed := sortStable(order, func(i, j int) interpreter.Item {
+		if err := stepInterrupted(env); err != nil {
+			return err
+		}
+		a, b := keys[i], keys[j]
+		if reverse {
+			a, b = b, a
+		}
+		return evaluateInfixExpr("<", a, b, env)
+	})
+	if failed != nil {
+		return failed
+	}
+	sorted := make([]interpreter.Item, len(items))
+	for i, index := range order {
+		sorted[i] = items[index]
+	}
+	return &interpreter.List{Elements: sorted}
+}
+
+// sortAbort carries the error that stops sortStable out of
+// sort.SliceStable, which has no other way to be stopped.
+type sortAbort struct {
+	err interpreter.Item
+}
+
+// sortStable sorts the indices in order like sort.SliceStable, with less
+// comparing the indices themselves and returning a bool item or an error.
+// It stops at the first error, returning it.
+func sortStable(order []int, less func(i, j int) interpreter.Item) (failed interpreter.Item) {
+	defer func() {
+		if r := recover(); r != nil {
+			abort, ok := r.(sortAbort)
+			if !ok {
+				panic(r)
+			}
+			failed = abort.err
+		}
+	}()
+	sort.SliceStable(order, func(i, j int) bool {
+		result := less(order[i], order[j])
+		if result.Type() == interpreter.ERR {
+			panic(sortAbort{result})
+		}
+		return isTrue(result)
+	})
+	return nil
+}
+
+// builtinReversed returns an iterator over a sequence from its last item
+// to its first. Lists are read as the iterator advances, like in Python,
+// so one that shrinks past the iterator's position ends it.
+func builtinReversed(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "reversed expected 1 argument, got %d", len(args))
+	}
+	var at func(i int) interpreter.Item
+	var n int
+	name := typeName(args[0]) + "_reverseiterator"
+	switch seq := args[0].(type) {
+	case *interpreter.List:
+		n = len(seq.Elements)
+		at = func(i int) interpreter.Item {
+			if i >= len(seq.Elements) {
+				return nil
+			}
+			return seq.Elements[i]
+		}
+	case *interpreter.Range:
+		n = int(seq.Len())
+		name = "range_iterator"
+		at = func(i int) interpreter.Item { return &interpreter.Int{Val: seq.At(int64(i))} }
+	case *interpreter.Tuple, *interpreter.Str, *interpreter.Dict:
+		items, _ := iterate(seq, env)
+		n = len(items)
+		at = func(i int) interpreter.Item { return items[i] }
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, seq, "__reversed__"); ok {
+			return result
+		}
+		return newException(typeErrorClass, "'%s' object is not reversible", typeName(seq))
+	default:
+		return newException(typeErrorClass, "'%s' object is not reversible", typeName(seq))
+	}
+	i := n
+	return &interpreter.Iterator{
+		Name: name,
+		Next: func() (interpreter.Item, bool) {
+			if i <= 0 {
+				return nil, false
+			}
+			i--
+			if item := at(i); item != nil {
+				return item, true
+			}
+			i = 0
+			return nil, false
+		},
+	}
+}
+
+// builtinEnumerate returns an iterator of (count, item) pairs over an
+// iterable, counting from start.
+func builtinEnumerate(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	var start interpreter.Item = &interpreter.Int{Val: 0}
+	for kw, val := range kwargs {
+		if kw != "start" || len(args) > 1 {
+			return newException(typeErrorClass, "'%s' is an invalid keyword argument for enumerate()", kw)
+		}
+		start = val
+	}
+	if len(args) == 2 {
+		start = args[1]
+	}
+	if len(args) == 0 || len(args) > 2 {
+		return newException(typeErrorClass, "enumerate() takes at most 2 arguments (%d given)", len(args))
+	}
+	count, ok := start.(*interpreter.Int)
+	if !ok {
+		return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(start))
+	}
+	next, err := iterator(args[0], env)
+	if err != nil {
+		return err
+	}
+	n := count.Val
+	return &interpreter.Iterator{
+		Name: "enumerate",
+		Next: func() (interpreter.Item, bool) {
+			item, ok := next()
+			if !ok || item.Type() == interpreter.ERR {
+				return item, ok
+			}
+			n++
+			return &interpreter.Tuple{Elements: []interpreter.Item{&interpreter.Int{Val: n - 1}, item}}, true
+		},
+	}
+}
+
+// builtinZip returns an iterator of tuples holding the next item of each
+// iterable, which stops as soon as any of them runs out.
+func builtinZip(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	nexts := make([]func() (interpreter.Item, bool), len(args))
+	for i, arg := range args {
+		next, err := iterator(arg, env)
+		if err != nil {
+			return newException(typeErrorClass, "zip argument #%d must support iteration", i+1)
+		}
+		nexts[i] = next
+	}
+	done := len(args) == 0
+	return &interpreter.Iterator{
+		Name: "zip",
+		Next: func() (interpreter.Item, bool) {
+			if done {
+				return nil, false
+			}
+			elements := make([]interpreter.Item, len(nexts))
+			for i, next := range nexts {
+				item, ok := next()
+				if !ok || item.Type() == interpreter.ERR {
+					done = true
+					return item, ok
+				}
+				elements[i] = item
+			}
+			return &interpreter.Tuple{Elements: elements}, true
+		},
+	}
+}
+
+// builtinMap returns an iterator calling fn with the next item of each
+// iterable, stopping when the shortest one runs out.
+func builtinMap(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) < 2 {
+		return newException(typeErrorClass, "map() must have at least two arguments.")
+	}
+	fn := args[0]
+	nexts := make([]func() (interpreter.Item, bool), len(args)-1)
+	for i, arg := range args[1:] {
+		next, err := iterator(arg, env)
+		if err != nil {
+			return err
+		}
+		nexts[i] = next
+	}
+	return &interpreter.Iterator{
+		Name: "map",
+		Next: func() (interpreter.Item, bool) {
+			fnArgs := make([]interpreter.Item, len(nexts))
+			for i, next := range nexts {
+				item, ok := next()
+				if !ok || item.Type() == interpreter.ERR {
+					return item, ok
+				}
+				fnArgs[i] = item
+			}
+			return applyFn(fn, fnArgs, nil, env), true
+		},
+	}
+}
+
+// builtinFilter returns an iterator over the items of an iterable for
+// which fn returns a true value, or which are true themselves if fn is
+// None.
+func builtinFilter(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 {
+		return newException(typeErrorClass, "filter expected 2 arguments, got %d", len(args))
+	}
+	fn := args[0]
+	next, err := iterator(args[1], env)
+	if err != nil {
+		return err
+	}
+	return &interpreter.Iterator{
+		Name: "filter",
+		Next: func() (interpreter.Item, bool) {
+			for {
+				item, ok := next()
+				if !ok || item.Type() == interpreter.ERR {
+					return item, ok
+				}
+				keep := item
+				if fn != NONE {
+					if keep = applyFn(fn, []interpreter.Item{item}, nil, env); keep.Type() == interpreter.ERR {
+						return keep, true
+					}
+				}
+				if isTrue(keep) {
+					return item, true
+				}
+			}
+		},
+	}
+}
+
+// builtinInput writes the prompt, if any, to Stdout and returns the next
+// line of Stdin without its line ending.
+func builtinInput(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) > 1 {
+		return newException(typeErrorClass, "input expected at most 1 argument, got %d", len(args))
+	}
+	if len(args) == 1 {
+		io.WriteString(stdout(env), args[0].Visit())
+	}
+	var line string
+	var err error
+	readStdin(env, func(r *bufio.Reader) {
+		line, err = r.ReadString('\n')
+	})
+	if err != nil && (err != io.EOF || line == "") {
+		return newException(eofErrorClass, "EOF when reading a line")
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return &interpreter.Str{Val: strings.TrimSuffix(line, "\r")}
+}
+
+// readStdin calls read with the buffered reader for the stdin of the
+// interpreter env belongs to, or for the current Stdin, which it holds
+// for the duration of the call.
+func readStdin(env *interpreter.Environment, read func(r *bufio.Reader)) {
+	if r := stateOf(env).stdin; r != nil {
+		read(r)
+		return
+	}
+	stdin.mu.Lock()
+	defer stdin.mu.Unlock()
+	if stdin.source != Stdin {
+		stdin.source, stdin.reader = Stdin, bufio.NewReader(Stdin)
+	}
+	read(stdin.reader)
+}
+
+// builtinPrint writes its arguments, converted as str() converts them,
+// separated by sep and followed by end, to file if one is given or to
+// Stdout. file may be any object with a write method.
+func builtinPrint(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	sep, end := " ", "\n"
+	var file interpreter.Item = NONE
+	for name, val := range kwargs {
+		switch name {
+		case "sep", "end":
+			if val == NONE {
+				continue
+			}
+			str, ok := val.(*interpreter.Str)
+			if !ok {
+				return newException(typeErrorClass, "%s must be None or a string, not %s", name, typeName(val))
+			}
+			if name == "sep" {
+				sep = str.Val
+			} else {
+				end = str.Val
+			}
+		case "file":
+			file = val
+		default:
+			return newException(typeErrorClass, "'%s' is an invalid keyword argument for print()", name)
+		}
+	}
+	var parts []string
+	for _, arg := range args {
+		str := builtinStr(env, arg)
+		if str.Type() == interpreter.ERR {
+			return str
+		}
+		parts = append(parts, str.(*interpreter.Str).Val)
+	}
+	text := &interpreter.Str{Val: strings.Join(parts, sep) + end}
+	if file == NONE {
+		if _, err := io.WriteString(stdout(env), text.Val); err != nil {
+			return newErr("%s", err)
+		}
+		return NONE
+	}
+	write := getAttr(file, "write")
+	if write.Type() == interpreter.ERR {
+		return newException(attributeErrorClass, "'%s' object has no attribute 'write'", typeName(file))
+	}
+	if result := applyFn(write, []interpreter.Item{text}, nil, env); result.Type() == interpreter.ERR {
+		return result
+	}
+	return NONE
+}
+
+// help describes item by its signature or name followed by its indented
+// docstring.
+func help(item interpreter.Item) string {
+	var header, doc string
+	switch item := item.(type) {
+	case *interpreter.Function:
+		var params []string
+		for i, param := range item.Params {
+			if i < len(item.Defaults) && item.Defaults[i] != nil {
+				params = append(params, param.Val+"="+interpreter.Repr(item.Defaults[i]))
+			} else {
+				params = append(params, param.Val)
+			}
+		}
+		if item.VarArgs != nil {
+			params = append(params, "*"+item.VarArgs.Val)
+		}
+		if item.KwArgs != nil {
+			params = append(params, "**"+item.KwArgs.Val)
+		}
+		header = item.Name + "(" + strings.Join(params, ", ") + ")"
+		doc = item.Doc
+	case *interpreter.Class:
+		header = "class " + item.Name
+		if item.Base != nil {
+			header += "(" + item.Base.Name + ")"
+		}
+		if str, ok := item.Attrs["__doc__"].(*interpreter.Str); ok {
+			doc = str.Val
+		}
+	case *interpreter.Module:
+		header = "module " + item.Name
+		if str, ok := item.Env.Locals()["__doc__"].(*interpreter.Str); ok {
+			doc = str.Val
+		}
+	case *interpreter.BoundMethod:
+		return help(item.Fn)
+	case *interpreter.Builtin:
+		header = "builtin function " + item.Name
+	case *interpreter.Instance:
+		return help(item.Class)
+	default:
+		header = typeName(item) + " object"
+	}
+	if doc == "" {
+		return header
+	}
+	return header + "\n    " + strings.ReplaceAll(doc, "\n", "\n    ")
+}
+
+func length(item interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	switch item := item.(type) {
+	case *interpreter.Str:
+		return &interpreter.Int{Val: int64(len([]rune(item.Val)))}
+	case *interpreter.Bytes:
+		return &interpreter.Int{Val: int64(len(item.Val))}
+	case *interpreter.List:
+		return &interpreter.Int{Val: int64(len(item.Elements))}
+	case *interpreter.Tuple:
+		return &interpreter.Int{Val: int64(len(item.Elements))}
+	case *interpreter.Dict:
+		return &interpreter.Int{Val: int64(len(item.Keys))}
+	case *interpreter.Set:
+		return &interpreter.Int{Val: int64(len(item.Keys))}
+	case *interpreter.Range:
+		return &interpreter.Int{Val: item.Len()}
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, item, "__len__"); ok {
+			return result
+		}
+	}
+	return newException(typeErrorClass, "object of type '%s' has no len()", typeName(item))
+}
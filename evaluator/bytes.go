@@ -0,0 +1,274 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"gopy/interpreter"
+	"strings"
+	"unicode/utf8"
+)
+
+// bytesMethods are the methods available on bytes objects. Each receives
+// the bytes itself as its first argument.
+var bytesMethods map[string]*interpreter.Builtin
+
+func init() {
+	bytesMethods = map[string]*interpreter.Builtin{
+		"decode": {
+			KwFn: func(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+				encoding, errors, err := codecArgs("decode", args, kwargs)
+				if err != nil {
+					return err
+				}
+				return decodeBytes(args[0].(*interpreter.Bytes).Val, encoding, errors)
+			},
+		},
+		"hex": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "bytes.hex() takes no arguments (%d given)", len(args)-1)
+				}
+				return &interpreter.Str{Val: hex.EncodeToString(args[0].(*interpreter.Bytes).Val)}
+			},
+		},
+	}
+	for name, method := range bytesMethods {
+		method.Name = name
+	}
+}
+
+// builtinBytes implements bytes(), bytes(n), bytes(iterable_of_ints) and
+// bytes(str, encoding, errors='strict').
+func builtinBytes(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) > 3 {
+		return newException(typeErrorClass, "bytes() takes at most 3 arguments (%d given)", len(args))
+	}
+	if len(args) == 0 {
+		return &interpreter.Bytes{Val: []byte{}}
+	}
+	if s, ok := args[0].(*interpreter.Str); ok {
+		if len(args) == 1 {
+			return newException(typeErrorClass, "string argument without an encoding")
+		}
+		encoding, errors, err := codecArgs("bytes", append([]interpreter.Item{s}, args[1:]...), nil)
+		if err != nil {
+			return err
+		}
+		return encodeStr(s.Val, encoding, errors)
+	}
+	if len(args) > 1 {
+		return newException(typeErrorClass, "encoding without a string argument")
+	}
+	switch arg := args[0].(type) {
+	case *interpreter.Bytes:
+		return arg
+	case *interpreter.Int:
+		if arg.Val < 0 {
+			return newException(valueErrorClass, "negative count")
+		}
+		if err := allocate(arg.Val, env); err != nil {
+			return err
+		}
+		return &interpreter.Bytes{Val: make([]byte, arg.Val)}
+	}
+	if !isIterable(args[0]) {
+		return newException(typeErrorClass, "cannot convert '%s' object to bytes", typeName(args[0]))
+	}
+	items, err := iterate(args[0], env)
+	if err != nil {
+		return err
+	}
+	val := make([]byte, len(items))
+	for i, item := range items {
+		n, ok := boolToInt(item).(*interpreter.Int)
+		if !ok {
+			return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(item))
+		}
+		if n.Val < 0 || n.Val > 255 {
+			return newException(valueErrorClass, "bytes must be in range(0, 256)")
+		}
+		val[i] = byte(n.Val)
+	}
+	return &interpreter.Bytes{Val: val}
+}
+
+// evaluateBytesInfixExpr concatenates, repeats and compares bytes.
+func evaluateBytesInfixExpr(op string, l interpreter.Item, r interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	left, lok := l.(*interpreter.Bytes)
+	right, rok := r.(*interpreter.Bytes)
+	switch op {
+	case "+":
+		if !lok || !rok {
+			return newException(typeErrorClass, "can't concat %s to %s", typeName(r), typeName(l))
+		}
+		if err := allocate(int64(len(left.Val) + len(right.Val)), env); err != nil {
+			return err
+		}
+		return &interpreter.Bytes{Val: append(append([]byte{}, left.Val...), right.Val...)}
+	case "*":
+		if lok && rok {
+			return newException(typeErrorClass, "can't multiply sequence by non-int of type '%s'", typeName(r))
+		}
+		seq, n := right, l
+		if lok {
+			seq, n = left, r
+		}
+		count := n.(*interpreter.Int).Val
+		if count <= 0 {
+			return &interpreter.Bytes{Val: []byte{}}
+		}
+		if err := allocateRepeat(len(seq.Val), count, env); err != nil {
+			return err
+		}
+		return &interpreter.Bytes{Val: bytes.Repeat(seq.Val, int(count))}
+	case "==":
+		return nativeBool(lok && rok && bytes.Equal(left.Val, right.Val))
+	case "!=":
+		return nativeBool(!lok || !rok || !bytes.Equal(left.Val, right.Val))
+	case "<", ">", "<=", ">=":
+		if !lok || !rok {
+			return unsupportedOperands(op, l, r)
+		}
+		cmp := bytes.Compare(left.Val, right.Val)
+		switch op {
+		case "<":
+			return nativeBool(cmp < 0)
+		case ">":
+			return nativeBool(cmp > 0)
+		case "<=":
+			return nativeBool(cmp <= 0)
+		}
+		return nativeBool(cmp >= 0)
+	}
+	return unsupportedOperands(op, l, r)
+}
+
+// codecArgs returns the encoding and errors arguments of str.encode,
+// bytes.decode and bytes(), which default to UTF-8 and strict. args
+// starts with the object being converted.
+func codecArgs(name string, args []interpreter.Item, kwargs map[string]interpreter.Item) (string, string, *interpreter.Error) {
+	if len(args) > 3 {
+		return "", "", newException(typeErrorClass, "%s() takes at most 2 arguments (%d given)", name, len(args)-1)
+	}
+	params := map[string]interpreter.Item{"encoding": &interpreter.Str{Val: "utf-8"}, "errors": &interpreter.Str{Val: "strict"}}
+	for i, param := range []string{"encoding", "errors"} {
+		if i+1 < len(args) {
+			params[param] = args[i+1]
+		}
+	}
+	for param, val := range kwargs {
+		if _, ok := params[param]; !ok {
+			return "", "", newException(typeErrorClass, "'%s' is an invalid keyword argument for %s()", param, name)
+		}
+		params[param] = val
+	}
+	var values [2]string
+	for i, param := range []string{"encoding", "errors"} {
+		s, ok := params[param].(*interpreter.Str)
+		if !ok {
+			return "", "", newException(typeErrorClass, "%s() argument '%s' must be str, not %s", name, param, typeName(params[param]))
+		}
+		values[i] = s.Val
+	}
+	encoding, ok := codecName(values[0])
+	if !ok {
+		return "", "", newException(lookupErrorClass, "unknown encoding: %s", values[0])
+	}
+	switch values[1] {
+	case "strict", "ignore", "replace":
+	default:
+		return "", "", newException(lookupErrorClass, "unknown error handler name '%s'", values[1])
+	}
+	return encoding, values[1], nil
+}
+
+// codecName returns the canonical name of a supported encoding, ignoring
+// case, dashes and underscores like Python does.
+func codecName(encoding string) (string, bool) {
+	switch strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(encoding)) {
+	case "utf8", "u8":
+		return "utf-8", true
+	case "ascii", "usascii":
+		return "ascii", true
+	case "latin1", "l1", "iso88591":
+		return "latin-1", true
+	}
+	return "", false
+}
+
+// encodeStr encodes s. Under the ignore handler characters the encoding
+// can't represent are dropped, and under replace they become '?'.
+func encodeStr(s string, encoding string, errors string) interpreter.Item {
+	if encoding == "utf-8" {
+		return &interpreter.Bytes{Val: []byte(s)}
+	}
+	limit := rune(0x7f)
+	if encoding == "latin-1" {
+		limit = 0xff
+	}
+	val := []byte{}
+	for i, r := range []rune(s) {
+		switch {
+		case r <= limit:
+			val = append(val, byte(r))
+		case errors == "replace":
+			val = append(val, '?')
+		case errors == "strict":
+			escape := fmt.Sprintf(`\u%04x`, r)
+			if r <= 0xff {
+				escape = fmt.Sprintf(`\x%02x`, r)
+			} else if r > 0xffff {
+				escape = fmt.Sprintf(`\U%08x`, r)
+			}
+			return newException(unicodeEncodeErrorClass, "'%s' codec can't encode character '%s' in position %d: ordinal not in range(%d)", encoding, escape, i, limit+1)
+		}
+	}
+	return &interpreter.Bytes{Val: val}
+}
+
+// decodeBytes decodes b. Under the ignore handler invalid bytes are
+// dropped, and under replace they become U+FFFD.
+func decodeBytes(b []byte, encoding string, errors string) interpreter.Item {
+	var sb strings.Builder
+	for i := 0; i < len(b); {
+		r, size := rune(b[i]), 1
+		var reason string
+		switch encoding {
+		case "utf-8":
+			r, size = utf8.DecodeRune(b[i:])
+			if r == utf8.RuneError && size <= 1 {
+				reason = utf8Problem(b[i:])
+			}
+		case "ascii":
+			if r > 0x7f {
+				reason = "ordinal not in range(128)"
+			}
+		}
+		if reason != "" {
+			switch errors {
+			case "strict":
+				return newException(unicodeDecodeErrorClass, "'%s' codec can't decode byte 0x%02x in position %d: %s", encoding, b[i], i, reason)
+			case "replace":
+				sb.WriteRune(utf8.RuneError)
+			}
+			i++
+			continue
+		}
+		sb.WriteRune(r)
+		i += size
+	}
+	return &interpreter.Str{Val: sb.String()}
+}
+
+// utf8Problem describes why b doesn't start with a valid UTF-8 sequence,
+// in Python's words.
+func utf8Problem(b []byte) string {
+	switch {
+	case b[0] < 0xc2 || b[0] > 0xf4:
+		return "invalid start byte"
+	case !utf8.FullRune(b):
+		return "unexpected end of data"
+	}
+	return "invalid continuation byte"
+}
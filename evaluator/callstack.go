@@ -0,0 +1,91 @@
+package evaluator
+
+import (
+	"gopy/ast"
+	"gopy/interpreter"
+)
+
+// RecursionLimit is the deepest the call stack may grow before a call
+// raises RecursionError, for interpreters whose limit hasn't been changed
+// by SetRecursionLimit or their scripts' sys.setrecursionlimit. It must
+// not be changed while scripts run.
+var RecursionLimit = 1000
+
+// SetRecursionLimit sets the recursion limit of the interpreter env
+// belongs to, as sys.setrecursionlimit does. Limits above
+// MaxRecursionLimit are lowered to it, and limits below 1 restore
+// RecursionLimit.
+func SetRecursionLimit(env *interpreter.Environment, n int) {
+	stateOf(env).maxDepth = n
+}
+
+// MaxRecursionLimit caps the recursion limit, however high it is set, so
+// that deep recursion raises RecursionError before it can overflow the
+// Go stack. Each call takes a few kilobytes of it.
+const MaxRecursionLimit = 20000
+
+// frame is a call in progress of the function or generator named name.
+type frame struct {
+	name string
+}
+
+// pushFrame enters a call of the function named name in the interpreter
+// env belongs to, failing with RecursionError if that would exceed its
+// recursion limit.
+func pushFrame(name string, env *interpreter.Environment) *interpreter.Error {
+	s := stateOf(env)
+	if len(s.callStack) >= s.recursionLimit() {
+		return newException(recursionErrorClass, "maximum recursion depth exceeded while calling '%s'", name)
+	}
+	s.callStack = append(s.callStack, frame{name: name})
+	return nil
+}
+
+// recursionLimit returns the limit set by sys.setrecursionlimit, or
+// RecursionLimit if there is none, capped at MaxRecursionLimit.
+func (s *state) recursionLimit() int {
+	limit := RecursionLimit
+	if s.maxDepth > 0 {
+		limit = s.maxDepth
+	}
+	if limit > MaxRecursionLimit {
+		return MaxRecursionLimit
+	}
+	return limit
+}
+
+// depthLeft returns how much deeper containers may nest, in the
+// interpreter env belongs to, before going through them raises
+// RecursionError.
+func depthLeft(env *interpreter.Environment) int {
+	s := stateOf(env)
+	return s.recursionLimit() - len(s.callStack) - s.nesting
+}
+
+// popFrame leaves the innermost call. An error it returns gets a
+// traceback entry left for the caller's statement to fill in.
+func popFrame(result interpreter.Item, env *interpreter.Environment) {
+	s := stateOf(env)
+	s.callStack = s.callStack[:len(s.callStack)-1]
+	if err, ok := result.(*interpreter.Error); ok {
+		err.Traceback = append(err.Traceback, interpreter.TraceEntry{})
+	}
+}
+
+// trace records stmt in the traceback of err unless the current call
+// already has an entry.
+func trace(err *interpreter.Error, stmt ast.Stmt, env *interpreter.Environment) {
+	n := len(err.Traceback)
+	if n > 0 && err.Traceback[n-1].Pos.IsValid() {
+		return
+	}
+	entry := interpreter.TraceEntry{Pos: stmt.Pos(), Func: "<module>"}
+	if callStack := stateOf(env).callStack; len(callStack) > 0 {
+		entry.Func = callStack[len(callStack)-1].name
+	}
+	if n == 0 {
+		err.Traceback = append(err.Traceback, entry)
+	} else {
+		err.Traceback[n-1] = entry
+	}
+}
@@ -0,0 +1,168 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+)
+
+func init() {
+	nativeModules["copy"] = newCopyModule
+}
+
+func newCopyModule(*interpreter.Environment) *interpreter.Module {
+	return newNativeModule("copy", map[string]interpreter.Item{
+		"copy": &interpreter.Builtin{
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "copy() takes exactly one argument (%d given)", len(args))
+				}
+				return shallowCopy(args[0], env)
+			},
+		},
+		"deepcopy": &interpreter.Builtin{
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "deepcopy() takes exactly one argument (%d given)", len(args))
+				}
+				return deepCopy(args[0], map[interpreter.Item]interpreter.Item{}, env)
+			},
+		},
+	})
+}
+
+// shallowCopy returns a new container holding the same items as item,
+// charging for it. Immutable items, functions, classes and modules are
+// returned unchanged.
+func shallowCopy(item interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	switch item := item.(type) {
+	case *interpreter.List:
+		if err := allocateRepeat(slotSize, int64(len(item.Elements)), env); err != nil {
+			return err
+		}
+		return &interpreter.List{Elements: append([]interpreter.Item{}, item.Elements...)}
+	case *interpreter.Dict:
+		if err := allocateRepeat(slotSize, int64(len(item.Keys)), env); err != nil {
+			return err
+		}
+		dup := interpreter.NewDict()
+		for _, hash := range item.Keys {
+			pair := item.Pairs[hash]
+			dup.Set(hash, pair.Key, pair.Value)
+		}
+		return dup
+	case *interpreter.Set:
+		if err := allocateRepeat(slotSize, int64(len(item.Keys)), env); err != nil {
+			return err
+		}
+		dup := interpreter.NewSet()
+		for _, hash := range item.Keys {
+			dup.Add(hash, item.Items[hash])
+		}
+		return dup
+	case *interpreter.Instance:
+		attrs := make(map[string]interpreter.Item, len(item.Attrs))
+		for name, val := range item.Attrs {
+			attrs[name] = val
+		}
+		return &interpreter.Instance{Class: item.Class, Attrs: attrs}
+	case *interpreter.Generator, *interpreter.Iterator, *interpreter.File:
+		return newException(typeErrorClass, "cannot copy '%s' object", typeName(item))
+	}
+	return item
+}
+
+// deepCopy copies item and, recursively, everything it contains. memo maps
+// each container already copied to its copy, so shared references stay
+// shared and cycles are copied as cycles. The copied keys of dicts and
+// sets are hashed afresh, running any __hash__ methods in env. Copying
+// containers nested deeper than the recursion limit raises RecursionError.
+func deepCopy(item interpreter.Item, memo map[interpreter.Item]interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	if dup, ok := memo[item]; ok {
+		return dup
+	}
+	switch item.(type) {
+	case *interpreter.List, *interpreter.Tuple, *interpreter.Dict, *interpreter.Set, *interpreter.Instance:
+		s := stateOf(env)
+		if len(s.callStack)+s.nesting >= s.recursionLimit() {
+			return newException(recursionErrorClass, "maximum recursion depth exceeded while copying an object")
+		}
+		s.nesting++
+		defer func() { s.nesting-- }()
+	}
+	switch item := item.(type) {
+	case *interpreter.List:
+		if err := allocateRepeat(slotSize, int64(len(item.Elements)), env); err != nil {
+			return err
+		}
+		dup := &interpreter.List{Elements: make([]interpreter.Item, len(item.Elements))}
+		memo[item] = dup
+		for i, el := range item.Elements {
+			if dup.Elements[i] = deepCopy(el, memo, env); dup.Elements[i].Type() == interpreter.ERR {
+				return dup.Elements[i]
+			}
+		}
+		return dup
+	case *interpreter.Tuple:
+		if err := allocateRepeat(slotSize, int64(len(item.Elements)), env); err != nil {
+			return err
+		}
+		dup := &interpreter.Tuple{Elements: make([]interpreter.Item, len(item.Elements))}
+		for i, el := range item.Elements {
+			if dup.Elements[i] = deepCopy(el, memo, env); dup.Elements[i].Type() == interpreter.ERR {
+				return dup.Elements[i]
+			}
+		}
+		// A tuple may already have been reached through one of its own
+		// elements.
+		if prev, ok := memo[item]; ok {
+			return prev
+		}
+		memo[item] = dup
+		return dup
+	case *interpreter.Dict:
+		dup := interpreter.NewDict()
+		memo[item] = dup
+		for _, hash := range item.Keys {
+			pair := item.Pairs[hash]
+			key := deepCopy(pair.Key, memo, env)
+			if key.Type() == interpreter.ERR {
+				return key
+			}
+			val := deepCopy(pair.Value, memo, env)
+			if val.Type() == interpreter.ERR {
+				return val
+			}
+			if err := dictSet(dup, key, val, env); err != nil {
+				return err
+			}
+		}
+		return dup
+	case *interpreter.Set:
+		dup := interpreter.NewSet()
+		memo[item] = dup
+		for _, hash := range item.Keys {
+			el := deepCopy(item.Items[hash], memo, env)
+			if el.Type() == interpreter.ERR {
+				return el
+			}
+			slot, _, err := setKey(dup, el, env)
+			if err != nil {
+				return err
+			}
+			if err := allocate(slotSize, env); err != nil {
+				return err
+			}
+			dup.Add(slot, el)
+		}
+		return dup
+	case *interpreter.Instance:
+		dup := &interpreter.Instance{Class: item.Class, Attrs: make(map[string]interpreter.Item, len(item.Attrs))}
+		memo[item] = dup
+		for name, val := range item.Attrs {
+			if dup.Attrs[name] = deepCopy(val, memo, env); dup.Attrs[name].Type() == interpreter.ERR {
+				return dup.Attrs[name]
+			}
+		}
+		return dup
+	}
+	return shallowCopy(item, env)
+}
@@ -0,0 +1,272 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+)
+
+// dictMethods are the methods available on dict objects. Each receives
+// the dict itself as its first argument. keys, values and items return
+// lists rather than views.
+var dictMethods map[string]*interpreter.Builtin
+
+func init() {
+	dictMethods = map[string]*interpreter.Builtin{
+		"get": {
+			Fn: dictGet,
+		},
+		"keys": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return dictList("keys", args, func(pair interpreter.DictPair) interpreter.Item { return pair.Key })
+			},
+		},
+		"values": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return dictList("values", args, func(pair interpreter.DictPair) interpreter.Item { return pair.Value })
+			},
+		},
+		"items": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return dictList("items", args, func(pair interpreter.DictPair) interpreter.Item {
+					return &interpreter.Tuple{Elements: []interpreter.Item{pair.Key, pair.Value}}
+				})
+			},
+		},
+		"pop": {
+			Fn: dictPop,
+		},
+		"setdefault": {
+			Fn: dictSetdefault,
+		},
+		"update": {
+			KwFn: dictUpdate,
+		},
+		"clear": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "dict.clear() takes no arguments (%d given)", len(args)-1)
+				}
+				dict := args[0].(*interpreter.Dict)
+				dict.Pairs = map[interpreter.HashKey]interpreter.DictPair{}
+				dict.Keys = nil
+				return NONE
+			},
+		},
+		"copy": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "dict.copy() takes no arguments (%d given)", len(args)-1)
+				}
+				return shallowCopy(args[0], env)
+			},
+		},
+	}
+	for name, method := range dictMethods {
+		method.Name = name
+	}
+}
+
+// hashOf returns the hash of key, failing with TypeError if it can't be
+// hashed. Instances hash by their __hash__ method if their class has one,
+// are unhashable if it defines __eq__ without it or sets it to None, and
+// otherwise hash by identity, as functions, classes and modules do.
+func hashOf(key interpreter.Item, env *interpreter.Environment) (uint64, *interpreter.Error) {
+	switch key := key.(type) {
+	case *interpreter.Instance:
+		method, ok := key.Class.Lookup("__hash__")
+		if !ok {
+			if _, ok := key.Class.Lookup("__eq__"); !ok {
+				return uint64(identity(key)), nil
+			}
+		} else if method != NONE {
+			result := applyFn(bindMethod(key, method), nil, nil, env)
+			if err, ok := result.(*interpreter.Error); ok {
+				return 0, err
+			}
+			n, ok := result.(*interpreter.Int)
+			if !ok {
+				return 0, newException(typeErrorClass, "__hash__ method should return an integer")
+			}
+			return uint64(n.Val), nil
+		}
+	case *interpreter.Function, *interpreter.Builtin, *interpreter.BoundMethod, *interpreter.Class, *interpreter.Module:
+		return uint64(identity(key)), nil
+	}
+	hash, ok := interpreter.Hash(key)
+	if !ok {
+		return 0, newException(typeErrorClass, "unhashable type: '%s'", typeName(key))
+	}
+	return hash, nil
+}
+
+// equalIn returns the Equal comparing keys like ==, running any __eq__
+// methods in env.
+func equalIn(env *interpreter.Environment) interpreter.Equal {
+	return func(a, b interpreter.Item) (bool, *interpreter.Error) {
+		return itemsEqual(a, b, env)
+	}
+}
+
+// dictKey returns the HashKey under which dict holds key and true, or the
+// one to store key under and false.
+func dictKey(dict *interpreter.Dict, key interpreter.Item, env *interpreter.Environment) (interpreter.HashKey, bool, *interpreter.Error) {
+	hash, err := hashOf(key, env)
+	if err != nil {
+		return interpreter.HashKey{}, false, err
+	}
+	return dict.Find(hash, key, equalIn(env))
+}
+
+// setKey is dictKey for the elements of sets.
+func setKey(set *interpreter.Set, item interpreter.Item, env *interpreter.Environment) (interpreter.HashKey, bool, *interpreter.Error) {
+	hash, err := hashOf(item, env)
+	if err != nil {
+		return interpreter.HashKey{}, false, err
+	}
+	return set.Find(hash, item, equalIn(env))
+}
+
+// dictSet stores value under key in dict, charging for a new key.
+func dictSet(dict *interpreter.Dict, key interpreter.Item, value interpreter.Item, env *interpreter.Environment) *interpreter.Error {
+	slot, exists, err := dictKey(dict, key, env)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := allocate(slotSize, env); err != nil {
+			return err
+		}
+	}
+	dict.Set(slot, key, value)
+	return nil
+}
+
+// storeKey stores value under key, a str or other plain data, in a dict
+// the evaluator builds itself, whose keys can't run Python code when
+// compared.
+func storeKey(dict *interpreter.Dict, key interpreter.Item, value interpreter.Item) {
+	hash, _ := interpreter.Hash(key)
+	slot, _, _ := dict.Find(hash, key, nil)
+	dict.Set(slot, key, value)
+}
+
+// dictGet implements get(key, default=None), which never raises KeyError.
+func dictGet(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 && len(args) != 3 {
+		return newException(typeErrorClass, "get expected 1 or 2 arguments, got %d", len(args)-1)
+	}
+	hash, found, err := dictKey(args[0].(*interpreter.Dict), args[1], env)
+	if err != nil {
+		return err
+	}
+	if found {
+		val, _ := args[0].(*interpreter.Dict).Get(hash)
+		return val
+	}
+	if len(args) == 3 {
+		return args[2]
+	}
+	return NONE
+}
+
+// dictList returns a list of what f takes from each pair, in order.
+func dictList(name string, args []interpreter.Item, f func(interpreter.DictPair) interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "dict.%s() takes no arguments (%d given)", name, len(args)-1)
+	}
+	dict := args[0].(*interpreter.Dict)
+	items := make([]interpreter.Item, len(dict.Keys))
+	for i, hash := range dict.Keys {
+		items[i] = f(dict.Pairs[hash])
+	}
+	return &interpreter.List{Elements: items}
+}
+
+// dictPop implements pop(key[, default]), removing the key and returning
+// its value, or default if it is missing.
+func dictPop(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 && len(args) != 3 {
+		return newException(typeErrorClass, "pop expected 1 or 2 arguments, got %d", len(args)-1)
+	}
+	dict := args[0].(*interpreter.Dict)
+	hash, found, err := dictKey(dict, args[1], env)
+	if err != nil {
+		return err
+	}
+	val, _ := dict.Get(hash)
+	if !found {
+		if len(args) == 3 {
+			return args[2]
+		}
+		return newException(keyErrorClass, "%s", args[1].Visit())
+	}
+	dict.Delete(hash)
+	return val
+}
+
+// dictSetdefault implements setdefault(key, default=None), storing default
+// under key if it is missing and returning the key's value.
+func dictSetdefault(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 && len(args) != 3 {
+		return newException(typeErrorClass, "setdefault expected 1 or 2 arguments, got %d", len(args)-1)
+	}
+	dict := args[0].(*interpreter.Dict)
+	hash, found, err := dictKey(dict, args[1], env)
+	if err != nil {
+		return err
+	}
+	if found {
+		val, _ := dict.Get(hash)
+		return val
+	}
+	var def interpreter.Item = NONE
+	if len(args) == 3 {
+		def = args[2]
+	}
+	if err := allocate(slotSize, env); err != nil {
+		return err
+	}
+	dict.Set(hash, args[1], def)
+	return def
+}
+
+// dictUpdate implements update([other], **kwargs). other is a dict or an
+// iterable of key, value pairs.
+func dictUpdate(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	if len(args) > 2 {
+		return newException(typeErrorClass, "update expected at most 1 argument, got %d", len(args)-1)
+	}
+	dict := args[0].(*interpreter.Dict)
+	if len(args) == 2 {
+		if other, ok := args[1].(*interpreter.Dict); ok {
+			for _, hash := range append([]interpreter.HashKey{}, other.Keys...) {
+				pair := other.Pairs[hash]
+				if err := dictSet(dict, pair.Key, pair.Value, env); err != nil {
+					return err
+				}
+			}
+		} else {
+			items, err := iterate(args[1], env)
+			if err != nil {
+				return err
+			}
+			for i, item := range items {
+				pair, err := iterate(item, env)
+				if err != nil {
+					return newException(typeErrorClass, "cannot convert dictionary update sequence element #%d to a sequence", i)
+				}
+				if len(pair) != 2 {
+					return newException(valueErrorClass, "dictionary update sequence element #%d has length %d; 2 is required", i, len(pair))
+				}
+				if keyErr := dictSet(dict, pair[0], pair[1], env); keyErr != nil {
+					return keyErr
+				}
+			}
+		}
+	}
+	for name, val := range kwargs {
+		if err := dictSet(dict, &interpreter.Str{Val: name}, val, env); err != nil {
+			return err
+		}
+	}
+	return NONE
+}
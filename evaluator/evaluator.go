@@ -1,44 +1,62 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
 	"gopy/ast"
 	"gopy/interpreter"
+	"math"
+	"strings"
 )
 
 var (
-	TRUE = &interpreter.Bool{Val: true}
-	FALSE = &interpreter.Bool{Val: false}
+	TRUE = interpreter.TrueValue
+	FALSE = interpreter.FalseValue
+	BREAK = &interpreter.Break{}
+	CONTINUE = &interpreter.Continue{}
+	NONE = interpreter.NoneValue
 )
 
-var builtins = map[string]*interpreter.Builtin{
-	"print": {
-		Fn: func(args ...interpreter.Item) interpreter.Item {
-			var result string
-			for _, arg := range args {
-				result += arg.Visit()
+// Evaluate evaluates node in env. Errors raised by a statement record the
+// statement's position unless an inner statement already did.
+func Evaluate(node ast.Node, env *interpreter.Environment) interpreter.Item {
+	var result interpreter.Item
+	if err := interrupted(node, env); err != nil {
+		result = err
+	} else {
+		callHook(node, env)
+		result = evaluate(node, env)
+	}
+	if err, ok := result.(*interpreter.Error); ok {
+		if stmt, ok := node.(ast.Stmt); ok {
+			if err.Pos == "" {
+				err.Pos = stmt.Pos().String()
 			}
-			return &interpreter.Str{Val: result}
-		},
-	},
+			trace(err, stmt, env)
+		}
+	}
+	return result
 }
 
-func Evaluate(node ast.Node, env *interpreter.Environment) interpreter.Item {
+func evaluate(node ast.Node, env *interpreter.Environment) interpreter.Item {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evaluateStmts(node.Stmts, env)
 	case *ast.ExprStmt:
 		return Evaluate(node.Expr, env)
 	case *ast.CallExpr:
+		if isZeroArgSuper(node, env) {
+			return evaluateSuper(env)
+		}
 		fn := Evaluate(node.Func, env)
 		if fn.Type() == interpreter.ERR {
 			return fn
 		}
-		args := evaluateExprs(node.Args, env)
-		if len(args) == 1 && args[0].Type() == interpreter.ERR {
-			return args[0]
+		args, kwargs, err := evaluateCallArgs(node, env)
+		if err != nil {
+			return err
 		}
-		//return applyFn(fn, args)
+		return applyFn(fn, args, kwargs, env)
 	case *ast.VarStmt:
 		v := Evaluate(node.Value, env)
 		if v.Type() == interpreter.ERR {
@@ -53,8 +71,11 @@ func Evaluate(node ast.Node, env *interpreter.Environment) interpreter.Item {
 		if expr.Type() == interpreter.ERR {
 			return expr
 		}
-		return evaluatePrefixExpr(node.Op, expr)
+		return evaluatePrefixExpr(node.Op, expr, env)
 	case *ast.InfixExpr:
+		if node.Op == "and" || node.Op == "or" {
+			return evaluateLogicalExpr(node, env)
+		}
 		l := Evaluate(node.Left, env)
 		if l.Type() == interpreter.ERR {
 			return l
@@ -63,31 +84,672 @@ func Evaluate(node ast.Node, env *interpreter.Environment) interpreter.Item {
 		if r.Type() == interpreter.ERR {
 			return r
 		}
-		return evaluateInfixExpr(node.Op, l, r)
+		return evaluateInfixExpr(node.Op, l, r, env)
+	case *ast.CompareExpr:
+		return evaluateCompareExpr(node, env)
 	case *ast.BlockStmt:
 		return evaluateStmts(node.Stmts, env)
+	case *ast.PassStmt:
+		return nil
+	case *ast.BreakStmt:
+		return BREAK
+	case *ast.FunctionDef:
+		return evaluateFunctionDef(node, env)
+	case *ast.ReturnStmt:
+		if node.Value == nil {
+			return &interpreter.ReturnValue{Value: NONE}
+		}
+		val := Evaluate(node.Value, env)
+		if val.Type() == interpreter.ERR {
+			return val
+		}
+		return &interpreter.ReturnValue{Value: val}
+	case *ast.ClassDef:
+		return evaluateClassDef(node, env)
+	case *ast.ImportStmt:
+		return evaluateImportStmt(node, env)
+	case *ast.FromImportStmt:
+		return evaluateFromImportStmt(node, env)
+	case *ast.TryStmt:
+		return evaluateTryStmt(node, env)
+	case *ast.RaiseStmt:
+		return evaluateRaiseStmt(node, env)
+	case *ast.WithStmt:
+		return evaluateWithStmt(node, 0, env)
+	case *ast.ForStmt:
+		return evaluateForStmt(node, env)
+	case *ast.MatchStmt:
+		return evaluateMatchStmt(node, env)
+	case *ast.AssertStmt:
+		return evaluateAssertStmt(node, env)
+	case *ast.GlobalStmt:
+		for _, name := range node.Names {
+			env.DeclareGlobal(name.Val)
+		}
+		return nil
+	case *ast.NonlocalStmt:
+		for _, name := range node.Names {
+			if !env.DeclareNonlocal(name.Val) {
+				return newException(syntaxErrorClass, "no binding for nonlocal '%s' found", name.Val)
+			}
+		}
+		return nil
+	case *ast.DelStmt:
+		for _, target := range node.Targets {
+			if result := deleteTarget(target, env); result != nil {
+				return result
+			}
+		}
+		return nil
+	case *ast.YieldExpr:
+		return evaluateYieldExpr(node, env)
+	case *ast.StarredExpr:
+		return newException(syntaxErrorClass, "can't use starred expression here")
+	case *ast.AttributeExpr:
+		obj := Evaluate(node.Object, env)
+		if obj.Type() == interpreter.ERR {
+			return obj
+		}
+		return getAttr(obj, node.Attr.Val)
+	case *ast.ContinueStmt:
+		return CONTINUE
 	case *ast.IfExpr:
 		return evaluateIfExpr(node, env)
+	case *ast.WhileExpr:
+		return evaluateWhileExpr(node, env)
+	case *ast.TernaryExpr:
+		cond := Evaluate(node.Cond, env)
+		if cond.Type() == interpreter.ERR {
+			return cond
+		}
+		if isTrue(cond) {
+			return Evaluate(node.Pass, env)
+		}
+		return Evaluate(node.Fail, env)
 	case *ast.IntLiteral:
 		return &interpreter.Int{Val: node.Value}
+	case *ast.FloatLiteral:
+		return &interpreter.Float{Val: node.Value}
 	case *ast.StrLiteral:
 		return &interpreter.Str{Val: node.Value}
+	case *ast.BytesLiteral:
+		return &interpreter.Bytes{Val: []byte(node.Value)}
+	case *ast.BoolLiteral:
+		return nativeBool(node.Value)
+	case *ast.NoneLiteral:
+		return NONE
+	case *ast.ListLiteral:
+		elements := evaluateExprs(node.Elements, env)
+		if len(elements) == 1 && elements[0].Type() == interpreter.ERR {
+			return elements[0]
+		}
+		return &interpreter.List{Elements: elements}
+	case *ast.TupleLiteral:
+		elements := evaluateExprs(node.Elements, env)
+		if len(elements) == 1 && elements[0].Type() == interpreter.ERR {
+			return elements[0]
+		}
+		return &interpreter.Tuple{Elements: elements}
+	case *ast.DictLiteral:
+		return evaluateDictLiteral(node, env)
+	case *ast.IndexExpr:
+		left := Evaluate(node.Left, env)
+		if left.Type() == interpreter.ERR {
+			return left
+		}
+		index := Evaluate(node.Index, env)
+		if index.Type() == interpreter.ERR {
+			return index
+		}
+		return evaluateIndexExpr(left, index, env)
+	case *ast.SliceExpr:
+		return evaluateSliceExpr(node, env)
+	case *ast.IndexAssignStmt:
+		return evaluateIndexAssignStmt(node, env)
+	case *ast.AugAssignStmt:
+		return evaluateAugAssignStmt(node, env)
+	case *ast.AssignStmt:
+		val := Evaluate(node.Value, env)
+		if val.Type() == interpreter.ERR {
+			return val
+		}
+		for _, target := range node.Targets {
+			if result := assign(target, val, env); result.Type() == interpreter.ERR {
+				return result
+			}
+		}
+		return val
+	}
+	return nil
+}
+
+// keyword is an argument passed by name.
+type keyword struct {
+	Name string
+	Value interpreter.Item
+}
+
+// evaluateCallArgs evaluates the arguments of a call, spreading *iterable
+// and **mapping arguments.
+func evaluateCallArgs(ce *ast.CallExpr, env *interpreter.Environment) ([]interpreter.Item, []keyword, interpreter.Item) {
+	var args []interpreter.Item
+	for _, expr := range ce.Args {
+		starred, isStarred := expr.(*ast.StarredExpr)
+		if isStarred {
+			expr = starred.Value
+		}
+		val := Evaluate(expr, env)
+		if val.Type() == interpreter.ERR {
+			return nil, nil, val
+		}
+		if !isStarred {
+			args = append(args, val)
+			continue
+		}
+		items, err := iterate(val, env)
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append(args, items...)
+	}
+	var kwargs []keyword
+	add := func(name string, val interpreter.Item) interpreter.Item {
+		for _, kw := range kwargs {
+			if kw.Name == name {
+				return newException(typeErrorClass, "%s() got multiple values for keyword argument '%s'", ce.Func.String(), name)
+			}
+		}
+		kwargs = append(kwargs, keyword{Name: name, Value: val})
+		return nil
+	}
+	for i, name := range ce.Keywords {
+		val := Evaluate(ce.KeywordValues[i], env)
+		if val.Type() == interpreter.ERR {
+			return nil, nil, val
+		}
+		if name != nil {
+			if err := add(name.Val, val); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		dict, ok := val.(*interpreter.Dict)
+		if !ok {
+			return nil, nil, newException(typeErrorClass, "argument after ** must be a mapping, not %s", typeName(val))
+		}
+		for _, hash := range dict.Keys {
+			pair := dict.Pairs[hash]
+			key, ok := pair.Key.(*interpreter.Str)
+			if !ok {
+				return nil, nil, newException(typeErrorClass, "keywords must be strings")
+			}
+			if err := add(key.Val, pair.Value); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return args, kwargs, nil
+}
+
+func evaluateFunctionDef(fd *ast.FunctionDef, env *interpreter.Environment) interpreter.Item {
+	fn := &interpreter.Function{
+		Name: fd.Name.Val,
+		Params: fd.Params,
+		VarArgs: fd.VarArgs,
+		KwArgs: fd.KwArgs,
+		Body: fd.Body,
+		Doc: fd.Doc,
+		Locals: fd.Locals,
+		Env: env,
+		Generator: fd.Generator,
+	}
+	fn.Defaults = make([]interpreter.Item, len(fd.Defaults))
+	for i, def := range fd.Defaults {
+		if def == nil {
+			continue
+		}
+		val := Evaluate(def, env)
+		if val.Type() == interpreter.ERR {
+			return val
+		}
+		fn.Defaults[i] = val
+	}
+	return env.Store(fd.Name.Val, fn)
+}
+
+func applyFn(fn interpreter.Item, args []interpreter.Item, kwargs []keyword, env *interpreter.Environment) interpreter.Item {
+	switch fn := fn.(type) {
+	case *interpreter.Builtin:
+		if fn.KwFn != nil {
+			named := map[string]interpreter.Item{}
+			for _, kw := range kwargs {
+				named[kw.Name] = kw.Value
+			}
+			return fn.KwFn(env, args, named)
+		}
+		if len(kwargs) > 0 {
+			return newException(typeErrorClass, "%s() takes no keyword arguments", fn.Name)
+		}
+		return fn.Fn(env, args...)
+	case *interpreter.Function:
+		return applyFunction(fn, args, kwargs)
+	case *interpreter.BoundMethod:
+		return applyFn(fn.Fn, append([]interpreter.Item{fn.Self}, args...), kwargs, env)
+	case *interpreter.Class:
+		return instantiate(fn, args, kwargs, env)
+	case *interpreter.Type:
+		if fn.New == nil {
+			return newException(typeErrorClass, "cannot create '%s' instances", fn.Name)
+		}
+		if len(kwargs) > 0 {
+			return newException(typeErrorClass, "%s() takes no keyword arguments", fn.Name)
+		}
+		return fn.New(env, args...)
+	case *interpreter.Instance:
+		if method, ok := fn.Class.Lookup("__call__"); ok {
+			return applyFn(bindMethod(fn, method), args, kwargs, env)
+		}
+		return newException(typeErrorClass, "'%s' object is not callable", typeName(fn))
+	default:
+		return newException(typeErrorClass, "'%s' object is not callable", typeName(fn))
+	}
+}
+
+// bindArgs matches positional and keyword arguments to the parameters of
+// fn and binds them in env, filling in defaults and collecting extra
+// arguments into the *args tuple and **kwargs dict.
+func bindArgs(fn *interpreter.Function, args []interpreter.Item, kwargs []keyword, env *interpreter.Environment) *interpreter.Error {
+	if len(args) > len(fn.Params) && fn.VarArgs == nil {
+		return newException(typeErrorClass, "%s() takes %d positional arguments but %d were given", fn.Name, len(fn.Params), len(args))
+	}
+	bound := make([]interpreter.Item, len(fn.Params))
+	extra := &interpreter.Tuple{Elements: []interpreter.Item{}}
+	for i, arg := range args {
+		if i < len(bound) {
+			bound[i] = arg
+		} else {
+			extra.Elements = append(extra.Elements, arg)
+		}
+	}
+	extraKwargs := interpreter.NewDict()
+	for _, kw := range kwargs {
+		i := paramIndex(fn, kw.Name)
+		if i < 0 {
+			if fn.KwArgs == nil {
+				return newException(typeErrorClass, "%s() got an unexpected keyword argument '%s'", fn.Name, kw.Name)
+			}
+			storeKey(extraKwargs, &interpreter.Str{Val: kw.Name}, kw.Value)
+			continue
+		}
+		if bound[i] != nil {
+			return newException(typeErrorClass, "%s() got multiple values for argument '%s'", fn.Name, kw.Name)
+		}
+		bound[i] = kw.Value
+	}
+	var missing []string
+	for i, param := range fn.Params {
+		if bound[i] == nil {
+			if fn.Defaults[i] == nil {
+				missing = append(missing, "'"+param.Val+"'")
+			}
+			bound[i] = fn.Defaults[i]
+		}
+	}
+	if len(missing) > 0 {
+		plural := ""
+		if len(missing) > 1 {
+			plural = "s"
+		}
+		return newException(typeErrorClass, "%s() missing %d required positional argument%s: %s",
+			fn.Name, len(missing), plural, joinNames(missing))
+	}
+	for i, param := range fn.Params {
+		env.Store(param.Val, bound[i])
+	}
+	if fn.VarArgs != nil {
+		env.Store(fn.VarArgs.Val, extra)
+	}
+	if fn.KwArgs != nil {
+		env.Store(fn.KwArgs.Val, extraKwargs)
+	}
+	return nil
+}
+
+func paramIndex(fn *interpreter.Function, name string) int {
+	for i, param := range fn.Params {
+		if param.Val == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// joinNames joins names the way Python lists them in messages: 'a',
+// 'a' and 'b', or 'a', 'b', and 'c'.
+func joinNames(names []string) string {
+	switch len(names) {
+	case 1:
+		return names[0]
+	case 2:
+		return names[0] + " and " + names[1]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", and " + names[len(names)-1]
+}
+
+func applyFunction(fn *interpreter.Function, args []interpreter.Item, kwargs []keyword) interpreter.Item {
+	env := interpreter.NewEnclosedEnv(fn.Env)
+	for _, name := range fn.Locals {
+		env.DeclareLocal(name)
+	}
+	if err := bindArgs(fn, args, kwargs, env); err != nil {
+		return err
+	}
+	if fn.Class != nil && len(args) > 0 {
+		// Backs the zero argument form of super(), like the implicit
+		// __class__ cell in CPython.
+		env.Store("__super__", &interpreter.Super{Class: fn.Class, Self: args[0]})
+	}
+	if fn.Generator {
+		return newGenerator(fn, env)
+	}
+	if err := pushFrame(fn.Name, env); err != nil {
+		return err
+	}
+	result := Evaluate(fn.Body, env)
+	popFrame(result, env)
+	if result == nil {
+		return NONE
+	}
+	if ret, ok := result.(*interpreter.ReturnValue); ok {
+		return ret.Value
+	}
+	if result.Type() == interpreter.ERR {
+		return result
+	}
+	return NONE
+}
+
+func instantiate(class *interpreter.Class, args []interpreter.Item, kwargs []keyword, env *interpreter.Environment) interpreter.Item {
+	instance := &interpreter.Instance{Class: class, Attrs: make(map[string]interpreter.Item)}
+	init, ok := class.Lookup("__init__")
+	if !ok {
+		if len(args) != 0 || len(kwargs) != 0 {
+			return newException(typeErrorClass, "%s() takes no arguments", class.Name)
+		}
+		return instance
+	}
+	result := applyFn(bindMethod(instance, init), args, kwargs, env)
+	if result.Type() == interpreter.ERR {
+		return result
+	}
+	return instance
+}
+
+func evaluateClassDef(cd *ast.ClassDef, env *interpreter.Environment) interpreter.Item {
+	class := &interpreter.Class{Name: cd.Name.Val}
+	if cd.Base != nil {
+		base := Evaluate(cd.Base, env)
+		if base.Type() == interpreter.ERR {
+			return base
+		}
+		baseClass, ok := base.(*interpreter.Class)
+		if !ok {
+			return newException(typeErrorClass, "class base must be a class, not %s", typeName(base))
+		}
+		class.Base = baseClass
+	}
+	classEnv := interpreter.NewEnclosedEnv(env)
+	result := Evaluate(cd.Body, classEnv)
+	if result != nil && result.Type() == interpreter.ERR {
+		return result
+	}
+	class.Attrs = classEnv.Locals()
+	if _, ok := class.Attrs["__doc__"]; !ok {
+		class.Attrs["__doc__"] = docItem(cd.Doc)
+	}
+	for _, attr := range class.Attrs {
+		// Methods see the scope around the class, not the class body.
+		if fn, ok := attr.(*interpreter.Function); ok && fn.Env == classEnv {
+			fn.Env = env
+			fn.Class = class
+		}
+	}
+	return env.Store(cd.Name.Val, class)
+}
+
+// isZeroArgSuper reports whether call is super() referring to the builtin.
+func isZeroArgSuper(call *ast.CallExpr, env *interpreter.Environment) bool {
+	ident, ok := call.Func.(*ast.Identifier)
+	if !ok || ident.Val != "super" || len(call.Args) != 0 {
+		return false
+	}
+	_, shadowed := env.Get("super")
+	return !shadowed
+}
+
+func evaluateSuper(env *interpreter.Environment) interpreter.Item {
+	if super, ok := env.Get("__super__"); ok {
+		return super
 	}
+	return newException(runtimeErrorClass, "super(): no arguments")
+}
+
+func getAttr(obj interpreter.Item, name string) interpreter.Item {
+	switch obj := obj.(type) {
+	case *interpreter.Instance:
+		if attr, ok := obj.Attrs[name]; ok {
+			return attr
+		}
+		if attr, ok := obj.Class.Lookup(name); ok {
+			return bindMethod(obj, attr)
+		}
+	case *interpreter.Class:
+		if attr, ok := obj.Lookup(name); ok {
+			return attr
+		}
+		return newException(attributeErrorClass, "type object '%s' has no attribute '%s'", obj.Name, name)
+	case *interpreter.Super:
+		if obj.Class.Base != nil {
+			if attr, ok := obj.Class.Base.Lookup(name); ok {
+				return bindMethod(obj.Self, attr)
+			}
+		}
+		return newException(attributeErrorClass, "'super' object has no attribute '%s'", name)
+	case *interpreter.Module:
+		if attr, ok := obj.Env.Locals()[name]; ok {
+			return attr
+		}
+		return newException(attributeErrorClass, "module '%s' has no attribute '%s'", obj.Name, name)
+	case *interpreter.Generator:
+		if method, ok := generatorMethods[name]; ok {
+			return &interpreter.BoundMethod{Self: obj, Fn: method}
+		}
+	case *interpreter.Str:
+		if method, ok := strMethods[name]; ok {
+			return &interpreter.BoundMethod{Self: obj, Fn: method}
+		}
+	case *interpreter.Bytes:
+		if method, ok := bytesMethods[name]; ok {
+			return &interpreter.BoundMethod{Self: obj, Fn: method}
+		}
+	case *interpreter.List:
+		if method, ok := listMethods[name]; ok {
+			return &interpreter.BoundMethod{Self: obj, Fn: method}
+		}
+	case *interpreter.Dict:
+		if method, ok := dictMethods[name]; ok {
+			return &interpreter.BoundMethod{Self: obj, Fn: method}
+		}
+	case *interpreter.File:
+		if method, ok := fileMethods[name]; ok {
+			return &interpreter.BoundMethod{Self: obj, Fn: method}
+		}
+		switch name {
+		case "name":
+			return &interpreter.Str{Val: obj.Name}
+		case "mode":
+			return &interpreter.Str{Val: obj.Mode}
+		case "closed":
+			return nativeBool(obj.Closed)
+		}
+	case *interpreter.Pattern:
+		if method, ok := patternMethods[name]; ok {
+			return &interpreter.BoundMethod{Self: obj, Fn: method}
+		}
+		switch name {
+		case "pattern":
+			return &interpreter.Str{Val: obj.Source}
+		case "flags":
+			return &interpreter.Int{Val: obj.Flags}
+		case "groups":
+			return &interpreter.Int{Val: int64(obj.Regexp.NumSubexp())}
+		}
+	case *interpreter.Match:
+		if method, ok := matchMethods[name]; ok {
+			return &interpreter.BoundMethod{Self: obj, Fn: method}
+		}
+		switch name {
+		case "string":
+			return &interpreter.Str{Val: obj.Str}
+		case "re":
+			return obj.Pattern
+		}
+	case *interpreter.Function:
+		if name == "__doc__" {
+			return docItem(obj.Doc)
+		}
+	}
+	return newException(attributeErrorClass, "'%s' object has no attribute '%s'", typeName(obj), name)
+}
+
+// docItem returns a docstring as a str, or None if there is none.
+func docItem(doc string) interpreter.Item {
+	if doc == "" {
+		return NONE
+	}
+	return &interpreter.Str{Val: doc}
+}
+
+func setAttr(obj interpreter.Item, name string, val interpreter.Item) interpreter.Item {
+	switch obj := obj.(type) {
+	case *interpreter.Instance:
+		obj.Attrs[name] = val
+	case *interpreter.Class:
+		obj.Attrs[name] = val
+	case *interpreter.Module:
+		obj.Env.Store(name, val)
+	default:
+		return newException(attributeErrorClass, "'%s' object has no attribute '%s'", typeName(obj), name)
+	}
+	return val
+}
+
+func delAttr(obj interpreter.Item, name string) interpreter.Item {
+	var attrs map[string]interpreter.Item
+	switch obj := obj.(type) {
+	case *interpreter.Instance:
+		attrs = obj.Attrs
+	case *interpreter.Class:
+		attrs = obj.Attrs
+	case *interpreter.Module:
+		attrs = obj.Env.Locals()
+	}
+	if _, ok := attrs[name]; !ok {
+		return newException(attributeErrorClass, "'%s' object has no attribute '%s'", typeName(obj), name)
+	}
+	delete(attrs, name)
 	return nil
 }
 
-func applyFn(fn interpreter.Item, args []interpreter.Item) interpreter.Item {
-	fun, ok := fn.(*interpreter.Builtin)
+// binaryMethods maps each binary operator to the special method implementing
+// it and the reflected method tried on the right operand.
+var binaryMethods = map[string][2]string{
+	"+": {"__add__", "__radd__"},
+	"-": {"__sub__", "__rsub__"},
+	"*": {"__mul__", "__rmul__"},
+	"/": {"__truediv__", "__rtruediv__"},
+	"%": {"__mod__", "__rmod__"},
+	"**": {"__pow__", "__rpow__"},
+	"==": {"__eq__", "__eq__"},
+	"!=": {"__ne__", "__ne__"},
+	"<": {"__lt__", "__gt__"},
+	"<=": {"__le__", "__ge__"},
+	">": {"__gt__", "__lt__"},
+	">=": {"__ge__", "__le__"},
+}
+
+// evaluateInstanceInfixExpr dispatches an operator with an instance operand
+// to the left operand's special method, then to the right operand's
+// reflected method.
+func evaluateInstanceInfixExpr(op string, l interpreter.Item, r interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	methods, ok := binaryMethods[op]
+	if ok {
+		if left, isInstance := l.(*interpreter.Instance); isInstance {
+			if result, ok := callMethod(env, left, methods[0], r); ok {
+				return result
+			}
+		}
+		if right, isInstance := r.(*interpreter.Instance); isInstance {
+			if result, ok := callMethod(env, right, methods[1], l); ok {
+				return result
+			}
+		}
+	}
+	switch op {
+	case "==":
+		return nativeBool(l == r)
+	case "!=":
+		// Without __ne__, != is the negation of ==.
+		eq := evaluateInstanceInfixExpr("==", l, r, env)
+		if eq.Type() == interpreter.ERR {
+			return eq
+		}
+		return nativeBool(!isTrue(eq))
+	}
+	return unsupportedOperands(op, l, r)
+}
+
+// unsupportedOperands returns the TypeError raised when op is not defined
+// for the types of l and r. Ordering comparisons word it the way Python
+// does.
+func unsupportedOperands(op string, l interpreter.Item, r interpreter.Item) *interpreter.Error {
+	switch op {
+	case "<", ">", "<=", ">=":
+		return newException(typeErrorClass, "'%s' not supported between instances of '%s' and '%s'", op, typeName(l), typeName(r))
+	}
+	return newException(typeErrorClass, "unsupported operand type(s) for %s: '%s' and '%s'", op, typeName(l), typeName(r))
+}
+
+// callMethod calls the named method of instance if its class defines one.
+func callMethod(env *interpreter.Environment, instance *interpreter.Instance, name string, args ...interpreter.Item) (interpreter.Item, bool) {
+	method, ok := instance.Class.Lookup(name)
 	if !ok {
-		return newErr("not a function: %s", fn.Type())
+		return nil, false
+	}
+	return applyFn(bindMethod(instance, method), args, nil, env), true
+}
+
+// bindMethod binds functions and builtins found on a class to the instance
+// they were looked up through. Other attributes are returned unchanged.
+func bindMethod(self interpreter.Item, attr interpreter.Item) interpreter.Item {
+	switch attr.(type) {
+	case *interpreter.Function, *interpreter.Builtin:
+		return &interpreter.BoundMethod{Self: self, Fn: attr}
 	}
-	return fun.Fn(args...)
+	return attr
 }
 
 func evaluateStmts(stmts []ast.Stmt, env *interpreter.Environment) interpreter.Item {
 	var result interpreter.Item
 	for _, stmt := range stmts {
 		result = Evaluate(stmt, env)
+		if result == nil {
+			continue
+		}
+		switch result.Type() {
+		case interpreter.ERR, interpreter.BREAK, interpreter.CONTINUE, interpreter.RETURN:
+			return result
+		}
 	}
 	return result
 }
@@ -108,114 +770,1647 @@ func evaluateIdent(i *ast.Identifier, env *interpreter.Environment) interpreter.
 	if val, ok := env.Get(i.Val); ok {
 		return val
 	}
-	if builtin, ok := builtins[i.Val]; ok {
+	if env.IsLocal(i.Val) {
+		return newException(unboundLocalErrorClass, "cannot access local variable '%s' where it is not associated with a value", i.Val)
+	}
+	if builtin, ok := lookupBuiltin(i.Val, env); ok {
 		return builtin
 	}
-	return newErr("identifier not found: " + i.Val)
+	return newException(nameErrorClass, "name '%s' is not defined", i.Val)
 }
 
-func evaluatePrefixExpr(op string, expr interpreter.Item) interpreter.Item {
+func evaluatePrefixExpr(op string, expr interpreter.Item, env *interpreter.Environment) interpreter.Item {
 	switch op {
-	case "-":
-		return evaluateNegateOpExpr(expr)
+	case "-", "+", "~":
+		return evaluateUnaryOpExpr(op, expr, env)
+	case "not":
+		return nativeBool(!isTrue(expr))
 	default:
-		return newErr("unknown operator: %s%s", op, expr.Type())
+		return newException(typeErrorClass, "bad operand type for unary %s: '%s'", op, typeName(expr))
 	}
 }
 
-func evaluateNegateOpExpr(expr interpreter.Item) interpreter.Item {
-	if expr.Type() != interpreter.INT {
-		return nil
+// unaryMethods maps each unary arithmetic operator to the special method
+// implementing it.
+var unaryMethods = map[string]string{
+	"-": "__neg__",
+	"+": "__pos__",
+	"~": "__invert__",
+}
+
+// evaluateUnaryOpExpr applies -, + or ~ to a number. Bools act as the ints
+// 0 and 1, and ~ only applies to integers.
+func evaluateUnaryOpExpr(op string, expr interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	switch operand := boolToInt(expr).(type) {
+	case *interpreter.Int:
+		switch op {
+		case "-":
+			if operand.Val == math.MinInt64 {
+				return intOverflow()
+			}
+			return &interpreter.Int{Val: -operand.Val}
+		case "~":
+			return &interpreter.Int{Val: ^operand.Val}
+		}
+		return operand
+	case *interpreter.Float:
+		switch op {
+		case "-":
+			return &interpreter.Float{Val: -operand.Val}
+		case "+":
+			return operand
+		}
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, operand, unaryMethods[op]); ok {
+			return result
+		}
 	}
-	val := expr.(*interpreter.Int).Val
-	return &interpreter.Int{Val: -val}
+	return newException(typeErrorClass, "bad operand type for unary %s: '%s'", op, typeName(expr))
 }
 
-func evaluateInfixExpr(op string, l interpreter.Item, r interpreter.Item) interpreter.Item {
+func evaluateInfixExpr(op string, l interpreter.Item, r interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	if left, ok := l.(*interpreter.Str); ok && op == "%" {
+		return percentFormat(left.Val, r, env)
+	}
+	if l.Type() == interpreter.INSTANCE || r.Type() == interpreter.INSTANCE {
+		return evaluateInstanceInfixExpr(op, l, r, env)
+	}
+	if l.Type() == interpreter.NONE || r.Type() == interpreter.NONE {
+		return evaluateNoneInfixExpr(op, l, r)
+	}
+	if l.Type() == interpreter.BOOL || r.Type() == interpreter.BOOL {
+		return evaluateBoolInfixExpr(op, l, r, env)
+	}
 	switch {
 	case l.Type() == interpreter.INT && r.Type() == interpreter.INT:
 		return evaluateIntInfixExpr(op, l, r)
+	case l.Type() == interpreter.FLOAT && r.Type() == interpreter.FLOAT,
+			l.Type() == interpreter.INT && r.Type() == interpreter.FLOAT,
+			l.Type() == interpreter.FLOAT && r.Type() == interpreter.INT:
+		return evaluateFloatInfixExpr(op, promote(l), promote(r))
 	case l.Type() == interpreter.STR && r.Type() == interpreter.STR,
 			l.Type() == interpreter.INT && r.Type() == interpreter.STR,
 			l.Type() == interpreter.STR && r.Type() == interpreter.INT:
-		return evaluateStrInfixExpr(op, l, r)
+		return evaluateStrInfixExpr(op, l, r, env)
+	case l.Type() == interpreter.BYTES && r.Type() == interpreter.BYTES,
+			l.Type() == interpreter.INT && r.Type() == interpreter.BYTES,
+			l.Type() == interpreter.BYTES && r.Type() == interpreter.INT:
+		return evaluateBytesInfixExpr(op, l, r, env)
+	case isOrdering(op) && l.Type() == r.Type() && (l.Type() == interpreter.LIST || l.Type() == interpreter.TUPLE):
+		return compareSequences(op, l, r, env)
+	case op == "==" || op == "!=":
+		// Containers of the same type compare by value. Other objects,
+		// like classes and functions, are only equal to themselves.
+		equal := l == r
+		if !equal && isContainer(l) && l.Type() == r.Type() {
+			var err *interpreter.Error
+			if equal, err = containersEqual(l, r, env); err != nil {
+				return err
+			}
+		}
+		return nativeBool(equal == (op == "=="))
 	default:
-		return newErr("unknown operator: %s %s %s", l.Type(), op, r.Type())
+		return unsupportedOperands(op, l, r)
 	}
 }
 
-func evaluateIntInfixExpr(op string, l interpreter.Item, r interpreter.Item) interpreter.Item {
-	left := l.(*interpreter.Int).Val
-	right := r.(*interpreter.Int).Val
-	switch op {
-	case "+":
-		return &interpreter.Int{Val: left+right}
-	case "-":
-		return &interpreter.Int{Val: left-right}
-	case "*":
-		return &interpreter.Int{Val: left*right}
-	case "/":
-		return &interpreter.Int{Val: left/right}
-	case "<":
-		if left < right {
-			return TRUE
+func isContainer(item interpreter.Item) bool {
+	switch item.Type() {
+	case interpreter.LIST, interpreter.TUPLE, interpreter.DICT, interpreter.SET:
+		return true
+	}
+	return false
+}
+
+// containersEqual compares two containers of the same type: sequences
+// item by item, dicts by their pairs and sets by their members. Elements
+// are compared by identity first, so containers holding themselves are
+// equal to themselves, and comparing containers nested deeper than the
+// recursion limit raises RecursionError.
+func containersEqual(l interpreter.Item, r interpreter.Item, env *interpreter.Environment) (bool, *interpreter.Error) {
+	s := stateOf(env)
+	if len(s.callStack)+s.nesting >= s.recursionLimit() {
+		return false, newException(recursionErrorClass, "maximum recursion depth exceeded in comparison")
+	}
+	s.nesting++
+	defer func() { s.nesting-- }()
+	switch left := l.(type) {
+	case *interpreter.List, *interpreter.Tuple:
+		var a, b []interpreter.Item
+		if list, ok := left.(*interpreter.List); ok {
+			a, b = list.Elements, r.(*interpreter.List).Elements
 		} else {
-			return FALSE
+			a, b = left.(*interpreter.Tuple).Elements, r.(*interpreter.Tuple).Elements
 		}
-	case ">":
-		if left > right {
-			return TRUE
-		} else {
-			return FALSE
+		if len(a) != len(b) {
+			return false, nil
 		}
-	case "==":
-		if left == right {
-			return TRUE
-		} else {
-			return FALSE
+		for i := range a {
+			if equal, err := itemsEqual(a[i], b[i], env); err != nil || !equal {
+				return false, err
+			}
 		}
-	case "!=":
-		if left != right {
-			return TRUE
-		} else {
-			return FALSE
+		return true, nil
+	case *interpreter.Dict:
+		right := r.(*interpreter.Dict)
+		if len(left.Keys) != len(right.Keys) {
+			return false, nil
 		}
-	default:
-		return newErr("unknown operator: %s", op)
+		for _, hash := range left.Keys {
+			pair := left.Pairs[hash]
+			slot, found, err := dictKey(right, pair.Key, env)
+			if err != nil || !found {
+				return false, err
+			}
+			value, _ := right.Get(slot)
+			if equal, err := itemsEqual(pair.Value, value, env); err != nil || !equal {
+				return false, err
+			}
+		}
+		return true, nil
+	case *interpreter.Set:
+		right := r.(*interpreter.Set)
+		if len(left.Keys) != len(right.Keys) {
+			return false, nil
+		}
+		for _, hash := range left.Keys {
+			if _, found, err := setKey(right, left.Items[hash], env); err != nil || !found {
+				return false, err
+			}
+		}
+		return true, nil
 	}
+	return l == r, nil
 }
 
-func evaluateStrInfixExpr(op string, l interpreter.Item, r interpreter.Item) interpreter.Item {
-	left := l.Visit()
-	right := r.Visit()
+func isOrdering(op string) bool {
 	switch op {
-	case "+":
-		return &interpreter.Str{Val: fmt.Sprintf("%s%s", left,right)}
-	default:
-		return newErr("unknown operator: %s", op)
+	case "<", ">", "<=", ">=":
+		return true
 	}
+	return false
 }
 
-func evaluateIfExpr(ie *ast.IfExpr, env *interpreter.Environment) interpreter.Item {
-	cond := Evaluate(ie.Cond, env)
-	if isTrue(cond) {
-		return Evaluate(ie.Pass, env)
-	} else if ie.Fail != nil {
-		return Evaluate(ie.Fail, env)
+// compareSequences orders two lists or two tuples lexicographically: by
+// the first elements that differ, or by their lengths if one is a prefix
+// of the other.
+func compareSequences(op string, l interpreter.Item, r interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	s := stateOf(env)
+	if len(s.callStack)+s.nesting >= s.recursionLimit() {
+		return newException(recursionErrorClass, "maximum recursion depth exceeded in comparison")
+	}
+	s.nesting++
+	defer func() { s.nesting-- }()
+	var a, b []interpreter.Item
+	if list, ok := l.(*interpreter.List); ok {
+		a, b = list.Elements, r.(*interpreter.List).Elements
 	} else {
-		return nil
+		a, b = l.(*interpreter.Tuple).Elements, r.(*interpreter.Tuple).Elements
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		equal, err := itemsEqual(a[i], b[i], env)
+		if err != nil {
+			return err
+		}
+		if !equal {
+			return evaluateInfixExpr(op, a[i], b[i], env)
+		}
 	}
+	return evaluateIntInfixExpr(op, &interpreter.Int{Val: int64(len(a))}, &interpreter.Int{Val: int64(len(b))})
 }
 
-func isTrue(item interpreter.Item) bool {
-	switch item {
-	case TRUE:
-		return true
-	case FALSE:
-		return false
+// evaluateNoneInfixExpr compares None by identity. It supports no other
+// operators.
+func evaluateNoneInfixExpr(op string, l interpreter.Item, r interpreter.Item) interpreter.Item {
+	switch op {
+	case "==":
+		return nativeBool(l == r)
+	case "!=":
+		return nativeBool(l != r)
+	}
+	return unsupportedOperands(op, l, r)
+}
+
+// evaluateBoolInfixExpr treats bools as the ints 0 and 1, except that the
+// bitwise operators keep two bools a bool.
+func evaluateBoolInfixExpr(op string, l interpreter.Item, r interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	left, lok := l.(*interpreter.Bool)
+	right, rok := r.(*interpreter.Bool)
+	if lok && rok {
+		switch op {
+		case "&":
+			return nativeBool(left.Val && right.Val)
+		case "|":
+			return nativeBool(left.Val || right.Val)
+		case "^":
+			return nativeBool(left.Val != right.Val)
+		}
+	}
+	return evaluateInfixExpr(op, boolToInt(l), boolToInt(r), env)
+}
+
+func boolToInt(item interpreter.Item) interpreter.Item {
+	if b, ok := item.(*interpreter.Bool); ok {
+		if b.Val {
+			return &interpreter.Int{Val: 1}
+		}
+		return &interpreter.Int{Val: 0}
+	}
+	return item
+}
+
+// evaluateLogicalExpr only evaluates the right operand of and/or when the
+// left one does not already decide the result. Like Python, the result is
+// whichever operand decided it rather than a bool.
+func evaluateLogicalExpr(ie *ast.InfixExpr, env *interpreter.Environment) interpreter.Item {
+	l := Evaluate(ie.Left, env)
+	if l.Type() == interpreter.ERR {
+		return l
+	}
+	if ie.Op == "and" && !isTrue(l) {
+		return l
+	}
+	if ie.Op == "or" && isTrue(l) {
+		return l
+	}
+	return Evaluate(ie.Right, env)
+}
+
+// evaluateCompareExpr evaluates each operand at most once and stops at the
+// first comparison that fails.
+func evaluateCompareExpr(ce *ast.CompareExpr, env *interpreter.Environment) interpreter.Item {
+	left := Evaluate(ce.Left, env)
+	if left.Type() == interpreter.ERR {
+		return left
+	}
+	var result interpreter.Item
+	for i, op := range ce.Ops {
+		right := Evaluate(ce.Comparators[i], env)
+		if right.Type() == interpreter.ERR {
+			return right
+		}
+		switch op {
+		case "is":
+			result = nativeBool(identical(left, right))
+		case "is not":
+			result = nativeBool(!identical(left, right))
+		case "in", "not in":
+			found, err := contains(right, left, env)
+			if err != nil {
+				return err
+			}
+			result = nativeBool(found == (op == "in"))
+		default:
+			result = evaluateInfixExpr(op, left, right, env)
+		}
+		if result.Type() == interpreter.ERR || !isTrue(result) {
+			return result
+		}
+		left = right
+	}
+	return result
+}
+
+// identical reports whether l and r are the same object, as `is` tests.
+// Like CPython, which caches small ints, ints from -5 to 256 are the same
+// object whenever their values are equal.
+func identical(l interpreter.Item, r interpreter.Item) bool {
+	if left, ok := l.(*interpreter.Int); ok {
+		if right, ok := r.(*interpreter.Int); ok && left.Val == right.Val && left.Val >= -5 && left.Val <= 256 {
+			return true
+		}
+	}
+	return l == r
+}
+
+// intOverflow is raised by int arithmetic whose result doesn't fit the 64
+// bits ints are held in.
+func intOverflow() *interpreter.Error {
+	return newException(overflowErrorClass, "int too large to fit in 64 bits")
+}
+
+// addInt, subInt and mulInt return a op b, or false when it overflows.
+func addInt(a, b int64) (int64, bool) {
+	c := a + b
+	return c, (c > a) == (b > 0)
+}
+
+func subInt(a, b int64) (int64, bool) {
+	c := a - b
+	return c, (c < a) == (b > 0)
+}
+
+func mulInt(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) || c/b != a {
+		return 0, false
+	}
+	return c, true
+}
+
+// checkedInt returns n as an Int, or raises OverflowError if it overflowed.
+func checkedInt(n int64, ok bool) interpreter.Item {
+	if !ok {
+		return intOverflow()
+	}
+	return &interpreter.Int{Val: n}
+}
+
+func evaluateIntInfixExpr(op string, l interpreter.Item, r interpreter.Item) interpreter.Item {
+	left := l.(*interpreter.Int).Val
+	right := r.(*interpreter.Int).Val
+	switch op {
+	case "+":
+		return checkedInt(addInt(left, right))
+	case "-":
+		return checkedInt(subInt(left, right))
+	case "*":
+		return checkedInt(mulInt(left, right))
+	case "/":
+		// True division always gives a float, as in Python 3.
+		if right == 0 {
+			return newException(zeroDivisionErrorClass, "division by zero")
+		}
+		return &interpreter.Float{Val: float64(left) / float64(right)}
+	case "//":
+		if right == 0 {
+			return newException(zeroDivisionErrorClass, "integer division or modulo by zero")
+		}
+		if left == math.MinInt64 && right == -1 {
+			return intOverflow()
+		}
+		quotient := left / right
+		if (left%right != 0) && ((left < 0) != (right < 0)) {
+			quotient--
+		}
+		return &interpreter.Int{Val: quotient}
+	case "%":
+		if right == 0 {
+			return newException(zeroDivisionErrorClass, "integer division or modulo by zero")
+		}
+		// The remainder takes the sign of the divisor, as in Python.
+		remainder := left % right
+		if remainder != 0 && (remainder < 0) != (right < 0) {
+			remainder += right
+		}
+		return &interpreter.Int{Val: remainder}
+	case "**":
+		if right < 0 {
+			return evaluateFloatInfixExpr(op, float64(left), float64(right))
+		}
+		// The base is only squared while bits of the exponent remain, so
+		// that overflowing a square nothing uses isn't an error.
+		result, ok := int64(1), true
+		for base := left; ; {
+			if right&1 == 1 {
+				if result, ok = mulInt(result, base); !ok {
+					return intOverflow()
+				}
+			}
+			if right >>= 1; right == 0 {
+				break
+			}
+			if base, ok = mulInt(base, base); !ok {
+				return intOverflow()
+			}
+		}
+		return &interpreter.Int{Val: result}
+	case "|":
+		return &interpreter.Int{Val: left | right}
+	case "^":
+		return &interpreter.Int{Val: left ^ right}
+	case "&":
+		return &interpreter.Int{Val: left & right}
+	case "<<", ">>":
+		if right < 0 {
+			return newException(valueErrorClass, "negative shift count")
+		}
+		if op == "<<" {
+			if left == 0 {
+				return &interpreter.Int{Val: 0}
+			}
+			if right >= 64 || left<<uint64(right)>>uint64(right) != left {
+				return intOverflow()
+			}
+			return &interpreter.Int{Val: left << uint64(right)}
+		}
+		return &interpreter.Int{Val: left >> uint64(right)}
+	case "<":
+		if left < right {
+			return TRUE
+		} else {
+			return FALSE
+		}
+	case ">":
+		if left > right {
+			return TRUE
+		} else {
+			return FALSE
+		}
+	case "<=":
+		return nativeBool(left <= right)
+	case ">=":
+		return nativeBool(left >= right)
+	case "==":
+		if left == right {
+			return TRUE
+		} else {
+			return FALSE
+		}
+	case "!=":
+		if left != right {
+			return TRUE
+		} else {
+			return FALSE
+		}
+	default:
+		return unsupportedOperands(op, l, r)
+	}
+}
+
+// promote returns the value of an int or float operand as a float, for
+// arithmetic mixing the two.
+func promote(item interpreter.Item) float64 {
+	if i, ok := item.(*interpreter.Int); ok {
+		return float64(i.Val)
+	}
+	return item.(*interpreter.Float).Val
+}
+
+func evaluateFloatInfixExpr(op string, left float64, right float64) interpreter.Item {
+	switch op {
+	case "+":
+		return &interpreter.Float{Val: left + right}
+	case "-":
+		return &interpreter.Float{Val: left - right}
+	case "*":
+		return &interpreter.Float{Val: left * right}
+	case "/":
+		if right == 0 {
+			return newException(zeroDivisionErrorClass, "float division by zero")
+		}
+		return &interpreter.Float{Val: left / right}
+	case "//":
+		if right == 0 {
+			return newException(zeroDivisionErrorClass, "float floor division by zero")
+		}
+		return &interpreter.Float{Val: math.Floor(left / right)}
+	case "%":
+		if right == 0 {
+			return newException(zeroDivisionErrorClass, "float modulo")
+		}
+		remainder := math.Mod(left, right)
+		if remainder != 0 && (remainder < 0) != (right < 0) {
+			remainder += right
+		}
+		return &interpreter.Float{Val: remainder}
+	case "**":
+		if left == 0 && right < 0 {
+			return newException(zeroDivisionErrorClass, "0.0 cannot be raised to a negative power")
+		}
+		if left < 0 && right != math.Trunc(right) {
+			return newException(valueErrorClass, "negative number cannot be raised to a fractional power")
+		}
+		return &interpreter.Float{Val: math.Pow(left, right)}
+	case "<":
+		return nativeBool(left < right)
+	case ">":
+		return nativeBool(left > right)
+	case "<=":
+		return nativeBool(left <= right)
+	case ">=":
+		return nativeBool(left >= right)
+	case "==":
+		return nativeBool(left == right)
+	case "!=":
+		return nativeBool(left != right)
+	default:
+		return newException(typeErrorClass, "unsupported operand type(s) for %s: 'float' and 'float'", op)
+	}
+}
+
+// repeatStr returns s repeated n times, or the empty string if n is not
+// positive.
+func repeatStr(s *interpreter.Str, n *interpreter.Int, env *interpreter.Environment) interpreter.Item {
+	if n.Val <= 0 {
+		return &interpreter.Str{Val: ""}
+	}
+	if err := allocateRepeat(len(s.Val), n.Val, env); err != nil {
+		return err
+	}
+	return &interpreter.Str{Val: strings.Repeat(s.Val, int(n.Val))}
+}
+
+func evaluateStrInfixExpr(op string, l interpreter.Item, r interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	left := l.Visit()
+	right := r.Visit()
+	sameType := l.Type() == r.Type()
+	switch op {
+	case "+":
+		if l.Type() != interpreter.STR {
+			return unsupportedOperands(op, l, r)
+		}
+		if !sameType {
+			return newException(typeErrorClass, "can only concatenate str (not \"%s\") to str", typeName(r))
+		}
+		if err := allocate(int64(len(left) + len(right)), env); err != nil {
+			return err
+		}
+		return &interpreter.Str{Val: left + right}
+	case "*":
+		if sameType {
+			return newException(typeErrorClass, "can't multiply sequence by non-int of type '%s'", typeName(r))
+		}
+		if l.Type() == interpreter.INT {
+			return repeatStr(r.(*interpreter.Str), l.(*interpreter.Int), env)
+		}
+		return repeatStr(l.(*interpreter.Str), r.(*interpreter.Int), env)
+	case "==":
+		return nativeBool(sameType && left == right)
+	case "!=":
+		return nativeBool(!sameType || left != right)
+	case "<", ">", "<=", ">=":
+		if !sameType {
+			return unsupportedOperands(op, l, r)
+		}
+		switch op {
+		case "<":
+			return nativeBool(left < right)
+		case ">":
+			return nativeBool(left > right)
+		case "<=":
+			return nativeBool(left <= right)
+		}
+		return nativeBool(left >= right)
+	default:
+		return unsupportedOperands(op, l, r)
+	}
+}
+
+func evaluateDictLiteral(dl *ast.DictLiteral, env *interpreter.Environment) interpreter.Item {
+	dict := interpreter.NewDict()
+	for i, keyExpr := range dl.Keys {
+		key := Evaluate(keyExpr, env)
+		if key.Type() == interpreter.ERR {
+			return key
+		}
+		value := Evaluate(dl.Values[i], env)
+		if value.Type() == interpreter.ERR {
+			return value
+		}
+		if err := dictSet(dict, key, value, env); err != nil {
+			return err
+		}
+	}
+	return dict
+}
+
+func evaluateSliceExpr(se *ast.SliceExpr, env *interpreter.Environment) interpreter.Item {
+	slice := &interpreter.Slice{}
+	bounds := []*interpreter.Item{&slice.Start, &slice.Stop, &slice.Step}
+	for i, expr := range []ast.Expr{se.Start, se.Stop, se.Step} {
+		if expr == nil {
+			continue
+		}
+		bound := Evaluate(expr, env)
+		if bound.Type() == interpreter.ERR {
+			return bound
+		}
+		if bound.Type() != interpreter.INT {
+			return newException(typeErrorClass, "slice indices must be integers, not %s", typeName(bound))
+		}
+		*bounds[i] = bound
+	}
+	return slice
+}
+
+func evaluateIndexExpr(left interpreter.Item, index interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	if slice, ok := index.(*interpreter.Slice); ok {
+		return evaluateSlice(left, slice)
+	}
+	switch left := left.(type) {
+	case *interpreter.List:
+		i, err := sequenceIndex(left, index, len(left.Elements))
+		if err != nil {
+			return err
+		}
+		return left.Elements[i]
+	case *interpreter.Tuple:
+		i, err := sequenceIndex(left, index, len(left.Elements))
+		if err != nil {
+			return err
+		}
+		return left.Elements[i]
+	case *interpreter.Range:
+		i, err := sequenceIndex(left, index, int(left.Len()))
+		if err != nil {
+			return err
+		}
+		return &interpreter.Int{Val: left.At(int64(i))}
+	case *interpreter.Str:
+		runes := []rune(left.Val)
+		i, err := sequenceIndex(left, index, len(runes))
+		if err != nil {
+			return err
+		}
+		return &interpreter.Str{Val: string(runes[i])}
+	case *interpreter.Bytes:
+		i, err := sequenceIndex(left, index, len(left.Val))
+		if err != nil {
+			return err
+		}
+		return &interpreter.Int{Val: int64(left.Val[i])}
+	case *interpreter.Dict:
+		hash, found, err := dictKey(left, index, env)
+		if err != nil {
+			return err
+		}
+		if found {
+			val, _ := left.Get(hash)
+			return val
+		}
+		return newException(keyErrorClass, "%s", index.Visit())
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, left, "__getitem__", index); ok {
+			return result
+		}
+		return newException(typeErrorClass, "'%s' object is not subscriptable", typeName(left))
 	default:
+		return newException(typeErrorClass, "'%s' object is not subscriptable", typeName(left))
+	}
+}
+
+func evaluateIndexAssignStmt(ia *ast.IndexAssignStmt, env *interpreter.Environment) interpreter.Item {
+	left := Evaluate(ia.Target.Left, env)
+	if left.Type() == interpreter.ERR {
+		return left
+	}
+	index := Evaluate(ia.Target.Index, env)
+	if index.Type() == interpreter.ERR {
+		return index
+	}
+	val := Evaluate(ia.Value, env)
+	if val.Type() == interpreter.ERR {
+		return val
+	}
+	return setIndex(left, index, val, env)
+}
+
+// assign binds val to an assignment target, unpacking it across tuple and
+// list targets.
+func assign(target ast.Expr, val interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		return env.Store(target.Val, val)
+	case *ast.IndexExpr:
+		left := Evaluate(target.Left, env)
+		if left.Type() == interpreter.ERR {
+			return left
+		}
+		index := Evaluate(target.Index, env)
+		if index.Type() == interpreter.ERR {
+			return index
+		}
+		return setIndex(left, index, val, env)
+	case *ast.AttributeExpr:
+		obj := Evaluate(target.Object, env)
+		if obj.Type() == interpreter.ERR {
+			return obj
+		}
+		return setAttr(obj, target.Attr.Val, val)
+	case *ast.TupleLiteral:
+		return unpack(target.Elements, val, env)
+	case *ast.ListLiteral:
+		return unpack(target.Elements, val, env)
+	default:
+		return newErr("cannot assign to %s", target.String())
+	}
+}
+
+func unpack(targets []ast.Expr, val interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	if !isIterable(val) {
+		return newException(typeErrorClass, "cannot unpack non-iterable %s object", typeName(val))
+	}
+	star := -1
+	for i, target := range targets {
+		if _, ok := target.(*ast.StarredExpr); ok {
+			star = i
+		}
+	}
+	items, err := unpackItems(val, star < 0, len(targets), env)
+	if err != nil {
+		return err
+	}
+	switch {
+	case star >= 0 && len(items) < len(targets)-1:
+		return newException(valueErrorClass, "not enough values to unpack (expected at least %d, got %d)", len(targets)-1, len(items))
+	case star >= 0:
+	case len(items) > len(targets):
+		return newException(valueErrorClass, "too many values to unpack (expected %d)", len(targets))
+	case len(items) < len(targets):
+		return newException(valueErrorClass, "not enough values to unpack (expected %d, got %d)", len(targets), len(items))
+	}
+	for i, target := range targets {
+		var result interpreter.Item
+		switch {
+		case star < 0 || i < star:
+			result = assign(target, items[i], env)
+		case i == star:
+			rest := items[star : len(items)-(len(targets)-star-1)]
+			list := &interpreter.List{Elements: append([]interpreter.Item{}, rest...)}
+			result = assign(target.(*ast.StarredExpr).Value, list, env)
+		default:
+			result = assign(target, items[len(items)-(len(targets)-i)], env)
+		}
+		if result.Type() == interpreter.ERR {
+			return result
+		}
+	}
+	return val
+}
+
+// unpackItems returns the items of val to unpack into n targets. Without
+// a starred target one item more than n shows there are too many, so no
+// more are taken, and endless iterators and huge ranges can be unpacked
+// in constant time.
+func unpackItems(val interpreter.Item, exact bool, n int, env *interpreter.Environment) ([]interpreter.Item, *interpreter.Error) {
+	if !exact {
+		return iterate(val, env)
+	}
+	step, err := iterator(val, env)
+	if err != nil {
+		return nil, err
+	}
+	var items []interpreter.Item
+	for len(items) <= n {
+		item, ok := step()
+		if !ok {
+			break
+		}
+		if err, ok := item.(*interpreter.Error); ok {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// forEach calls fn with the items of val one at a time, as iterator
+// produces them, so that builtins reducing ranges and iterators to one
+// value never hold all their items. It stops at the first error, whether
+// raised producing an item or returned by fn.
+func forEach(val interpreter.Item, env *interpreter.Environment, fn func(item interpreter.Item) *interpreter.Error) *interpreter.Error {
+	step, err := iterator(val, env)
+	if err != nil {
+		return err
+	}
+	for item, ok := step(); ok; item, ok = step() {
+		if err, ok := item.(*interpreter.Error); ok {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iterate returns all the items of an iterable, charging for the slice
+// holding them. Builtin containers are copied directly; anything else is
+// drained through iterator, charging for each item as it comes so that
+// endless iterators run out of memory budget.
+func iterate(val interpreter.Item, env *interpreter.Environment) ([]interpreter.Item, *interpreter.Error) {
+	if r, ok := val.(*interpreter.Range); ok {
+		if err := allocateRepeat(slotSize, r.Len(), env); err != nil {
+			return nil, err
+		}
+		items := make([]interpreter.Item, r.Len())
+		for i := range items {
+			if err := stepInterrupted(env); err != nil {
+				return nil, err
+			}
+			items[i] = &interpreter.Int{Val: r.At(int64(i))}
+		}
+		return items, nil
+	}
+	if items, ok := containerItems(val); ok {
+		if err := allocateRepeat(slotSize, int64(len(items)), env); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+	next, err := iterator(val, env)
+	if err != nil {
+		return nil, err
+	}
+	var items []interpreter.Item
+	for item, ok := next(); ok; item, ok = next() {
+		if err, ok := item.(*interpreter.Error); ok {
+			return nil, err
+		}
+		if err := allocate(slotSize, env); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// containerItems returns a copy of the items of a builtin container, or
+// false for anything else. Unlike iterate it charges nothing, since for
+// loops take their copy with it.
+func containerItems(val interpreter.Item) ([]interpreter.Item, bool) {
+	switch val := val.(type) {
+	case *interpreter.List:
+		return append([]interpreter.Item{}, val.Elements...), true
+	case *interpreter.Tuple:
+		return append([]interpreter.Item{}, val.Elements...), true
+	case *interpreter.Set:
+		return val.Elements(), true
+	case *interpreter.Str:
+		var items []interpreter.Item
+		for _, r := range val.Val {
+			items = append(items, &interpreter.Str{Val: string(r)})
+		}
+		return items, true
+	case *interpreter.Bytes:
+		items := make([]interpreter.Item, len(val.Val))
+		for i, b := range val.Val {
+			items[i] = &interpreter.Int{Val: int64(b)}
+		}
+		return items, true
+	case *interpreter.Dict:
+		var items []interpreter.Item
+		for _, hash := range val.Keys {
+			items = append(items, val.Pairs[hash].Key)
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+// iterator returns a function producing the items of val one at a time,
+// and false once they run out. An error raised while producing an item is
+// returned as the item. This is the iteration protocol shared by for
+// loops, unpacking and the builtins taking iterables: ranges, generators,
+// builtin iterators and instances are stepped on demand, and builtin
+// containers are copied by iterate up front.
+//
+// An instance is iterable if its class defines __iter__, returning an
+// iterator whose __next__ raises StopIteration at the end, or else
+// __getitem__, which is called with 0, 1, 2, ... until it raises
+// IndexError.
+//
+// Each item first checks whether the evaluation has been cancelled, since
+// builtins stepping through an iterable run no statements that would.
+func iterator(val interpreter.Item, env *interpreter.Environment) (func() (interpreter.Item, bool), *interpreter.Error) {
+	step, err := iteratorOf(val, env)
+	if err != nil {
+		return nil, err
+	}
+	return func() (interpreter.Item, bool) {
+		if err := stepInterrupted(env); err != nil {
+			return err, true
+		}
+		return step()
+	}, nil
+}
+
+// iteratorOf returns the function iterator checks for cancellation.
+func iteratorOf(val interpreter.Item, env *interpreter.Environment) (func() (interpreter.Item, bool), *interpreter.Error) {
+	switch val := val.(type) {
+	case *interpreter.Iterator:
+		return val.Next, nil
+	case *interpreter.Generator, *interpreter.File:
+		return stepper(val, env), nil
+	case *interpreter.Range:
+		i, n := int64(0), val.Len()
+		return func() (interpreter.Item, bool) {
+			if i >= n {
+				return nil, false
+			}
+			i++
+			return &interpreter.Int{Val: val.At(i - 1)}, true
+		}, nil
+	case *interpreter.Instance:
+		if it, ok := callMethod(env, val, "__iter__"); ok {
+			if err, ok := it.(*interpreter.Error); ok {
+				return nil, err
+			}
+			if !isIterator(it) {
+				return nil, newException(typeErrorClass, "iter() returned non-iterator of type '%s'", typeName(it))
+			}
+			return stepper(it, env), nil
+		}
+		if _, ok := val.Class.Lookup("__getitem__"); ok {
+			i := int64(0)
+			return func() (interpreter.Item, bool) {
+				item, _ := callMethod(env, val, "__getitem__", &interpreter.Int{Val: i})
+				if err, ok := item.(*interpreter.Error); ok && isSubclass(exceptionOf(err).Class, indexErrorClass) {
+					return nil, false
+				}
+				i++
+				return item, true
+			}, nil
+		}
+		return nil, newException(typeErrorClass, "'%s' object is not iterable", typeName(val))
+	case *interpreter.List, *interpreter.Tuple, *interpreter.Str, *interpreter.Bytes, *interpreter.Dict, *interpreter.Set:
+	default:
+		return nil, newException(typeErrorClass, "'%s' object is not iterable", typeName(val))
+	}
+	items, _ := containerItems(val)
+	return func() (interpreter.Item, bool) {
+		if len(items) == 0 {
+			return nil, false
+		}
+		item := items[0]
+		items = items[1:]
+		return item, true
+	}, nil
+}
+
+// contains reports whether x is in container, as the in operator tests:
+// strs hold their substrings, dicts and sets their keys, and other
+// containers their items. Instances may define __contains__, or else are
+// searched by iterating over them.
+func contains(container interpreter.Item, x interpreter.Item, env *interpreter.Environment) (bool, *interpreter.Error) {
+	switch c := container.(type) {
+	case *interpreter.Str:
+		sub, ok := x.(*interpreter.Str)
+		if !ok {
+			return false, newException(typeErrorClass, "'in <string>' requires string as left operand, not %s", typeName(x))
+		}
+		return strings.Contains(c.Val, sub.Val), nil
+	case *interpreter.Bytes:
+		switch x := x.(type) {
+		case *interpreter.Bytes:
+			return bytes.Contains(c.Val, x.Val), nil
+		case *interpreter.Int:
+			if x.Val < 0 || x.Val > 255 {
+				return false, newException(valueErrorClass, "byte must be in range(0, 256)")
+			}
+			return bytes.IndexByte(c.Val, byte(x.Val)) >= 0, nil
+		}
+		return false, newException(typeErrorClass, "a bytes-like object is required, not '%s'", typeName(x))
+	case *interpreter.Dict:
+		_, found, err := dictKey(c, x, env)
+		return found, err
+	case *interpreter.Set:
+		_, found, err := setKey(c, x, env)
+		return found, err
+	case *interpreter.Range:
+		if n, ok := boolToInt(x).(*interpreter.Int); ok {
+			offset := n.Val - c.Start
+			inside := offset >= 0 && n.Val < c.Stop
+			if c.Step < 0 {
+				inside = offset <= 0 && n.Val > c.Stop
+			}
+			return inside && offset%c.Step == 0, nil
+		}
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, c, "__contains__", x); ok {
+			if err, ok := result.(*interpreter.Error); ok {
+				return false, err
+			}
+			return isTrue(result), nil
+		}
+	}
+	if !isIterable(container) {
+		return false, newException(typeErrorClass, "argument of type '%s' is not iterable", typeName(container))
+	}
+	// Iterators are consumed up to the item found.
+	step, err := iterator(container, env)
+	if err != nil {
+		return false, err
+	}
+	for item, ok := step(); ok; item, ok = step() {
+		if err, isErr := item.(*interpreter.Error); isErr {
+			return false, err
+		}
+		if equal, err := itemsEqual(item, x, env); err != nil || equal {
+			return equal, err
+		}
+	}
+	return false, nil
+}
+
+// stepper returns a function advancing an iterator object with next,
+// reporting false once it raises StopIteration.
+func stepper(it interpreter.Item, env *interpreter.Environment) func() (interpreter.Item, bool) {
+	return func() (interpreter.Item, bool) {
+		item := next(it, env)
+		if err, ok := item.(*interpreter.Error); ok && isSubclass(exceptionOf(err).Class, stopIterationClass) {
+			return nil, false
+		}
+		return item, true
+	}
+}
+
+// isIterator reports whether next can advance it.
+func isIterator(it interpreter.Item) bool {
+	switch it := it.(type) {
+	case *interpreter.Generator, *interpreter.Iterator, *interpreter.File:
+		return true
+	case *interpreter.Instance:
+		_, ok := it.Class.Lookup("__next__")
+		return ok
+	}
+	return false
+}
+
+// isIterable reports whether iterator accepts val.
+func isIterable(val interpreter.Item) bool {
+	switch val := val.(type) {
+	case *interpreter.List, *interpreter.Tuple, *interpreter.Str, *interpreter.Bytes, *interpreter.Dict, *interpreter.Set, *interpreter.Range, *interpreter.Generator, *interpreter.Iterator, *interpreter.File:
+		return true
+	case *interpreter.Instance:
+		for _, name := range []string{"__iter__", "__getitem__"} {
+			if _, ok := val.Class.Lookup(name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func setIndex(left interpreter.Item, index interpreter.Item, val interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	switch left := left.(type) {
+	case *interpreter.List:
+		if slice, ok := index.(*interpreter.Slice); ok {
+			if err := setSlice(left, slice, val, env); err != nil {
+				return err
+			}
+			return val
+		}
+		i, err := sequenceIndex(left, index, len(left.Elements))
+		if err != nil {
+			return err
+		}
+		left.Elements[i] = val
+	case *interpreter.Dict:
+		if err := dictSet(left, index, val, env); err != nil {
+			return err
+		}
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, left, "__setitem__", index, val); !ok {
+			return newException(typeErrorClass, "'%s' object does not support item assignment", typeName(left))
+		} else if result.Type() == interpreter.ERR {
+			return result
+		}
+	default:
+		return newException(typeErrorClass, "'%s' object does not support item assignment", typeName(left))
+	}
+	return val
+}
+
+// setSlice replaces the items of list selected by slice with the items of
+// val. A simple slice may change the length of the list, while an extended
+// slice must be given exactly as many items as it selects.
+func setSlice(list *interpreter.List, slice *interpreter.Slice, val interpreter.Item, env *interpreter.Environment) *interpreter.Error {
+	if !isIterable(val) {
+		return newException(typeErrorClass, "can only assign an iterable")
+	}
+	items, err := iterate(val, env)
+	if err != nil {
+		return err
+	}
+	n := len(list.Elements)
+	indices, err := sliceIndices(slice, n)
+	if err != nil {
+		return err
+	}
+	if slice.Step != nil && slice.Step.(*interpreter.Int).Val != 1 {
+		if len(items) != len(indices) {
+			return newException(valueErrorClass, "attempt to assign sequence of size %d to extended slice of size %d", len(items), len(indices))
+		}
+		for j, i := range indices {
+			list.Elements[i] = items[j]
+		}
+		return nil
+	}
+	// An empty slice still marks where the items are inserted.
+	start := 0
+	if slice.Start != nil {
+		start = int(slice.Start.(*interpreter.Int).Val)
+		if start < 0 {
+			start += n
+		}
+		start = int(math.Max(0, math.Min(float64(start), float64(n))))
+	}
+	stop := start
+	if len(indices) > 0 {
+		start, stop = indices[0], indices[len(indices)-1]+1
+	}
+	elements := append([]interpreter.Item{}, list.Elements[:start]...)
+	elements = append(elements, items...)
+	list.Elements = append(elements, list.Elements[stop:]...)
+	return nil
+}
+
+// deleteTarget unbinds a del target, returning an error item on failure
+// and nil otherwise.
+func deleteTarget(target ast.Expr, env *interpreter.Environment) interpreter.Item {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		if !env.Delete(target.Val) {
+			return newException(nameErrorClass, "name '%s' is not defined", target.Val)
+		}
+	case *ast.IndexExpr:
+		left := Evaluate(target.Left, env)
+		if left.Type() == interpreter.ERR {
+			return left
+		}
+		index := Evaluate(target.Index, env)
+		if index.Type() == interpreter.ERR {
+			return index
+		}
+		return deleteIndex(left, index, env)
+	case *ast.AttributeExpr:
+		obj := Evaluate(target.Object, env)
+		if obj.Type() == interpreter.ERR {
+			return obj
+		}
+		return delAttr(obj, target.Attr.Val)
+	case *ast.TupleLiteral:
+		for _, element := range target.Elements {
+			if result := deleteTarget(element, env); result != nil {
+				return result
+			}
+		}
+	case *ast.ListLiteral:
+		for _, element := range target.Elements {
+			if result := deleteTarget(element, env); result != nil {
+				return result
+			}
+		}
+	default:
+		return newErr("cannot delete %s", target.String())
+	}
+	return nil
+}
+
+func deleteIndex(left interpreter.Item, index interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	switch left := left.(type) {
+	case *interpreter.List:
+		if slice, ok := index.(*interpreter.Slice); ok {
+			indices, err := sliceIndices(slice, len(left.Elements))
+			if err != nil {
+				return err
+			}
+			remove := make(map[int]bool)
+			for _, i := range indices {
+				remove[i] = true
+			}
+			elements := []interpreter.Item{}
+			for i, element := range left.Elements {
+				if !remove[i] {
+					elements = append(elements, element)
+				}
+			}
+			left.Elements = elements
+			return nil
+		}
+		i, err := sequenceIndex(left, index, len(left.Elements))
+		if err != nil {
+			return err
+		}
+		left.Elements = append(left.Elements[:i], left.Elements[i+1:]...)
+	case *interpreter.Dict:
+		hash, found, err := dictKey(left, index, env)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return newException(keyErrorClass, "%s", index.Visit())
+		}
+		left.Delete(hash)
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, left, "__delitem__", index); !ok {
+			return newException(typeErrorClass, "'%s' object doesn't support item deletion", typeName(left))
+		} else if result.Type() == interpreter.ERR {
+			return result
+		}
+	default:
+		return newException(typeErrorClass, "'%s' object doesn't support item deletion", typeName(left))
+	}
+	return nil
+}
+
+// evaluateAugAssignStmt applies the operator to the target's current value
+// and stores the result back, evaluating the target's subexpressions once.
+func evaluateAugAssignStmt(as *ast.AugAssignStmt, env *interpreter.Environment) interpreter.Item {
+	switch target := as.Target.(type) {
+	case *ast.Identifier:
+		current := evaluateIdent(target, env)
+		if current.Type() == interpreter.ERR {
+			return current
+		}
+		val := Evaluate(as.Value, env)
+		if val.Type() == interpreter.ERR {
+			return val
+		}
+		result := evaluateInfixExpr(as.Op, current, val, env)
+		if result.Type() == interpreter.ERR {
+			return result
+		}
+		return env.Store(target.Val, result)
+	case *ast.IndexExpr:
+		left := Evaluate(target.Left, env)
+		if left.Type() == interpreter.ERR {
+			return left
+		}
+		index := Evaluate(target.Index, env)
+		if index.Type() == interpreter.ERR {
+			return index
+		}
+		current := evaluateIndexExpr(left, index, env)
+		if current.Type() == interpreter.ERR {
+			return current
+		}
+		val := Evaluate(as.Value, env)
+		if val.Type() == interpreter.ERR {
+			return val
+		}
+		result := evaluateInfixExpr(as.Op, current, val, env)
+		if result.Type() == interpreter.ERR {
+			return result
+		}
+		return setIndex(left, index, result, env)
+	case *ast.AttributeExpr:
+		obj := Evaluate(target.Object, env)
+		if obj.Type() == interpreter.ERR {
+			return obj
+		}
+		current := getAttr(obj, target.Attr.Val)
+		if current.Type() == interpreter.ERR {
+			return current
+		}
+		val := Evaluate(as.Value, env)
+		if val.Type() == interpreter.ERR {
+			return val
+		}
+		result := evaluateInfixExpr(as.Op, current, val, env)
+		if result.Type() == interpreter.ERR {
+			return result
+		}
+		return setAttr(obj, target.Attr.Val, result)
+	default:
+		return newErr("illegal target for augmented assignment: %s", as.Target.String())
+	}
+}
+
+func evaluateSlice(left interpreter.Item, slice *interpreter.Slice) interpreter.Item {
+	switch left := left.(type) {
+	case *interpreter.List:
+		indices, err := sliceIndices(slice, len(left.Elements))
+		if err != nil {
+			return err
+		}
+		elements := []interpreter.Item{}
+		for _, i := range indices {
+			elements = append(elements, left.Elements[i])
+		}
+		return &interpreter.List{Elements: elements}
+	case *interpreter.Tuple:
+		indices, err := sliceIndices(slice, len(left.Elements))
+		if err != nil {
+			return err
+		}
+		elements := []interpreter.Item{}
+		for _, i := range indices {
+			elements = append(elements, left.Elements[i])
+		}
+		return &interpreter.Tuple{Elements: elements}
+	case *interpreter.Str:
+		runes := []rune(left.Val)
+		indices, err := sliceIndices(slice, len(runes))
+		if err != nil {
+			return err
+		}
+		var result []rune
+		for _, i := range indices {
+			result = append(result, runes[i])
+		}
+		return &interpreter.Str{Val: string(result)}
+	case *interpreter.Bytes:
+		indices, err := sliceIndices(slice, len(left.Val))
+		if err != nil {
+			return err
+		}
+		result := make([]byte, len(indices))
+		for j, i := range indices {
+			result[j] = left.Val[i]
+		}
+		return &interpreter.Bytes{Val: result}
+	default:
+		return newException(typeErrorClass, "'%s' object is not subscriptable", typeName(left))
+	}
+}
+
+// sliceIndices returns the positions selected by slice in a sequence of the
+// given length, clamping out of range bounds like Python does.
+func sliceIndices(slice *interpreter.Slice, length int) ([]int, *interpreter.Error) {
+	step := 1
+	if slice.Step != nil {
+		step = int(slice.Step.(*interpreter.Int).Val)
+	}
+	if step == 0 {
+		return nil, newException(valueErrorClass, "slice step cannot be zero")
+	}
+	bound := func(item interpreter.Item, def int) int {
+		if item == nil {
+			return def
+		}
+		i := int(item.(*interpreter.Int).Val)
+		if i < 0 {
+			i += length
+			if i < 0 {
+				if step < 0 {
+					return -1
+				}
+				return 0
+			}
+		} else if i >= length {
+			if step < 0 {
+				return length - 1
+			}
+			return length
+		}
+		return i
+	}
+	var start, stop int
+	if step > 0 {
+		start, stop = bound(slice.Start, 0), bound(slice.Stop, length)
+	} else {
+		start, stop = bound(slice.Start, length-1), bound(slice.Stop, -1)
+	}
+	var indices []int
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		indices = append(indices, i)
+	}
+	return indices, nil
+}
+
+// sequenceIndex converts index into a position within a sequence of the
+// given length, counting negative indices from the end.
+func sequenceIndex(seq interpreter.Item, index interpreter.Item, length int) (int, *interpreter.Error) {
+	idx, ok := index.(*interpreter.Int)
+	if !ok {
+		return 0, newException(typeErrorClass, "%s indices must be integers, not %s", typeName(seq), typeName(index))
+	}
+	i := int(idx.Val)
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i >= length {
+		return 0, newException(indexErrorClass, "%s index out of range", typeName(seq))
+	}
+	return i, nil
+}
+
+// TypeName returns the name scripts know item's type by, as in their
+// error messages.
+func TypeName(item interpreter.Item) string {
+	return typeName(item)
+}
+
+func typeName(item interpreter.Item) string {
+	if instance, ok := item.(*interpreter.Instance); ok {
+		return instance.Class.Name
+	}
+	if item.Type() == interpreter.NONE {
+		return "NoneType"
+	}
+	return strings.ToLower(string(item.Type()))
+}
+
+func evaluateIfExpr(ie *ast.IfExpr, env *interpreter.Environment) interpreter.Item {
+	cond := Evaluate(ie.Cond, env)
+	if cond.Type() == interpreter.ERR {
+		return cond
+	}
+	if isTrue(cond) {
+		return Evaluate(ie.Pass, env)
+	} else if ie.Fail != nil {
+		return Evaluate(ie.Fail, env)
+	} else {
+		return nil
+	}
+}
+
+// SetMaxLoopIterations bounds the number of times a single while loop in
+// the interpreter env belongs to may run its body before a RuntimeError is
+// raised. Zero means no limit.
+func SetMaxLoopIterations(env *interpreter.Environment, n int) {
+	stateOf(env).maxLoops = n
+}
+
+// evaluateWhileExpr runs the body for as long as the condition holds, then
+// the else clause if the loop was not ended by break.
+func evaluateWhileExpr(we *ast.WhileExpr, env *interpreter.Environment) interpreter.Item {
+	limit := stateOf(env).maxLoops
+	for i := 0; ; i++ {
+		cond := Evaluate(we.Cond, env)
+		if cond.Type() == interpreter.ERR {
+			return cond
+		}
+		if !isTrue(cond) {
+			break
+		}
+		if limit > 0 && i >= limit {
+			return newException(runtimeErrorClass, "while loop exceeded %d iterations", limit)
+		}
+		result := Evaluate(we.Body, env)
+		if result == nil {
+			continue
+		}
+		switch result.Type() {
+		case interpreter.BREAK:
+			return nil
+		case interpreter.ERR, interpreter.RETURN:
+			return result
+		}
+	}
+	if we.Else != nil {
+		return Evaluate(we.Else, env)
+	}
+	return nil
+}
+
+// evaluateForStmt runs the body once per item of the iterable, then the
+// else clause if the loop was not ended by break.
+func evaluateForStmt(fs *ast.ForStmt, env *interpreter.Environment) interpreter.Item {
+	iterable := Evaluate(fs.Iter, env)
+	if iterable.Type() == interpreter.ERR {
+		return iterable
+	}
+	next, err := iterator(iterable, env)
+	if err != nil {
+		return err
+	}
+	for item, ok := next(); ok; item, ok = next() {
+		if item.Type() == interpreter.ERR {
+			return item
+		}
+		if result := assign(fs.Target, item, env); result.Type() == interpreter.ERR {
+			return result
+		}
+		result := Evaluate(fs.Body, env)
+		if result == nil {
+			continue
+		}
+		switch result.Type() {
+		case interpreter.BREAK:
+			return nil
+		case interpreter.ERR, interpreter.RETURN:
+			return result
+		}
+	}
+	if fs.Else != nil {
+		return Evaluate(fs.Else, env)
+	}
+	return nil
+}
+
+// evaluateMatchStmt runs the body of the first case whose pattern matches
+// the subject, after binding the names the pattern captured.
+func evaluateMatchStmt(ms *ast.MatchStmt, env *interpreter.Environment) interpreter.Item {
+	subject := Evaluate(ms.Subject, env)
+	if subject.Type() == interpreter.ERR {
+		return subject
+	}
+	for _, c := range ms.Cases {
+		bindings := map[string]interpreter.Item{}
+		matched, err := matchPattern(c.Pattern, subject, bindings, env)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		for name, val := range bindings {
+			env.Store(name, val)
+		}
+		return Evaluate(c.Body, env)
+	}
+	return nil
+}
+
+// matchPattern reports whether subject matches pattern, recording the
+// values of the names it captures in bindings.
+func matchPattern(pattern ast.Pattern, subject interpreter.Item, bindings map[string]interpreter.Item, env *interpreter.Environment) (bool, *interpreter.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return true, nil
+	case *ast.CapturePattern:
+		bindings[pattern.Name.Val] = subject
+		return true, nil
+	case *ast.LiteralPattern:
+		want := Evaluate(pattern.Value, env)
+		if err, ok := want.(*interpreter.Error); ok {
+			return false, err
+		}
+		return literalEqual(want, subject), nil
+	case *ast.SequencePattern:
+		// Like Python, strings are not matched as sequences.
+		switch subject := subject.(type) {
+		case *interpreter.List:
+			return matchSequence(pattern.Patterns, subject.Elements, bindings, env)
+		case *interpreter.Tuple:
+			return matchSequence(pattern.Patterns, subject.Elements, bindings, env)
+		}
+	}
+	return false, nil
+}
+
+// matchSequence matches elements against patterns, which contain at most
+// one star pattern collecting the elements the others leave over.
+func matchSequence(patterns []ast.Pattern, elements []interpreter.Item, bindings map[string]interpreter.Item, env *interpreter.Environment) (bool, *interpreter.Error) {
+	star := -1
+	for i, pattern := range patterns {
+		if _, ok := pattern.(*ast.StarPattern); ok {
+			star = i
+		}
+	}
+	if star < 0 && len(elements) != len(patterns) || len(elements) < len(patterns)-1 {
+		return false, nil
+	}
+	for i, pattern := range patterns {
+		if i == star {
+			continue
+		}
+		element := i
+		if star >= 0 && i > star {
+			element = len(elements) - (len(patterns) - i)
+		}
+		if matched, err := matchPattern(pattern, elements[element], bindings, env); !matched || err != nil {
+			return false, err
+		}
+	}
+	if star >= 0 {
+		if name := patterns[star].(*ast.StarPattern).Name.Val; name != "_" {
+			rest := elements[star : len(elements)-(len(patterns)-star-1)]
+			bindings[name] = &interpreter.List{Elements: append([]interpreter.Item{}, rest...)}
+		}
+	}
+	return true, nil
+}
+
+// literalEqual reports whether subject equals the value of a literal
+// pattern. Values of different types never match.
+func literalEqual(want interpreter.Item, subject interpreter.Item) bool {
+	switch want := want.(type) {
+	case *interpreter.Int:
+		got, ok := subject.(*interpreter.Int)
+		return ok && got.Val == want.Val
+	case *interpreter.Str:
+		got, ok := subject.(*interpreter.Str)
+		return ok && got.Val == want.Val
+	case *interpreter.Bool, *interpreter.None:
+		return subject == want
+	}
+	return false
+}
+
+// isTrue reports whether item counts as true in a condition: zero numbers,
+// empty strings and collections, False and None are false, and every other
+// object is true.
+func isTrue(item interpreter.Item) bool {
+	switch item := item.(type) {
+	case *interpreter.Bool:
+		return item.Val
+	case *interpreter.None:
 		return false
+	case *interpreter.Int:
+		return item.Val != 0
+	case *interpreter.Float:
+		return item.Val != 0
+	case *interpreter.Str:
+		return item.Val != ""
+	case *interpreter.Bytes:
+		return len(item.Val) > 0
+	case *interpreter.List:
+		return len(item.Elements) > 0
+	case *interpreter.Tuple:
+		return len(item.Elements) > 0
+	case *interpreter.Dict:
+		return len(item.Keys) > 0
+	case *interpreter.Set:
+		return len(item.Keys) > 0
+	case *interpreter.Range:
+		return item.Len() > 0
+	}
+	return true
+}
+
+func nativeBool(b bool) *interpreter.Bool {
+	if b {
+		return TRUE
 	}
+	return FALSE
 }
 
 func newErr(f string, e ...interface{}) *interpreter.Error {
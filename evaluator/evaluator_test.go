@@ -0,0 +1,2744 @@
+package evaluator
+
+import (
+	"bytes"
+	"context"
+	"gopy/ast"
+	"gopy/interpreter"
+	"gopy/parser"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEval(t *testing.T, input string) interpreter.Item {
+	p, program := parser.StartParseRepl(input)
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return Evaluate(&program, interpreter.NewEnv())
+}
+
+func TestIndexExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`[1, 2, 3][0]`, "1"},
+		{`[1, 2, 3][-1]`, "3"},
+		{`(4, 5)[1]`, "5"},
+		{`"hello"[1]`, "e"},
+		{`{"a": 1, "b": 2}["b"]`, "2"},
+		{"x = [1, 2]\nx[0] = 7\nx", "[7, 2]"},
+		{"d = {}\nd[\"k\"] = 3\nd", "{'k': 3}"},
+		{`[1][3]`, "IndexError: list index out of range"},
+		{`{"a": 1}["z"]`, "KeyError: z"},
+		{"t = (1, 2)\nt[0] = 5", "TypeError: 'tuple' object does not support item assignment"},
+		{"class A:\n\tpass\na = A()\na.d = {}\na.d[\"k\"] = [0]\na.d[\"k\"][0] += 2\na.x, a.d[\"j\"] = 1, 3\n(a.x, a.d)", "(1, {'k': [2], 'j': 3})"},
+		{"class A:\n\tpass\na = A()\nd = {}\na.v = d[\"v\"] = 5\n(a.v, d)", "(5, {'v': 5})"},
+		{"x = [1, 2, 3, 4]\nx[1:3] = [7]\nx", "[1, 7, 4]"},
+		{"x = [1, 2]\nx[1:1] = (5, 6)\nx[:0] = \"a\"\nx", "['a', 1, 5, 6, 2]"},
+		{"x = [1, 2, 3]\nx[5:] = [9]\nx[-10:1] = []\nx", "[2, 3, 9]"},
+		{"x = [1, 2, 3]\nx[1:] = x\nx", "[1, 1, 2, 3]"},
+		{"x = [1, 2, 3, 4]\nx[::2] = [0, 0]\nx[::-1] = range(4)\nx", "[3, 2, 1, 0]"},
+		{"x = [1, 2, 3]\nx[::2] = [0]", "ValueError: attempt to assign sequence of size 1 to extended slice of size 2"},
+		{"x = [1]\nx[:] = 5", "TypeError: can only assign an iterable"},
+		{"x = [1]\nx[0] = x\nx", "[[...]]"},
+		{"d = {}\nd[\"self\"] = d\nd", "{'self': {...}}"},
+		{"x = []\nt = (x,)\nx.append(t)\nt", "([(...)],)"},
+		{"x = [1]\ny = [x, x]\ny", "[[1], [1]]"},
+		{"x = []\nx.append(x)\nx == x", "True"},
+		{"x = []\nx.append(x)\ny = []\ny.append(y)\nx == y", "RecursionError: maximum recursion depth exceeded in comparison"},
+		{"d = {}\nd[1] = d\ne = {}\ne[1] = e\nd != e", "RecursionError: maximum recursion depth exceeded in comparison"},
+		{"x = []\nfor i in range(2000):\n\tx = [x]\nstr(x)", "RecursionError: maximum recursion depth exceeded while getting the repr of an object"},
+		{"x = []\nfor i in range(2000):\n\tx = {1: (x,)}\nprint(x)", "RecursionError: maximum recursion depth exceeded while getting the repr of an object"},
+		{"x = []\nfor i in range(2000):\n\tx = [x]\n\"%r\" % (x,)", "RecursionError: maximum recursion depth exceeded while getting the repr of an object"},
+		{"x = []\nfor i in range(2000):\n\tx = [x]\n\"{!r}\".format(x)", "RecursionError: maximum recursion depth exceeded while getting the repr of an object"},
+		{"import copy\nx = []\nfor i in range(2000):\n\tx = [x]\ncopy.deepcopy(x)", "RecursionError: maximum recursion depth exceeded while copying an object"},
+		{"import copy\nx = []\nfor i in range(500):\n\tx = [x]\n(len(str(x)), copy.deepcopy(x) == x)", "(1002, True)"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestVisitDeeplyNested(t *testing.T) {
+	x := &interpreter.List{}
+	for i := 0; i < 50000; i++ {
+		x = &interpreter.List{Elements: []interpreter.Item{x}}
+	}
+	if s := x.Visit(); !strings.HasPrefix(s, "[[[") || !strings.Contains(s, "...") {
+		t.Errorf("Visit of a deeply nested list should stop descending at a bound")
+	}
+	if _, ok := interpreter.VisitLimit(x, 100); ok {
+		t.Errorf("VisitLimit of a list nested deeper than its limit should report false")
+	}
+}
+
+func TestSliceExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`"abcdef"[1:4]`, "bcd"},
+		{`"abcdef"[::2]`, "ace"},
+		{`"abcdef"[::-1]`, "fedcba"},
+		{`"abcdef"[-2:]`, "ef"},
+		{`[1, 2, 3, 4][:-1]`, "[1, 2, 3]"},
+		{`[1, 2, 3, 4][10:]`, "[]"},
+		{`(1, 2, 3)[1:]`, "(2, 3)"},
+		{`(1, 2, 3)[2:0:-1]`, "(3, 2)"},
+		{`[1, 2][::0]`, "ValueError: slice step cannot be zero"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestTernaryExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`1 if 2 > 1 else 0`, "1"},
+		{`1 if 1 > 2 else 0`, "0"},
+		{`"a" if 1 == 2 else "b" if 2 == 2 else "c"`, "b"},
+		{`1 if 1 == 1 else missing`, "1"},
+		{`missing if 1 == 2 else 2`, "2"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestCompareExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"x = 5\n1 < x < 10", "True"},
+		{"x = 50\n1 < x < 10", "False"},
+		{"x = 5\n1 < x == 5", "True"},
+		{`3 > 2 > 1`, "True"},
+		{`1 > 2 > missing`, "False"},
+		{`1 < 2 < missing`, "NameError: name 'missing' is not defined"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestBoolAndNoneLiterals(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"True", "True"},
+		{"False", "False"},
+		{"None", "None"},
+		{"[True, None]", "[True, None]"},
+		{"True == 1", "True"},
+		{"False == 0", "True"},
+		{"True + True", "2"},
+		{"True < 2", "True"},
+		{"True & False", "False"},
+		{"True | False", "True"},
+		{"None == None", "True"},
+		{"None != 0", "True"},
+		{"None == False", "False"},
+		{"x = None\nx == None", "True"},
+		{"not False", "True"},
+		{"1 if True else 2", "1"},
+		{"def f():\n\tpass\nf() == None", "True"},
+		{"None < 1", "TypeError: '<' not supported between instances of 'NoneType' and 'int'"},
+		{"None + 1", "TypeError: unsupported operand type(s) for +: 'NoneType' and 'int'"},
+		{"def m(x):\n\tmatch x:\n\t\tcase True:\n\t\t\treturn 1\n\t\tcase None:\n\t\t\treturn 2\n\treturn 3\nm(True) * 100 + m(None) * 10 + m(1)", "123"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestTruthiness(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"bool(1)", "True"},
+		{"bool(0)", "False"},
+		{"bool(-3)", "True"},
+		{"bool(\"a\")", "True"},
+		{"bool(\"\")", "False"},
+		{"bool([])", "False"},
+		{"bool([0])", "True"},
+		{"bool(())", "False"},
+		{"bool({})", "False"},
+		{"bool({1: 2})", "True"},
+		{"bool(None)", "False"},
+		{"bool(True)", "True"},
+		{"bool()", "False"},
+		{"class A:\n\tpass\nbool(A())", "True"},
+		{"bool(len)", "True"},
+		{"x = 0\nif 1:\n\tx = 1\nx", "1"},
+		{"x = 0\nif []:\n\tx = 1\nx", "0"},
+		{"\"yes\" if \"s\" else \"no\"", "yes"},
+		{"not \"\"", "True"},
+		{"n = 3\nt = 0\nwhile n:\n\tt += n\n\tn -= 1\nt", "6"},
+		{"if missing:\n\tpass", "NameError: name 'missing' is not defined"},
+		{"bool(1, 2)", "TypeError: bool expected at most 1 argument, got 2"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestNotExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`not 1 == 1`, "False"},
+		{`not 1 == 2`, "True"},
+		{`not not 2 > 1`, "True"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestLogicalExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`1 > 2 or 2 > 1`, "True"},
+		{`1 > 2 or 1 > 3`, "False"},
+		{`2 > 1 and 3 > 2`, "True"},
+		{`2 > 1 and 1 > 3`, "False"},
+		{`2 > 1 or missing`, "True"},
+		{`1 > 2 and missing`, "False"},
+		{`1 > 2 or missing`, "NameError: name 'missing' is not defined"},
+		{`0 or "default"`, "default"},
+		{`"first" or "second"`, "first"},
+		{`0 or []`, "[]"},
+		{`None and missing`, "None"},
+		{`1 and 2 and 3`, "3"},
+		{`1 and 0 and missing`, "0"},
+		{`"" or 0 or 7`, "7"},
+		{"n = None\nname = n or \"anon\"\nname", "anon"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestModAndPow(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"7 % 3", "1"},
+		{"0 - 7 % 3", "-1"},
+		{"(0 - 7) % 3", "2"},
+		{"7 % (0 - 3)", "-2"},
+		{"(0 - 7) % (0 - 3)", "-1"},
+		{"6 % 3", "0"},
+		{"2 ** 10", "1024"},
+		{"2 ** 3 ** 2", "512"},
+		{"5 ** 0", "1"},
+		{"2 ** (0 - 1)", "0.5"},
+		{"x = 10\nx %= 4\nx", "2"},
+		{"x = 3\nx **= 2\nx", "9"},
+		{"7.5 % 2.0", "1.5"},
+		{"(0.0 - 7.5) % 2.0", "0.5"},
+		{"2.0 ** 0.5 > 1.41", "True"},
+		{"1.5 ** 2.0", "2.25"},
+		{"1.5 + 2.25", "3.75"},
+		{"1e16 * 1.0", "1e+16"},
+		{"1 % 0", "ZeroDivisionError: integer division or modulo by zero"},
+		{"1.0 % 0.0", "ZeroDivisionError: float modulo"},
+		{"0 ** (0 - 1)", "ZeroDivisionError: 0.0 cannot be raised to a negative power"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestStrComparison(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`"a" < "b"`, "True"},
+		{`"b" < "a"`, "False"},
+		{`"abc" < "abd"`, "True"},
+		{`"ab" < "abc"`, "True"},
+		{`"B" < "a"`, "True"},
+		{`"b" > "a"`, "True"},
+		{`"" < "a"`, "True"},
+		{`"hi" == "hi"`, "True"},
+		{`"hi" == "ho"`, "False"},
+		{`"hi" != "ho"`, "True"},
+		{`"1" == 1`, "False"},
+		{`1 != "1"`, "True"},
+		{`"a" < "b" < "c"`, "True"},
+		{`"a" < 1`, "TypeError: '<' not supported between instances of 'str' and 'int'"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestStrArithmetic(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`"ab" + "cd"`, "abcd"},
+		{`"ab" * 3`, "ababab"},
+		{`3 * "ab"`, "ababab"},
+		{`"ab" * 0`, ""},
+		{`"ab" * (0 - 2)`, ""},
+		{"s = \"-\"\ns *= 4\ns", "----"},
+		{`"ab" + 1`, "TypeError: can only concatenate str (not \"int\") to str"},
+		{`1 + "ab"`, "TypeError: unsupported operand type(s) for +: 'int' and 'str'"},
+		{`"ab" * "cd"`, "TypeError: can't multiply sequence by non-int of type 'str'"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestIntOverflow(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"9223372036854775807 + 1", "OverflowError: int too large to fit in 64 bits"},
+		{"-9223372036854775807 - 2", "OverflowError: int too large to fit in 64 bits"},
+		{"4294967296 * 4294967296", "OverflowError: int too large to fit in 64 bits"},
+		{"(-9223372036854775807 - 1) * -1", "OverflowError: int too large to fit in 64 bits"},
+		{"(-9223372036854775807 - 1) // -1", "OverflowError: int too large to fit in 64 bits"},
+		{"-(-9223372036854775807 - 1)", "OverflowError: int too large to fit in 64 bits"},
+		{"abs(-9223372036854775807 - 1)", "OverflowError: int too large to fit in 64 bits"},
+		{"2 ** 63", "OverflowError: int too large to fit in 64 bits"},
+		{"10 ** 19", "OverflowError: int too large to fit in 64 bits"},
+		{"1 << 63", "OverflowError: int too large to fit in 64 bits"},
+		{"3 << 100", "OverflowError: int too large to fit in 64 bits"},
+		{"x = 9223372036854775807\nx += 1", "OverflowError: int too large to fit in 64 bits"},
+		{"int(\"99999999999999999999\")", "OverflowError: int too large to fit in 64 bits"},
+		{"int(1e19)", "OverflowError: int too large to fit in 64 bits"},
+		{"round(1e300)", "OverflowError: int too large to fit in 64 bits"},
+		{"try:\n\tx = 2 ** 64\nexcept OverflowError:\n\tx = \"caught\"\nx", "caught"},
+		{"2 ** 62 + (2 ** 62 - 1)", "9223372036854775807"},
+		{"-2 ** 63", "OverflowError: int too large to fit in 64 bits"},
+		{"(-2) ** 63", "-9223372036854775808"},
+		{"3 ** 39", "4052555153018976267"},
+		{"1 << 62", "4611686018427387904"},
+		{"-1 << 63", "-9223372036854775808"},
+		{"0 << 1000", "0"},
+		{"int(-9.2e18)", "-9200000000000000000"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1 / 0", "ZeroDivisionError: division by zero"},
+		{"1 // 0", "ZeroDivisionError: integer division or modulo by zero"},
+		{"1 % 0", "ZeroDivisionError: integer division or modulo by zero"},
+		{"x = 4\nx /= 0", "ZeroDivisionError: division by zero"},
+		{"try:\n\t1 / 0\nexcept ZeroDivisionError as e:\n\tr = \"caught\"\nr", "caught"},
+		{"try:\n\t1 % 0\nexcept ArithmeticError:\n\tr = 1\nr", "1"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestAugAssignStmt(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"x = 1\nx += 2\nx", "3"},
+		{"x = 10\nx -= 4\nx", "6"},
+		{"x = 3\nx *= 3\nx", "9"},
+		{"x = 9\nx /= 3\nx", "3.0"},
+		{"s = \"ab\"\ns += \"cd\"\ns", "abcd"},
+		{"l = [1, 2]\nl[1] += 5\nl", "[1, 7]"},
+		{"d = {\"k\": 1}\nd[\"k\"] *= 4\nd", "{'k': 4}"},
+		{"y += 1", "NameError: name 'y' is not defined"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestAssignStmt(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"a, b = 1, 2\n(a, b)", "(1, 2)"},
+		{"a = b = 0\n(a, b)", "(0, 0)"},
+		{"a, b = 1, 2\na, b = b, a\n(a, b)", "(2, 1)"},
+		{"a, b = [3, 4]\nb", "4"},
+		{"(a, b), c = (1, 2), 3\n[a, b, c]", "[1, 2, 3]"},
+		{"l = [0, 0]\nl[0], l[1] = 5, 6\nl", "[5, 6]"},
+		{"x = 1, 2\nx", "(1, 2)"},
+		{"a, b = 1, 2, 3", "ValueError: too many values to unpack (expected 2)"},
+		{"a, b, c = 1, 2", "ValueError: not enough values to unpack (expected 3, got 2)"},
+		{"a, b = 1", "TypeError: cannot unpack non-iterable int object"},
+		{"first, *rest = [1, 2, 3]\n(first, rest)", "(1, [2, 3])"},
+		{"*init, last = 1, 2, 3\n(init, last)", "([1, 2], 3)"},
+		{"a, *b, c = \"xyz\"\nb", "['y']"},
+		{"[a, *b, c] = (1, 2)\nb", "[]"},
+		{"(a, *b), c = (1, 2, 3), 4\nb", "[2, 3]"},
+		{"l = [0, 0]\nx, *l[0] = 1, 2, 3\nl", "[[2, 3], 0]"},
+		{"a, *b, c = [1]", "ValueError: not enough values to unpack (expected at least 2, got 1)"},
+		{"*a", "SyntaxError: can't use starred expression here"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestLoopControlSignals(t *testing.T) {
+	p, program := parser.StartParseRepl("while 1 > 2:\n\tx = 1\n\tif 1 == 1:\n\t\tbreak\n\tx = 2\n")
+	if len(p.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors())
+	}
+	env := interpreter.NewEnv()
+	body := program.Stmts[0].(*ast.ExprStmt).Expr.(*ast.WhileExpr).Body
+	if got := Evaluate(body, env); got != BREAK {
+		t.Errorf("want break signal; got %v", got)
+	}
+	if x, _ := env.Get("x"); x.Visit() != "1" {
+		t.Errorf("statements after break ran; x = %s", x.Visit())
+	}
+}
+
+func TestClassInheritance(t *testing.T) {
+	setup := `class Animal:
+	sound = "..."
+	def __init__(self, name):
+		self.name = name
+	def speak(self):
+		return self.name + " says " + self.sound
+	def kind(self):
+		return "animal"
+
+class Dog(Animal):
+	sound = "woof"
+	def __init__(self, name, tricks):
+		super().__init__(name)
+		self.tricks = tricks
+	def kind(self):
+		return "dog, a kind of " + super().kind()
+
+d = Dog("rex", 3)
+`
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"d.speak()", "rex says woof"},
+		{"d.kind()", "dog, a kind of animal"},
+		{"d.tricks", "3"},
+		{"Animal(\"cat\").speak()", "cat says ..."},
+		{"super(Dog, d).kind()", "animal"},
+		{"d.tricks += 1\nd.tricks", "4"},
+		{"Dog.sound", "woof"},
+		{"d.missing", "AttributeError: 'Dog' object has no attribute 'missing'"},
+		{"super()", "RuntimeError: super(): no arguments"},
+		{"Dog(\"rex\")", "TypeError: __init__() missing 1 required positional argument: 'tricks'"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, setup+tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestDunderMethods(t *testing.T) {
+	setup := `class Vec:
+	def __init__(self, x, y):
+		self.x = x
+		self.y = y
+	def __add__(self, other):
+		return Vec(self.x + other.x, self.y + other.y)
+	def __mul__(self, k):
+		return Vec(self.x * k, self.y * k)
+	def __rmul__(self, k):
+		return Vec(self.x * k, self.y * k)
+	def __eq__(self, other):
+		return self.x == other.x and self.y == other.y
+	def __lt__(self, other):
+		return self.x < other.x
+	def __neg__(self):
+		return Vec(-self.x, -self.y)
+	def __len__(self):
+		return 2
+	def __getitem__(self, i):
+		return (self.x, self.y)[i]
+	def __setitem__(self, i, v):
+		if i == 0:
+			self.x = v
+		else:
+			self.y = v
+	def __call__(self, k):
+		return self.x * k
+
+a = Vec(1, 2)
+b = Vec(3, 4)
+`
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"(a + b).y", "6"},
+		{"(a * 3).x", "3"},
+		{"(3 * a).y", "6"},
+		{"a == Vec(1, 2)", "True"},
+		{"a != b", "True"},
+		{"a != Vec(1, 2)", "False"},
+		{"a < b", "True"},
+		{"b > a", "True"},
+		{"(-a).x", "-1"},
+		{"len(a)", "2"},
+		{"a[1]", "2"},
+		{"a[0] = 9\na.x", "9"},
+		{"a(5)", "5"},
+		{"a - b", "TypeError: unsupported operand type(s) for -: 'Vec' and 'Vec'"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, setup+tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"shapes.py": "import counter\nsides = 4\ndef area(w, h):\n\treturn w * h\n",
+		"counter.py": "loads = [0]\nloads[0] += 1\n",
+		"broken.py": "x = (1\n",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(path []string) { SearchPath = path }(SearchPath)
+	SearchPath = []string{dir}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"import shapes\nshapes.area(2, 3)", "6"},
+		{"import shapes as s\ns.sides", "4"},
+		{"from shapes import area, sides as n\narea(n, 2)", "8"},
+		{"import shapes\nimport counter\ncounter.loads[0]", "1"},
+		{"import missing", "ModuleNotFoundError: No module named 'missing'"},
+		{"from shapes import nope", "ImportError: cannot import name 'nope' from 'shapes'"},
+		{"import shapes\nshapes.nope", "AttributeError: module 'shapes' has no attribute 'nope'"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+	want := "SyntaxError: " + filepath.Join(dir, "broken.py") + ": error at line 1, column 7: expected next token to be ), got EOF instead"
+	if got := testEval(t, "import broken"); got == nil || got.Visit() != want {
+		t.Errorf("import broken; want %s; got %v", want, got)
+	}
+	if got := testEval(t, "try:\n\timport broken\nexcept SyntaxError:\n\tx = 1\nx"); got == nil || got.Visit() != "1" {
+		t.Errorf("import broken; want a SyntaxError to catch; got %v", got)
+	}
+}
+
+func TestTryStmt(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"try:\n\tx = [1][3]\nexcept Exception:\n\tx = 0\nx", "0"},
+		{"try:\n\tx = 1\nexcept:\n\tx = 2\nelse:\n\tx = x + 10\nx", "11"},
+		{"log = [0, 0]\ntry:\n\t{}[1]\nexcept Exception as e:\n\tlog[0] = e.args[0]\nfinally:\n\tlog[1] = 1\nlog", "['1', 1]"},
+		{"try:\n\t[][0]\nexcept (Exception, BaseException):\n\tx = 1\nx", "1"},
+		{"class E(Exception):\n\tpass\ntry:\n\t[][0]\nexcept E:\n\tpass", "IndexError: list index out of range"},
+		{"try:\n\t[][0]\nexcept 1:\n\tpass", "TypeError: catching classes that do not inherit from BaseException is not allowed"},
+		{"try:\n\t[][0]\nexcept Exception:\n\t{}[2]", "KeyError: 2"},
+		{"x = [0]\ntry:\n\t[][0]\nfinally:\n\tx[0] = 1", "IndexError: list index out of range"},
+		{"def f(x):\n\ttry:\n\t\treturn 1\n\tfinally:\n\t\tx[0] = 2\nx = [0]\nf(x)\nx[0]", "2"},
+		{"def f():\n\ttry:\n\t\treturn 1\n\tfinally:\n\t\treturn 2\nf()", "2"},
+		{"class E(Exception):\n\tpass\nE(1, 2).args", "(1, 2)"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	got := testEval(t, "x = 1\ndef f():\n\treturn [][0]\nf()")
+	err, ok := got.(*interpreter.Error)
+	if !ok {
+		t.Fatalf("want an error; got %v", got)
+	}
+	if want := "line 3, column 5"; err.Pos != want {
+		t.Errorf("want error at %s; got %s", want, err.Pos)
+	}
+}
+
+func TestRaiseStmt(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`raise ValueError("bad")`, "ValueError: bad"},
+		{"raise KeyError", "KeyError"},
+		{"try:\n\traise ValueError(\"bad\")\nexcept ValueError as e:\n\tx = e.args[0]\nx", "bad"},
+		{"try:\n\t[][0]\nexcept LookupError:\n\tx = 1\nx", "1"},
+		{"try:\n\tmissing\nexcept NameError:\n\tx = 2\nx", "2"},
+		{"try:\n\traise TypeError(\"t\")\nexcept ValueError:\n\tpass", "TypeError: t"},
+		{"try:\n\traise ValueError(\"v\")\nexcept ValueError:\n\traise", "ValueError: v"},
+		{"raise", "RuntimeError: No active exception to reraise"},
+		{"raise 1", "TypeError: exceptions must derive from BaseException"},
+		{"class MyError(ValueError):\n\tpass\ntry:\n\traise MyError(\"m\")\nexcept Exception as e:\n\tx = e\nx.args", "('m',)"},
+		{"try:\n\t[][0]\nexcept IndexError as e:\n\tx = str(e)\nx", "list index out of range"},
+		{"str(ValueError()) + \"|\" + str(ValueError(1)) + \"|\" + str(ValueError(\"a\", 2))", "|1|('a', 2)"},
+		{"ValueError().__repr__() + \"|\" + KeyError(\"k\").__repr__() + \"|\" + OSError(2, \"gone\").__repr__()", "ValueError()|KeyError('k')|OSError(2, 'gone')"},
+		{"class MyError(Exception):\n\tdef __str__(self):\n\t\treturn \"mine\"\nstr(MyError(1))", "mine"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestWithStmt(t *testing.T) {
+	manager := "class M:\n" +
+		"\tdef __init__(self, log, suppress):\n" +
+		"\t\tself.log = log\n" +
+		"\t\tself.suppress = suppress\n" +
+		"\tdef __enter__(self):\n" +
+		"\t\tself.log[0] = \"entered\"\n" +
+		"\t\treturn self.log\n" +
+		"\tdef __exit__(self, typ, exc, tb):\n" +
+		"\t\tself.log[1] = typ\n" +
+		"\t\treturn self.suppress\n" +
+		"log = [0, 0, 0]\n"
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"with M(log, 0) as l:\n\tl[2] = 1\nlog", "['entered', None, 1]"},
+		{"with M(log, 0):\n\t[][0]", "IndexError: list index out of range"},
+		{"try:\n\twith M(log, 0):\n\t\t[][0]\nexcept IndexError:\n\tpass\nlog[1]", "<class 'IndexError'>"},
+		{"with M(log, 1 == 1):\n\t[][0]\nlog[1]", "<class 'IndexError'>"},
+		{"def f():\n\twith M(log, 0):\n\t\treturn 5\nf()\nlog", "['entered', None, 0]"},
+		{"with M(log, 0) as a, M([0, 0, 0], 0) as b:\n\tb[2] = a[0]\nb", "['entered', None, 'entered']"},
+		{"with 1:\n\tpass", "TypeError: 'int' object does not support the context manager protocol"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, manager+tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestAssertStmt(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"assert 1 < 2\n3", "3"},
+		{"assert 1 > 2", "AssertionError"},
+		{"assert 1 > 2, \"too small\"", "AssertionError: too small"},
+		{"try:\n\tassert 1 > 2\nexcept AssertionError:\n\tx = 4\nx", "4"},
+		{"assert missing", "NameError: name 'missing' is not defined"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+
+	env := NewEnv()
+	SetAssertions(env, false)
+	_, program := parser.StartParseRepl("assert 1 > 2\n5")
+	if got := Evaluate(&program, env); got == nil || got.Visit() != "5" {
+		t.Errorf("assertions disabled; want 5; got %v", got)
+	}
+	if got := testEval(t, "assert 1 > 2"); got == nil || got.Visit() != "AssertionError" {
+		t.Errorf("assertions in another interpreter; want AssertionError; got %v", got)
+	}
+}
+
+func TestDelStmt(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"x = 1\ndel x\nx", "NameError: name 'x' is not defined"},
+		{"del y", "NameError: name 'y' is not defined"},
+		{"d = {\"a\": 1, \"b\": 2, \"c\": 3}\ndel d[\"b\"]\nd", "{'a': 1, 'c': 3}"},
+		{"d = {}\ndel d[1]", "KeyError: 1"},
+		{"l = [1, 2, 3, 4]\ndel l[-1], l[0]\nl", "[2, 3]"},
+		{"l = [1, 2, 3, 4, 5]\ndel l[::2]\nl", "[2, 4]"},
+		{"l = [1]\ndel l[5]", "IndexError: list index out of range"},
+		{"t = (1, 2)\ndel t[0]", "TypeError: 'tuple' object doesn't support item deletion"},
+		{"class A:\n\tpass\na = A()\na.x = 1\ndel a.x\na.x", "AttributeError: 'A' object has no attribute 'x'"},
+		{"class A:\n\tdef __delitem__(self, k):\n\t\tself.k = k\na = A()\ndel a[7]\na.k", "7"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestScopeDeclarations(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"n = 0\ndef inc():\n\tglobal n\n\tn = n + 1\ninc()\ninc()\nn", "2"},
+		{"n = 0\ndef f():\n\tn = 5\nf()\nn", "0"},
+		{"def f():\n\tglobal g\n\tg = 3\nf()\ng", "3"},
+		{"def counter():\n\tc = 0\n\tdef inc():\n\t\tnonlocal c\n\t\tc = c + 1\n\t\treturn c\n\treturn inc\ni = counter()\ni()\ni()", "2"},
+		{"def outer():\n\tx = 1\n\tdef mid():\n\t\tdef inner():\n\t\t\tnonlocal x\n\t\t\tx = 9\n\t\tinner()\n\tmid()\n\treturn x\nouter()", "9"},
+		{"x = 1\ndef f():\n\tnonlocal x\n\tx = 2\nf()", "SyntaxError: no binding for nonlocal 'x' found"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"def gen():\n\tyield\ngen()", "<generator object gen>"},
+		{"def gen():\n\tyield 1\n\tyield 2\ng = gen()\nnext(g)\nnext(g)", "2"},
+		{"def gen():\n\tyield 1\ng = gen()\nnext(g)\nnext(g)", "StopIteration"},
+		{"def gen():\n\tyield 1\ng = gen()\nnext(g)\nnext(g, 0)", "0"},
+		{"def gen():\n\tyield 1\n\treturn 5\ng = gen()\nnext(g)\ntry:\n\tnext(g)\nexcept StopIteration as e:\n\tx = e.args[0]\nx", "5"},
+		{"def gen():\n\tyield 1, 2\nnext(gen())", "(1, 2)"},
+		{"def echo():\n\tx = yield 1\n\tyield x\ng = echo()\nnext(g)\ng.send(7)", "7"},
+		{"def echo():\n\tyield 1\necho().send(1)", "TypeError: can't send non-None value to a just-started generator"},
+		{"log = [0]\ndef gen():\n\tlog[0] = 1\n\tyield 2\ng = gen()\nlog[0]", "0"},
+		{"log = [0]\ndef gen():\n\ttry:\n\t\tyield 1\n\tfinally:\n\t\tlog[0] = 1\ng = gen()\nnext(g)\ng.close()\nlog[0]", "1"},
+		{"def gen():\n\tyield [][0]\nnext(gen())", "IndexError: list index out of range"},
+		{"next(1)", "TypeError: 'int' object is not an iterator"},
+		{"def gen():\n\twhile True:\n\t\ttry:\n\t\t\tyield 1\n\t\texcept GeneratorExit:\n\t\t\tpass\ng = gen()\nnext(g)\ng.close()", "RuntimeError: generator ignored GeneratorExit"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+// waitGoroutines waits for the number of goroutines to fall to n, failing
+// t if it doesn't soon.
+func waitGoroutines(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines are left running; want %d", runtime.NumGoroutine(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGeneratorsStop(t *testing.T) {
+	const paused = "def gen():\n\twhile True:\n\t\ttry:\n\t\t\tyield 1\n\t\texcept GeneratorExit:\n\t\t\tpass\ng = gen()\nnext(g)\n"
+	before := runtime.NumGoroutine()
+
+	env := NewEnv()
+	_, program := parser.StartParseRepl(paused + "g.close()")
+	Evaluate(&program, env)
+	waitGoroutines(t, before)
+
+	env = NewEnv()
+	_, program = parser.StartParseRepl(paused)
+	Evaluate(&program, env)
+	Close(env)
+	waitGoroutines(t, before)
+	_, program = parser.StartParseRepl("next(g, 0)")
+	if got := Evaluate(&program, env); got == nil || got.Visit() != "0" {
+		t.Errorf("next(g, 0) after Close = %v; want 0", got)
+	}
+
+	env = NewEnv()
+	ctx, cancel := context.WithCancel(context.Background())
+	_, program = parser.StartParseRepl(paused)
+	EvaluateContext(ctx, &program, env)
+	cancel()
+	waitGoroutines(t, before)
+}
+
+func TestCallArguments(t *testing.T) {
+	fn := "def f(a, b=2, c=3):\n\treturn [a, b, c]\n"
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"f(1)", "[1, 2, 3]"},
+		{"f(1, 5)", "[1, 5, 3]"},
+		{"f(1, c=9)", "[1, 2, 9]"},
+		{"f(c=7, a=0)", "[0, 2, 7]"},
+		{"f(b=1)", "TypeError: f() missing 1 required positional argument: 'a'"},
+		{"f(1, 2, 3, 4)", "TypeError: f() takes 3 positional arguments but 4 were given"},
+		{"f(1, a=2)", "TypeError: f() got multiple values for argument 'a'"},
+		{"f(1, d=2)", "TypeError: f() got an unexpected keyword argument 'd'"},
+		{"def g(x, y, z):\n\tpass\ng()", "TypeError: g() missing 3 required positional arguments: 'x', 'y', and 'z'"},
+		{"n = 1\ndef g(x=n):\n\treturn x\nn = 2\ng()", "1"},
+		{"class P:\n\tdef __init__(self, x, y=0):\n\t\tself.y = y\nP(1, y=4).y", "4"},
+		{"len(x=1)", "TypeError: len() takes no keyword arguments"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, fn+tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestVariadicArguments(t *testing.T) {
+	fn := "def f(a, *args, **kwargs):\n\treturn [a, args, kwargs]\n"
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"f(1)", "[1, (), {}]"},
+		{"f(1, 2, 3)", "[1, (2, 3), {}]"},
+		{"f(1, x=2)", "[1, (), {'x': 2}]"},
+		{"f(*[1, 2], *(3,))", "[1, (2, 3), {}]"},
+		{"f(**{\"a\": 1, \"b\": 2})", "[1, (), {'b': 2}]"},
+		{"f(1, *\"ab\", y=1, **{\"z\": 2})", "[1, ('a', 'b'), {'y': 1, 'z': 2}]"},
+		{"f(1, x=1, **{\"x\": 2})", "TypeError: f() got multiple values for keyword argument 'x'"},
+		{"f(1, **[1])", "TypeError: argument after ** must be a mapping, not list"},
+		{"f(1, **{1: 2})", "TypeError: keywords must be strings"},
+		{"f(*1)", "TypeError: 'int' object is not iterable"},
+		{"def g(a, b):\n\treturn a - b\ng(*[5, 3])", "2"},
+		{"def g(a, b):\n\treturn a - b\ng(**{\"b\": 5, \"a\": 3})", "-2"},
+		{"def g(**kw):\n\treturn kw\ng(1)", "TypeError: g() takes 0 positional arguments but 1 were given"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, fn+tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestDocstrings(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"def f():\n\t\"Adds things.\"\n\treturn 1\nf.__doc__", "Adds things."},
+		{"def f():\n\treturn 1\nf.__doc__", "None"},
+		{"def f():\n\tx = 1\n\t\"not a docstring\"\nf.__doc__", "None"},
+		{"class C:\n\t\"A class.\"\n\tpass\nC.__doc__", "A class."},
+		{"class C:\n\t\"A class.\"\n\tpass\nC().__doc__", "A class."},
+		{"class C:\n\t\"A class.\"\n\tpass\nclass D(C):\n\tpass\nD.__doc__", "None"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+
+	helps := []struct {
+		input string
+		want  string
+	}{
+		{"def f(a, *rest):\n\t\"Adds things.\"\n\treturn 1\nhelp(f)", "f(a, *rest)\n    Adds things.\n"},
+		{"def f(a, b=1, c=\"x\", d=None, **kw):\n\tpass\nhelp(f)", "f(a, b=1, c='x', d=None, **kw)\n"},
+		{"class C:\n\t\"A class.\"\n\tpass\nhelp(C)", "class C\n    A class.\n"},
+		{"help(len)", "builtin function len\n"},
+	}
+	defer func(w io.Writer) { Stdout = w }(Stdout)
+	for _, tt := range helps {
+		var out bytes.Buffer
+		Stdout = &out
+		got := testEval(t, tt.input)
+		if got == nil || got.Type() != interpreter.NONE || out.String() != tt.want {
+			t.Errorf("eval(%q); want output %q; got %q (result %v)", tt.input, tt.want, out.String(), got)
+		}
+	}
+}
+
+func TestBitwiseExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"6 | 3", "7"},
+		{"6 ^ 3", "5"},
+		{"6 & 3", "2"},
+		{"1 << 4", "16"},
+		{"-16 >> 2", "-4"},
+		{"~5", "-6"},
+		{"7 // 2", "3"},
+		{"-7 // 2", "-4"},
+		{"1 | 2 ^ 3 & 4 << 1", "3"},
+		{"1 // 0", "ZeroDivisionError: integer division or modulo by zero"},
+		{"1 << -1", "ValueError: negative shift count"},
+		{"~\"a\"", "TypeError: bad operand type for unary ~: 'str'"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestUnaryExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"+3", "3"},
+		{"-2.5", "-2.5"},
+		{"+2.5", "2.5"},
+		{"- -4", "4"},
+		{"-True", "-1"},
+		{"+False", "0"},
+		{"~True", "-2"},
+		{"-True + 1", "0"},
+		{"True + True", "2"},
+		{"x = 1.5\n-x * 2.0", "-3.0"},
+		{"class V:\n\tdef __pos__(self):\n\t\treturn \"pos\"\n\tdef __invert__(self):\n\t\treturn \"inv\"\n(+V(), ~V())", "('pos', 'inv')"},
+		{"-\"a\"", "TypeError: bad operand type for unary -: 'str'"},
+		{"+[1]", "TypeError: bad operand type for unary +: 'list'"},
+		{"~1.5", "TypeError: bad operand type for unary ~: 'float'"},
+		{"class V:\n\tpass\n-V()", "TypeError: bad operand type for unary -: 'V'"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestIdentityExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"None is None", "True"},
+		{"x = None\nx is not None", "False"},
+		{"True is True", "True"},
+		{"(1 == 1) is True", "True"},
+		{"1 is True", "False"},
+		{"a = [1]\nb = a\na is b", "True"},
+		{"[1] is [1]", "False"},
+		{"[1] == [1] is False", "False"},
+		{"([1, (2, 3)] == [1, (2, 3)], {1: [2]} != {1: [3]}, [1] == (1,), [] == 0)", "(True, True, False, False)"},
+		{"a = {}\nb = {}\n(a is not b, a == b)", "(True, True)"},
+		{"x = 7\ny = 7\nx is y", "True"},
+		{"x = 1000\ny = 999 + 1\nx is y", "False"},
+		{"class A:\n\tpass\na = A()\n(a is a, a is A(), type(a) is A)", "(True, False, True)"},
+		{"def f():\n\tpass\nf() is None", "True"},
+		{"1 is 1 is not 2", "True"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestMembershipExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`"ell" in "hello"`, "True"},
+		{`"" in "abc"`, "True"},
+		{`"z" not in "abc"`, "True"},
+		{"2 in [1, 2, 3]", "True"},
+		{"[2] in [[1], [2]]", "True"},
+		{"4 not in (1, 2, 3)", "True"},
+		{"True in [1]", "True"},
+		{`d = {"a": 1}` + "\n(\"a\" in d, 1 in d)", "(True, False)"},
+		{"1 in set([1, 2])", "True"},
+		{"(5 in range(1, 10, 2), 4 in range(1, 10, 2), 10 in range(10), 8 in range(10, 0, -2), 0 in range(10, 0, -2))", "(True, False, False, True, False)"},
+		{"def gen():\n\tyield 1\n\tyield 2\n\tyield 3\ng = gen()\n(2 in g, next(g))", "(True, 3)"},
+		{"class Bag:\n\tdef __contains__(self, x):\n\t\treturn x == 7\n(7 in Bag(), 8 in Bag(), 8 not in Bag())", "(True, False, True)"},
+		{"class Seq:\n\tdef __getitem__(self, i):\n\t\tif i > 2:\n\t\t\traise IndexError\n\t\treturn i * 10\n(20 in Seq(), 30 in Seq())", "(True, False)"},
+		{"x = 3\nif x not in [1, 2]:\n\ty = \"missing\"\ny", "missing"},
+		{`1 in "abc"`, "TypeError: 'in <string>' requires string as left operand, not int"},
+		{"1 in 5", "TypeError: argument of type 'int' is not iterable"},
+		{"[] in {}", "TypeError: unhashable type: 'list'"},
+		{"try:\n\t1 in None\nexcept TypeError:\n\tx = \"caught\"\nx", "caught"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestNoneSentinel(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"str(None)", "None"},
+		{"[None, (None,)]", "[None, (None,)]"},
+		{"(None == None, None is None, None != None, None is not None)", "(True, True, False, False)"},
+		{`(None == 0, None == False, None == "", None == [])`, "(False, False, False, False)"},
+		{"(not None, bool(None))", "(True, False)"},
+		{"x = None\nif x:\n\ty = 1\nelse:\n\ty = 2\ny", "2"},
+		{"d = {None: 1}\n(d[None], None in d, 0 in d)", "(1, True, False)"},
+		{`d = {"a": None}` + "\n" + `(d["a"] is None, d.get("a", 5), d.get("b") is None, d.get("b", 5))`, "(True, None, True, 5)"},
+		{"d = {}\n" + `(d.setdefault("k"), d)`, "(None, {'k': None})"},
+		{`d = {"k": 1}` + "\n" + `(d.pop("k"), d.pop("k", None), d)`, "(1, None, {})"},
+		{"d = {1: 2}\nd.update([(3, None)], a=4)\n(d.keys(), d.values())", "([1, 3, 'a'], [2, None, 4])"},
+		{`{}.pop("z")`, "KeyError: z"},
+		{"{}.get([])", "TypeError: unhashable type: 'list'"},
+		{"None < 1", "TypeError: '<' not supported between instances of 'NoneType' and 'int'"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestOperandTypeErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`1 + "a"`, "TypeError: unsupported operand type(s) for +: 'int' and 'str'"},
+		{`"a" - "b"`, "TypeError: unsupported operand type(s) for -: 'str' and 'str'"},
+		{"[1] * 1.5", "TypeError: unsupported operand type(s) for *: 'list' and 'float'"},
+		{"{} < {}", "TypeError: '<' not supported between instances of 'dict' and 'dict'"},
+		{"1.5 | 2.5", "TypeError: unsupported operand type(s) for |: 'float' and 'float'"},
+		{"(1, 2) - None", "TypeError: unsupported operand type(s) for -: 'tuple' and 'NoneType'"},
+		{"class A:\n\tpass\nA() > 1", "TypeError: '>' not supported between instances of 'A' and 'int'"},
+		{"~\"a\"", "TypeError: bad operand type for unary ~: 'str'"},
+		{"try:\n\tx = {} + 1\nexcept TypeError as e:\n\tx = e.args[0]\nx", "unsupported operand type(s) for +: 'dict' and 'int'"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestHashAndId(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"(hash(5), hash(True), hash(None) == hash(None))", "(5, 1, True)"},
+		{`(hash("abc") == hash("ab" + "c"), hash("abc") == hash("abd"))`, "(True, False)"},
+		{`(hash((1, "a")) == hash((1, "a")), hash((1, 2)) == hash((2, 1)))`, "(True, False)"},
+		{"hash(1.5) == hash(1.5)", "True"},
+		{"hash([1])", "TypeError: unhashable type: 'list'"},
+		{"hash((1, {}))", "TypeError: unhashable type: 'tuple'"},
+		{`d = {(1, 2): "x", 2.5: "y"}` + "\n(d[(1, 2)], d[2.5], (1, 2) in d)", "('x', 'y', True)"},
+		{"len(set([(1, 2), (1, 2), (2, 1)]))", "2"},
+		{"{(1, []): 1}", "TypeError: unhashable type: 'tuple'"},
+		{"class P:\n\tdef __init__(self, x):\n\t\tself.x = x\n\tdef __hash__(self):\n\t\treturn hash(self.x)\nhash(P(7))", "7"},
+		{"class P:\n\tdef __hash__(self):\n\t\treturn \"x\"\nhash(P())", "TypeError: __hash__ method should return an integer"},
+		{"class A:\n\tpass\na = A()\nb = A()\n(id(a) == id(a), id(a) == id(b), hash(a) == id(a))", "(True, False, True)"},
+		{"x = [1]\ny = x\n(id(x) == id(y), id(x) == id([1]), hash(len) == id(len))", "(True, False, True)"},
+		{"id()", "TypeError: id() takes exactly one argument (0 given)"},
+		{"(hash(1) == hash(1.0), hash(1.0) == hash(True), hash(0) == hash(-0.0))", "(True, True, True)"},
+		{"d = {1: \"a\"}\nd[1.0] = \"b\"\nd[True] = \"c\"\n(d, d[1.0], 1 in set([True]))", "({1: 'c'}, 'c', True)"},
+		{"class A:\n\tpass\na = A()\nd = {a: 1}\n(d[a], a in d, A() in d, len(set([a, a])))", "(1, True, False, 1)"},
+		{"class K:\n\tdef __init__(self, v):\n\t\tself.v = v\n\tdef __hash__(self):\n\t\treturn 0\n\tdef __eq__(self, other):\n\t\treturn self.v == other.v\nd = {K(1): \"a\", K(2): \"b\"}\nd[K(2)] = \"c\"\n(len(d), d[K(1)], d[K(2)])", "(2, 'a', 'c')"},
+		{"class K:\n\tdef __init__(self, v):\n\t\tself.v = v\n\tdef __hash__(self):\n\t\treturn 0\n\tdef __eq__(self, other):\n\t\treturn self.v == other.v\nd = {K(1): \"a\", K(2): \"b\", K(3): \"c\"}\ndel d[K(1)]\n(len(d), d[K(2)], d[K(3)], K(1) in d)", "(2, 'b', 'c', False)"},
+		{"class K:\n\tdef __init__(self, v):\n\t\tself.v = v\n\tdef __hash__(self):\n\t\treturn 0\n\tdef __eq__(self, other):\n\t\treturn self.v == other.v\n(len(set([K(1), K(1), K(2)])), K(2) in set([K(2)]), {K(1): 1} == {K(1): 1})", "(2, True, True)"},
+		{"class P:\n\tdef __eq__(self, other):\n\t\treturn True\n{P(): 1}", "TypeError: unhashable type: 'P'"},
+		{"class P:\n\t__hash__ = None\nhash(P())", "TypeError: unhashable type: 'P'"},
+		{"import copy\nclass A:\n\tpass\nd = copy.deepcopy({A(): 1})\nd[list(d.keys())[0]]", "1"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"a = [1, [2]]\nb = a.copy()\nb.append(3)\nb[1].append(4)\n(a, b)", "([1, [2, 4]], [1, [2, 4], 3])"},
+		{`a = {"k": [1]}` + "\nb = a.copy()\nb[\"j\"] = 2\nb[\"k\"].append(5)\n(a, b)", "({'k': [1, 5]}, {'k': [1, 5], 'j': 2})"},
+		{"import copy\na = [1, [2]]\nb = copy.copy(a)\n(a == b, a is b, a[1] is b[1])", "(True, False, True)"},
+		{"import copy\na = [1, [2], {\"k\": (3, [4])}]\nb = copy.deepcopy(a)\nb[1].append(9)\nb[2][\"k\"][1].append(9)\n(a, b)", "([1, [2], {'k': (3, [4])}], [1, [2, 9], {'k': (3, [4, 9])}])"},
+		{"import copy\nshared = [0]\na = [shared, shared]\nb = copy.deepcopy(a)\n(b[0] is b[1], b[0] is shared)", "(True, False)"},
+		{"import copy\na = [1]\na.append(a)\nb = copy.deepcopy(a)\n(b[1] is b, b is a, len(b))", "(True, False, 2)"},
+		{"import copy\nclass Node:\n\tdef __init__(self):\n\t\tself.items = []\n\t\tself.me = self\nn = Node()\nm = copy.deepcopy(n)\nm.items.append(1)\n(n.items, m.items, m.me is m, type(m) is Node)", "([], [1], True, True)"},
+		{"import copy\nclass P:\n\tpass\np = P()\np.x = [1]\nq = copy.copy(p)\nq.x = 2\n(p.x, q.x, type(q) is P)", "([1], 2, True)"},
+		{"import copy\ns = set([1, 2])\nt = copy.copy(s)\n(t == s, t is s)", "(True, False)"},
+		{"import copy\n(copy.deepcopy(5), copy.copy(\"a\"), copy.deepcopy(None))", "(5, 'a', None)"},
+		{"import copy\ndef gen():\n\tyield 1\ncopy.deepcopy([gen()])", "TypeError: cannot copy 'generator' object"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`b"abc"`, "b'abc'"},
+		{`(b"", type(b"x"))`, "(b'', <class 'bytes'>)"},
+		{`str(b"hi")`, "b'hi'"},
+		{`"héllo".encode()`, `b'h\xc3\xa9llo'`},
+		{`b"h" + "é".encode("utf-8") + b"llo"`, `b'h\xc3\xa9llo'`},
+		{`"héllo".encode().decode()`, "héllo"},
+		{`("é".encode("latin-1"), "é".encode(encoding="ascii", errors="replace"), "aé".encode("ascii", "ignore"))`, `(b'\xe9', b'?', b'a')`},
+		{`"aé".encode("ascii")`, `UnicodeEncodeError: 'ascii' codec can't encode character '\xe9' in position 1: ordinal not in range(128)`},
+		{`bytes([104, 255]).decode()`, "UnicodeDecodeError: 'utf-8' codec can't decode byte 0xff in position 1: invalid start byte"},
+		{`(bytes([104, 255]).decode("utf-8", "replace"), bytes([104, 255]).decode(errors="ignore"), bytes([233]).decode("latin1"))`, "('h\uFFFD', 'h', 'é')"},
+		{`bytes([233]).decode("ascii")`, "UnicodeDecodeError: 'ascii' codec can't decode byte 0xe9 in position 0: ordinal not in range(128)"},
+		{`b"x".decode("klingon")`, "LookupError: unknown encoding: klingon"},
+		{"try:\n\tbytes([255]).decode()\nexcept ValueError:\n\tx = 1\nx", "1"},
+		{`(bytes(), bytes(3), bytes([1, 2]), bytes("hi", "ascii"), bytes(b"z"))`, `(b'', b'\x00\x00\x00', b'\x01\x02', b'hi', b'z')`},
+		{"bytes([256])", "ValueError: bytes must be in range(0, 256)"},
+		{`bytes("hi")`, "TypeError: string argument without an encoding"},
+		{`b = b"hello"` + "\n(len(b), b[0], b[-1], b[1:3], b[::-1], list(b[:2]))", "(5, 104, 111, b'el', b'olleh', [104, 101])"},
+		{`(b"ell" in b"hello", 104 in b"hello", b"x" in b"hello")`, "(True, True, False)"},
+		{`"a" in b"abc"`, "TypeError: a bytes-like object is required, not 'str'"},
+		{`(b"ab" * 2, 0 * b"ab", b"ab" == b"ab", b"ab" == "ab", b"ab" < b"b", b"ab" >= b"ab")`, "(b'abab', b'', True, False, True, True)"},
+		{`b"a" + "b"`, "TypeError: unsupported operand type(s) for +: 'bytes' and 'str'"},
+		{`(bool(b""), bool(b"0"), {b"k": 1}[b"k"], b"ab".hex())`, "(False, True, 1, '6162')"},
+		{`(len(b"\x00"), b"\x00\xff", b"a\nb\tc\\d", b"\101\0", b"\q", b"\'")`, `(1, b'\x00\xff', b'a\nb\tc\\d', b'A\x00', b'\\q', b"'")`},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestExceptionChaining(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"try:\n\t1 / 0\nexcept ZeroDivisionError:\n\ttry:\n\t\traise ValueError\n\texcept ValueError as e:\n\t\tx = e\n(type(x.__context__), x.__cause__, x.__suppress_context__)", "(<class 'ZeroDivisionError'>, None, False)"},
+		{"try:\n\ttry:\n\t\t1 / 0\n\texcept ZeroDivisionError as e:\n\t\traise KeyError(\"k\") from e\nexcept KeyError as e:\n\tx = e\n(type(x.__cause__), x.__cause__ is x.__context__, x.__suppress_context__)", "(<class 'ZeroDivisionError'>, True, True)"},
+		{"try:\n\ttry:\n\t\t1 / 0\n\texcept ZeroDivisionError:\n\t\traise KeyError from None\nexcept KeyError as e:\n\tx = e\n(x.__cause__, x.__suppress_context__, type(x.__context__))", "(None, True, <class 'ZeroDivisionError'>)"},
+		{"try:\n\traise ValueError from OSError\nexcept ValueError as e:\n\tx = e\ntype(x.__cause__)", "<class 'OSError'>"},
+		{"raise ValueError from 5", "TypeError: exception causes must derive from BaseException"},
+		{"e = ValueError(\"x\")\n(e.__context__, e.__cause__)", "(None, None)"},
+		{"try:\n\ttry:\n\t\t1 / 0\n\tfinally:\n\t\t[][1]\nexcept IndexError as e:\n\tx = e\ntype(x.__context__)", "<class 'ZeroDivisionError'>"},
+		{"try:\n\ttry:\n\t\traise ValueError(\"a\")\n\texcept ValueError as e:\n\t\tfirst = e\n\t\traise\nexcept ValueError as e:\n\tsecond = e\n(first is second, second.__context__)", "(True, None)"},
+		{"try:\n\traise ValueError\nexcept ValueError as e:\n\ttry:\n\t\traise e\n\texcept ValueError as f:\n\t\tx = f.__context__\nx", "None"},
+		{"log = []\ndef f():\n\ttry:\n\t\treturn 1\n\tfinally:\n\t\tlog.append(\"f\")\n(f(), log)", "(1, ['f'])"},
+		{"log = []\nfor i in range(3):\n\ttry:\n\t\tbreak\n\tfinally:\n\t\tlog.append(i)\nlog", "[0]"},
+		{"log = []\nfor i in range(3):\n\ttry:\n\t\tcontinue\n\tfinally:\n\t\tlog.append(i)\nlog", "[0, 1, 2]"},
+		{"log = []\ndef f():\n\ttry:\n\t\t1 / 0\n\texcept ZeroDivisionError:\n\t\t[][1]\n\tfinally:\n\t\tlog.append(\"f\")\ntry:\n\tf()\nexcept IndexError:\n\tlog.append(\"caught\")\nlog", "['f', 'caught']"},
+		{"def f():\n\ttry:\n\t\t1 / 0\n\tfinally:\n\t\treturn \"swallowed\"\nf()", "swallowed"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestNumericPromotion(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"(1 + 2.5, 2.5 + 1, 3 - 0.5, 2 * 1.5, 1.5 ** 2, 2 ** 0.5 * 2 ** 0.5 > 1.99)", "(3.5, 3.5, 2.5, 3.0, 2.25, True)"},
+		{"(7 / 2, 6 / 3, -7 / 2, 1 / 4.0)", "(3.5, 2.0, -3.5, 0.25)"},
+		{"(7 // 2, -7 // 2, 7.5 // 2, 7 // 2.0, 7 % 2.5, -7 % 2.0)", "(3, -4, 3.0, 3.0, 2.0, 1.0)"},
+		{"(type(6 / 3), type(6 // 3), type(6 * 1.0), type(True + 0.5))", "(<class 'float'>, <class 'int'>, <class 'float'>, <class 'float'>)"},
+		{"(1 < 1.5, 2 > 1.5, 1 == 1.0, 1.0 != 1, 2 < 1.5, True == 1.0)", "(True, True, True, False, False, True)"},
+		{"(1 < 2.0 < 3, 3 > 2.5 > 2)", "(True, True)"},
+		{"x = 1\nx += 0.5\nx /= 3\nx", "0.5"},
+		{"(max(1, 2.5), min(3, 0.5), sum([1, 0.5, 2]), [1, 2] == [1.0, 2.0])", "(2.5, 0.5, 3.5, True)"},
+		{"1 / 0", "ZeroDivisionError: division by zero"},
+		{"1 / 0.0", "ZeroDivisionError: float division by zero"},
+		{"5 // 0.0", "ZeroDivisionError: float floor division by zero"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestScopeIntrospection(t *testing.T) {
+	tests := []struct {
+		input string
+		want string
+	}{
+		{"def f(a):\n    b = 2\n    return locals()\nf(1)", "{'a': 1, 'b': 2}"},
+		{"x = 1\ndef f():\n    y = 2\n    return globals()\nf()", "{'f': <function f>, 'x': 1}"},
+		{"x = 1\ny = 2\ndir()", "['x', 'y']"},
+		{"class A:\n    k = 1\nclass B(A):\n    def m(self):\n        pass\nb = B()\nb.z = 3\ndir(b)", "['__doc__', 'k', 'm', 'z']"},
+		{"dir(1)", "[]"},
+		{"\"pop\" in dir([])", "True"},
+		{"class A:\n    def __init__(self):\n        self.x = 1\nvars(A())", "{'x': 1}"},
+		{"def f():\n    v = 1\n    return vars()\nf()", "{'v': 1}"},
+		{"vars(1)", "TypeError: vars() argument must have __dict__ attribute"},
+		{"locals(1)", "TypeError: locals() takes no arguments (1 given)"},
+		{"d = locals()\nd[\"q\"] = 1\n\"q\" in dir()", "False"},
+	}
+
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got.Visit())
+		}
+	}
+}
+
+func TestExecEval(t *testing.T) {
+	tests := []struct {
+		input string
+		want string
+	}{
+		{"x = 2\neval(\"x * 3\")", "6"},
+		{"eval(b\"1 + 1\")", "2"},
+		{"exec(\"y = 4\")\ny", "4"},
+		{"exec(\"y = 4\")", "None"},
+		{"def f():\n    exec(\"z = 1\")\n    return locals()\nf()", "{'z': 1}"},
+		{"g = {\"a\": 1}\nexec(\"b = a + 1\", g)\ng", "{'a': 1, 'b': 2}"},
+		{"g = {\"a\": 1}\nexec(\"del a\", g)\ng", "{}"},
+		{"a = 1\neval(\"a\", {\"a\": 5})", "5"},
+		{"a = 1\neval(\"a + b\", None, {\"b\": 2})", "3"},
+		{"g = {}\nl = {}\nexec(\"c = 3\", g, l)\n(g, l)", "({}, {'c': 3})"},
+		{"eval(\"1 +\")", "SyntaxError: error at line 1, column 4: invalid syntax: unexpected EOF"},
+		{"eval(\"x = 1\")", "SyntaxError: error at line 1, column 3: invalid syntax: unexpected ="},
+		{"eval(\"missing\")", "NameError: name 'missing' is not defined"},
+		{"exec(1)", "TypeError: exec() arg 1 must be a string or bytes object"},
+		{"eval(\"1\", [])", "TypeError: globals must be a dict, not list"},
+		{"eval(\"1\", {1: 2})", "TypeError: eval keys must be str, not int"},
+	}
+
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got.Visit())
+		}
+	}
+}
+
+func TestPerInterpreterBuiltins(t *testing.T) {
+	eval := func(input string, env *interpreter.Environment) string {
+		p, program := parser.StartParseRepl(input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", input, p.Errors())
+		}
+		return Evaluate(&program, env).Visit()
+	}
+	custom := NewEnv()
+	custom.Builtins()["double"] = &interpreter.Builtin{
+		Name: "double",
+		Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+			return &interpreter.Int{Val: args[0].(*interpreter.Int).Val * 2}
+		},
+	}
+	delete(custom.Builtins(), "len")
+	plain := NewEnv()
+
+	tests := []struct {
+		input string
+		env *interpreter.Environment
+		want string
+	}{
+		{"double(21)", custom, "42"},
+		{"def f():\n    return double(1)\nf()", custom, "2"},
+		{"exec(\"r = double(3)\", {})\nr", custom, "NameError: name 'r' is not defined"},
+		{"g = {}\nexec(\"r = double(3)\", g)\ng", custom, "{'r': 6}"},
+		{"len([1])", custom, "NameError: name 'len' is not defined"},
+		{"double(21)", plain, "NameError: name 'double' is not defined"},
+		{"len([1])", plain, "1"},
+		{"len([1])", interpreter.NewEnv(), "1"},
+	}
+
+	for _, tt := range tests {
+		if got := eval(tt.input, tt.env); got != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestComparisonMatrix(t *testing.T) {
+	ops := []string{"<", ">", "<=", ">=", "==", "!="}
+	holds := func(op string, cmp int) bool {
+		switch op {
+		case "<":
+			return cmp < 0
+		case ">":
+			return cmp > 0
+		case "<=":
+			return cmp <= 0
+		case ">=":
+			return cmp >= 0
+		case "==":
+			return cmp == 0
+		}
+		return cmp != 0
+	}
+	pairs := []struct {
+		left, right string
+		cmp int
+	}{
+		{"1", "2", -1},
+		{"2", "2", 0},
+		{"3", "2", 1},
+		{"-1", "0", -1},
+		{"1.5", "2.5", -1},
+		{"2.5", "2.5", 0},
+		{"3.5", "2.5", 1},
+		{"1", "1.5", -1},
+		{"2", "2.0", 0},
+		{"2", "1.5", 1},
+		{"1.5", "2", -1},
+		{"2.0", "2", 0},
+		{"2.5", "2", 1},
+		{"True", "2", -1},
+		{"True", "1", 0},
+		{"\"a\"", "\"b\"", -1},
+		{"\"ab\"", "\"ab\"", 0},
+		{"\"b\"", "\"ab\"", 1},
+		{"\"\"", "\"a\"", -1},
+		{"[1, 2]", "[1, 3]", -1},
+		{"[1, 2]", "[1, 2]", 0},
+		{"[1, 2]", "[1]", 1},
+		{"[]", "[0]", -1},
+		{"[2]", "[1, 5]", 1},
+		{"(1, \"b\")", "(1, \"a\")", 1},
+		{"(1, 2.0)", "(1, 2)", 0},
+		{"((1, 2), 3)", "((1, 3), 0)", -1},
+		{"()", "()", 0},
+	}
+
+	for _, pair := range pairs {
+		for _, op := range ops {
+			input := pair.left + " " + op + " " + pair.right
+			want := "False"
+			if holds(op, pair.cmp) {
+				want = "True"
+			}
+			if got := testEval(t, input); got.Visit() != want {
+				t.Errorf("eval(%q); want %s; got %v", input, want, got.Visit())
+			}
+		}
+	}
+
+	for _, op := range []string{"<", ">", "<=", ">="} {
+		input := "1 " + op + " \"a\""
+		want := "TypeError: '" + op + "' not supported between instances of 'int' and 'str'"
+		if got := testEval(t, input); got.Visit() != want {
+			t.Errorf("eval(%q); want %s; got %v", input, want, got.Visit())
+		}
+	}
+
+	mixed := []struct {
+		input string
+		want  string
+	}{
+		{"[1] < (1,)", "TypeError: '<' not supported between instances of 'list' and 'tuple'"},
+		{"[1, \"a\"] < [1, 2]", "TypeError: '<' not supported between instances of 'str' and 'int'"},
+		{"[1, \"a\"] < [2, 2]", "True"},
+		{"l = []\nl.append(l)\nl < l", "False"},
+	}
+	for _, tt := range mixed {
+		if got := testEval(t, tt.input); got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got.Visit())
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`print(1, "a", [2])`, "1 a [2]\n"},
+		{`print()`, "\n"},
+		{`print(1, 2, sep=", ")`, "1, 2\n"},
+		{`print(1, 2, sep="", end="")`, "12"},
+		{"def none():\n\tpass\nprint(1, 2, sep=none(), end=none(), file=none())", "1 2\n"},
+		{"class P:\n\tdef __str__(self):\n\t\treturn \"point\"\nprint(P(), [1])", "point [1]\n"},
+		{"try:\n\t{}[\"k\"]\nexcept KeyError as e:\n\tprint(e)", "k\n"},
+	}
+	defer func(w io.Writer) { Stdout = w }(Stdout)
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Stdout = &out
+		got := testEval(t, tt.input)
+		if got == nil || got.Type() != interpreter.NONE || out.String() != tt.want {
+			t.Errorf("eval(%q); want output %q; got %q (result %v)", tt.input, tt.want, out.String(), got)
+		}
+	}
+}
+
+func TestInput(t *testing.T) {
+	defer func(w io.Writer, r io.Reader) { Stdout, Stdin = w, r }(Stdout, Stdin)
+	var out bytes.Buffer
+	Stdout = &out
+	Stdin = strings.NewReader("Ash\r\n42\nlast")
+
+	input := "name = input(\"name? \")\nage = int(input())\nrest = input()\n(name, age, rest)"
+	want := "('Ash', 42, 'last')"
+	if got := testEval(t, input); got == nil || got.Visit() != want {
+		t.Errorf("eval(%q); want %s; got %v", input, want, got)
+	}
+	if out.String() != "name? " {
+		t.Errorf("want prompt %q written; got %q", "name? ", out.String())
+	}
+	want = "EOFError: EOF when reading a line"
+	if got := testEval(t, "input()"); got == nil || got.Visit() != want {
+		t.Errorf("eval(%q); want %s; got %v", "input()", want, got)
+	}
+}
+
+func TestPrintFile(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"class W:\n\tdef __init__(self):\n\t\tself.text = \"\"\n\tdef write(self, s):\n\t\tself.text += s\nw = W()\nprint(1, 2, file=w)\nprint(3, end=\"\", file=w)\nw.text", "1 2\n3"},
+		{`print(1, sep=2)`, "TypeError: sep must be None or a string, not int"},
+		{`print(1, flush=1)`, "TypeError: 'flush' is an invalid keyword argument for print()"},
+		{`print(1, file=3)`, "AttributeError: 'int' object has no attribute 'write'"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"def outer():\n\tx = 1\n\tdef inner():\n\t\treturn x\n\treturn inner\nouter()()", "1"},
+		{"def adder(a):\n\tdef add(b):\n\t\treturn a + b\n\treturn add\nadd2 = adder(2)\nadd3 = adder(3)\nadd2(1) * 10 + add3(1)", "34"},
+		{"def outer():\n\tx = 1\n\tdef inner():\n\t\treturn x\n\tx = 5\n\treturn inner()\nouter()", "5"},
+		{"def make():\n\tn = 0\n\tdef inc():\n\t\tnonlocal n\n\t\tn += 1\n\t\treturn n\n\treturn inc\na = make()\nb = make()\na()\na()\nb() * 10 + a()", "13"},
+		{"def make():\n\tcount = 0\n\tdef inc():\n\t\tnonlocal count\n\t\tcount += 1\n\tdef get():\n\t\treturn count\n\treturn inc, get\ninc, get = make()\ninc()\ninc()\nget()", "2"},
+		{"def a():\n\tx = 1\n\tdef b():\n\t\tdef c():\n\t\t\treturn x\n\t\treturn c\n\treturn b\na()()()", "1"},
+		{"def outer():\n\tdef fact(n):\n\t\tif n < 2:\n\t\t\treturn 1\n\t\treturn n * fact(n - 1)\n\treturn fact(5)\nouter()", "120"},
+		{"def outer():\n\tx = 1\n\tdef inner():\n\t\tx = 2\n\t\treturn x\n\tinner()\n\treturn x\nouter()", "1"},
+		{"def outer():\n\tx = 1\n\tdef inner():\n\t\tx += 1\n\t\treturn x\n\treturn inner()\nouter()", "UnboundLocalError: cannot access local variable 'x' where it is not associated with a value"},
+		{"x = 1\ndef f():\n\ty = x\n\tx = 2\n\treturn y\nf()", "UnboundLocalError: cannot access local variable 'x' where it is not associated with a value"},
+		{"def outer():\n\tdef inner():\n\t\tnonlocal x\n\t\tx = 2\n\tinner()\n\treturn x\n\tx = 1\nouter()", "2"},
+		{"def outer():\n\tdef inner():\n\t\treturn 1\n\treturn inner\nouter()", "<function inner>"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"total = 0\nfor x in [1, 2, 3]:\n\ttotal += x\ntotal", "6"},
+		{"s = \"\"\nfor c in \"abc\":\n\ts = c + s\ns", "cba"},
+		{"total = 0\nfor a, b in [(1, 2), (3, 4)]:\n\ttotal += a * b\ntotal", "14"},
+		{"keys = \"\"\nfor k in {\"a\": 1, \"b\": 2}:\n\tkeys += k\nkeys", "ab"},
+		{"n = 0\nfor x in [1, 2, 3]:\n\tif x == 2:\n\t\tcontinue\n\tn += x\nn", "4"},
+		{"found = 0\nfor x in [1, 2, 3]:\n\tif x == 2:\n\t\tfound = x\n\t\tbreak\nelse:\n\tfound = 99\nfound", "2"},
+		{"found = 0\nfor x in [1, 2, 3]:\n\tif x == 5:\n\t\tbreak\nelse:\n\tfound = 99\nfound", "99"},
+		{"ran = 0\nfor x in []:\n\tpass\nelse:\n\tran = 1\nran", "1"},
+		{"def first(xs):\n\tfor x in xs:\n\t\treturn x\n\treturn 0\nfirst([7, 8]) + first([])", "7"},
+		{"def gen():\n\tyield 1\n\tyield 2\nt = 0\nfor x in gen():\n\tt += x\nt", "3"},
+		{"for x in 5:\n\tpass", "TypeError: 'int' object is not iterable"},
+		{"for a, b in [1]:\n\tpass", "TypeError: cannot unpack non-iterable int object"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestWhileLoops(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"i = 0\ntotal = 0\nwhile i < 4:\n\ti += 1\n\ttotal += i\ntotal", "10"},
+		{"i = 0\nwhile i < 0:\n\ti += 1\ni", "0"},
+		{"i = 0\nn = 0\nwhile i < 5:\n\ti += 1\n\tif i == 3:\n\t\tcontinue\n\tn += i\nn", "12"},
+		{"i = 0\nwhile 1 == 1:\n\ti += 1\n\tif i == 3:\n\t\tbreak\ni", "3"},
+		{"i = 0\nwhile i < 3:\n\ti += 1\nelse:\n\ti = 99\ni", "99"},
+		{"i = 0\nwhile i < 3:\n\ti += 1\n\tbreak\nelse:\n\ti = 99\ni", "1"},
+		{"def f():\n\ti = 0\n\twhile i < 10:\n\t\ti += 1\n\t\tif i == 4:\n\t\t\treturn i\nf()", "4"},
+		{"while x < 1:\n\tpass", "NameError: name 'x' is not defined"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestMaxLoopIterations(t *testing.T) {
+	run := func(env *interpreter.Environment, input string) interpreter.Item {
+		_, program := parser.StartParseRepl(input)
+		return Evaluate(&program, env)
+	}
+	env := NewEnv()
+	SetMaxLoopIterations(env, 3)
+
+	input := "i = 0\nwhile i < 3:\n\ti += 1\ni"
+	if got := run(env, input); got == nil || got.Visit() != "3" {
+		t.Errorf("eval(%q); want 3; got %v", input, got)
+	}
+	input = "while 1 == 1:\n\tpass"
+	want := "RuntimeError: while loop exceeded 3 iterations"
+	if got := run(env, input); got == nil || got.Visit() != want {
+		t.Errorf("eval(%q); want %s; got %v", input, want, got)
+	}
+	input = "i = 0\nwhile i < 5:\n\ti += 1\ni"
+	if got := run(NewEnv(), input); got == nil || got.Visit() != "5" {
+		t.Errorf("eval(%q) in another interpreter; want 5; got %v", input, got)
+	}
+}
+
+func TestLen(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`len("")`, "0"},
+		{`len("héllo")`, "5"},
+		{`len([1, 2, 3])`, "3"},
+		{`len((1,))`, "1"},
+		{`len({"a": 1, "b": 2})`, "2"},
+		{`len(set([1, 2, 2, 3, 1]))`, "3"},
+		{`len(set())`, "0"},
+		{`len(range(3, 9))`, "6"},
+		{"class C:\n\tdef __len__(self):\n\t\treturn 4\nlen(C())", "4"},
+		{`len(5)`, "TypeError: object of type 'int' has no len()"},
+		{`len(None)`, "TypeError: object of type 'NoneType' has no len()"},
+		{`len()`, "TypeError: len() takes exactly one argument (0 given)"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"set()", "set()"},
+		{"set([3, 1, 3, 2])", "{3, 1, 2}"},
+		{`set("abca")`, "{'a', 'b', 'c'}"},
+		{"t = 0\nfor x in set([1, 2, 2]):\n\tt += x\nt", "3"},
+		{"bool(set())", "False"},
+		{"set([[1]])", "TypeError: unhashable type: 'list'"},
+		{"set(1)", "TypeError: 'int' object is not iterable"},
+		{"set([], [])", "TypeError: set expected at most 1 argument, got 2"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestTypeIntrospection(t *testing.T) {
+	classes := "class A:\n\tpass\nclass B(A):\n\tpass\n"
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"type(1)", "<class 'int'>"},
+		{"type(\"s\")", "<class 'str'>"},
+		{"type(True)", "<class 'bool'>"},
+		{"type(1.5)", "<class 'float'>"},
+		{"type([])", "<class 'list'>"},
+		{"type(None)", "<class 'NoneType'>"},
+		{"type(range(2))", "<class 'range'>"},
+		{"type(int)", "<class 'type'>"},
+		{"type(B())", "<class 'B'>"},
+		{"type(B)", "<class 'type'>"},
+		{"type(1) == int", "True"},
+		{"type(B()) == A", "False"},
+		{"isinstance(1, int)", "True"},
+		{"isinstance(True, int)", "True"},
+		{"isinstance(1, bool)", "False"},
+		{"isinstance(\"s\", (int, str))", "True"},
+		{"isinstance([], (int, dict))", "False"},
+		{"isinstance(B(), A)", "True"},
+		{"isinstance(A(), B)", "False"},
+		{"isinstance(A(), int)", "False"},
+		{"isinstance(A, type)", "True"},
+		{"isinstance(ValueError(), Exception)", "True"},
+		{"issubclass(B, A)", "True"},
+		{"issubclass(A, B)", "False"},
+		{"issubclass(bool, int)", "True"},
+		{"issubclass(int, (str, int))", "True"},
+		{"issubclass(B, int)", "False"},
+		{"issubclass(KeyError, LookupError)", "True"},
+		{"isinstance(range(3), range)", "True"},
+		{"bool(\"x\")", "True"},
+		{"isinstance(1, 2)", "TypeError: isinstance() arg 2 must be a type or tuple of types"},
+		{"issubclass(1, int)", "TypeError: issubclass() arg 1 must be a class"},
+		{"type(1, 2)", "TypeError: type() takes 1 argument"},
+		{"type(len)()", "TypeError: cannot create 'builtin_function_or_method' instances"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, classes+tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestConversions(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"int()", "0"},
+		{"int(7)", "7"},
+		{"int(True)", "1"},
+		{"int(3.9)", "3"},
+		{"int(0.0 - 3.9)", "-3"},
+		{`int(" 42 ")`, "42"},
+		{`int("-17")`, "-17"},
+		{`int("007")`, "7"},
+		{`int("ff", 16)`, "255"},
+		{`int("0xff", 16)`, "255"},
+		{`int("0b101", 0)`, "5"},
+		{`int("12abc")`, "ValueError: invalid literal for int() with base 10: '12abc'"},
+		{`int("")`, "ValueError: invalid literal for int() with base 10: ''"},
+		{`int("12", 1)`, "ValueError: int() base must be >= 2 and <= 36, or 0"},
+		{"int(12, 16)", "TypeError: int() can't convert non-string with explicit base"},
+		{"int([])", "TypeError: int() argument must be a string or a real number, not 'list'"},
+		{"int(1e400)", "OverflowError: cannot convert float infinity to integer"},
+		{"float()", "0.0"},
+		{"float(3)", "3.0"},
+		{"float(False)", "0.0"},
+		{`float("2.5")`, "2.5"},
+		{`float(" -1e3 ")`, "-1000.0"},
+		{`float("inf")`, "inf"},
+		{`float("abc")`, "ValueError: could not convert string to float: 'abc'"},
+		{"float(None)", "TypeError: float() argument must be a string or a real number, not 'NoneType'"},
+		{"str()", ""},
+		{"str(12)", "12"},
+		{"str(1.5)", "1.5"},
+		{"str(None)", "None"},
+		{"str([1, \"a\"])", "[1, 'a']"},
+		{"\"n=\" + str(3)", "n=3"},
+		{"class P:\n\tdef __str__(self):\n\t\treturn \"point\"\nstr(P())", "point"},
+		{"class Q:\n\tdef __str__(self):\n\t\treturn 1\nstr(Q())", "TypeError: __str__ returned non-string (type int)"},
+		{"bool(0.0)", "False"},
+		{"int(float(\"2.75\") * 4.0)", "11"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestNumericBuiltins(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"abs(-5)", "5"},
+		{"abs(3)", "3"},
+		{"abs(0.0 - 2.5)", "2.5"},
+		{"abs(True)", "1"},
+		{`abs("a")`, "TypeError: bad operand type for abs(): 'str'"},
+		{"min(3, 1, 2)", "1"},
+		{"max(3, 1, 2)", "3"},
+		{"min([4, 2, 8])", "2"},
+		{"max((4, 2, 8))", "8"},
+		{`max("hello")`, "o"},
+		{`min(["b", "a", "c"])`, "a"},
+		{"max(range(5))", "4"},
+		{"max([1, -7, 3], key=abs)", "-7"},
+		{"def neg(x):\n\treturn -x\nmin(1, 5, 3, key=neg)", "5"},
+		{"min([], default=0)", "0"},
+		{"max([1, 1, 1], key=None)", "1"},
+		{"min([])", "ValueError: min() arg is an empty sequence"},
+		{"max(range(0))", "ValueError: max() arg is an empty sequence"},
+		{"min()", "TypeError: min expected at least 1 argument, got 0"},
+		{"max(1, 2, default=0)", "TypeError: Cannot specify a default for max() with multiple positional arguments"},
+		{"max([1, 2], reverse=True)", "TypeError: 'reverse' is an invalid keyword argument for max()"},
+		{`min(1, "a")`, "TypeError: '<' not supported between instances of 'str' and 'int'"},
+		{"sum([1, 2, 3])", "6"},
+		{"sum([])", "0"},
+		{"sum(range(101))", "5050"},
+		{"sum([1, 2], 10)", "13"},
+		{"sum([0.5, 0.25], 0.0)", "0.75"},
+		{`sum(["a", "b"], "")`, "TypeError: sum() can't sum strings [use ''.join(seq) instead]"},
+		{"sum(5)", "TypeError: 'int' object is not iterable"},
+		{"round(2.5)", "2"},
+		{"round(3.5)", "4"},
+		{"round(0.0 - 2.5)", "-2"},
+		{"round(2.675, 1)", "2.7"},
+		{"round(1.25, 1)", "1.2"},
+		{"round(7)", "7"},
+		{"round(1250, -2)", "1200"},
+		{"round(1350, -2)", "1400"},
+		{"round(0 - 1250, -2)", "-1200"},
+		{"round(1234.5678, 2)", "1234.57"},
+		{"round(5, 1)", "5"},
+		{`round("x")`, "TypeError: type str doesn't define __round__ method"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestSortedAndReversed(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"sorted([3, 1, 2])", "[1, 2, 3]"},
+		{"sorted([3, 1, 2], reverse=True)", "[3, 2, 1]"},
+		{`sorted("cab")`, "['a', 'b', 'c']"},
+		{"sorted((5, 4))", "[4, 5]"},
+		{"sorted({2: 0, 1: 0})", "[1, 2]"},
+		{"sorted([])", "[]"},
+		{"sorted([-3, 1, -2], key=abs)", "[1, -2, -3]"},
+		{"sorted([-3, 1, -2], key=abs, reverse=True)", "[-3, -2, 1]"},
+		{"def first(p):\n\treturn p[0]\nsorted([(2, \"a\"), (1, \"b\"), (2, \"c\"), (1, \"d\")], key=first)", "[(1, 'b'), (1, 'd'), (2, 'a'), (2, 'c')]"},
+		{"def first(p):\n\treturn p[0]\nsorted([(2, \"a\"), (1, \"b\"), (2, \"c\")], key=first, reverse=True)", "[(2, 'a'), (2, 'c'), (1, 'b')]"},
+		{"xs = [2, 1]\nys = sorted(xs)\nxs", "[2, 1]"},
+		{"sorted({\"b\": 2, \"a\": 1, \"c\": 0}.items())", "[('a', 1), ('b', 2), ('c', 0)]"},
+		{"sorted([[2, 1], [1, 2], [1]])", "[[1], [1, 2], [2, 1]]"},
+		{`sorted([1, "a"])`, "TypeError: '<' not supported between instances of 'str' and 'int'"},
+		{"sorted(1)", "TypeError: 'int' object is not iterable"},
+		{"sorted([1], cmp=1)", "TypeError: 'cmp' is an invalid keyword argument for sort()"},
+		{"sorted()", "TypeError: sorted expected 1 argument, got 0"},
+		{"r = reversed([1, 2, 3])\n(next(r), next(r))", "(3, 2)"},
+		{"s = \"\"\nfor c in reversed(\"abc\"):\n\ts += c\ns", "cba"},
+		{"t = 0\nfor i in reversed(range(4)):\n\tt = t * 10 + i\nt", "3210"},
+		{"a, b = reversed((1, 2))\n(a, b)", "(2, 1)"},
+		{"r = reversed([1])\nnext(r)\nnext(r, 0)", "0"},
+		{"reversed([])", "<list_reverseiterator object>"},
+		{"reversed(set())", "TypeError: 'set' object is not reversible"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestEnumerateAndZip(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"s = 0\nfor i, x in enumerate([10, 20, 30]):\n\ts += i * x\ns", "80"},
+		{"e = enumerate(\"ab\", 1)\n(next(e), next(e))", "((1, 'a'), (2, 'b'))"},
+		{"e = enumerate(\"ab\", start=5)\nnext(e)", "(5, 'a')"},
+		{"e = enumerate([])\nnext(e, None)", "None"},
+		{"s = \"\"\nfor a, b in zip(\"abc\", [1, 2, 3]):\n\ts += a * b\ns", "abbccc"},
+		{"z = zip([1, 2, 3], \"xy\")\n(next(z), next(z), next(z, 0))", "((1, 'x'), (2, 'y'), 0)"},
+		{"z = zip([1], [2], [3])\nnext(z)", "(1, 2, 3)"},
+		{"next(zip(), 7)", "7"},
+		{"a, b = zip([1, 2], [3, 4])\nb", "(2, 4)"},
+		{"def nat():\n\tn = 0\n\twhile True:\n\t\tyield n\n\t\tn += 1\nt = 0\nfor i, n in zip(range(3), nat()):\n\tt += n\nt", "3"},
+		{"def nat():\n\tn = 0\n\twhile True:\n\t\tyield n\n\t\tn += 1\nfor i, n in enumerate(nat()):\n\tif i == 4:\n\t\tbreak\nn", "4"},
+		{"def bad():\n\tyield 1\n\traise ValueError(\"boom\")\nfor x in enumerate(bad()):\n\tpass", "ValueError: boom"},
+		{"zip([1], 2)", "TypeError: zip argument #2 must support iteration"},
+		{"enumerate(1)", "TypeError: 'int' object is not iterable"},
+		{"enumerate([], \"a\")", "TypeError: 'str' object cannot be interpreted as an integer"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestMapAndFilter(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"def sq(x):\n\treturn x * x\nlist(map(sq, range(4)))", "[0, 1, 4, 9]"},
+		{"list(map(str, [1, 2]))", "['1', '2']"},
+		{"def add(a, b):\n\treturn a + b\ntuple(map(add, [1, 2, 3], [10, 20]))", "(11, 22)"},
+		{"s = 0\nfor n in map(abs, [-1, -2, 3]):\n\ts += n\ns", "6"},
+		{"def odd(x):\n\treturn x % 2\nlist(filter(odd, range(6)))", "[1, 3, 5]"},
+		{"list(filter(None, [0, 1, \"\", \"a\", None, [], [0]]))", "[1, 'a', [0]]"},
+		{"f = filter(None, [0, 0])\nnext(f, \"done\")", "done"},
+		{"def nat():\n\tn = 0\n\twhile True:\n\t\tyield n\n\t\tn += 1\nm = map(abs, nat())\n(next(m), next(m))", "(0, 1)"},
+		{"def inv(x):\n\treturn 1 / x\nlist(map(inv, [1, 0]))", "ZeroDivisionError: division by zero"},
+		{"list()", "[]"},
+		{"tuple(\"ab\")", "('a', 'b')"},
+		{"list(1)", "TypeError: 'int' object is not iterable"},
+		{"list([], [])", "TypeError: list expected at most 1 argument, got 2"},
+		{"map(abs)", "TypeError: map() must have at least two arguments."},
+		{"map(abs, 1)", "TypeError: 'int' object is not iterable"},
+		{"filter(None, 1)", "TypeError: 'int' object is not iterable"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestStrMethods(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`"Hello".upper()`, "HELLO"},
+		{`"Hello".lower()`, "hello"},
+		{`"  hi  ".strip()`, "hi"},
+		{`"xxhixx".strip("x")`, "hi"},
+		{`"  hi ".lstrip() + "|"`, "hi |"},
+		{`"  hi ".rstrip() + "|"`, "  hi|"},
+		{`"a,b,,c".split(",")`, "['a', 'b', '', 'c']"},
+		{`"  a  b c ".split()`, "['a', 'b', 'c']"},
+		{`"a b c d".split(" ", 2)`, "['a', 'b', 'c d']"},
+		{`"a  b  c".split(maxsplit=1)`, "['a', 'b  c']"},
+		{`"".split()`, "[]"},
+		{`"-".join(["a", "b", "c"])`, "a-b-c"},
+		{`", ".join(map(str, range(3)))`, "0, 1, 2"},
+		{`"aaa".replace("a", "b")`, "bbb"},
+		{`"aaa".replace("a", "b", 2)`, "bba"},
+		{`"hello".find("l")`, "2"},
+		{`"hello".find("l", 3)`, "3"},
+		{`"hello".find("l", 0, 2)`, "-1"},
+		{`"héllo".find("l")`, "2"},
+		{`"hello".find("z")`, "-1"},
+		{`"hello".startswith("he")`, "True"},
+		{`"hello".endswith(("x", "lo"))`, "True"},
+		{`"hello".endswith("he")`, "False"},
+		{`"{} + {} = {}".format(1, 2, 3)`, "1 + 2 = 3"},
+		{`"{1}{0}{1}".format("a", "b")`, "bab"},
+		{`"{name} is {age}".format(name="Ann", age=3)`, "Ann is 3"},
+		{`"{{}} {}".format([1])`, "{} [1]"},
+		{`s = "a,b"` + "\n" + `f = s.split` + "\n" + `f(",")`, "['a', 'b']"},
+		{`"".split("")`, "ValueError: empty separator"},
+		{`"a".split(1)`, "TypeError: must be str or None, not int"},
+		{`"-".join([1])`, "TypeError: sequence item 0: expected str instance, int found"},
+		{`"a".upper(1)`, "TypeError: str.upper() takes no arguments (1 given)"},
+		{`"a".startswith(1)`, "TypeError: startswith first arg must be str or a tuple of str, not int"},
+		{`"{} {}".format(1)`, "IndexError: Replacement index 1 out of range for positional args tuple"},
+		{`"{x}".format()`, "KeyError: 'x'"},
+		{`"{0} {}".format(1, 2)`, "ValueError: cannot switch from manual field specification to automatic field numbering"},
+		{`"}".format()`, "ValueError: Single '}' encountered in format string"},
+		{`"a".nope`, "AttributeError: 'str' object has no attribute 'nope'"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestListMethods(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"a = [1]\nb = a\nb.append(2)\na", "[1, 2]"},
+		{"a = [1]\na.append(2)", "None"},
+		{"a = [1]\na.extend(range(2, 4))\na", "[1, 2, 3]"},
+		{"a = [1, 2]\na.extend(a)\na", "[1, 2, 1, 2]"},
+		{"a = [1, 3]\na.insert(1, 2)\na.insert(-10, 0)\na.insert(10, 4)\na", "[0, 1, 2, 3, 4]"},
+		{"a = [1, 2, 3]\n(a.pop(), a)", "(3, [1, 2])"},
+		{"a = [1, 2, 3]\n(a.pop(0), a.pop(-1), a)", "(1, 3, [2])"},
+		{"a = [1, 2, 1]\na.remove(1)\na", "[2, 1]"},
+		{"[\"a\", \"b\"].index(\"b\")", "1"},
+		{"[1, 2, 1, \"1\"].count(1)", "2"},
+		{"a = [3, 1, 2]\nb = a\na.sort()\nb", "[1, 2, 3]"},
+		{"a = [\"bb\", \"a\", \"ccc\"]\na.sort(key=len, reverse=True)\na", "['ccc', 'bb', 'a']"},
+		{"a = [1, 2, 3]\na.reverse()\na", "[3, 2, 1]"},
+		{"a = []\nfor i in range(3):\n\ta.append(i * i)\na", "[0, 1, 4]"},
+		{"a = [1]\nf = a.append\nf(2)\na", "[1, 2]"},
+		{"[].pop()", "IndexError: pop from empty list"},
+		{"[1].pop(5)", "IndexError: pop index out of range"},
+		{"[1].remove(2)", "ValueError: list.remove(x): x not in list"},
+		{"[1].index(\"x\")", "ValueError: 'x' is not in list"},
+		{"[1].append()", "TypeError: list.append() takes exactly one argument (0 given)"},
+		{"[1].extend(2)", "TypeError: 'int' object is not iterable"},
+		{"[2, \"a\"].sort()", "TypeError: '<' not supported between instances of 'str' and 'int'"},
+		{"[1].sort(1)", "TypeError: sort() takes no positional arguments"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestIterationProtocol(t *testing.T) {
+	countdown := "class Countdown:\n\tdef __init__(self, n):\n\t\tself.n = n\n\tdef __iter__(self):\n\t\treturn self\n\tdef __next__(self):\n\t\tif self.n == 0:\n\t\t\traise StopIteration\n\t\tself.n -= 1\n\t\treturn self.n + 1\n"
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{countdown + "s = 0\nfor i in Countdown(3):\n\ts = s * 10 + i\ns", "321"},
+		{countdown + "list(Countdown(3))", "[3, 2, 1]"},
+		{countdown + "a, *b = Countdown(3)\nb", "[2, 1]"},
+		{countdown + "sum(Countdown(4))", "10"},
+		{countdown + "sorted(Countdown(3))", "[1, 2, 3]"},
+		{countdown + "list(zip(Countdown(2), \"ab\"))", "[(2, 'a'), (1, 'b')]"},
+		{countdown + "def f(*args):\n\treturn args\nf(*Countdown(2))", "(2, 1)"},
+		{countdown + "it = iter(Countdown(2))\n(next(it), next(it), next(it, 0))", "(2, 1, 0)"},
+		{"class Bag:\n\tdef __init__(self):\n\t\tself.items = [1, 2]\n\tdef __iter__(self):\n\t\treturn iter(self.items)\ntuple(Bag())", "(1, 2)"},
+		{"class Gen:\n\tdef __iter__(self):\n\t\tyield 1\n\t\tyield 2\nlist(map(str, Gen()))", "['1', '2']"},
+		{"class Seq:\n\tdef __getitem__(self, i):\n\t\tif i > 2:\n\t\t\traise IndexError(i)\n\t\treturn i * i\nlist(Seq())", "[0, 1, 4]"},
+		{"it = iter([1, 2])\nnext(it)\nlist(it)", "[2]"},
+		{"iter(range(3))", "<range_iterator object>"},
+		{"iter({})", "<dict_keyiterator object>"},
+		{"class A:\n\tpass\nfor x in A():\n\tpass", "TypeError: 'A' object is not iterable"},
+		{"class A:\n\tpass\na, b = A()", "TypeError: cannot unpack non-iterable A object"},
+		{"class A:\n\tdef __iter__(self):\n\t\treturn 1\nlist(A())", "TypeError: iter() returned non-iterator of type 'int'"},
+		{"class A:\n\tdef __iter__(self):\n\t\treturn 1\niter(A())", "TypeError: iter() returned non-iterator of type 'int'"},
+		{"class A:\n\tdef __iter__(self):\n\t\treturn self\n\tdef __next__(self):\n\t\traise ValueError(\"bad\")\nfor x in A():\n\tpass", "ValueError: bad"},
+		{"iter(1)", "TypeError: 'int' object is not iterable"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "lines.txt"), []byte("one\ntwo\nthree"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"f = open(\"lines.txt\")\ns = f.read()\nf.close()\nlen(s)", "13"},
+		{"f = open(\"lines.txt\")\n(f.read(3), f.readline(), f.readline())", "('one', '\n', 'two\n')"},
+		{"open(\"lines.txt\").readlines()", "['one\n', 'two\n', 'three']"},
+		{"n = 0\nfor line in open(\"lines.txt\"):\n\tn += 1\nn", "3"},
+		{"with open(\"lines.txt\") as f:\n\tfirst = f.readline()\n(first, f.closed)", "('one\n', True)"},
+		{"with open(\"out.txt\", \"w\") as f:\n\tn = f.write(\"héllo\")\n\tprint(\"!\", file=f)\nwith open(\"out.txt\") as f:\n\t(n, f.read())", "(5, 'héllo!\n')"},
+		{"with open(\"out.txt\", mode=\"a\") as f:\n\tf.write(\"more\")\nopen(\"out.txt\").read()", "héllo!\nmore"},
+		{"f = open(\"out.txt\", \"r+\")\nf.write(\"H\")\nf.close()\nopen(\"out.txt\").read(1)", "H"},
+		{"f = open(\"lines.txt\")\n(f.name, f.mode, type(f))", "('lines.txt', 'r', <class '_io.TextIOWrapper'>)"},
+		{"open(\"missing.txt\")", "FileNotFoundError: [Errno 2] No such file or directory: 'missing.txt'"},
+		{"open(\"lines.txt\", \"x\")", "FileExistsError: [Errno 17] File exists: 'lines.txt'"},
+		{"open(\"sub\")", "IsADirectoryError: [Errno 21] Is a directory: 'sub'"},
+		{"try:\n\topen(\"missing.txt\")\nexcept OSError:\n\tx = 1\nx", "1"},
+		{"f = open(\"lines.txt\")\nf.close()\nf.read()", "ValueError: I/O operation on closed file."},
+		{"open(\"lines.txt\").write(\"x\")", "OSError: not writable"},
+		{"open(\"out.txt\", \"w\").read()", "OSError: not readable"},
+		{"open(\"out.txt\", \"w\").write(1)", "TypeError: write() argument must be str, not int"},
+		{"open(\"lines.txt\", \"rw\")", "ValueError: invalid mode: 'rw'"},
+		{"open(\"lines.txt\", \"\")", "ValueError: invalid mode: ''"},
+		{"f = open(\"lines.txt\", \"rb\")\n(f.read(4), f.readline(), f.read(), f.read(), f)", "(b'one\\n', b'two\\n', b'three', b'', <_io.BufferedReader name='lines.txt'>)"},
+		{"with open(\"bin.dat\", \"wb\") as f:\n\tn = f.write(bytes([0, 255]) + \"é\".encode())\nwith open(\"bin.dat\", \"rb\") as f:\n\t(n, f.read(), f.mode)", "(4, b'\\x00\\xff\\xc3\\xa9', 'rb')"},
+		{"lines = []\nfor line in open(\"lines.txt\", \"rb\"):\n\tlines.append(line)\nlines", "[b'one\\n', b'two\\n', b'three']"},
+		{"open(\"bin.dat\", \"wb\").write(\"x\")", "TypeError: a bytes-like object is required, not 'str'"},
+		{"open(\"out.txt\", \"w\").write(b\"x\")", "TypeError: write() argument must be str, not bytes"},
+		{"open(\"lines.txt\", \"rb\", encoding=\"utf-8\")", "ValueError: binary mode doesn't take an encoding argument"},
+		{"open(\"lines.txt\", \"rbt\")", "ValueError: can't have text and binary mode at once"},
+		{"open(\"lines.txt\", encoding=\"latin-1\")", "LookupError: unknown encoding: latin-1"},
+		{"with 1:\n\tpass", "TypeError: 'int' object does not support the context manager protocol"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"import math\nmath.pi", "3.141592653589793"},
+		{"from math import e, tau\n(e, tau)", "(2.718281828459045, 6.283185307179586)"},
+		{"import math\nmath.sqrt(16)", "4.0"},
+		{"import math\n(math.floor(2.7), math.ceil(2.1), math.trunc(0.0 - 2.7), math.floor(0.0 - 2.5))", "(2, 3, -2, -3)"},
+		{"import math\nmath.floor(5)", "5"},
+		{"import math\n(math.sin(0), math.cos(0))", "(0.0, 1.0)"},
+		{"import math\n(math.log(math.e), math.log(8, 2), math.log10(1000), math.log2(8))", "(1.0, 3.0, 3.0, 3.0)"},
+		{"import math\n(math.pow(2, 10), math.hypot(3, 4), math.fabs(0 - 2))", "(1024.0, 5.0, 2.0)"},
+		{"import math\n(math.degrees(math.pi), math.radians(180) == math.pi)", "(180.0, True)"},
+		{"import math\n(math.factorial(5), math.gcd(12, -18), math.gcd())", "(120, 6, 0)"},
+		{"import math\n(math.isnan(math.nan), math.isinf(0.0 - math.inf), math.isfinite(1))", "(True, True, True)"},
+		{"import math\nmath.sqrt(math.inf)", "inf"},
+		{"import math\nmath.sqrt(-1)", "ValueError: math domain error"},
+		{"import math\nmath.log(0)", "ValueError: math domain error"},
+		{"import math\nmath.exp(1000)", "OverflowError: math range error"},
+		{"import math\nmath.floor(math.nan)", "ValueError: cannot convert float NaN to integer"},
+		{"import math\nmath.factorial(-1)", "ValueError: factorial() not defined for negative values"},
+		{"import math\nmath.sqrt(\"4\")", "TypeError: must be real number, not str"},
+		{"import math\nmath.sqrt()", "TypeError: math.sqrt() takes exactly one argument (0 given)"},
+		{"import math\nmath.nope", "AttributeError: module 'math' has no attribute 'nope'"},
+		{"class Half:\n\tdef __float__(self):\n\t\treturn 0.5\nimport math\nmath.floor(Half())", "0"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestRandomModule(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"import random\nrandom.seed(7)\na, b = random.random(), random.randint(1, 100)\nrandom.seed(7)\na == random.random() and b == random.randint(1, 100)", "True"},
+		{"import random\nrandom.seed(\"abc\")\na = random.random()\nrandom.seed(\"abc\")\na == random.random()", "True"},
+		{"import random\nok = True\nfor i in range(200):\n\tx = random.random()\n\tok = ok and not x < 0.0 and x < 1.0\nok", "True"},
+		{"import random\nseen = [0, 0, 0, 0, 0]\nfor i in range(200):\n\tseen[random.randint(1, 3)] = 1\nseen", "[0, 1, 1, 1, 0]"},
+		{"import random\nok = True\nfor i in range(100):\n\tx = random.randrange(0, 10, 5)\n\tok = ok and (x == 0 or x == 5)\nok", "True"},
+		{"import random\nx = random.uniform(2, 3)\nnot x < 2.0 and not x > 3.0", "True"},
+		{"import random\nrandom.choice([\"only\"])", "only"},
+		{"import random\nrandom.choice(\"aaa\")", "a"},
+		{"import random\na = list(range(10))\nb = a\nrandom.shuffle(a)\n(len(b), sum(b))", "(10, 45)"},
+		{"import random\nrandom.randint(5, 3)", "ValueError: empty range in randrange(5, 4)"},
+		{"import random\nrandom.choice([])", "IndexError: Cannot choose from an empty sequence"},
+		{"import random\nrandom.shuffle((1, 2))", "TypeError: 'tuple' object does not support item assignment"},
+		{"import random\nrandom.seed([])", "TypeError: The only supported seed types are: None, int, float, str"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestRandomPerInterpreter(t *testing.T) {
+	run := func(env *interpreter.Environment, input string) string {
+		p, program := parser.StartParseRepl(input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", input, p.Errors())
+		}
+		return Evaluate(&program, env).Visit()
+	}
+	a, b := NewEnv(), NewEnv()
+	a1 := run(a, "import random\nrandom.seed(7)\nrandom.random()")
+	b1 := run(b, "import random\nrandom.seed(7)\nrandom.random()")
+	b2 := run(b, "random.random()")
+	a2 := run(a, "random.random()")
+	if a1 != b1 || a2 != b2 {
+		t.Errorf("interpreters seeded alike drew %s, %s and %s, %s; want the same values", a1, a2, b1, b2)
+	}
+}
+
+func TestTimeModule(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"import time\ntime.time() > 1500000000.0", "True"},
+		{"import time\nstart = time.monotonic()\ntime.sleep(0.01)\ntime.monotonic() - start > 0.005", "True"},
+		{"import time\ntime.sleep(0)", "None"},
+		{"import time\ntime.sleep(0 - 1)", "ValueError: sleep length must be non-negative"},
+		{"import time\ntime.sleep(\"1\")", "TypeError: 'str' object cannot be interpreted as an integer"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestInterruptSleep(t *testing.T) {
+	defer func(c <-chan struct{}) { Interrupt = c }(Interrupt)
+	interrupt := make(chan struct{})
+	Interrupt = interrupt
+	time.AfterFunc(10*time.Millisecond, func() { close(interrupt) })
+	start := time.Now()
+	got := testEval(t, "import time\ntry:\n\ttime.sleep(60)\nexcept KeyboardInterrupt:\n\tx = \"interrupted\"\nx")
+	if got == nil || got.Visit() != "interrupted" {
+		t.Errorf("sleep; want interrupted; got %v", got)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("interrupted sleep took %v", elapsed)
+	}
+}
+
+func TestSysModule(t *testing.T) {
+	defer func(argv []string) { Argv = argv }(Argv)
+	defer func(w io.Writer, e io.Writer, r io.Reader) { Stdout, Stderr, Stdin = w, e, r }(Stdout, Stderr, Stdin)
+	Argv = []string{"script.py", "-v", "x"}
+
+	tests := []struct {
+		input  string
+		stdin  string
+		want   string
+		stdout string
+		stderr string
+	}{
+		{"import sys\nsys.argv", "", "['script.py', '-v', 'x']", "", ""},
+		{"import sys\nsys.stdout.write(\"héllo\")", "", "5", "héllo", ""},
+		{"import sys\nprint(\"a\", file=sys.stdout)\nprint(\"b\", file=sys.stderr)", "", "None", "a\n", "b\n"},
+		{"import sys\n(sys.stdin.readline(), input())", "one\ntwo\n", "('one\n', 'two')", "", ""},
+		{"import sys\nsys.stdin.read()", "all\nof it", "all\nof it", "", ""},
+		{"import sys\nsys.version_info[0]", "", "3", "", ""},
+		{"import sys\nsys.exit(2)", "", "SystemExit: 2", "", ""},
+		{"import sys\ntry:\n\tsys.exit(1)\nexcept Exception:\n\tx = \"caught\"", "", "SystemExit: 1", "", ""},
+		{"import sys\ntry:\n\tsys.exit(\"bye\")\nexcept SystemExit as e:\n\tx = e.code\nx", "", "bye", "", ""},
+		{"import sys\nsys.stdout.write(1)", "", "TypeError: write() argument must be str, not int", "", ""},
+	}
+	for _, tt := range tests {
+		var out, errOut bytes.Buffer
+		Stdout, Stderr, Stdin = &out, &errOut, strings.NewReader(tt.stdin)
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+		if out.String() != tt.stdout || errOut.String() != tt.stderr {
+			t.Errorf("eval(%q); want output %q, %q; got %q, %q", tt.input, tt.stdout, tt.stderr, out.String(), errOut.String())
+		}
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		input  string
+		status int
+		exit   bool
+		stderr string
+	}{
+		{"import sys\nsys.exit()", 0, true, ""},
+		{"import sys\nsys.exit(None)", 0, true, ""},
+		{"import sys\nsys.exit(3)", 3, true, ""},
+		{"import sys\nsys.exit(\"fatal\")", 1, true, "fatal\n"},
+		{"raise SystemExit(4)", 4, true, ""},
+		{"raise ValueError(4)", 0, false, ""},
+	}
+	for _, tt := range tests {
+		var errOut bytes.Buffer
+		env := NewEnv()
+		SetStderr(env, &errOut)
+		_, program := parser.StartParseRepl(tt.input)
+		err, ok := Evaluate(&program, env).(*interpreter.Error)
+		if !ok {
+			t.Errorf("eval(%q); want an error", tt.input)
+			continue
+		}
+		status, exit := ExitStatus(err, env)
+		if status != tt.status || exit != tt.exit || errOut.String() != tt.stderr {
+			t.Errorf("ExitStatus(%q); want %d, %t, %q; got %d, %t, %q", tt.input, tt.status, tt.exit, tt.stderr, status, exit, errOut.String())
+		}
+	}
+}
+
+func TestOSModule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"b.txt", "a.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GOPY_TEST_VAR", "set")
+	defer os.Unsetenv("GOPY_TEST_VAR")
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"import os\nos.getenv(\"GOPY_TEST_VAR\")", "set"},
+		{"import os\nos.getenv(\"GOPY_TEST_MISSING\")", "None"},
+		{"import os\nos.getenv(\"GOPY_TEST_MISSING\", \"default\")", "default"},
+		{"import os\nos.environ[\"GOPY_TEST_VAR\"]", "set"},
+		{"import os\nsorted(os.listdir())", "['a.txt', 'b.txt', 'sub']"},
+		{"import os\nos.listdir(\"sub\")", "[]"},
+		{"import os\nos.path.join(\"a\", \"b\", \"c.txt\")", "a/b/c.txt"},
+		{"import os\nos.path.join(\"a/\", \"b\")", "a/b"},
+		{"import os\nos.path.join(\"a\", \"/b\", \"c\")", "/b/c"},
+		{"from os import path\n(path.exists(\"a.txt\"), path.exists(\"missing\"), path.exists(1))", "(True, False, False)"},
+		{"import os\n(os.path.isfile(\"a.txt\"), os.path.isfile(\"sub\"), os.path.isdir(\"sub\"))", "(True, False, True)"},
+		{"import os\n(os.path.basename(\"/x/y.txt\"), os.path.basename(\"/x/\"), os.path.basename(\"y\"))", "('y.txt', '', 'y')"},
+		{"import os\n(os.path.dirname(\"/x/y.txt\"), os.path.dirname(\"/y\"), os.path.dirname(\"y\"))", "('/x', '/', '')"},
+		{"import os\nos.path.basename(os.getcwd()) == os.path.basename(os.path.abspath(\".\"))", "True"},
+		{"import os\nos.listdir(\"missing\")", "FileNotFoundError: [Errno 2] No such file or directory: 'missing'"},
+		{"import os\nos.path.basename(1)", "TypeError: expected str, bytes or os.PathLike object, not int"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+// TestJSONModule passes JSON text in through the doc variable, since
+// string literals can't hold double quotes.
+func TestJSONModule(t *testing.T) {
+	tests := []struct {
+		doc   string
+		input string
+		want  string
+	}{
+		{"", "json.dumps({\"a\": [1, 2.5, True, None], \"b\": \"x\"})", `{"a": [1, 2.5, true, null], "b": "x"}`},
+		{"", "json.dumps((1, \"héllo\"))", `[1, "h\u00e9llo"]`},
+		{"", "json.dumps({2: 1, \"a\": 3}, sort_keys=True)", `{"2": 1, "a": 3}`},
+		{"", "json.dumps({\"a\": [1, {}], \"b\": []}, indent=2)", "{\n  \"a\": [\n    1,\n    {}\n  ],\n  \"b\": []\n}"},
+		{"", "json.dumps(1e400)", "Infinity"},
+		{`{"b": [1, 2.5, true, null], "a": "xé\n"}`, "json.loads(doc)", "{'b': [1, 2.5, True, None], 'a': 'xé\n'}"},
+		{`[{"k": 1}, "s", -3e2]`, "json.loads(doc)[0][\"k\"]", "1"},
+		{`  "text"  `, "json.loads(doc)", "text"},
+		{`{"a": [1, {"b": null}]}`, "json.dumps(json.loads(doc)) == doc", "True"},
+		{"", "json.dumps(set())", "TypeError: Object of type set is not JSON serializable"},
+		{"", "a = []\na.append(a)\njson.dumps(a)", "ValueError: Circular reference detected"},
+		{"", "json.loads(\"\")", "JSONDecodeError: Expecting value: line 1 column 1 (char 0)"},
+		{`[1, 2`, "json.loads(doc)", "JSONDecodeError: Unexpected end of JSON input: line 1 column 6 (char 5)"},
+		{"{\n  \"a\" 1}", "json.loads(doc)", "JSONDecodeError: Invalid character '1' after object key: line 2 column 7 (char 8)"},
+		{`1 2`, "json.loads(doc)", "JSONDecodeError: Extra data: line 1 column 3 (char 2)"},
+		{`nope`, "try:\n\tjson.loads(doc)\nexcept ValueError:\n\tx = \"caught\"\nx", "caught"},
+		{"", "json.loads(1)", "TypeError: the JSON object must be str, not 'int'"},
+	}
+	for _, tt := range tests {
+		input := "import json\n" + tt.input
+		p, program := parser.StartParseRepl(input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", input, p.Errors())
+		}
+		env := interpreter.NewEnv()
+		env.Store("doc", &interpreter.Str{Val: tt.doc})
+		got := Evaluate(&program, env)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q) with doc %q; want %s; got %v", tt.input, tt.doc, tt.want, got)
+		}
+	}
+}
+
+func TestReModule(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`re.match("\d+", "42 apples")`, "<re.Match object; span=(0, 2), match='42'>"},
+		{`re.match("\d+", "apples 42")`, "None"},
+		{`re.search("\d+", "apples 42").group()`, "42"},
+		{`re.fullmatch("a+", "aab")`, "None"},
+		{`m = re.search("(\w+)@(?P<host>\w+)", "mail bob@example now")
+(m.group(1), m.group("host"), m.groups(), m.span(2), m.start(), m.end("host"))`, "('bob', 'example', ('bob', 'example'), (9, 16), 5, 16)"},
+		{`re.search("(?<year>\d{4})-(\d+)?", "é 2024-").groupdict()`, "{'year': '2024'}"},
+		{`re.match("(a)(b)?", "a").groups("-")`, "('a', '-')"},
+		{`re.findall("\d+", "1 22 333")`, "['1', '22', '333']"},
+		{`re.findall("(\w)=(\d)", "a=1, b=2")`, "[('a', '1'), ('b', '2')]"},
+		{`def text(m):
+	return m.group()
+list(map(text, re.finditer("o.", "foo boa")))`, "['oo', 'oa']"},
+		{`re.sub("(\w+) (\w+)", "\2 \g<1>", "hello world")`, "world hello"},
+		{`re.sub("\s+", "-", "a  b   c", count=1)`, "a-b   c"},
+		{`def shout(m):
+	return m.group().upper()
+re.sub("[aeiou]", shout, "banana")`, "bAnAnA"},
+		{`re.split(",\s*", "a, b,c")`, "['a', 'b', 'c']"},
+		{`re.split("(-)", "a-b-c", 1)`, "['a', '-', 'b-c']"},
+		{`p = re.compile("ab+", re.I)
+(p.match("ABBA").group(), p.pattern, p.flags, p.groups)`, "('ABB', 'ab+', 2, 0)"},
+		{`re.findall("^x", "x1
+x2", re.M)`, "['x', 'x']"},
+		{`re.search("end\Z", "the end").span()`, "(4, 7)"},
+		{`re.compile("a{,2}").match("aaa").group()`, "aa"},
+		{`re.compile("[a-")`, "error: missing closing ]: `[a-`"},
+		{`re.compile("(a)\1")`, "error: backreferences are not supported at position 3"},
+		{`re.search("foo(?=bar)", "foobar")`, "error: lookaround assertions are not supported at position 3"},
+		{`re.match("a", "a").group(2)`, "IndexError: no such group"},
+		{`try:
+	re.compile("(")
+except re.error:
+	x = "caught"
+x`, "caught"},
+		{`re.match(1, "a")`, "TypeError: first argument must be string or compiled pattern"},
+		{`re.match("a", 1)`, "TypeError: expected string or bytes-like object, got 'int'"},
+	}
+	for _, tt := range tests {
+		input := "import re\n" + tt.input
+		got := testEval(t, input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", input, tt.want, got)
+		}
+	}
+}
+
+func TestStringFormatting(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`"%s = %d" % ("n", 42)`, "n = 42"},
+		{`"%5.2f|%-6s|%05d" % (3.14159, "ab", -42)`, " 3.14|ab    |-0042"},
+		{`"%x %X %#o %e" % (255, 255, 8, 12345.678)`, "ff FF 0o10 1.234568e+04"},
+		{`"%r %c%c 100%%" % ("q", 72, "i")`, "'q' Hi 100%"},
+		{`"%(name)s is %(age)d" % {"name": "Ann", "age": 3}`, "Ann is 3"},
+		{`"%*d|%.*f" % (4, 7, 1, 2.25)`, "   7|2.2"},
+		{`"%s" % [1, 2]`, "[1, 2]"},
+		{`"%d %d" % (1,)`, "TypeError: not enough arguments for format string"},
+		{`"%d" % (1, 2)`, "TypeError: not all arguments converted during string formatting"},
+		{`"%d" % "x"`, "TypeError: %d format: a real number is required, not str"},
+		{`"%z" % 1`, "ValueError: unsupported format character 'z' (0x7a) at index 1"},
+		{`"{:>8}|{:<6}|{:^7}|".format("right", "left", "mid")`, "   right|left  |  mid  |"},
+		{`"{:*^9}".format("x")`, "****x****"},
+		{`"{:.3f} {:8.2f} {:+d} {: d}".format(3.14159, 2.5, 5, 5)`, "3.142     2.50 +5  5"},
+		{`"{:08.3f} {:,} {:_x} {:#b}".format(0.0 - 3.5, 1234567, 1048575, 5)`, "-003.500 1,234,567 f_ffff 0b101"},
+		{`"{:e} {:g} {:g} {:.2%}".format(1500.0, 0.00001, 2.0, 0.256)`, "1.500000e+03 1e-05 2 25.60%"},
+		{`"{:.3} {:.3} {:.2s}".format(1.0, 1234.5, "abc")`, "1.0 1.23e+03 ab"},
+		{`"{0!r:>5} {0!s}".format("a")`, "  'a' a"},
+		{`"{:>{}}|{:{w}.{p}f}".format("x", 3, 1.5, w=6, p=2)`, "  x|  1.50"},
+		{`format(42, "04d") + format(3.5) + format("s", "<3")`, "00423.5s  "},
+		{`class Money:
+	def __format__(self, spec):
+		return "$" + spec
+"{:x}".format(Money())`, "$x"},
+		{`"{:d}".format("s")`, "ValueError: Unknown format code 'd' for object of type 'str'"},
+		{`"{:.2d}".format(1)`, "ValueError: Precision not allowed in integer format specifier"},
+		{`"{:5}".format([1])`, "TypeError: unsupported format string passed to list.__format__"},
+		{`"{0!x}".format(1)`, "ValueError: Unknown conversion specifier x"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestRecursionLimit(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"def f(n):\n\treturn f(n + 1)\nf(0)", "RecursionError: maximum recursion depth exceeded while calling 'f'"},
+		{"def down(n):\n\tif n == 0:\n\t\treturn 0\n\treturn 1 + down(n - 1)\ndown(900)", "900"},
+		{"def f():\n\treturn f()\ntry:\n\tf()\nexcept RuntimeError as e:\n\tx = type(e)\nx", "<class 'RecursionError'>"},
+		{"class A:\n\tdef __init__(self):\n\t\tA()\nA()", "RecursionError: maximum recursion depth exceeded while calling '__init__'"},
+		{"import sys\nsys.getrecursionlimit()", "1000"},
+		{"import sys\nsys.setrecursionlimit(50)\ndef down(n):\n\tif n == 0:\n\t\treturn 0\n\treturn down(n - 1)\ndown(60)", "RecursionError: maximum recursion depth exceeded while calling 'down'"},
+		{"import sys\nsys.setrecursionlimit(0)", "ValueError: recursion limit must be greater or equal than 1"},
+		{"import sys\ndef f():\n\tsys.setrecursionlimit(1)\nf()", "RecursionError: cannot set the recursion limit to 1 at the recursion depth 1: the limit is too low"},
+		{"import sys\nsys.setrecursionlimit(1000000000)\nsys.getrecursionlimit()", "20000"},
+		{"import sys\nsys.setrecursionlimit(1000000000)\ndef f(n):\n\treturn f(n + 1)\nf(0)", "RecursionError: maximum recursion depth exceeded while calling 'f'"},
+	}
+	for _, tt := range tests {
+		_, program := parser.StartParseRepl(tt.input)
+		env := interpreter.NewEnv()
+		got := Evaluate(&program, env)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+		if n := len(stateOf(env).callStack); n != 0 {
+			t.Errorf("eval(%q) left %d frames on the call stack", tt.input, n)
+		}
+	}
+}
+
+func TestRecursionLimitPerInterpreter(t *testing.T) {
+	_, program := parser.StartParseRepl("import sys\nsys.setrecursionlimit(50)")
+	Evaluate(&program, NewEnv())
+	_, program = parser.StartParseRepl("import sys\nsys.getrecursionlimit()")
+	if got := Evaluate(&program, NewEnv()); got == nil || got.Visit() != "1000" {
+		t.Errorf("getrecursionlimit() in another interpreter = %v; want 1000", got)
+	}
+}
+
+func TestSettingsPerInterpreter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "local.py"), []byte("x = 7\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	env := NewEnv()
+	SetArgv(env, []string{"job.py", "1"})
+	SetSearchPath(env, []string{dir})
+	SetRecursionLimit(env, 30)
+	input := "import sys\nimport local\n(sys.argv, local.x, sys.getrecursionlimit())"
+	_, program := parser.StartParseRepl(input)
+	if got := Evaluate(&program, env); got == nil || got.Visit() != "(['job.py', '1'], 7, 30)" {
+		t.Errorf("eval(%q) with its own settings = %v", input, got)
+	}
+	want := "ModuleNotFoundError: No module named 'local'"
+	if got := testEval(t, "import local"); got == nil || got.Visit() != want {
+		t.Errorf("another interpreter should keep the default search path; got %v", got)
+	}
+}
+
+func TestInputShared(t *testing.T) {
+	defer func(r io.Reader) { Stdin = r }(Stdin)
+	Stdin = strings.NewReader(strings.Repeat("line\n", 200))
+	counts := make(chan interpreter.Item)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, program := parser.StartParseRepl("n = 0\ntry:\n\twhile True:\n\t\tinput()\n\t\tn += 1\nexcept EOFError:\n\tpass\nn")
+			counts <- Evaluate(&program, NewEnv())
+		}()
+	}
+	total := int64(0)
+	for i := 0; i < 2; i++ {
+		n, ok := (<-counts).(*interpreter.Int)
+		if !ok {
+			t.Fatal("want a count of the lines read")
+		}
+		total += n.Val
+	}
+	if total != 200 {
+		t.Errorf("interpreters sharing Stdin read %d lines between them; want 200", total)
+	}
+}
+
+func TestTraceback(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"x = 1\ny = x / 0", "Traceback (most recent call last):\n  File \"<stdin>\", line 2, in <module>\nZeroDivisionError: division by zero\n"},
+		{"def f(x):\n\tif x:\n\t\treturn g()\ndef g():\n\treturn missing\nf(1)",
+			"Traceback (most recent call last):\n  File \"<stdin>\", line 6, in <module>\n  File \"<stdin>\", line 3, in f\n  File \"<stdin>\", line 5, in g\nNameError: name 'missing' is not defined\n"},
+		{"def gen():\n\tyield 1\n\traise ValueError(\"late\")\nfor x in gen():\n\tpass",
+			"Traceback (most recent call last):\n  File \"<stdin>\", line 4, in <module>\n  File \"<stdin>\", line 3, in gen\nValueError: late\n"},
+		{"def f():\n\ttry:\n\t\t1 / 0\n\texcept ZeroDivisionError:\n\t\tpass\n\t[][1]\nf()",
+			"Traceback (most recent call last):\n  File \"<stdin>\", line 7, in <module>\n  File \"<stdin>\", line 6, in f\nIndexError: list index out of range\n"},
+		{"try:\n\t1 / 0\nexcept ZeroDivisionError:\n\t[][1]",
+			"Traceback (most recent call last):\n  File \"<stdin>\", line 2, in <module>\nZeroDivisionError: division by zero\n" +
+				"\nDuring handling of the above exception, another exception occurred:\n\n" +
+				"Traceback (most recent call last):\n  File \"<stdin>\", line 4, in <module>\nIndexError: list index out of range\n"},
+		{"try:\n\t{}[\"k\"]\nexcept KeyError as e:\n\traise ValueError(\"bad\") from e",
+			"Traceback (most recent call last):\n  File \"<stdin>\", line 2, in <module>\nKeyError: k\n" +
+				"\nThe above exception was the direct cause of the following exception:\n\n" +
+				"Traceback (most recent call last):\n  File \"<stdin>\", line 4, in <module>\nValueError: bad\n"},
+		{"try:\n\t{}[\"k\"]\nexcept KeyError:\n\traise ValueError(\"bad\") from None",
+			"Traceback (most recent call last):\n  File \"<stdin>\", line 4, in <module>\nValueError: bad\n"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		err, ok := got.(*interpreter.Error)
+		if !ok {
+			t.Errorf("eval(%q) = %v; want an error", tt.input, got)
+			continue
+		}
+		if tb := FormatTraceback(err); tb != tt.want {
+			t.Errorf("traceback of %q:\n%s\nwant:\n%s", tt.input, tb, tt.want)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "traceback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "script.py")
+	src := "def half(n):\n    return n / 0\n\nprint(half(4))\n"
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	program, parseErr := parser.ParseFile(path)
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+	got, ok := Evaluate(program, interpreter.NewEnv()).(*interpreter.Error)
+	if !ok {
+		t.Fatalf("eval(%q) did not raise", src)
+	}
+	want := "Traceback (most recent call last):\n" +
+		"  File \"" + path + "\", line 4, in <module>\n    print(half(4))\n" +
+		"  File \"" + path + "\", line 2, in half\n    return n / 0\n" +
+		"ZeroDivisionError: division by zero\n"
+	if tb := FormatTraceback(got); tb != want {
+		t.Errorf("traceback of %s:\n%s\nwant:\n%s", path, tb, want)
+	}
+}
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"range(5)", "range(0, 5)"},
+		{"range(1, 10, 2)", "range(1, 10, 2)"},
+		{"len(range(5))", "5"},
+		{"len(range(1, 10, 2))", "5"},
+		{"len(range(10, 0, -3))", "4"},
+		{"len(range(5, 1))", "0"},
+		{"range(10)[3]", "3"},
+		{"range(10, 0, -3)[-1]", "1"},
+		{"t = 0\nfor i in range(4):\n\tt += i\nt", "6"},
+		{"s = \"\"\nfor i in range(3, 0, -1):\n\ts += \"x\" * i\ns", "xxxxxx"},
+		{"a, b, c = range(3)\nc", "2"},
+		{"bool(range(0))", "False"},
+		{"n = 0\nfor i in range(1000000000):\n\tif i == 3:\n\t\tbreak\n\tn += 1\nn", "3"},
+		{"range(5)[5]", "IndexError: range index out of range"},
+		{"range()", "TypeError: range expected at least 1 argument, got 0"},
+		{"range(1, 2, 3, 4)", "TypeError: range expected at most 3 arguments, got 4"},
+		{"range(\"a\")", "TypeError: 'str' object cannot be interpreted as an integer"},
+		{"range(1, 5, 0)", "ValueError: range() arg 3 must not be zero"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestRangeContains(t *testing.T) {
+	tests := []struct {
+		r    interpreter.Range
+		n    int64
+		want bool
+	}{
+		{interpreter.Range{Start: 0, Stop: 5, Step: 1}, 4, true},
+		{interpreter.Range{Start: 0, Stop: 5, Step: 1}, 5, false},
+		{interpreter.Range{Start: 1, Stop: 10, Step: 3}, 7, true},
+		{interpreter.Range{Start: 1, Stop: 10, Step: 3}, 8, false},
+		{interpreter.Range{Start: 10, Stop: 0, Step: -2}, 4, true},
+		{interpreter.Range{Start: 10, Stop: 0, Step: -2}, 0, false},
+		{interpreter.Range{Start: 10, Stop: 0, Step: -2}, 11, false},
+	}
+	for _, tt := range tests {
+		if got := tt.r.Contains(tt.n); got != tt.want {
+			t.Errorf("%s.Contains(%d); want %t; got %t", tt.r.Visit(), tt.n, tt.want, got)
+		}
+	}
+}
+
+func TestMatchStmt(t *testing.T) {
+	describe := `def describe(x):
+	match x:
+		case 0:
+			return "zero"
+		case -1:
+			return "minus one"
+		case "hi":
+			return "greeting"
+		case []:
+			return "empty"
+		case [a]:
+			return a
+		case (a, 2):
+			return "pair ending in 2"
+		case [first, *rest]:
+			return first * 10 + len(rest)
+		case other:
+			return "other"
+`
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"describe(0)", "zero"},
+		{"describe(-1)", "minus one"},
+		{`describe("hi")`, "greeting"},
+		{`describe("0")`, "other"},
+		{"describe([])", "empty"},
+		{"describe(())", "empty"},
+		{"describe([5])", "5"},
+		{"describe((1, 2))", "pair ending in 2"},
+		{"describe([1, 3, 4])", "12"},
+		{"describe({})", "other"},
+		{"match [1, 2, 3, 4]:\n\tcase [a, *mid, b]:\n\t\tx = mid\nx", "[2, 3]"},
+		{"match (1, 2, 3):\n\tcase *_, last:\n\t\tx = last\nx", "3"},
+		{"match [1, [2, 3]]:\n\tcase [a, [b, c]]:\n\t\tx = a + b + c\nx", "6"},
+		{"x = 0\nmatch 5:\n\tcase 1:\n\t\tx = 1\nx", "0"},
+		{"match 5:\n\tcase _:\n\t\tx = 2\nx", "2"},
+		{"match 5:\n\tcase [a]:\n\t\tpass\n\tcase b:\n\t\tpass\na", "NameError: name 'a' is not defined"},
+		{"match = 3\nmatch", "3"},
+	}
+	for _, tt := range tests {
+		got := testEval(t, describe+tt.input)
+		if got == nil || got.Visit() != tt.want {
+			t.Errorf("eval(%q); want %s; got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestAccessListsEveryBuiltin(t *testing.T) {
+	for name := range builtins {
+		if _, ok := access[""][name]; !ok {
+			t.Errorf("builtin %s is missing from access, so sandboxes disable it", name)
+		}
+	}
+	var check func(module *interpreter.Module)
+	check = func(module *interpreter.Module) {
+		for name, member := range module.Env.Locals() {
+			if _, ok := access[module.Name][name]; !ok {
+				t.Errorf("%s.%s is missing from access, so sandboxes disable it", module.Name, name)
+			}
+			if inner, ok := member.(*interpreter.Module); ok {
+				check(inner)
+			}
+		}
+	}
+	for name, build := range nativeModules {
+		if !registered[name] {
+			check(build(NewEnv()))
+		}
+	}
+}
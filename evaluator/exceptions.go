@@ -0,0 +1,464 @@
+package evaluator
+
+import (
+	"fmt"
+	"gopy/ast"
+	"gopy/interpreter"
+)
+
+// exceptionClasses holds the builtin exception classes by name. Scripts
+// see them like builtins and may subclass them.
+var exceptionClasses = map[string]*interpreter.Class{}
+
+var (
+	baseExceptionClass = newExceptionClass("BaseException", nil)
+	exceptionClass = newExceptionClass("Exception", baseExceptionClass)
+	typeErrorClass = newExceptionClass("TypeError", exceptionClass)
+	valueErrorClass = newExceptionClass("ValueError", exceptionClass)
+	unicodeErrorClass = newExceptionClass("UnicodeError", valueErrorClass)
+	unicodeEncodeErrorClass = newExceptionClass("UnicodeEncodeError", unicodeErrorClass)
+	unicodeDecodeErrorClass = newExceptionClass("UnicodeDecodeError", unicodeErrorClass)
+	nameErrorClass = newExceptionClass("NameError", exceptionClass)
+	unboundLocalErrorClass = newExceptionClass("UnboundLocalError", nameErrorClass)
+	attributeErrorClass = newExceptionClass("AttributeError", exceptionClass)
+	runtimeErrorClass = newExceptionClass("RuntimeError", exceptionClass)
+	recursionErrorClass = newExceptionClass("RecursionError", runtimeErrorClass)
+	arithmeticErrorClass = newExceptionClass("ArithmeticError", exceptionClass)
+	zeroDivisionErrorClass = newExceptionClass("ZeroDivisionError", arithmeticErrorClass)
+	overflowErrorClass = newExceptionClass("OverflowError", arithmeticErrorClass)
+	lookupErrorClass = newExceptionClass("LookupError", exceptionClass)
+	keyErrorClass = newExceptionClass("KeyError", lookupErrorClass)
+	indexErrorClass = newExceptionClass("IndexError", lookupErrorClass)
+	importErrorClass = newExceptionClass("ImportError", exceptionClass)
+	moduleNotFoundErrorClass = newExceptionClass("ModuleNotFoundError", importErrorClass)
+	assertionErrorClass = newExceptionClass("AssertionError", exceptionClass)
+	syntaxErrorClass = newExceptionClass("SyntaxError", exceptionClass)
+	stopIterationClass = newExceptionClass("StopIteration", exceptionClass)
+	eofErrorClass = newExceptionClass("EOFError", exceptionClass)
+	osErrorClass = newExceptionClass("OSError", exceptionClass)
+	fileNotFoundErrorClass = newExceptionClass("FileNotFoundError", osErrorClass)
+	fileExistsErrorClass = newExceptionClass("FileExistsError", osErrorClass)
+	permissionErrorClass = newExceptionClass("PermissionError", osErrorClass)
+	isADirectoryErrorClass = newExceptionClass("IsADirectoryError", osErrorClass)
+	timeoutErrorClass = newExceptionClass("TimeoutError", osErrorClass)
+	memoryErrorClass = newExceptionClass("MemoryError", exceptionClass)
+	generatorExitClass = newExceptionClass("GeneratorExit", baseExceptionClass)
+	keyboardInterruptClass = newExceptionClass("KeyboardInterrupt", baseExceptionClass)
+	systemExitClass = newExceptionClass("SystemExit", baseExceptionClass)
+)
+
+func newExceptionClass(name string, base *interpreter.Class) *interpreter.Class {
+	class := &interpreter.Class{Name: name, Base: base, Attrs: map[string]interpreter.Item{}}
+	exceptionClasses[name] = class
+	return class
+}
+
+func init() {
+	baseExceptionClass.Attrs["__init__"] = &interpreter.Builtin{
+		Name: "__init__",
+		Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+			self, ok := args[0].(*interpreter.Instance)
+			if !ok {
+				return newException(typeErrorClass, "descriptor '__init__' requires a 'BaseException' object but received a '%s'", typeName(args[0]))
+			}
+			self.Attrs["args"] = &interpreter.Tuple{Elements: args[1:]}
+			initChaining(self)
+			return NONE
+		},
+	}
+	baseExceptionClass.Attrs["__str__"] = &interpreter.Builtin{
+		Name: "__str__",
+		Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+			self, ok := args[0].(*interpreter.Instance)
+			if !ok || len(args) != 1 {
+				return newException(typeErrorClass, "__str__() takes no arguments (%d given)", len(args)-1)
+			}
+			return &interpreter.Str{Val: exceptionText(self)}
+		},
+	}
+	baseExceptionClass.Attrs["__repr__"] = &interpreter.Builtin{
+		Name: "__repr__",
+		Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+			self, ok := args[0].(*interpreter.Instance)
+			if !ok || len(args) != 1 {
+				return newException(typeErrorClass, "__repr__() takes no arguments (%d given)", len(args)-1)
+			}
+			excArgs, _ := self.Attrs["args"].(*interpreter.Tuple)
+			if excArgs == nil {
+				return &interpreter.Str{Val: self.Class.Name + "()"}
+			}
+			if len(excArgs.Elements) == 1 {
+				return &interpreter.Str{Val: self.Class.Name + "(" + interpreter.Repr(excArgs.Elements[0]) + ")"}
+			}
+			return &interpreter.Str{Val: self.Class.Name + excArgs.Visit()}
+		},
+	}
+}
+
+// newException returns an error raising a new instance of class with the
+// formatted message as its only argument.
+func newException(class *interpreter.Class, f string, e ...interface{}) *interpreter.Error {
+	instance := &interpreter.Instance{
+		Class: class,
+		Attrs: map[string]interpreter.Item{
+			"args": &interpreter.Tuple{Elements: []interpreter.Item{&interpreter.Str{Val: fmt.Sprintf(f, e...)}}},
+		},
+	}
+	return raise(instance)
+}
+
+// NewException returns an error raising a new instance of the builtin
+// exception class called name, such as "ValueError", for Go code called
+// from scripts. Unknown names raise a RuntimeError instead.
+func NewException(name string, f string, e ...interface{}) *interpreter.Error {
+	class, ok := exceptionClasses[name]
+	if !ok {
+		class = runtimeErrorClass
+	}
+	return newException(class, f, e...)
+}
+
+// raise returns an error carrying the exception instance. Whether it was
+// raised while another was being handled is only known once it reaches a
+// try statement or leaves a handler, which chain it.
+func raise(instance *interpreter.Instance) *interpreter.Error {
+	err := &interpreter.Error{Err: exceptionMessage(instance), Exception: instance}
+	initChaining(instance)
+	return err
+}
+
+// chain records the error being handled in env's interpreter, if any, as
+// the __context__ of err, unless err already has a context or is part of
+// that error's chain. Errors raised inside a handler or finally block get
+// chained before anything else can catch them.
+func chain(err *interpreter.Error, env *interpreter.Environment) {
+	handling := stateOf(env).handling
+	if len(handling) == 0 || err.Context != nil || err.Abort {
+		return
+	}
+	context := handling[len(handling)-1]
+	instance := exceptionOf(err)
+	if context == err || inChain(context, instance) {
+		return
+	}
+	err.Context = context
+	instance.Attrs["__context__"] = context.Exception
+}
+
+// initChaining gives an exception the chaining attributes it has before it
+// is first raised.
+func initChaining(instance *interpreter.Instance) {
+	defaults := map[string]interpreter.Item{"__context__": NONE, "__cause__": NONE, "__suppress_context__": FALSE}
+	for name, val := range defaults {
+		if _, ok := instance.Attrs[name]; !ok {
+			instance.Attrs[name] = val
+		}
+	}
+}
+
+// inChain reports whether instance is the exception of err or of an error
+// err was chained onto, so that chaining it would make a cycle.
+func inChain(err *interpreter.Error, instance *interpreter.Instance) bool {
+	for ; err != nil; err = err.Context {
+		if err.Exception == instance {
+			return true
+		}
+	}
+	return false
+}
+
+// exceptionMessage formats an exception as its class name followed by its
+// arguments, like the last line of a Python traceback.
+func exceptionMessage(instance *interpreter.Instance) string {
+	if args, _ := instance.Attrs["args"].(*interpreter.Tuple); args == nil || len(args.Elements) == 0 {
+		return instance.Class.Name
+	}
+	return instance.Class.Name + ": " + exceptionText(instance)
+}
+
+// exceptionText returns what str() gives for an exception: nothing if it
+// has no arguments, its argument if it has one, and the tuple of them
+// otherwise.
+func exceptionText(instance *interpreter.Instance) string {
+	args, _ := instance.Attrs["args"].(*interpreter.Tuple)
+	switch {
+	case args == nil || len(args.Elements) == 0:
+		return ""
+	case len(args.Elements) == 1:
+		return args.Elements[0].Visit()
+	default:
+		return args.Visit()
+	}
+}
+
+// exceptionOf returns the exception object carried by err. Errors raised by
+// the interpreter itself get a plain Exception holding their message.
+func exceptionOf(err *interpreter.Error) *interpreter.Instance {
+	if err.Exception == nil {
+		err.Exception = &interpreter.Instance{
+			Class: exceptionClass,
+			Attrs: map[string]interpreter.Item{
+				"args": &interpreter.Tuple{Elements: []interpreter.Item{&interpreter.Str{Val: err.Err}}},
+			},
+		}
+	}
+	return err.Exception
+}
+
+// isSubclass reports whether class is base or derives from it.
+func isSubclass(class *interpreter.Class, base *interpreter.Class) bool {
+	for ; class != nil; class = class.Base {
+		if class == base {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateTryStmt runs the handler matching an error raised by the try
+// body, or the else block if nothing was raised. The finally block runs on
+// every path and replaces the result if it raises, returns or leaves a
+// loop itself. Errors raised by a handler, or by the finally block while
+// an error is unwinding, chain onto the error being handled.
+func evaluateTryStmt(ts *ast.TryStmt, env *interpreter.Environment) interpreter.Item {
+	s := stateOf(env)
+	result := Evaluate(ts.Body, env)
+	if err, ok := result.(*interpreter.Error); ok && !err.Abort {
+		chain(err, env)
+		for _, handler := range ts.Handlers {
+			matched, matchErr := exceptionMatches(handler, err, env)
+			if matchErr != nil {
+				result = matchErr
+				break
+			}
+			if matched {
+				exception := exceptionOf(err)
+				if handler.Name != nil {
+					env.Store(handler.Name.Val, exception)
+				}
+				s.handling = append(s.handling, err)
+				result = Evaluate(handler.Body, env)
+				if raised, ok := result.(*interpreter.Error); ok {
+					chain(raised, env)
+				}
+				s.handling = s.handling[:len(s.handling)-1]
+				break
+			}
+		}
+	} else if ts.Else != nil && !isSignal(result) {
+		result = Evaluate(ts.Else, env)
+	}
+	if ts.Finally != nil {
+		err, unwinding := result.(*interpreter.Error)
+		if unwinding {
+			exceptionOf(err)
+			s.handling = append(s.handling, err)
+		}
+		final := Evaluate(ts.Finally, env)
+		if unwinding {
+			if raised, ok := final.(*interpreter.Error); ok {
+				chain(raised, env)
+			}
+			s.handling = s.handling[:len(s.handling)-1]
+		}
+		if isSignal(final) {
+			return final
+		}
+	}
+	return result
+}
+
+// evaluateRaiseStmt raises an exception instance, instantiating exception
+// classes without arguments. A bare raise re-raises the exception being
+// handled with the traceback it already has. raise ... from sets the
+// exception's __cause__, or with None only hides its __context__.
+func evaluateRaiseStmt(rs *ast.RaiseStmt, env *interpreter.Environment) interpreter.Item {
+	handling := stateOf(env).handling
+	if rs.Exception == nil {
+		if len(handling) == 0 {
+			return newException(runtimeErrorClass, "No active exception to reraise")
+		}
+		current := *handling[len(handling)-1]
+		current.Traceback = append([]interpreter.TraceEntry{}, current.Traceback...)
+		return &current
+	}
+	exception := Evaluate(rs.Exception, env)
+	if exception.Type() == interpreter.ERR {
+		return exception
+	}
+	instance, err := exceptionInstance(exception, "exceptions must derive from BaseException", env)
+	if err != nil {
+		return err
+	}
+	if rs.Cause == nil {
+		return raise(instance)
+	}
+	cause := Evaluate(rs.Cause, env)
+	if cause.Type() == interpreter.ERR {
+		return cause
+	}
+	var causeErr *interpreter.Error
+	if cause != NONE {
+		causeInstance, err := exceptionInstance(cause, "exception causes must derive from BaseException", env)
+		if err != nil {
+			return err
+		}
+		cause = causeInstance
+		causeErr = &interpreter.Error{Err: exceptionMessage(causeInstance), Exception: causeInstance}
+		for _, handled := range handling {
+			if handled.Exception == causeInstance {
+				causeErr = handled
+			}
+		}
+	}
+	raised := raise(instance)
+	raised.Cause = causeErr
+	instance.Attrs["__cause__"] = cause
+	instance.Attrs["__suppress_context__"] = TRUE
+	return raised
+}
+
+// exceptionInstance returns exception if it is an exception instance, or
+// instantiates it without arguments if it is an exception class. Anything
+// else raises TypeError with msg.
+func exceptionInstance(exception interpreter.Item, msg string, env *interpreter.Environment) (*interpreter.Instance, *interpreter.Error) {
+	if class, ok := exception.(*interpreter.Class); ok && isSubclass(class, baseExceptionClass) {
+		exception = instantiate(class, nil, nil, env)
+		if err, ok := exception.(*interpreter.Error); ok {
+			return nil, err
+		}
+	}
+	instance, ok := exception.(*interpreter.Instance)
+	if !ok || !isSubclass(instance.Class, baseExceptionClass) {
+		return nil, newException(typeErrorClass, "%s", msg)
+	}
+	return instance, nil
+}
+
+// SetAssertions makes assert statements in the interpreter env belongs to
+// no-ops when enabled is false, like running Python with -O.
+func SetAssertions(env *interpreter.Environment, enabled bool) {
+	stateOf(env).noAssert = !enabled
+}
+
+func evaluateAssertStmt(as *ast.AssertStmt, env *interpreter.Environment) interpreter.Item {
+	if stateOf(env).noAssert {
+		return nil
+	}
+	cond := Evaluate(as.Cond, env)
+	if cond.Type() == interpreter.ERR {
+		return cond
+	}
+	if isTrue(cond) {
+		return nil
+	}
+	args := []interpreter.Item{}
+	if as.Msg != nil {
+		msg := Evaluate(as.Msg, env)
+		if msg.Type() == interpreter.ERR {
+			return msg
+		}
+		args = append(args, msg)
+	}
+	instance := &interpreter.Instance{
+		Class: assertionErrorClass,
+		Attrs: map[string]interpreter.Item{"args": &interpreter.Tuple{Elements: args}},
+	}
+	return raise(instance)
+}
+
+// evaluateWithStmt enters the i-th context of ws and runs the rest of the
+// statement inside it. The context's __exit__ runs however the body ends
+// and receives the exception if one was raised; a true result suppresses
+// it.
+func evaluateWithStmt(ws *ast.WithStmt, i int, env *interpreter.Environment) interpreter.Item {
+	if i == len(ws.Contexts) {
+		return Evaluate(ws.Body, env)
+	}
+	context := Evaluate(ws.Contexts[i], env)
+	if context.Type() == interpreter.ERR {
+		return context
+	}
+	enter, exit, ok := contextMethods(context)
+	if !ok {
+		return newException(typeErrorClass, "'%s' object does not support the context manager protocol", typeName(context))
+	}
+	result := applyFn(enter, nil, nil, env)
+	if result.Type() == interpreter.ERR {
+		return result
+	}
+	if ws.Targets[i] != nil {
+		result = assign(ws.Targets[i], result, env)
+	}
+	if result.Type() != interpreter.ERR {
+		result = evaluateWithStmt(ws, i+1, env)
+	}
+	args := []interpreter.Item{NONE, NONE, NONE}
+	err, raised := result.(*interpreter.Error)
+	if raised {
+		chain(err, env)
+		exception := exceptionOf(err)
+		args = []interpreter.Item{exception.Class, exception, NONE}
+	}
+	suppress := applyFn(exit, args, nil, env)
+	if suppress.Type() == interpreter.ERR {
+		return suppress
+	}
+	if raised && !err.Abort && isTrue(suppress) {
+		return nil
+	}
+	return result
+}
+
+// contextMethods returns the bound __enter__ and __exit__ methods of a
+// context manager, or false if it lacks either. Like Python, instances are
+// looked up on their class only.
+func contextMethods(context interpreter.Item) (interpreter.Item, interpreter.Item, bool) {
+	if instance, ok := context.(*interpreter.Instance); ok {
+		enter, hasEnter := instance.Class.Lookup("__enter__")
+		exit, hasExit := instance.Class.Lookup("__exit__")
+		return bindMethod(instance, enter), bindMethod(instance, exit), hasEnter && hasExit
+	}
+	enter, exit := getAttr(context, "__enter__"), getAttr(context, "__exit__")
+	return enter, exit, enter.Type() != interpreter.ERR && exit.Type() != interpreter.ERR
+}
+
+// exceptionMatches reports whether handler catches err. The handler type
+// may be an exception class or a tuple of them.
+func exceptionMatches(handler *ast.ExceptClause, err *interpreter.Error, env *interpreter.Environment) (bool, interpreter.Item) {
+	if handler.Type == nil {
+		return true, nil
+	}
+	typ := Evaluate(handler.Type, env)
+	if typ.Type() == interpreter.ERR {
+		return false, typ
+	}
+	classes := []interpreter.Item{typ}
+	if tuple, ok := typ.(*interpreter.Tuple); ok {
+		classes = tuple.Elements
+	}
+	raised := exceptionOf(err).Class
+	for _, item := range classes {
+		class, ok := item.(*interpreter.Class)
+		if !ok || !isSubclass(class, baseExceptionClass) {
+			return false, newException(typeErrorClass, "catching classes that do not inherit from BaseException is not allowed")
+		}
+		if isSubclass(raised, class) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isSignal reports whether item unwinds the enclosing block: an error,
+// return, break or continue.
+func isSignal(item interpreter.Item) bool {
+	if item == nil {
+		return false
+	}
+	switch item.Type() {
+	case interpreter.ERR, interpreter.RETURN, interpreter.BREAK, interpreter.CONTINUE:
+		return true
+	}
+	return false
+}
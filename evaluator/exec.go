@@ -0,0 +1,142 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+	"gopy/parser"
+	"strings"
+)
+
+func init() {
+	registerBuiltin("eval", func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		src, scope, err := execArgs("eval", env, args)
+		if err != nil {
+			return err
+		}
+		expr, perr := parser.ParseExpr(src)
+		if perr != nil {
+			return newException(syntaxErrorClass, "%s", strings.Join(perr.(*parser.SyntaxError).Messages(), "; "))
+		}
+		result := Evaluate(expr, scope)
+		if result.Type() == interpreter.ERR {
+			return result
+		}
+		storeExecArgs(args, scope)
+		return result
+	})
+	registerBuiltin("exec", func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		src, scope, err := execArgs("exec", env, args)
+		if err != nil {
+			return err
+		}
+		program, perr := parser.Parse([]byte(src))
+		if perr != nil {
+			return newException(syntaxErrorClass, "%s", strings.Join(perr.(*parser.SyntaxError).Messages(), "; "))
+		}
+		result := Evaluate(program, scope)
+		if result != nil {
+			switch result.Type() {
+			case interpreter.ERR:
+				return result
+			case interpreter.RETURN:
+				return newException(syntaxErrorClass, "'return' outside function")
+			case interpreter.BREAK:
+				return newException(syntaxErrorClass, "'break' outside loop")
+			case interpreter.CONTINUE:
+				return newException(syntaxErrorClass, "'continue' not properly in loop")
+			}
+		}
+		storeExecArgs(args, scope)
+		return NONE
+	})
+}
+
+// execArgs checks the arguments of eval and exec, returning the source to
+// run and the scope to run it in. By default the source runs in env, the
+// caller's scope. A globals dict replaces env with a new module scope
+// holding its bindings, and a locals dict adds a scope holding its
+// bindings inside that.
+func execArgs(name string, env *interpreter.Environment, args []interpreter.Item) (string, *interpreter.Environment, *interpreter.Error) {
+	if len(args) < 1 || len(args) > 3 {
+		return "", nil, newException(typeErrorClass, "%s expected 1 to 3 arguments, got %d", name, len(args))
+	}
+	var src string
+	switch arg := args[0].(type) {
+	case *interpreter.Str:
+		src = arg.Val
+	case *interpreter.Bytes:
+		decoded := decodeBytes(arg.Val, "utf-8", "strict")
+		if err, ok := decoded.(*interpreter.Error); ok {
+			return "", nil, err
+		}
+		src = decoded.(*interpreter.Str).Val
+	default:
+		return "", nil, newException(typeErrorClass, "%s() arg 1 must be a string or bytes object", name)
+	}
+	scope := env
+	for i, arg := range args[1:] {
+		if arg.Type() == interpreter.NONE {
+			continue
+		}
+		dict, ok := arg.(*interpreter.Dict)
+		if !ok {
+			kind := "globals"
+			if i == 1 {
+				kind = "locals"
+			}
+			return "", nil, newException(typeErrorClass, "%s must be a dict, not %s", kind, typeName(arg))
+		}
+		if i == 0 {
+			scope = newGlobalScope(env)
+		} else {
+			scope = interpreter.NewEnclosedEnv(scope)
+		}
+		for _, key := range dict.Keys {
+			pair := dict.Pairs[key]
+			k, ok := pair.Key.(*interpreter.Str)
+			if !ok {
+				return "", nil, newException(typeErrorClass, "%s keys must be str, not %s", name, typeName(pair.Key))
+			}
+			scope.Store(k.Val, pair.Value)
+		}
+	}
+	return src, scope, nil
+}
+
+// storeExecArgs copies the bindings left in scope by eval or exec back
+// into the globals and locals dicts they were given.
+func storeExecArgs(args []interpreter.Item, scope *interpreter.Environment) {
+	var dicts []*interpreter.Dict
+	for _, arg := range args[1:] {
+		if dict, ok := arg.(*interpreter.Dict); ok {
+			dicts = append(dicts, dict)
+		} else {
+			dicts = append(dicts, nil)
+		}
+	}
+	if len(dicts) == 2 && dicts[1] != nil {
+		storeScope(dicts[1], scope)
+		if dicts[0] == nil {
+			return
+		}
+		scope = scope.Outer()
+	}
+	if len(dicts) > 0 && dicts[0] != nil {
+		storeScope(dicts[0], scope)
+	}
+}
+
+// storeScope makes dict hold the bindings made directly in scope, keeping
+// the order of the keys it already had.
+func storeScope(dict *interpreter.Dict, scope *interpreter.Environment) {
+	locals := scope.Locals()
+	for _, key := range append([]interpreter.HashKey{}, dict.Keys...) {
+		if name, ok := dict.Pairs[key].Key.(*interpreter.Str); ok {
+			if _, bound := locals[name.Val]; !bound {
+				dict.Delete(key)
+			}
+		}
+	}
+	for _, name := range scopeNames(scope) {
+		storeKey(dict, &interpreter.Str{Val: name}, locals[name])
+	}
+}
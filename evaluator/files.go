@@ -0,0 +1,321 @@
+package evaluator
+
+import (
+	"bufio"
+	"errors"
+	"gopy/interpreter"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+)
+
+// fileMethods are the methods available on file objects. Each receives the
+// file itself as its first argument.
+var fileMethods map[string]*interpreter.Builtin
+
+func init() {
+	fileMethods = map[string]*interpreter.Builtin{
+		"read": {
+			Fn: fileRead,
+		},
+		"readline": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "readline() takes no arguments (%d given)", len(args)-1)
+				}
+				return fileReadline(args[0].(*interpreter.File))
+			},
+		},
+		"readlines": {
+			Fn: fileReadlines,
+		},
+		"write": {
+			Fn: fileWrite,
+		},
+		"close": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return closeFile(args[0].(*interpreter.File))
+			},
+		},
+		"__enter__": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if err := checkFile(args[0].(*interpreter.File), ""); err != nil {
+					return err
+				}
+				return args[0]
+			},
+		},
+		"__exit__": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return closeFile(args[0].(*interpreter.File))
+			},
+		},
+	}
+	for name, method := range fileMethods {
+		method.Name = name
+	}
+}
+
+// fileModes maps the access part of an open mode to the flags opening the
+// file, without and with "+".
+var fileModes = map[byte][2]int{
+	'r': {os.O_RDONLY, os.O_RDWR},
+	'w': {os.O_WRONLY | os.O_CREATE | os.O_TRUNC, os.O_RDWR | os.O_CREATE | os.O_TRUNC},
+	'a': {os.O_WRONLY | os.O_CREATE | os.O_APPEND, os.O_RDWR | os.O_CREATE | os.O_APPEND},
+	'x': {os.O_WRONLY | os.O_CREATE | os.O_EXCL, os.O_RDWR | os.O_CREATE | os.O_EXCL},
+}
+
+// builtinOpen implements open(file, mode='r', encoding=None). Text files
+// are read and written as UTF-8, and binary files as bytes.
+func builtinOpen(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	if len(args) == 0 || len(args) > 3 {
+		return newException(typeErrorClass, "open() takes from 1 to 3 positional arguments (%d given)", len(args))
+	}
+	params := map[string]interpreter.Item{"mode": &interpreter.Str{Val: "r"}, "encoding": NONE}
+	for i, name := range []string{"mode", "encoding"} {
+		if i+1 < len(args) {
+			params[name] = args[i+1]
+		}
+	}
+	for name, val := range kwargs {
+		if _, ok := params[name]; !ok {
+			return newException(typeErrorClass, "'%s' is an invalid keyword argument for open()", name)
+		}
+		params[name] = val
+	}
+	path, ok := args[0].(*interpreter.Str)
+	if !ok {
+		return newException(typeErrorClass, "expected str, not %s", typeName(args[0]))
+	}
+	mode, ok := params["mode"].(*interpreter.Str)
+	if !ok {
+		return newException(typeErrorClass, "open() argument 'mode' must be str, not %s", typeName(params["mode"]))
+	}
+	switch encoding := params["encoding"].(type) {
+	case *interpreter.None:
+	case *interpreter.Str:
+		if name := strings.ToLower(strings.Replace(encoding.Val, "-", "", -1)); name != "utf8" {
+			return newException(lookupErrorClass, "unknown encoding: %s", encoding.Val)
+		}
+	default:
+		return newException(typeErrorClass, "open() argument 'encoding' must be str or None, not %s", typeName(encoding))
+	}
+	flag, err := openFlag(mode.Val)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(mode.Val, "b") && params["encoding"] != NONE {
+		return newException(valueErrorClass, "binary mode doesn't take an encoding argument")
+	}
+	f, openErr := os.OpenFile(path.Val, flag, 0666)
+	if openErr != nil {
+		return osError(openErr, path.Val)
+	}
+	if info, statErr := f.Stat(); statErr == nil && info.IsDir() {
+		f.Close()
+		return osError(syscall.EISDIR, path.Val)
+	}
+	return &interpreter.File{Name: path.Val, Mode: mode.Val, File: f, Reader: bufio.NewReader(f)}
+}
+
+// openFlag returns the os.OpenFile flag for a mode made of one of r, w, a
+// or x, optionally followed by +, and t or b, in any order.
+func openFlag(mode string) (int, *interpreter.Error) {
+	if strings.Contains(mode, "b") && strings.Contains(mode, "t") {
+		return 0, newException(valueErrorClass, "can't have text and binary mode at once")
+	}
+	rest := strings.NewReplacer("+", "", "t", "", "b", "").Replace(mode)
+	if len(rest) != 1 {
+		return 0, newException(valueErrorClass, "invalid mode: '%s'", mode)
+	}
+	flags, ok := fileModes[rest[0]]
+	if !ok {
+		return 0, newException(valueErrorClass, "invalid mode: '%s'", mode)
+	}
+	if strings.Contains(mode, "+") {
+		return flags[1], nil
+	}
+	return flags[0], nil
+}
+
+// osError converts an error from the os package into the OSError subclass
+// Python raises for the same errno.
+func osError(err error, path string) *interpreter.Error {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return newException(osErrorClass, "%s", err)
+	}
+	class := osErrorClass
+	switch errno {
+	case syscall.ENOENT:
+		class = fileNotFoundErrorClass
+	case syscall.EEXIST:
+		class = fileExistsErrorClass
+	case syscall.EACCES, syscall.EPERM:
+		class = permissionErrorClass
+	case syscall.EISDIR:
+		class = isADirectoryErrorClass
+	}
+	msg := errno.Error()
+	if msg != "" {
+		msg = strings.ToUpper(msg[:1]) + msg[1:]
+	}
+	return newException(class, "[Errno %d] %s: '%s'", int(errno), msg, path)
+}
+
+// checkFile returns an error if f is closed or, when access is "readable"
+// or "writable", if its mode doesn't allow that.
+func checkFile(f *interpreter.File, access string) *interpreter.Error {
+	if f.Closed {
+		return newException(valueErrorClass, "I/O operation on closed file.")
+	}
+	readable := strings.Contains(f.Mode, "r") || strings.Contains(f.Mode, "+")
+	writable := !strings.Contains(f.Mode, "r") || strings.Contains(f.Mode, "+")
+	if access == "readable" && !readable || access == "writable" && !writable {
+		return newException(osErrorClass, "not %s", access)
+	}
+	return nil
+}
+
+// fileRead implements read(size=-1), returning at most size characters,
+// or bytes in binary mode, or the rest of the file if size is negative or
+// None.
+func fileRead(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) > 2 {
+		return newException(typeErrorClass, "read expected at most 1 argument, got %d", len(args)-1)
+	}
+	f := args[0].(*interpreter.File)
+	if err := checkFile(f, "readable"); err != nil {
+		return err
+	}
+	size := int64(-1)
+	if len(args) == 2 && args[1] != NONE {
+		n, ok := args[1].(*interpreter.Int)
+		if !ok {
+			return newException(typeErrorClass, "argument should be integer or None, not '%s'", typeName(args[1]))
+		}
+		size = n.Val
+	}
+	if size < 0 {
+		data, err := ioutil.ReadAll(f.Reader)
+		if err != nil {
+			return osError(err, f.Name)
+		}
+		if err := allocate(int64(len(data)), env); err != nil {
+			return err
+		}
+		if isBinary(f) {
+			return &interpreter.Bytes{Val: data}
+		}
+		return &interpreter.Str{Val: string(data)}
+	}
+	if isBinary(f) {
+		data, err := ioutil.ReadAll(io.LimitReader(f.Reader, size))
+		if err != nil {
+			return osError(err, f.Name)
+		}
+		if err := allocate(int64(len(data)), env); err != nil {
+			return err
+		}
+		return &interpreter.Bytes{Val: data}
+	}
+	var text strings.Builder
+	for i := int64(0); i < size; i++ {
+		r, _, err := f.Reader.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return osError(err, f.Name)
+		}
+		text.WriteRune(r)
+	}
+	if err := allocate(int64(text.Len()), env); err != nil {
+		return err
+	}
+	return &interpreter.Str{Val: text.String()}
+}
+
+// fileReadline returns the next line of f including its newline, or an
+// empty string or bytes at the end of the file.
+func fileReadline(f *interpreter.File) interpreter.Item {
+	if err := checkFile(f, "readable"); err != nil {
+		return err
+	}
+	line, err := f.Reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return osError(err, f.Name)
+	}
+	if isBinary(f) {
+		return &interpreter.Bytes{Val: line}
+	}
+	return &interpreter.Str{Val: string(line)}
+}
+
+func fileReadlines(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "readlines() takes no arguments (%d given)", len(args)-1)
+	}
+	f := args[0].(*interpreter.File)
+	lines := &interpreter.List{Elements: []interpreter.Item{}}
+	for {
+		line := fileReadline(f)
+		if line.Type() == interpreter.ERR {
+			return line
+		}
+		if !isTrue(line) {
+			return lines
+		}
+		lines.Elements = append(lines.Elements, line)
+	}
+}
+
+// fileWrite writes a string, or bytes in binary mode, to the file and
+// returns the number of characters or bytes written.
+func fileWrite(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 {
+		return newException(typeErrorClass, "write() takes exactly one argument (%d given)", len(args)-1)
+	}
+	f := args[0].(*interpreter.File)
+	if err := checkFile(f, "writable"); err != nil {
+		return err
+	}
+	if isBinary(f) {
+		b, ok := args[1].(*interpreter.Bytes)
+		if !ok {
+			return newException(typeErrorClass, "a bytes-like object is required, not '%s'", typeName(args[1]))
+		}
+		if _, err := f.File.Write(b.Val); err != nil {
+			return osError(err, f.Name)
+		}
+		return &interpreter.Int{Val: int64(len(b.Val))}
+	}
+	s, ok := args[1].(*interpreter.Str)
+	if !ok {
+		return newException(typeErrorClass, "write() argument must be str, not %s", typeName(args[1]))
+	}
+	if _, err := io.WriteString(f.File, s.Val); err != nil {
+		return osError(err, f.Name)
+	}
+	return &interpreter.Int{Val: int64(utf8.RuneCountInString(s.Val))}
+}
+
+// isBinary reports whether f was opened in binary mode.
+func isBinary(f *interpreter.File) bool {
+	return strings.Contains(f.Mode, "b")
+}
+
+// closeFile closes f. Closing a file more than once has no effect.
+func closeFile(f *interpreter.File) interpreter.Item {
+	if f.Closed {
+		return NONE
+	}
+	f.Closed = true
+	if err := f.File.Close(); err != nil {
+		return osError(err, f.Name)
+	}
+	return NONE
+}
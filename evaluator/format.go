@@ -0,0 +1,550 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatSpec is a parsed format specification, as used by format(),
+// str.format and the % operator:
+//
+//	[[fill]align][sign][#][0][width][,|_][.precision][type]
+//
+// A precision of -1 means none was given, and a zero type means the
+// default presentation of the value.
+type formatSpec struct {
+	fill rune
+	align byte
+	sign byte
+	alt bool
+	width int
+	grouping byte
+	precision int
+	typ byte
+}
+
+func parseFormatSpec(spec string) (*formatSpec, *interpreter.Error) {
+	f := &formatSpec{fill: ' ', precision: -1}
+	rest := spec
+	if r, size := utf8.DecodeRuneInString(rest); size > 0 && len(rest) > size && strings.IndexByte("<>=^", rest[size]) >= 0 {
+		f.fill, f.align = r, rest[size]
+		rest = rest[size+1:]
+	} else if rest != "" && strings.IndexByte("<>=^", rest[0]) >= 0 {
+		f.align = rest[0]
+		rest = rest[1:]
+	}
+	if rest != "" && strings.IndexByte("+- ", rest[0]) >= 0 {
+		f.sign = rest[0]
+		rest = rest[1:]
+	}
+	if strings.HasPrefix(rest, "#") {
+		f.alt = true
+		rest = rest[1:]
+	}
+	if strings.HasPrefix(rest, "0") {
+		// A leading zero pads with zeros after the sign unless an
+		// alignment was given.
+		if f.align == 0 {
+			f.fill, f.align = '0', '='
+		}
+		rest = rest[1:]
+	}
+	f.width, rest = leadingInt(rest)
+	if rest != "" && (rest[0] == ',' || rest[0] == '_') {
+		f.grouping = rest[0]
+		rest = rest[1:]
+	}
+	if strings.HasPrefix(rest, ".") {
+		before := rest[1:]
+		f.precision, rest = leadingInt(before)
+		if rest == before {
+			return nil, newException(valueErrorClass, "Format specifier missing precision")
+		}
+	}
+	if rest != "" {
+		f.typ = rest[0]
+		rest = rest[1:]
+	}
+	if rest != "" {
+		return nil, newException(valueErrorClass, "Invalid format specifier")
+	}
+	return f, nil
+}
+
+// leadingInt parses the digits at the start of s, returning 0 if there
+// are none, and the rest of s.
+func leadingInt(s string) (int, string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	n, _ := strconv.Atoi(s[:i])
+	return n, s[i:]
+}
+
+// formatValue implements format(val, spec). Instances may define
+// __format__; otherwise an empty spec gives str(val).
+func formatValue(val interpreter.Item, spec string, env *interpreter.Environment) interpreter.Item {
+	if instance, ok := val.(*interpreter.Instance); ok {
+		if result, ok := callMethod(env, instance, "__format__", &interpreter.Str{Val: spec}); ok {
+			if result.Type() != interpreter.ERR && result.Type() != interpreter.STR {
+				return newException(typeErrorClass, "__format__ must return a str, not %s", typeName(result))
+			}
+			return result
+		}
+	}
+	if spec == "" {
+		return builtinStr(env, val)
+	}
+	f, err := parseFormatSpec(spec)
+	if err != nil {
+		return err
+	}
+	_, isStr := val.(*interpreter.Str)
+	if err := allocateSpec(f, !isStr, env); err != nil {
+		return err
+	}
+	var s string
+	switch val := val.(type) {
+	case *interpreter.Str:
+		s, err = formatStr(val.Val, f)
+	case *interpreter.Bool:
+		s, err = formatInt(boolToInt(val).(*interpreter.Int).Val, f)
+	case *interpreter.Int:
+		s, err = formatInt(val.Val, f)
+	case *interpreter.Float:
+		s, err = formatFloat(val.Val, f)
+	default:
+		return newException(typeErrorClass, "unsupported format string passed to %s.__format__", typeName(val))
+	}
+	if err != nil {
+		return err
+	}
+	return &interpreter.Str{Val: s}
+}
+
+func formatStr(s string, f *formatSpec) (string, *interpreter.Error) {
+	switch {
+	case f.typ != 0 && f.typ != 's':
+		return "", newException(valueErrorClass, "Unknown format code '%c' for object of type 'str'", f.typ)
+	case f.sign != 0:
+		return "", newException(valueErrorClass, "Sign not allowed in string format specifier")
+	case f.alt:
+		return "", newException(valueErrorClass, "Alternate form (#) not allowed in string format specifier")
+	case f.align == '=':
+		return "", newException(valueErrorClass, "'=' alignment not allowed in string format specifier")
+	}
+	if f.precision >= 0 && utf8.RuneCountInString(s) > f.precision {
+		s = string([]rune(s)[:f.precision])
+	}
+	return pad(s, f, '<'), nil
+}
+
+func formatInt(n int64, f *formatSpec) (string, *interpreter.Error) {
+	base, prefix := 10, ""
+	switch f.typ {
+	case 0, 'd', 'n':
+	case 'b':
+		base, prefix = 2, "0b"
+	case 'o':
+		base, prefix = 8, "0o"
+	case 'x':
+		base, prefix = 16, "0x"
+	case 'X':
+		base, prefix = 16, "0X"
+	case 'c':
+		if f.sign != 0 {
+			return "", newException(valueErrorClass, "Sign not allowed with integer format specifier 'c'")
+		}
+		if n < 0 || n > utf8.MaxRune {
+			return "", newException(overflowErrorClass, "%%c arg not in range(0x110000)")
+		}
+		return pad(string(rune(n)), f, '>'), nil
+	case 'e', 'E', 'f', 'F', 'g', 'G', '%':
+		return formatFloat(float64(n), f)
+	default:
+		return "", newException(valueErrorClass, "Unknown format code '%c' for object of type 'int'", f.typ)
+	}
+	if f.precision >= 0 {
+		return "", newException(valueErrorClass, "Precision not allowed in integer format specifier")
+	}
+	if f.grouping == ',' && base != 10 {
+		return "", newException(valueErrorClass, "Cannot specify ',' with '%c'.", f.typ)
+	}
+	if !f.alt {
+		prefix = ""
+	}
+	digits := strconv.FormatUint(uint64(n), base)
+	if n < 0 {
+		digits = strconv.FormatUint(uint64(-n), base)
+	}
+	if f.typ == 'X' {
+		digits = strings.ToUpper(digits)
+	}
+	return formatNumber(n < 0, prefix, digits, f), nil
+}
+
+func formatFloat(x float64, f *formatSpec) (string, *interpreter.Error) {
+	precision := f.precision
+	if precision < 0 {
+		precision = 6
+	}
+	negative := math.Signbit(x) && !math.IsNaN(x)
+	abs := math.Abs(x)
+	var digits string
+	switch {
+	case math.IsInf(x, 0):
+		digits = "inf"
+	case math.IsNaN(x):
+		digits = "nan"
+	}
+	switch f.typ {
+	case 'f', 'F':
+		if digits == "" {
+			digits = strconv.FormatFloat(abs, 'f', precision, 64)
+		}
+	case 'e', 'E':
+		if digits == "" {
+			digits = strconv.FormatFloat(abs, 'e', precision, 64)
+		}
+	case 'g', 'G', 'n':
+		if digits == "" {
+			digits = formatGeneral(abs, precision)
+		}
+	case '%':
+		if digits == "" {
+			digits = strconv.FormatFloat(abs*100, 'f', precision, 64)
+		}
+		digits += "%"
+	case 0:
+		// Without a type, floats print as str() does, or in the general
+		// format if a precision is given, but always with a decimal point
+		// in fixed-point notation.
+		switch {
+		case digits != "":
+		case f.precision < 0:
+			digits = (&interpreter.Float{Val: abs}).Visit()
+		default:
+			digits = formatGeneral(abs, f.precision)
+			if !strings.ContainsAny(digits, ".e") {
+				digits += ".0"
+			}
+		}
+	default:
+		return "", newException(valueErrorClass, "Unknown format code '%c' for object of type 'float'", f.typ)
+	}
+	if f.typ == 'E' || f.typ == 'F' || f.typ == 'G' {
+		digits = strings.ToUpper(digits)
+	}
+	return formatNumber(negative, "", digits, f), nil
+}
+
+// formatGeneral formats x in fixed-point or scientific notation, whichever
+// suits its exponent, with precision significant digits and no trailing
+// zeros.
+func formatGeneral(x float64, precision int) string {
+	if precision == 0 {
+		precision = 1
+	}
+	return strconv.FormatFloat(x, 'g', precision, 64)
+}
+
+// formatNumber adds the sign, prefix, grouping and padding to the digits
+// of a number's absolute value.
+func formatNumber(negative bool, prefix string, digits string, f *formatSpec) string {
+	sign := ""
+	switch {
+	case negative:
+		sign = "-"
+	case f.sign == '+':
+		sign = "+"
+	case f.sign == ' ':
+		sign = " "
+	}
+	if f.grouping != 0 {
+		// Decimal digits are grouped by thousands up to the fraction or
+		// exponent, other bases by fours.
+		end, every := strings.IndexAny(digits, ".eE%"), 3
+		if strings.IndexByte("boxX", f.typ) >= 0 {
+			end, every = -1, 4
+		}
+		if end < 0 {
+			end = len(digits)
+		}
+		var grouped strings.Builder
+		for i := 0; i < end; i++ {
+			if i > 0 && (end-i)%every == 0 {
+				grouped.WriteByte(f.grouping)
+			}
+			grouped.WriteByte(digits[i])
+		}
+		digits = grouped.String() + digits[end:]
+	}
+	if f.align == '=' {
+		n := f.width - utf8.RuneCountInString(sign+prefix+digits)
+		if n > 0 {
+			digits = strings.Repeat(string(f.fill), n) + digits
+		}
+		return sign + prefix + digits
+	}
+	return pad(sign+prefix+digits, f, '>')
+}
+
+// pad pads s to the width of f with its fill character, aligning s by
+// def if f has no alignment.
+func pad(s string, f *formatSpec, def byte) string {
+	n := f.width - utf8.RuneCountInString(s)
+	if n <= 0 {
+		return s
+	}
+	fill := string(f.fill)
+	align := f.align
+	if align == 0 {
+		align = def
+	}
+	switch align {
+	case '<':
+		return s + strings.Repeat(fill, n)
+	case '^':
+		return strings.Repeat(fill, n/2) + s + strings.Repeat(fill, n-n/2)
+	}
+	return strings.Repeat(fill, n) + s
+}
+
+// percentFormat implements format % arg. arg is a tuple of the values to
+// format, a dict the specifiers name keys of, or a single value.
+func percentFormat(format string, arg interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	args := []interpreter.Item{arg}
+	if tuple, ok := arg.(*interpreter.Tuple); ok {
+		args = tuple.Elements
+	}
+	mapping, _ := arg.(*interpreter.Dict)
+	next := 0
+	nextArg := func() (interpreter.Item, *interpreter.Error) {
+		if next >= len(args) {
+			return nil, newException(typeErrorClass, "not enough arguments for format string")
+		}
+		next++
+		return args[next-1], nil
+	}
+	// star reads a * width or precision from the arguments.
+	star := func() (int, *interpreter.Error) {
+		val, err := nextArg()
+		if err != nil {
+			return 0, err
+		}
+		n, ok := val.(*interpreter.Int)
+		if !ok {
+			return 0, newException(typeErrorClass, "* wants int")
+		}
+		return int(n.Val), nil
+	}
+	// The text between conversions is charged up front, and each
+	// conversion as it is written.
+	if err := allocate(int64(len(format)), env); err != nil {
+		return err
+	}
+	var out strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+		i++
+		var val interpreter.Item
+		if i < len(format) && format[i] == '(' {
+			end := strings.IndexByte(format[i:], ')')
+			if end < 0 {
+				return newException(valueErrorClass, "incomplete format key")
+			}
+			if mapping == nil {
+				return newException(typeErrorClass, "format requires a mapping")
+			}
+			key := &interpreter.Str{Val: format[i+1 : i+end]}
+			hash, found, err := dictKey(mapping, key, env)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return newException(keyErrorClass, "%s", interpreter.Repr(key))
+			}
+			val, _ = mapping.Get(hash)
+			i += end + 1
+		}
+		f := &formatSpec{fill: ' ', align: '>', precision: -1}
+		zero := false
+		for ; i < len(format) && strings.IndexByte("-+ #0", format[i]) >= 0; i++ {
+			switch format[i] {
+			case '-':
+				f.align = '<'
+			case '+':
+				f.sign = '+'
+			case ' ':
+				if f.sign == 0 {
+					f.sign = ' '
+				}
+			case '#':
+				f.alt = true
+			case '0':
+				zero = true
+			}
+		}
+		if zero && f.align != '<' {
+			f.fill, f.align = '0', '='
+		}
+		if i < len(format) && format[i] == '*' {
+			width, err := star()
+			if err != nil {
+				return err
+			}
+			if width < 0 {
+				f.align, width = '<', -width
+			}
+			f.width = width
+			i++
+		} else {
+			f.width = leadingIntAt(format, &i)
+		}
+		if i < len(format) && format[i] == '.' {
+			i++
+			if i < len(format) && format[i] == '*' {
+				precision, err := star()
+				if err != nil {
+					return err
+				}
+				f.precision = precision
+				i++
+			} else {
+				f.precision = leadingIntAt(format, &i)
+			}
+		}
+		for i < len(format) && strings.IndexByte("hlL", format[i]) >= 0 {
+			i++
+		}
+		if i == len(format) {
+			return newException(valueErrorClass, "incomplete format")
+		}
+		c := format[i]
+		if c == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if val == nil {
+			var err *interpreter.Error
+			if val, err = nextArg(); err != nil {
+				return err
+			}
+		}
+		if err := allocateSpec(f, strings.IndexByte("srac", c) < 0, env); err != nil {
+			return err
+		}
+		s, err := percentConvert(c, val, f, i, env)
+		if err != nil {
+			return err
+		}
+		if err := allocate(int64(len(s)), env); err != nil {
+			return err
+		}
+		out.WriteString(s)
+	}
+	if next < len(args) && mapping == nil {
+		return newException(typeErrorClass, "not all arguments converted during string formatting")
+	}
+	return &interpreter.Str{Val: out.String()}
+}
+
+// allocateSpec charges for the padding f asks for, and for its precision
+// when digits count it out rather than truncating, before anything is
+// built.
+func allocateSpec(f *formatSpec, digits bool, env *interpreter.Environment) *interpreter.Error {
+	if err := allocateRepeat(utf8.RuneLen(f.fill), int64(f.width), env); err != nil {
+		return err
+	}
+	if digits && f.precision > 0 {
+		return allocate(int64(f.precision), env)
+	}
+	return nil
+}
+
+// leadingIntAt parses the digits of format at *i, advancing *i past them.
+func leadingIntAt(format string, i *int) int {
+	n, rest := leadingInt(format[*i:])
+	*i = len(format) - len(rest)
+	return n
+}
+
+// percentConvert formats val for the % conversion c, found at index of
+// the format.
+func percentConvert(c byte, val interpreter.Item, f *formatSpec, index int, env *interpreter.Environment) (string, *interpreter.Error) {
+	switch c {
+	case 's', 'r', 'a':
+		var s string
+		if c == 's' {
+			str := builtinStr(env, val)
+			if err, ok := str.(*interpreter.Error); ok {
+				return "", err
+			}
+			s = str.(*interpreter.Str).Val
+		} else {
+			var err *interpreter.Error
+			if s, err = repr(val, env); err != nil {
+				return "", err
+			}
+		}
+		if f.precision >= 0 && utf8.RuneCountInString(s) > f.precision {
+			s = string([]rune(s)[:f.precision])
+		}
+		return pad(s, &formatSpec{fill: ' ', align: f.align, width: f.width}, '>'), nil
+	case 'd', 'i', 'u':
+		var n int64
+		switch val := val.(type) {
+		case *interpreter.Int:
+			n = val.Val
+		case *interpreter.Bool:
+			n = boolToInt(val).(*interpreter.Int).Val
+		case *interpreter.Float:
+			if math.IsInf(val.Val, 0) || math.IsNaN(val.Val) {
+				return "", newException(overflowErrorClass, "cannot convert float %s to integer", val.Visit())
+			}
+			n = int64(val.Val)
+		default:
+			return "", newException(typeErrorClass, "%%%c format: a real number is required, not %s", c, typeName(val))
+		}
+		f.typ, f.precision = 'd', -1
+		return formatInt(n, f)
+	case 'o', 'x', 'X':
+		var n int64
+		switch val := val.(type) {
+		case *interpreter.Int:
+			n = val.Val
+		case *interpreter.Bool:
+			n = boolToInt(val).(*interpreter.Int).Val
+		default:
+			return "", newException(typeErrorClass, "%%%c format: an integer is required, not %s", c, typeName(val))
+		}
+		f.typ, f.precision = c, -1
+		return formatInt(n, f)
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		x, err := toFloat(val, env)
+		if err != nil {
+			return "", err
+		}
+		f.typ = c
+		return formatFloat(x, f)
+	case 'c':
+		switch val := val.(type) {
+		case *interpreter.Int:
+			f.typ, f.sign, f.precision = 'c', 0, -1
+			return formatInt(val.Val, f)
+		case *interpreter.Str:
+			if utf8.RuneCountInString(val.Val) == 1 {
+				return pad(val.Val, f, '>'), nil
+			}
+		}
+		return "", newException(typeErrorClass, "%%c requires int or char")
+	}
+	return "", newException(valueErrorClass, "unsupported format character '%c' (0x%x) at index %d", c, c, index)
+}
@@ -0,0 +1,219 @@
+package evaluator
+
+import (
+	"context"
+	"gopy/ast"
+	"gopy/interpreter"
+	"runtime"
+)
+
+// generatorMethods are the methods available on generator objects.
+var generatorMethods map[string]*interpreter.Builtin
+
+func init() {
+	generatorMethods = map[string]*interpreter.Builtin{
+		"__next__": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return resumeGenerator(args[0].(*interpreter.Generator), NONE, env)
+			},
+		},
+		"send": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 2 {
+					return newException(typeErrorClass, "send() takes exactly one argument (%d given)", len(args)-1)
+				}
+				gen := args[0].(*interpreter.Generator)
+				if gen.Start != nil && args[1] != NONE {
+					return newException(typeErrorClass, "can't send non-None value to a just-started generator")
+				}
+				return resumeGenerator(gen, args[1], env)
+			},
+		},
+		"close": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return closeGenerator(args[0].(*interpreter.Generator), env)
+			},
+		},
+	}
+	for name, method := range generatorMethods {
+		method.Name = name
+	}
+}
+
+// newGenerator returns the generator for a call of fn whose arguments are
+// already bound in env. The body does not start until the first resume.
+func newGenerator(fn *interpreter.Function, env *interpreter.Environment) *interpreter.Generator {
+	gen := &interpreter.Generator{
+		Name: fn.Name,
+		Resume: make(chan interpreter.Item),
+		Yield: make(chan interpreter.Item),
+	}
+	env.Store("__generator__", gen)
+	gen.Start = func() {
+		result := Evaluate(fn.Body, env)
+		if result == nil || (result.Type() != interpreter.RETURN && result.Type() != interpreter.ERR) {
+			result = &interpreter.ReturnValue{Value: NONE}
+		}
+		gen.Yield <- result
+		gen.Stop()
+	}
+	return gen
+}
+
+// startGenerator starts the goroutine running gen's body. It is stopped
+// when the interpreter env belongs to is closed, or when the context of
+// the evaluation starting it is done, so that generators left paused
+// don't outlive them.
+func startGenerator(gen *interpreter.Generator, env *interpreter.Environment) {
+	s := stateOf(env)
+	ctx, stop := context.WithCancel(s.life)
+	gen.Stopped, gen.Stop = ctx.Done(), stop
+	if s.caller != nil && s.caller.Done() != nil {
+		caller := s.caller.Done()
+		go func() {
+			select {
+			case <-caller:
+				stop()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	start := gen.Start
+	gen.Start = nil
+	go start()
+}
+
+// resumeGenerator runs gen until its next yield, passing sent in as the
+// value of the paused yield expression. A finished generator raises
+// StopIteration carrying its return value.
+func resumeGenerator(gen *interpreter.Generator, sent interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	if gen.Done {
+		return newStopIteration(nil)
+	}
+	// The body runs as a call of the generator's function until it yields.
+	if err := pushFrame(gen.Name, env); err != nil {
+		return err
+	}
+	if gen.Start != nil {
+		startGenerator(gen, env)
+	} else {
+		select {
+		case gen.Resume <- sent:
+		case <-gen.Stopped:
+			gen.Done = true
+			err := newStopIteration(nil)
+			popFrame(err, env)
+			return err
+		}
+	}
+	out := <-gen.Yield
+	popFrame(out, env)
+	switch out := out.(type) {
+	case *interpreter.ReturnValue:
+		gen.Done = true
+		return newStopIteration(out.Value)
+	case *interpreter.Error:
+		gen.Done = true
+		if isSubclass(exceptionOf(out).Class, stopIterationClass) {
+			return newException(runtimeErrorClass, "generator raised StopIteration")
+		}
+		return out
+	default:
+		return out
+	}
+}
+
+// closeGenerator raises GeneratorExit at the paused yield so that the body
+// unwinds, running its finally blocks.
+func closeGenerator(gen *interpreter.Generator, env *interpreter.Environment) interpreter.Item {
+	if gen.Start != nil || gen.Done {
+		gen.Start = nil
+		gen.Done = true
+		return NONE
+	}
+	gen.Done = true
+	select {
+	case gen.Resume <- newException(generatorExitClass, ""):
+	case <-gen.Stopped:
+		return NONE
+	}
+	out := <-gen.Yield
+	switch out := out.(type) {
+	case *interpreter.ReturnValue:
+		return NONE
+	case *interpreter.Error:
+		if isSubclass(exceptionOf(out).Class, generatorExitClass) {
+			return NONE
+		}
+		return out
+	default:
+		// The body yielded again instead of finishing, so its goroutine
+		// is stopped there.
+		gen.Stop()
+		return newException(runtimeErrorClass, "generator ignored GeneratorExit")
+	}
+}
+
+// evaluateYieldExpr hands value to the caller resuming the generator and
+// waits to be resumed. An error sent back, such as the GeneratorExit from
+// close(), is raised at the yield. If the generator is stopped instead,
+// its goroutine ends there.
+func evaluateYieldExpr(ye *ast.YieldExpr, env *interpreter.Environment) interpreter.Item {
+	var val interpreter.Item = NONE
+	if ye.Value != nil {
+		val = Evaluate(ye.Value, env)
+		if val.Type() == interpreter.ERR {
+			return val
+		}
+	}
+	item, ok := env.Get("__generator__")
+	if !ok {
+		return newException(syntaxErrorClass, "'yield' outside function")
+	}
+	gen := item.(*interpreter.Generator)
+	gen.Yield <- val
+	select {
+	case sent := <-gen.Resume:
+		return sent
+	case <-gen.Stopped:
+		runtime.Goexit()
+		return nil
+	}
+}
+
+func newStopIteration(value interpreter.Item) *interpreter.Error {
+	args := []interpreter.Item{}
+	if value != nil && value != NONE {
+		args = append(args, value)
+	}
+	instance := &interpreter.Instance{
+		Class: stopIterationClass,
+		Attrs: map[string]interpreter.Item{"args": &interpreter.Tuple{Elements: args}},
+	}
+	return raise(instance)
+}
+
+// next advances an iterator: a generator, a builtin iterator, a file or
+// an instance defining __next__.
+func next(iterator interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	switch iterator := iterator.(type) {
+	case *interpreter.Generator:
+		return resumeGenerator(iterator, NONE, env)
+	case *interpreter.Iterator:
+		if item, ok := iterator.Next(); ok {
+			return item
+		}
+		return newStopIteration(nil)
+	case *interpreter.File:
+		line := fileReadline(iterator)
+		if line.Type() != interpreter.ERR && !isTrue(line) {
+			return newStopIteration(nil)
+		}
+		return line
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, iterator, "__next__"); ok {
+			return result
+		}
+	}
+	return newException(typeErrorClass, "'%s' object is not an iterator", typeName(iterator))
+}
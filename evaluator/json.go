@@ -0,0 +1,371 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopy/interpreter"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonDecodeErrorClass is raised by json.loads on malformed input.
+var jsonDecodeErrorClass = &interpreter.Class{Name: "JSONDecodeError", Base: valueErrorClass, Attrs: map[string]interpreter.Item{}}
+
+func init() {
+	nativeModules["json"] = newJSONModule
+}
+
+func newJSONModule(*interpreter.Environment) *interpreter.Module {
+	return newNativeModule("json", map[string]interpreter.Item{
+		"dumps": &interpreter.Builtin{KwFn: jsonDumps},
+		"loads": &interpreter.Builtin{Fn: jsonLoads},
+		"JSONDecodeError": jsonDecodeErrorClass,
+	})
+}
+
+// jsonEncoder writes items as JSON the way Python's json module does by
+// default: non-ASCII characters escaped and ", " and ": " separators, or
+// one item per line if indent is set.
+type jsonEncoder struct {
+	buf bytes.Buffer
+	indented bool
+	indent string
+	sortKeys bool
+	// encoding holds the containers being encoded, to detect cycles.
+	encoding []interpreter.Item
+	env *interpreter.Environment
+}
+
+// jsonDumps implements dumps(obj, indent=None, sort_keys=False).
+func jsonDumps(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "dumps() takes 1 positional argument but %d were given", len(args))
+	}
+	e := &jsonEncoder{env: env}
+	for name, val := range kwargs {
+		switch name {
+		case "indent":
+			switch indent := val.(type) {
+			case *interpreter.None:
+			case *interpreter.Int:
+				e.indented = true
+				if err := allocate(indent.Val, env); err != nil {
+					return err
+				}
+				if indent.Val > 0 {
+					e.indent = strings.Repeat(" ", int(indent.Val))
+				}
+			case *interpreter.Str:
+				e.indented, e.indent = true, indent.Val
+			default:
+				return newException(typeErrorClass, "indent must be None, an int or a str, not %s", typeName(val))
+			}
+		case "sort_keys":
+			e.sortKeys = isTrue(val)
+		default:
+			return newException(typeErrorClass, "dumps() got an unexpected keyword argument '%s'", name)
+		}
+	}
+	if err := e.encode(args[0], 0); err != nil {
+		return err
+	}
+	return &interpreter.Str{Val: e.buf.String()}
+}
+
+// newline starts a new line at the given depth when indenting, charging
+// for its indentation.
+func (e *jsonEncoder) newline(depth int) *interpreter.Error {
+	if !e.indented {
+		return nil
+	}
+	if err := allocateRepeat(len(e.indent), int64(depth)+1, e.env); err != nil {
+		return err
+	}
+	e.buf.WriteByte('\n')
+	e.buf.WriteString(strings.Repeat(e.indent, depth))
+	return nil
+}
+
+func (e *jsonEncoder) encode(item interpreter.Item, depth int) *interpreter.Error {
+	switch item := item.(type) {
+	case *interpreter.None:
+		e.buf.WriteString("null")
+	case *interpreter.Bool:
+		e.buf.WriteString(strconv.FormatBool(item.Val))
+	case *interpreter.Int:
+		e.buf.WriteString(item.Visit())
+	case *interpreter.Float:
+		e.buf.WriteString(jsonFloat(item.Val))
+	case *interpreter.Str:
+		e.writeString(item.Val)
+	case *interpreter.List, *interpreter.Tuple, *interpreter.Dict:
+		for _, seen := range e.encoding {
+			if seen == item {
+				return newException(valueErrorClass, "Circular reference detected")
+			}
+		}
+		e.encoding = append(e.encoding, item)
+		defer func() { e.encoding = e.encoding[:len(e.encoding)-1] }()
+		if dict, ok := item.(*interpreter.Dict); ok {
+			return e.encodeDict(dict, depth)
+		}
+		elements, err := iterate(item, e.env)
+		if err != nil {
+			return err
+		}
+		return e.encodeArray(elements, depth)
+	default:
+		return newException(typeErrorClass, "Object of type %s is not JSON serializable", typeName(item))
+	}
+	return nil
+}
+
+func (e *jsonEncoder) encodeArray(elements []interpreter.Item, depth int) *interpreter.Error {
+	e.buf.WriteByte('[')
+	for i, elem := range elements {
+		if i > 0 {
+			e.separator()
+		}
+		if err := e.newline(depth + 1); err != nil {
+			return err
+		}
+		if err := e.encode(elem, depth+1); err != nil {
+			return err
+		}
+	}
+	if len(elements) > 0 {
+		if err := e.newline(depth); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte(']')
+	return nil
+}
+
+func (e *jsonEncoder) encodeDict(dict *interpreter.Dict, depth int) *interpreter.Error {
+	keys := make([]string, len(dict.Keys))
+	values := make(map[string]interpreter.Item, len(dict.Keys))
+	for i, hash := range dict.Keys {
+		pair := dict.Pairs[hash]
+		switch key := pair.Key.(type) {
+		case *interpreter.Str:
+			keys[i] = key.Val
+		case *interpreter.Int:
+			keys[i] = key.Visit()
+		case *interpreter.Float:
+			keys[i] = jsonFloat(key.Val)
+		case *interpreter.Bool:
+			keys[i] = strconv.FormatBool(key.Val)
+		case *interpreter.None:
+			keys[i] = "null"
+		default:
+			return newException(typeErrorClass, "keys must be str, int, float, bool or None, not %s", typeName(pair.Key))
+		}
+		values[keys[i]] = pair.Value
+	}
+	if e.sortKeys {
+		sort.Strings(keys)
+	}
+	e.buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			e.separator()
+		}
+		if err := e.newline(depth + 1); err != nil {
+			return err
+		}
+		e.writeString(key)
+		e.buf.WriteString(": ")
+		if err := e.encode(values[key], depth+1); err != nil {
+			return err
+		}
+	}
+	if len(keys) > 0 {
+		if err := e.newline(depth); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+// separator writes the separator between items, whose trailing space is
+// left out when each item goes on its own line.
+func (e *jsonEncoder) separator() {
+	if !e.indented {
+		e.buf.WriteString(", ")
+	} else {
+		e.buf.WriteByte(',')
+	}
+}
+
+// writeString writes s as a JSON string with everything outside printable
+// ASCII escaped.
+func (e *jsonEncoder) writeString(s string) {
+	e.buf.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			e.buf.WriteByte('\\')
+			e.buf.WriteRune(r)
+		case r == '\n':
+			e.buf.WriteString(`\n`)
+		case r == '\r':
+			e.buf.WriteString(`\r`)
+		case r == '\t':
+			e.buf.WriteString(`\t`)
+		case r == '\b':
+			e.buf.WriteString(`\b`)
+		case r == '\f':
+			e.buf.WriteString(`\f`)
+		case r < 0x20 || r > 0x7e && r < 0x10000:
+			fmt.Fprintf(&e.buf, `\u%04x`, r)
+		case r >= 0x10000:
+			r -= 0x10000
+			fmt.Fprintf(&e.buf, `\u%04x\u%04x`, 0xd800+(r>>10), 0xdc00+(r&0x3ff))
+		default:
+			e.buf.WriteRune(r)
+		}
+	}
+	e.buf.WriteByte('"')
+}
+
+// jsonFloat formats a float like Python's json module, which writes the
+// non-finite values as JavaScript names.
+func jsonFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return (&interpreter.Float{Val: f}).Visit()
+}
+
+// jsonLoads parses a JSON document into dicts, lists, strs, ints, floats,
+// bools and None. Object keys keep their order.
+func jsonLoads(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "loads() takes 1 positional argument but %d were given", len(args))
+	}
+	s, ok := args[0].(*interpreter.Str)
+	if !ok {
+		return newException(typeErrorClass, "the JSON object must be str, not '%s'", typeName(args[0]))
+	}
+	dec := json.NewDecoder(strings.NewReader(s.Val))
+	dec.UseNumber()
+	item, err := jsonDecode(dec, s.Val)
+	if err != nil {
+		return err
+	}
+	end := int(dec.InputOffset())
+	if _, tokenErr := dec.Token(); tokenErr != io.EOF {
+		rest := s.Val[end:]
+		return jsonError("Extra data", s.Val, end+len(rest)-len(strings.TrimLeft(rest, " \t\r\n")))
+	}
+	return item
+}
+
+func jsonDecode(dec *json.Decoder, src string) (interpreter.Item, *interpreter.Error) {
+	offset := int(dec.InputOffset())
+	token, err := dec.Token()
+	if err == io.EOF {
+		return nil, jsonError("Expecting value", src, len(src))
+	}
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		return nil, jsonSyntaxError(syntaxErr, src)
+	}
+	if err != nil {
+		return nil, jsonError(err.Error(), src, offset)
+	}
+	switch token := token.(type) {
+	case nil:
+		return NONE, nil
+	case bool:
+		return nativeBool(token), nil
+	case string:
+		return &interpreter.Str{Val: token}, nil
+	case json.Number:
+		if n, err := token.Int64(); err == nil {
+			return &interpreter.Int{Val: n}, nil
+		}
+		f, _ := token.Float64()
+		return &interpreter.Float{Val: f}, nil
+	case json.Delim:
+		if token == '[' {
+			list := &interpreter.List{Elements: []interpreter.Item{}}
+			for dec.More() {
+				elem, err := jsonDecode(dec, src)
+				if err != nil {
+					return nil, err
+				}
+				list.Elements = append(list.Elements, elem)
+			}
+			_, err := dec.Token()
+			return list, jsonTokenError(err, dec, src)
+		}
+		dict := interpreter.NewDict()
+		for dec.More() {
+			key, err := jsonDecode(dec, src)
+			if err != nil {
+				return nil, err
+			}
+			val, err := jsonDecode(dec, src)
+			if err != nil {
+				return nil, err
+			}
+			storeKey(dict, key, val)
+		}
+		_, err := dec.Token()
+		return dict, jsonTokenError(err, dec, src)
+	}
+	return nil, jsonError("Expecting value", src, offset)
+}
+
+// jsonTokenError converts an error reading the token closing an array or
+// object.
+func jsonTokenError(err error, dec *json.Decoder, src string) *interpreter.Error {
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case *json.SyntaxError:
+		return jsonSyntaxError(err, src)
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return jsonError("Unexpected end of JSON input", src, len(src))
+	}
+	return jsonError(err.Error(), src, int(dec.InputOffset()))
+}
+
+// jsonSyntaxError converts a syntax error from encoding/json, whose
+// offset is just past the offending byte, or at the end of the input if
+// it ended early.
+func jsonSyntaxError(err *json.SyntaxError, src string) *interpreter.Error {
+	msg := err.Error()
+	pos := int(err.Offset) - 1
+	if strings.HasPrefix(msg, "unexpected end") {
+		pos = len(src)
+	}
+	return jsonError(strings.ToUpper(msg[:1])+msg[1:], src, pos)
+}
+
+// jsonError returns a JSONDecodeError locating the byte offset pos in src
+// by line, column and character like Python does.
+func jsonError(msg string, src string, pos int) *interpreter.Error {
+	if pos < 0 {
+		pos = 0
+	}
+	if pos > len(src) {
+		pos = len(src)
+	}
+	line := strings.Count(src[:pos], "\n") + 1
+	col := utf8.RuneCountInString(src[strings.LastIndex(src[:pos], "\n")+1:pos]) + 1
+	return newException(jsonDecodeErrorClass, "%s: line %d column %d (char %d)", msg, line, col, utf8.RuneCountInString(src[:pos]))
+}
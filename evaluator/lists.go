@@ -0,0 +1,229 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+)
+
+// listMethods are the methods available on list objects. Each receives
+// the list itself as its first argument. Apart from copy, they change it
+// in place, so every reference to the list sees the result.
+var listMethods map[string]*interpreter.Builtin
+
+func init() {
+	listMethods = map[string]*interpreter.Builtin{
+		"append": {
+			Fn: listAppend,
+		},
+		"extend": {
+			Fn: listExtend,
+		},
+		"insert": {
+			Fn: listInsert,
+		},
+		"pop": {
+			Fn: listPop,
+		},
+		"remove": {
+			Fn: listRemove,
+		},
+		"index": {
+			Fn: listIndex,
+		},
+		"count": {
+			Fn: listCount,
+		},
+		"sort": {
+			KwFn: listSort,
+		},
+		"reverse": {
+			Fn: listReverse,
+		},
+		"copy": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "list.copy() takes no arguments (%d given)", len(args)-1)
+				}
+				return shallowCopy(args[0], env)
+			},
+		},
+	}
+	for name, method := range listMethods {
+		method.Name = name
+	}
+}
+
+func listAppend(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 {
+		return newException(typeErrorClass, "list.append() takes exactly one argument (%d given)", len(args)-1)
+	}
+	if err := allocate(slotSize, env); err != nil {
+		return err
+	}
+	list := args[0].(*interpreter.List)
+	list.Elements = append(list.Elements, args[1])
+	return NONE
+}
+
+func listExtend(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 {
+		return newException(typeErrorClass, "list.extend() takes exactly one argument (%d given)", len(args)-1)
+	}
+	items, err := iterate(args[1], env)
+	if err != nil {
+		return err
+	}
+	if err := allocateRepeat(slotSize, int64(len(items)), env); err != nil {
+		return err
+	}
+	list := args[0].(*interpreter.List)
+	list.Elements = append(list.Elements, items...)
+	return NONE
+}
+
+// listInsert implements list.insert(i, x). Like slicing, an index past
+// either end inserts at that end.
+func listInsert(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 3 {
+		return newException(typeErrorClass, "insert expected 2 arguments, got %d", len(args)-1)
+	}
+	list := args[0].(*interpreter.List)
+	index, ok := args[1].(*interpreter.Int)
+	if !ok {
+		return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(args[1]))
+	}
+	if err := allocate(slotSize, env); err != nil {
+		return err
+	}
+	i := clampIndex(int(index.Val), len(list.Elements))
+	list.Elements = append(list.Elements, nil)
+	copy(list.Elements[i+1:], list.Elements[i:])
+	list.Elements[i] = args[2]
+	return NONE
+}
+
+// listPop removes and returns the item at the optional index, which
+// defaults to the last one.
+func listPop(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) > 2 {
+		return newException(typeErrorClass, "pop expected at most 1 argument, got %d", len(args)-1)
+	}
+	list := args[0].(*interpreter.List)
+	if len(list.Elements) == 0 {
+		return newException(indexErrorClass, "pop from empty list")
+	}
+	i := len(list.Elements) - 1
+	if len(args) == 2 {
+		index, ok := args[1].(*interpreter.Int)
+		if !ok {
+			return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(args[1]))
+		}
+		if i = int(index.Val); i < 0 {
+			i += len(list.Elements)
+		}
+		if i < 0 || i >= len(list.Elements) {
+			return newException(indexErrorClass, "pop index out of range")
+		}
+	}
+	item := list.Elements[i]
+	list.Elements = append(list.Elements[:i], list.Elements[i+1:]...)
+	return item
+}
+
+func listRemove(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 {
+		return newException(typeErrorClass, "list.remove() takes exactly one argument (%d given)", len(args)-1)
+	}
+	list := args[0].(*interpreter.List)
+	i, err := findItem(list.Elements, args[1], env)
+	if err != nil {
+		return err
+	}
+	if i < 0 {
+		return newException(valueErrorClass, "list.remove(x): x not in list")
+	}
+	list.Elements = append(list.Elements[:i], list.Elements[i+1:]...)
+	return NONE
+}
+
+func listIndex(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 {
+		return newException(typeErrorClass, "index expected 1 argument, got %d", len(args)-1)
+	}
+	i, err := findItem(args[0].(*interpreter.List).Elements, args[1], env)
+	if err != nil {
+		return err
+	}
+	if i < 0 {
+		return newException(valueErrorClass, "%s is not in list", interpreter.Repr(args[1]))
+	}
+	return &interpreter.Int{Val: int64(i)}
+}
+
+func listCount(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 {
+		return newException(typeErrorClass, "list.count() takes exactly one argument (%d given)", len(args)-1)
+	}
+	n := 0
+	for _, item := range args[0].(*interpreter.List).Elements {
+		eq, err := itemsEqual(item, args[1], env)
+		if err != nil {
+			return err
+		}
+		if eq {
+			n++
+		}
+	}
+	return &interpreter.Int{Val: int64(n)}
+}
+
+// listSort sorts a list in place, taking the same key and reverse keyword
+// arguments as sorted.
+func listSort(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "sort() takes no positional arguments")
+	}
+	sorted := builtinSorted(env, args, kwargs)
+	if sorted.Type() == interpreter.ERR {
+		return sorted
+	}
+	list := args[0].(*interpreter.List)
+	copy(list.Elements, sorted.(*interpreter.List).Elements)
+	return NONE
+}
+
+func listReverse(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "list.reverse() takes no arguments (%d given)", len(args)-1)
+	}
+	elements := args[0].(*interpreter.List).Elements
+	for i, j := 0, len(elements)-1; i < j; i, j = i+1, j-1 {
+		elements[i], elements[j] = elements[j], elements[i]
+	}
+	return NONE
+}
+
+// findItem returns the position of the first of items equal to x, or -1.
+func findItem(items []interpreter.Item, x interpreter.Item, env *interpreter.Environment) (int, *interpreter.Error) {
+	for i, item := range items {
+		eq, err := itemsEqual(item, x, env)
+		if err != nil {
+			return 0, err
+		}
+		if eq {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// itemsEqual reports whether a == b, identical items always being equal.
+func itemsEqual(a interpreter.Item, b interpreter.Item, env *interpreter.Environment) (bool, *interpreter.Error) {
+	if a == b {
+		return true, nil
+	}
+	eq := evaluateInfixExpr("==", a, b, env)
+	if err, ok := eq.(*interpreter.Error); ok {
+		return false, err
+	}
+	return isTrue(eq), nil
+}
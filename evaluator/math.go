@@ -0,0 +1,232 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+	"math"
+)
+
+func init() {
+	nativeModules["math"] = newMathModule
+}
+
+func newMathModule(*interpreter.Environment) *interpreter.Module {
+	return newNativeModule("math", map[string]interpreter.Item{
+		"pi": &interpreter.Float{Val: math.Pi},
+		"e": &interpreter.Float{Val: math.E},
+		"tau": &interpreter.Float{Val: 2 * math.Pi},
+		"inf": &interpreter.Float{Val: math.Inf(1)},
+		"nan": &interpreter.Float{Val: math.NaN()},
+		"sqrt": mathFunc("sqrt", math.Sqrt),
+		"exp": mathFunc("exp", math.Exp),
+		"sin": mathFunc("sin", math.Sin),
+		"cos": mathFunc("cos", math.Cos),
+		"tan": mathFunc("tan", math.Tan),
+		"asin": mathFunc("asin", math.Asin),
+		"acos": mathFunc("acos", math.Acos),
+		"atan": mathFunc("atan", math.Atan),
+		"fabs": mathFunc("fabs", math.Abs),
+		"log2": mathFunc("log2", math.Log2),
+		"log10": mathFunc("log10", math.Log10),
+		"degrees": mathFunc("degrees", func(x float64) float64 { return x * 180 / math.Pi }),
+		"radians": mathFunc("radians", func(x float64) float64 { return x * math.Pi / 180 }),
+		"floor": mathRound("floor", math.Floor, "__floor__"),
+		"ceil": mathRound("ceil", math.Ceil, "__ceil__"),
+		"trunc": mathRound("trunc", math.Trunc, "__trunc__"),
+		"isnan": mathTest("isnan", math.IsNaN),
+		"isinf": mathTest("isinf", func(x float64) bool { return math.IsInf(x, 0) }),
+		"isfinite": mathTest("isfinite", func(x float64) bool { return !math.IsNaN(x) && !math.IsInf(x, 0) }),
+		"log": &interpreter.Builtin{Fn: mathLog},
+		"pow": mathFunc2("pow", math.Pow),
+		"atan2": mathFunc2("atan2", math.Atan2),
+		"hypot": mathFunc2("hypot", math.Hypot),
+		"fmod": mathFunc2("fmod", math.Mod),
+		"copysign": mathFunc2("copysign", math.Copysign),
+		"factorial": &interpreter.Builtin{Fn: mathFactorial},
+		"gcd": &interpreter.Builtin{Fn: mathGcd},
+	})
+}
+
+// toFloat converts a number argument of a math function to a float.
+func toFloat(item interpreter.Item, env *interpreter.Environment) (float64, *interpreter.Error) {
+	switch item := item.(type) {
+	case *interpreter.Float:
+		return item.Val, nil
+	case *interpreter.Int:
+		return float64(item.Val), nil
+	case *interpreter.Bool:
+		return float64(boolToInt(item).(*interpreter.Int).Val), nil
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, item, "__float__"); ok {
+			if err, ok := result.(*interpreter.Error); ok {
+				return 0, err
+			}
+			if f, ok := result.(*interpreter.Float); ok {
+				return f.Val, nil
+			}
+			return 0, newException(typeErrorClass, "%s.__float__ returned non-float (type %s)", typeName(item), typeName(result))
+		}
+	}
+	return 0, newException(typeErrorClass, "must be real number, not %s", typeName(item))
+}
+
+// mathResult checks the result of a math function called with finite
+// arguments. Like Python, a NaN result is a domain error and an infinite
+// one a range error.
+func mathResult(result float64, args ...float64) interpreter.Item {
+	for _, arg := range args {
+		if math.IsNaN(arg) || math.IsInf(arg, 0) {
+			return &interpreter.Float{Val: result}
+		}
+	}
+	switch {
+	case math.IsNaN(result):
+		return newException(valueErrorClass, "math domain error")
+	case math.IsInf(result, 0):
+		return newException(overflowErrorClass, "math range error")
+	}
+	return &interpreter.Float{Val: result}
+}
+
+// mathFunc wraps a float function of one argument.
+func mathFunc(name string, f func(float64) float64) *interpreter.Builtin {
+	b := &interpreter.Builtin{Name: name}
+	b.Fn = func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		if len(args) != 1 {
+			return newException(typeErrorClass, "math.%s() takes exactly one argument (%d given)", name, len(args))
+		}
+		x, err := toFloat(args[0], env)
+		if err != nil {
+			return err
+		}
+		return mathResult(f(x), x)
+	}
+	return b
+}
+
+// mathFunc2 wraps a float function of two arguments.
+func mathFunc2(name string, f func(float64, float64) float64) *interpreter.Builtin {
+	b := &interpreter.Builtin{Name: name}
+	b.Fn = func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		if len(args) != 2 {
+			return newException(typeErrorClass, "%s expected 2 arguments, got %d", name, len(args))
+		}
+		x, err := toFloat(args[0], env)
+		if err != nil {
+			return err
+		}
+		y, err := toFloat(args[1], env)
+		if err != nil {
+			return err
+		}
+		return mathResult(f(x, y), x, y)
+	}
+	return b
+}
+
+// mathTest wraps a float predicate.
+func mathTest(name string, f func(float64) bool) *interpreter.Builtin {
+	b := &interpreter.Builtin{Name: name}
+	b.Fn = func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		if len(args) != 1 {
+			return newException(typeErrorClass, "math.%s() takes exactly one argument (%d given)", name, len(args))
+		}
+		x, err := toFloat(args[0], env)
+		if err != nil {
+			return err
+		}
+		return nativeBool(f(x))
+	}
+	return b
+}
+
+// mathRound wraps a rounding function returning an int. Ints are returned
+// unchanged and instances may define method instead.
+func mathRound(name string, f func(float64) float64, method string) *interpreter.Builtin {
+	b := &interpreter.Builtin{Name: name}
+	b.Fn = func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		if len(args) != 1 {
+			return newException(typeErrorClass, "math.%s() takes exactly one argument (%d given)", name, len(args))
+		}
+		switch arg := args[0].(type) {
+		case *interpreter.Int:
+			return arg
+		case *interpreter.Instance:
+			if result, ok := callMethod(env, arg, method); ok {
+				return result
+			}
+		}
+		x, err := toFloat(args[0], env)
+		if err != nil {
+			return err
+		}
+		return builtinInt(env, &interpreter.Float{Val: f(x)})
+	}
+	return b
+}
+
+// mathLog implements log(x) and log(x, base).
+func mathLog(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 && len(args) != 2 {
+		return newException(typeErrorClass, "log expected 1 or 2 arguments, got %d", len(args))
+	}
+	var xs []float64
+	for _, arg := range args {
+		x, err := toFloat(arg, env)
+		if err != nil {
+			return err
+		}
+		if x <= 0 {
+			return newException(valueErrorClass, "math domain error")
+		}
+		xs = append(xs, x)
+	}
+	if len(xs) == 1 {
+		return mathResult(math.Log(xs[0]), xs[0])
+	}
+	if xs[1] == 1 {
+		return newException(zeroDivisionErrorClass, "float division by zero")
+	}
+	return mathResult(math.Log(xs[0])/math.Log(xs[1]), xs...)
+}
+
+func mathFactorial(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "math.factorial() takes exactly one argument (%d given)", len(args))
+	}
+	n, ok := args[0].(*interpreter.Int)
+	if !ok {
+		return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(args[0]))
+	}
+	if n.Val < 0 {
+		return newException(valueErrorClass, "factorial() not defined for negative values")
+	}
+	result := int64(1)
+	for i := int64(2); i <= n.Val; i++ {
+		if result > math.MaxInt64/i {
+			return newException(overflowErrorClass, "factorial() result too large")
+		}
+		result *= i
+	}
+	return &interpreter.Int{Val: result}
+}
+
+// mathGcd returns the greatest common divisor of its integer arguments,
+// which is 0 if there are none or they are all 0.
+func mathGcd(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	var gcd int64
+	for _, arg := range args {
+		n, ok := arg.(*interpreter.Int)
+		if !ok {
+			return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(arg))
+		}
+		a, b := gcd, n.Val
+		for b != 0 {
+			a, b = b, a%b
+		}
+		if a < 0 {
+			a = -a
+		}
+		gcd = a
+	}
+	return &interpreter.Int{Val: gcd}
+}
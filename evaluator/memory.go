@@ -0,0 +1,36 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+	"math"
+)
+
+// slotSize is roughly the memory an element of a list or dict takes, not
+// counting the item it refers to.
+const slotSize = 16
+
+// allocate charges n bytes to the evaluation in progress in the
+// interpreter env belongs to, raising a MemoryError instead if that would
+// exceed its budget.
+func allocate(n int64, env *interpreter.Environment) *interpreter.Error {
+	s := stateOf(env)
+	if !s.running || s.budget.MaxMemory <= 0 {
+		return nil
+	}
+	if n > s.budget.MaxMemory-s.memory {
+		return newException(memoryErrorClass, "evaluation exceeded its memory limit of %d bytes", s.budget.MaxMemory)
+	}
+	s.memory += n
+	return nil
+}
+
+// allocateRepeat charges for n copies of size bytes.
+func allocateRepeat(size int, n int64, env *interpreter.Environment) *interpreter.Error {
+	if size <= 0 || n <= 0 {
+		return nil
+	}
+	if int64(size) > math.MaxInt64/n {
+		return allocate(math.MaxInt64, env)
+	}
+	return allocate(int64(size) * n, env)
+}
@@ -0,0 +1,166 @@
+package evaluator
+
+import (
+	"bytes"
+	"gopy/ast"
+	"gopy/interpreter"
+	"gopy/parser"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// SearchPath lists the directories searched, in order, for name.py when a
+// module is imported, for interpreters not given a path of their own by
+// SetSearchPath. It must not be changed while scripts run.
+var SearchPath = []string{"."}
+
+// SetSearchPath makes the interpreter env belongs to look for modules in
+// dirs, in order, instead of SearchPath.
+func SetSearchPath(env *interpreter.Environment, dirs []string) {
+	stateOf(env).searchPath = append([]string{}, dirs...)
+}
+
+// nativeModules holds the standard library modules implemented in Go, by
+// name. Each one is built for an interpreter, given the scope importing
+// it, when first imported there, and shadows any module of the same name
+// on the search path.
+var nativeModules = map[string]func(env *interpreter.Environment) *interpreter.Module{}
+
+// registered holds the names of the modules hosts added with
+// RegisterModule.
+var registered = map[string]bool{}
+
+func evaluateImportStmt(is *ast.ImportStmt, env *interpreter.Environment) interpreter.Item {
+	for i, name := range is.Names {
+		module := importModule(name.Val, env)
+		if module.Type() == interpreter.ERR {
+			return module
+		}
+		bound := name.Val
+		if is.Aliases[i] != nil {
+			bound = is.Aliases[i].Val
+		}
+		env.Store(bound, module)
+	}
+	return nil
+}
+
+func evaluateFromImportStmt(fs *ast.FromImportStmt, env *interpreter.Environment) interpreter.Item {
+	item := importModule(fs.Module.Val, env)
+	if item.Type() == interpreter.ERR {
+		return item
+	}
+	module := item.(*interpreter.Module)
+	for i, name := range fs.Names {
+		val, ok := module.Env.Locals()[name.Val]
+		if !ok {
+			return newException(importErrorClass, "cannot import name '%s' from '%s'", name.Val, module.Name)
+		}
+		bound := name.Val
+		if fs.Aliases[i] != nil {
+			bound = fs.Aliases[i].Val
+		}
+		env.Store(bound, val)
+	}
+	return nil
+}
+
+// importModule returns the cached module called name, loading and executing
+// it from the search path on first use. A module loaded from source uses
+// the builtins and state of env, the scope importing it, and a native one
+// is restricted to the capabilities of env's interpreter.
+func importModule(name string, env *interpreter.Environment) interpreter.Item {
+	modules := moduleCache(env)
+	if module, ok := modules[name]; ok {
+		return module
+	}
+	caps := capabilities(env)
+	if build, ok := nativeModules[name]; ok {
+		if !importAllowed(caps, name) {
+			return newException(permissionErrorClass, "import of module '%s' is disabled in this interpreter", name)
+		}
+		module := build(env)
+		if !registered[name] {
+			restrictModule(module, caps)
+		}
+		modules[name] = module
+		return module
+	}
+	path, ok := findModule(name, env)
+	if !ok || !caps.AllowFiles {
+		return newException(moduleNotFoundErrorClass, "No module named '%s'", name)
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return newException(importErrorClass, "%s", err)
+	}
+	program, err := parser.ParseReader(path, bytes.NewReader(src))
+	if err != nil {
+		return newException(syntaxErrorClass, "%s", err)
+	}
+	module := &interpreter.Module{Name: name, Env: newGlobalScope(env)}
+	module.Env.Store("__doc__", docItem(program.Doc))
+	// The module is cached before it runs so that circular imports see the
+	// partially initialised module instead of recursing forever.
+	modules[name] = module
+	result := Evaluate(program, module.Env)
+	if result != nil && result.Type() == interpreter.ERR {
+		delete(modules, name)
+		return result
+	}
+	return module
+}
+
+// moduleCache returns the cache of imported modules for env's interpreter.
+func moduleCache(env *interpreter.Environment) map[string]*interpreter.Module {
+	s := stateOf(env)
+	if s.modules == nil {
+		s.modules = map[string]*interpreter.Module{}
+	}
+	return s.modules
+}
+
+// RegisterModule makes name importable as a module holding members, ahead
+// of any module of that name on the search path or in the standard
+// library. Members that are builtins take their names from their keys.
+// Every interpreter importing the module gets its own module object, but
+// the members themselves are shared. Interpreters lacking any capability
+// only import it with AllowModules. It should be called before any
+// evaluation starts, such as from an init function.
+func RegisterModule(name string, members map[string]interpreter.Item) {
+	registered[name] = true
+	nativeModules[name] = func(*interpreter.Environment) *interpreter.Module {
+		return newNativeModule(name, members)
+	}
+}
+
+// newNativeModule returns a module holding the given members, with
+// builtin functions named after their keys. The builtins are copied to be
+// named, leaving the caller's alone.
+func newNativeModule(name string, members map[string]interpreter.Item) *interpreter.Module {
+	module := &interpreter.Module{Name: name, Env: interpreter.NewEnv()}
+	for key, member := range members {
+		if builtin, ok := member.(*interpreter.Builtin); ok && builtin.Name != key {
+			named := *builtin
+			named.Name = key
+			member = &named
+		}
+		module.Env.Store(key, member)
+	}
+	return module
+}
+
+func findModule(name string, env *interpreter.Environment) (string, bool) {
+	dirs := stateOf(env).searchPath
+	if dirs == nil {
+		dirs = SearchPath
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name+".py")
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,193 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+func init() {
+	nativeModules["os"] = newOSModule
+}
+
+func newOSModule(*interpreter.Environment) *interpreter.Module {
+	environ := interpreter.NewDict()
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		storeKey(environ, &interpreter.Str{Val: parts[0]}, &interpreter.Str{Val: parts[1]})
+	}
+	name := "posix"
+	if runtime.GOOS == "windows" {
+		name = "nt"
+	}
+	return newNativeModule("os", map[string]interpreter.Item{
+		"name": &interpreter.Str{Val: name},
+		"sep": &interpreter.Str{Val: string(os.PathSeparator)},
+		"environ": environ,
+		"getenv": &interpreter.Builtin{Fn: osGetenv},
+		"getcwd": &interpreter.Builtin{
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 0 {
+					return newException(typeErrorClass, "getcwd() takes no arguments (%d given)", len(args))
+				}
+				dir, err := os.Getwd()
+				if err != nil {
+					return osError(err, ".")
+				}
+				return &interpreter.Str{Val: dir}
+			},
+		},
+		"listdir": &interpreter.Builtin{Fn: osListdir},
+		"path": newNativeModule("os.path", map[string]interpreter.Item{
+			"join": &interpreter.Builtin{Fn: pathJoin},
+			"exists": pathTest("exists", func(info os.FileInfo) bool { return true }),
+			"isfile": pathTest("isfile", func(info os.FileInfo) bool { return info.Mode().IsRegular() }),
+			"isdir": pathTest("isdir", func(info os.FileInfo) bool { return info.IsDir() }),
+			"basename": pathFunc("basename", pathBasename),
+			"dirname": pathFunc("dirname", pathDirname),
+			"abspath": pathFunc("abspath", func(path string) string {
+				abs, err := filepath.Abs(path)
+				if err != nil {
+					return path
+				}
+				return abs
+			}),
+		}),
+	})
+}
+
+// pathArg returns the path string of an argument to an os function.
+func pathArg(item interpreter.Item) (string, *interpreter.Error) {
+	s, ok := item.(*interpreter.Str)
+	if !ok {
+		return "", newException(typeErrorClass, "expected str, bytes or os.PathLike object, not %s", typeName(item))
+	}
+	return s.Val, nil
+}
+
+// osGetenv implements getenv(key, default=None).
+func osGetenv(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 && len(args) != 2 {
+		return newException(typeErrorClass, "getenv() takes 1 or 2 arguments (%d given)", len(args))
+	}
+	key, ok := args[0].(*interpreter.Str)
+	if !ok {
+		return newException(typeErrorClass, "str expected, not %s", typeName(args[0]))
+	}
+	if val, ok := os.LookupEnv(key.Val); ok {
+		return &interpreter.Str{Val: val}
+	}
+	if len(args) == 2 {
+		return args[1]
+	}
+	return NONE
+}
+
+// osListdir returns the names of the entries in a directory, which
+// defaults to the current one.
+func osListdir(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) > 1 {
+		return newException(typeErrorClass, "listdir() takes at most 1 argument (%d given)", len(args))
+	}
+	dir := "."
+	if len(args) == 1 {
+		var err *interpreter.Error
+		if dir, err = pathArg(args[0]); err != nil {
+			return err
+		}
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return osError(err, dir)
+	}
+	names := make([]interpreter.Item, len(infos))
+	for i, info := range infos {
+		names[i] = &interpreter.Str{Val: info.Name()}
+	}
+	return &interpreter.List{Elements: names}
+}
+
+// pathJoin joins path components with the separator. Like Python, and
+// unlike filepath.Join, an absolute component discards the ones before it
+// and the result is not cleaned.
+func pathJoin(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) == 0 {
+		return newException(typeErrorClass, "join() missing 1 required positional argument: 'a'")
+	}
+	var joined string
+	for i, arg := range args {
+		part, err := pathArg(arg)
+		if err != nil {
+			return err
+		}
+		switch {
+		case i == 0 || filepath.IsAbs(part):
+			joined = part
+		case joined == "" || os.IsPathSeparator(joined[len(joined)-1]):
+			joined += part
+		default:
+			joined += string(os.PathSeparator) + part
+		}
+	}
+	return &interpreter.Str{Val: joined}
+}
+
+// pathBasename returns everything after the last separator, which is
+// empty for a path ending in one.
+func pathBasename(path string) string {
+	return path[strings.LastIndexFunc(path, isSeparator)+1:]
+}
+
+// pathDirname returns everything before the last separator, without
+// trailing separators unless the result is the root.
+func pathDirname(path string) string {
+	head := path[:strings.LastIndexFunc(path, isSeparator)+1]
+	if trimmed := strings.TrimRightFunc(head, isSeparator); trimmed != "" {
+		return trimmed
+	}
+	return head
+}
+
+func isSeparator(r rune) bool {
+	return r < 0x80 && os.IsPathSeparator(uint8(r))
+}
+
+// pathFunc wraps a function from a path to a path.
+func pathFunc(name string, f func(string) string) *interpreter.Builtin {
+	b := &interpreter.Builtin{Name: name}
+	b.Fn = func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		if len(args) != 1 {
+			return newException(typeErrorClass, "%s() takes exactly one argument (%d given)", name, len(args))
+		}
+		path, err := pathArg(args[0])
+		if err != nil {
+			return err
+		}
+		return &interpreter.Str{Val: f(path)}
+	}
+	return b
+}
+
+// pathTest wraps a test of a path's file info. Paths that can't be
+// stat'ed, or aren't strings, fail the test.
+func pathTest(name string, f func(os.FileInfo) bool) *interpreter.Builtin {
+	b := &interpreter.Builtin{Name: name}
+	b.Fn = func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		if len(args) != 1 {
+			return newException(typeErrorClass, "%s() takes exactly one argument (%d given)", name, len(args))
+		}
+		path, ok := args[0].(*interpreter.Str)
+		if !ok {
+			return FALSE
+		}
+		info, err := os.Stat(path.Val)
+		return nativeBool(err == nil && f(info))
+	}
+	return b
+}
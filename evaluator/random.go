@@ -0,0 +1,142 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// rng returns the generator the random module of env's interpreter draws
+// from, seeding it from the time when first used. Each interpreter has its
+// own, so that seeding one leaves the others alone and interpreters can
+// run at once.
+func rng(env *interpreter.Environment) *rand.Rand {
+	s := stateOf(env)
+	if s.rng == nil {
+		s.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return s.rng
+}
+
+func init() {
+	nativeModules["random"] = newRandomModule
+}
+
+func newRandomModule(*interpreter.Environment) *interpreter.Module {
+	return newNativeModule("random", map[string]interpreter.Item{
+		"seed": &interpreter.Builtin{Fn: randomSeed},
+		"random": &interpreter.Builtin{
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 0 {
+					return newException(typeErrorClass, "random() takes no arguments (%d given)", len(args))
+				}
+				return &interpreter.Float{Val: rng(env).Float64()}
+			},
+		},
+		"uniform": &interpreter.Builtin{Fn: randomUniform},
+		"randint": &interpreter.Builtin{
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 2 {
+					return newException(typeErrorClass, "randint() takes 2 positional arguments but %d were given", len(args))
+				}
+				b, ok := args[1].(*interpreter.Int)
+				if !ok {
+					return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(args[1]))
+				}
+				return randomRange(env, args[0], &interpreter.Int{Val: b.Val + 1})
+			},
+		},
+		"randrange": &interpreter.Builtin{Fn: randomRange},
+		"choice": &interpreter.Builtin{Fn: randomChoice},
+		"shuffle": &interpreter.Builtin{Fn: randomShuffle},
+	})
+}
+
+// randomSeed reseeds the generator from an int, a hashable item, or the
+// current time if no seed or None is given.
+func randomSeed(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) > 1 {
+		return newException(typeErrorClass, "seed() takes at most 1 argument (%d given)", len(args))
+	}
+	seed := time.Now().UnixNano()
+	if len(args) == 1 && args[0] != NONE {
+		switch arg := args[0].(type) {
+		case *interpreter.Int:
+			seed = arg.Val
+		case *interpreter.Float:
+			seed = int64(math.Float64bits(arg.Val))
+		default:
+			hash, ok := interpreter.Hash(arg)
+			if !ok {
+				return newException(typeErrorClass, "The only supported seed types are: None, int, float, str")
+			}
+			seed = int64(hash)
+		}
+	}
+	rng(env).Seed(seed)
+	return NONE
+}
+
+func randomUniform(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 {
+		return newException(typeErrorClass, "uniform() takes 2 positional arguments but %d were given", len(args))
+	}
+	a, err := toFloat(args[0], env)
+	if err != nil {
+		return err
+	}
+	b, err := toFloat(args[1], env)
+	if err != nil {
+		return err
+	}
+	return &interpreter.Float{Val: a + (b-a)*rng(env).Float64()}
+}
+
+// randomRange implements randrange(stop) and randrange(start, stop, step),
+// choosing an item of the matching range.
+func randomRange(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	r := builtinRange(env, args...)
+	if r.Type() == interpreter.ERR {
+		return r
+	}
+	n := r.(*interpreter.Range).Len()
+	if n == 0 {
+		return newException(valueErrorClass, "empty range in randrange%s", (&interpreter.Tuple{Elements: args}).Visit())
+	}
+	return &interpreter.Int{Val: r.(*interpreter.Range).At(rng(env).Int63n(n))}
+}
+
+func randomChoice(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "choice() takes 1 positional argument but %d were given", len(args))
+	}
+	switch args[0].(type) {
+	case *interpreter.List, *interpreter.Tuple, *interpreter.Str, *interpreter.Range:
+	default:
+		return newException(typeErrorClass, "'%s' object is not subscriptable", typeName(args[0]))
+	}
+	items, err := iterate(args[0], env)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return newException(indexErrorClass, "Cannot choose from an empty sequence")
+	}
+	return items[rng(env).Intn(len(items))]
+}
+
+// randomShuffle shuffles a list in place.
+func randomShuffle(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "shuffle() takes 1 positional argument but %d were given", len(args))
+	}
+	list, ok := args[0].(*interpreter.List)
+	if !ok {
+		return newException(typeErrorClass, "'%s' object does not support item assignment", typeName(args[0]))
+	}
+	rng(env).Shuffle(len(list.Elements), func(i, j int) {
+		list.Elements[i], list.Elements[j] = list.Elements[j], list.Elements[i]
+	})
+	return NONE
+}
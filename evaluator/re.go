@@ -0,0 +1,635 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// reErrorClass is raised for patterns that don't compile.
+var reErrorClass = &interpreter.Class{Name: "error", Base: exceptionClass, Attrs: map[string]interpreter.Item{}}
+
+// The flags accepted by the re module, with Python's values.
+const (
+	reIgnoreCase = 2
+	reMultiline = 8
+	reDotAll = 16
+	reVerbose = 64
+)
+
+// patternCache holds the patterns compiled so far, keyed by flags and
+// source. Like Python's, it is emptied when it reaches maxPatternCache.
+// Interpreters share it, so it is guarded by patternCacheMu.
+var (
+	patternCache = map[string]*interpreter.Pattern{}
+	patternCacheMu sync.Mutex
+)
+
+const maxPatternCache = 512
+
+// patternMethods and matchMethods are the methods of compiled patterns
+// and match objects. Each receives the object as its first argument.
+var (
+	patternMethods map[string]*interpreter.Builtin
+	matchMethods map[string]*interpreter.Builtin
+)
+
+func init() {
+	nativeModules["re"] = newReModule
+	patternMethods = map[string]*interpreter.Builtin{}
+	for name, fn := range reFunctions {
+		fn := fn
+		patternMethods[name] = &interpreter.Builtin{
+			Name: name,
+			KwFn: func(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+				return fn(env, args[0].(*interpreter.Pattern), args[1:], kwargs)
+			},
+		}
+	}
+	matchMethods = map[string]*interpreter.Builtin{
+		"group": {Fn: matchGroup},
+		"groups": {Fn: matchGroups},
+		"groupdict": {Fn: matchGroupdict},
+		"start": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return matchPos("start", args, func(start, end int) interpreter.Item { return &interpreter.Int{Val: int64(start)} })
+			},
+		},
+		"end": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return matchPos("end", args, func(start, end int) interpreter.Item { return &interpreter.Int{Val: int64(end)} })
+			},
+		},
+		"span": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return matchPos("span", args, func(start, end int) interpreter.Item {
+					return &interpreter.Tuple{Elements: []interpreter.Item{&interpreter.Int{Val: int64(start)}, &interpreter.Int{Val: int64(end)}}}
+				})
+			},
+		},
+	}
+	for name, method := range matchMethods {
+		method.Name = name
+	}
+}
+
+// reFunctions implement the operations available both as module functions
+// taking a pattern first and as methods of compiled patterns.
+var reFunctions = map[string]func(env *interpreter.Environment, p *interpreter.Pattern, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item{
+	"match": func(env *interpreter.Environment, p *interpreter.Pattern, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+		return reFind(p.Anchored, p, "match", args, kwargs)
+	},
+	"fullmatch": func(env *interpreter.Environment, p *interpreter.Pattern, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+		return reFind(p.Full, p, "fullmatch", args, kwargs)
+	},
+	"search": func(env *interpreter.Environment, p *interpreter.Pattern, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+		return reFind(p.Regexp, p, "search", args, kwargs)
+	},
+	"findall": reFindall,
+	"finditer": reFinditer,
+	"sub": reSub,
+	"split": reSplit,
+}
+
+func newReModule(*interpreter.Environment) *interpreter.Module {
+	members := map[string]interpreter.Item{
+		"compile": &interpreter.Builtin{Fn: reCompile},
+		"escape": &interpreter.Builtin{
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "escape() takes 1 positional argument but %d were given", len(args))
+				}
+				s, ok := args[0].(*interpreter.Str)
+				if !ok {
+					return newException(typeErrorClass, "expected str, not %s", typeName(args[0]))
+				}
+				return &interpreter.Str{Val: regexp.QuoteMeta(s.Val)}
+			},
+		},
+		"error": reErrorClass,
+		"I": &interpreter.Int{Val: reIgnoreCase},
+		"IGNORECASE": &interpreter.Int{Val: reIgnoreCase},
+		"M": &interpreter.Int{Val: reMultiline},
+		"MULTILINE": &interpreter.Int{Val: reMultiline},
+		"S": &interpreter.Int{Val: reDotAll},
+		"DOTALL": &interpreter.Int{Val: reDotAll},
+	}
+	for name, fn := range reFunctions {
+		fn := fn
+		// The module functions take the pattern first and the flags after
+		// the other arguments.
+		flagsPos := 2
+		switch name {
+		case "sub":
+			flagsPos = 4
+		case "split":
+			flagsPos = 3
+		}
+		members[name] = &interpreter.Builtin{
+			KwFn: func(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+				if len(args) == 0 {
+					return newException(typeErrorClass, "missing required argument 'pattern' (pos 1)")
+				}
+				flags := interpreter.Item(&interpreter.Int{Val: 0})
+				if len(args) > flagsPos {
+					flags, args = args[flagsPos], args[:flagsPos]
+				}
+				if f, ok := kwargs["flags"]; ok {
+					flags = f
+					delete(kwargs, "flags")
+				}
+				p := compilePattern(args[0], flags)
+				if p.Type() == interpreter.ERR {
+					return p
+				}
+				return fn(env, p.(*interpreter.Pattern), args[1:], kwargs)
+			},
+		}
+	}
+	return newNativeModule("re", members)
+}
+
+func reCompile(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 && len(args) != 2 {
+		return newException(typeErrorClass, "compile() takes from 1 to 2 positional arguments but %d were given", len(args))
+	}
+	flags := interpreter.Item(&interpreter.Int{Val: 0})
+	if len(args) == 2 {
+		flags = args[1]
+	}
+	return compilePattern(args[0], flags)
+}
+
+// compilePattern returns the compiled pattern for a pattern string, or a
+// pattern object as it is.
+func compilePattern(pattern interpreter.Item, flags interpreter.Item) interpreter.Item {
+	f, ok := flags.(*interpreter.Int)
+	if !ok {
+		return newException(typeErrorClass, "flags must be an int, not %s", typeName(flags))
+	}
+	switch pattern := pattern.(type) {
+	case *interpreter.Pattern:
+		if f.Val != 0 {
+			return newException(valueErrorClass, "cannot process flags argument with a compiled pattern")
+		}
+		return pattern
+	case *interpreter.Str:
+		key := strconv.FormatInt(f.Val, 10) + ":" + pattern.Val
+		patternCacheMu.Lock()
+		p, ok := patternCache[key]
+		patternCacheMu.Unlock()
+		if ok {
+			return p
+		}
+		if f.Val&reVerbose != 0 {
+			return newException(reErrorClass, "the VERBOSE flag is not supported")
+		}
+		translated, err := translateRegexp(pattern.Val)
+		if err != nil {
+			return err
+		}
+		prefix := ""
+		for _, flag := range []struct {
+			bit int64
+			name string
+		}{{reIgnoreCase, "i"}, {reMultiline, "m"}, {reDotAll, "s"}} {
+			if f.Val&flag.bit != 0 {
+				prefix += flag.name
+			}
+		}
+		if prefix != "" {
+			translated = "(?" + prefix + ")" + translated
+		}
+		re, compileErr := regexp.Compile(translated)
+		if compileErr != nil {
+			return newException(reErrorClass, "%s", strings.TrimPrefix(compileErr.Error(), "error parsing regexp: "))
+		}
+		p = &interpreter.Pattern{
+			Source: pattern.Val,
+			Flags: f.Val,
+			Regexp: re,
+			Anchored: regexp.MustCompile(`\A(?:` + translated + `)`),
+			Full: regexp.MustCompile(`\A(?:` + translated + `)\z`),
+		}
+		patternCacheMu.Lock()
+		if len(patternCache) >= maxPatternCache {
+			patternCache = map[string]*interpreter.Pattern{}
+		}
+		patternCache[key] = p
+		patternCacheMu.Unlock()
+		return p
+	}
+	return newException(typeErrorClass, "first argument must be string or compiled pattern")
+}
+
+// translateRegexp rewrites the Python syntax that RE2 spells differently
+// and rejects what RE2 can't do at all, such as backreferences and
+// lookaround.
+func translateRegexp(src string) (string, *interpreter.Error) {
+	var out strings.Builder
+	inClass := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\\' && i+1 < len(src):
+			next := src[i+1]
+			switch {
+			case !inClass && next >= '1' && next <= '9':
+				return "", newException(reErrorClass, "backreferences are not supported at position %d", i)
+			case next == 'Z':
+				out.WriteString(`\z`)
+			default:
+				out.WriteByte(c)
+				out.WriteByte(next)
+			}
+			i++
+			continue
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+			out.WriteByte(c)
+			// A ] right after [ or [^ is a literal.
+			if strings.HasPrefix(src[i+1:], "^") {
+				out.WriteByte('^')
+				i++
+			}
+			if strings.HasPrefix(src[i+1:], "]") {
+				out.WriteString(`\]`)
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "(?=") || strings.HasPrefix(src[i:], "(?!") ||
+			strings.HasPrefix(src[i:], "(?<=") || strings.HasPrefix(src[i:], "(?<!"):
+			return "", newException(reErrorClass, "lookaround assertions are not supported at position %d", i)
+		case strings.HasPrefix(src[i:], "(?P="):
+			return "", newException(reErrorClass, "backreferences are not supported at position %d", i)
+		case strings.HasPrefix(src[i:], "(?<"):
+			out.WriteString("(?P<")
+			i += 2
+			continue
+		case strings.HasPrefix(src[i:], "{,"):
+			out.WriteString("{0,")
+			i++
+			continue
+		}
+		out.WriteByte(c)
+	}
+	return out.String(), nil
+}
+
+// reString returns the string argument of a pattern operation.
+func reString(item interpreter.Item) (string, *interpreter.Error) {
+	s, ok := item.(*interpreter.Str)
+	if !ok {
+		return "", newException(typeErrorClass, "expected string or bytes-like object, got '%s'", typeName(item))
+	}
+	return s.Val, nil
+}
+
+// reArgs matches the arguments of a pattern operation against the names
+// of its optional parameters, which follow the required ones.
+func reArgs(name string, args []interpreter.Item, kwargs map[string]interpreter.Item, required []string, optional []string) (map[string]interpreter.Item, *interpreter.Error) {
+	params := append(append([]string{}, required...), optional...)
+	if len(args) > len(params) {
+		return nil, newException(typeErrorClass, "%s() takes at most %d arguments (%d given)", name, len(params), len(args))
+	}
+	values := map[string]interpreter.Item{}
+	for i, arg := range args {
+		values[params[i]] = arg
+	}
+	for key, val := range kwargs {
+		known := false
+		for _, param := range params {
+			known = known || param == key
+		}
+		if !known {
+			return nil, newException(typeErrorClass, "'%s' is an invalid keyword argument for %s()", key, name)
+		}
+		values[key] = val
+	}
+	for _, param := range required {
+		if _, ok := values[param]; !ok {
+			return nil, newException(typeErrorClass, "%s() missing required argument '%s'", name, param)
+		}
+	}
+	return values, nil
+}
+
+// reFind implements match, fullmatch and search, which differ only in how
+// the expression is anchored.
+func reFind(re *regexp.Regexp, p *interpreter.Pattern, name string, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	values, err := reArgs(name, args, kwargs, []string{"string"}, nil)
+	if err != nil {
+		return err
+	}
+	s, err := reString(values["string"])
+	if err != nil {
+		return err
+	}
+	groups := re.FindStringSubmatchIndex(s)
+	if groups == nil {
+		return NONE
+	}
+	return &interpreter.Match{Pattern: p, Str: s, Groups: groups}
+}
+
+// reFindall returns every non-overlapping match: the whole match if the
+// pattern has no groups, the one group's text if it has one, or a tuple of
+// the groups.
+func reFindall(env *interpreter.Environment, p *interpreter.Pattern, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	values, err := reArgs("findall", args, kwargs, []string{"string"}, nil)
+	if err != nil {
+		return err
+	}
+	s, err := reString(values["string"])
+	if err != nil {
+		return err
+	}
+	found := &interpreter.List{Elements: []interpreter.Item{}}
+	for _, groups := range p.Regexp.FindAllStringSubmatchIndex(s, -1) {
+		if err := allocateRepeat(slotSize, int64(1+p.Regexp.NumSubexp()), env); err != nil {
+			return err
+		}
+		m := &interpreter.Match{Pattern: p, Str: s, Groups: groups}
+		switch n := p.Regexp.NumSubexp(); n {
+		case 0:
+			found.Elements = append(found.Elements, m.Group(0, &interpreter.Str{}))
+		case 1:
+			found.Elements = append(found.Elements, m.Group(1, &interpreter.Str{}))
+		default:
+			tuple := &interpreter.Tuple{}
+			for i := 1; i <= n; i++ {
+				tuple.Elements = append(tuple.Elements, m.Group(i, &interpreter.Str{}))
+			}
+			found.Elements = append(found.Elements, tuple)
+		}
+	}
+	return found
+}
+
+func reFinditer(env *interpreter.Environment, p *interpreter.Pattern, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	values, err := reArgs("finditer", args, kwargs, []string{"string"}, nil)
+	if err != nil {
+		return err
+	}
+	s, err := reString(values["string"])
+	if err != nil {
+		return err
+	}
+	matches := p.Regexp.FindAllStringSubmatchIndex(s, -1)
+	return &interpreter.Iterator{
+		Name: "callable_iterator",
+		Next: func() (interpreter.Item, bool) {
+			if len(matches) == 0 {
+				return nil, false
+			}
+			m := &interpreter.Match{Pattern: p, Str: s, Groups: matches[0]}
+			matches = matches[1:]
+			return m, true
+		},
+	}
+}
+
+// reSub implements sub(repl, string, count=0). repl is either a template,
+// in which \n and \g<name> stand for groups, or a function called with
+// each match object and returning its replacement.
+func reSub(env *interpreter.Environment, p *interpreter.Pattern, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	values, err := reArgs("sub", args, kwargs, []string{"repl", "string"}, []string{"count"})
+	if err != nil {
+		return err
+	}
+	s, err := reString(values["string"])
+	if err != nil {
+		return err
+	}
+	count := -1
+	if c, ok := values["count"]; ok {
+		n, ok := c.(*interpreter.Int)
+		if !ok {
+			return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(c))
+		}
+		if n.Val > 0 {
+			count = int(n.Val)
+		}
+	}
+	// The text between matches is charged up front, and each replacement
+	// as it is written.
+	if err := allocate(int64(len(s)), env); err != nil {
+		return err
+	}
+	var out strings.Builder
+	last := 0
+	for _, groups := range p.Regexp.FindAllStringSubmatchIndex(s, count) {
+		out.WriteString(s[last:groups[0]])
+		m := &interpreter.Match{Pattern: p, Str: s, Groups: groups}
+		var replacement interpreter.Item
+		if template, ok := values["repl"].(*interpreter.Str); ok {
+			replacement = expandTemplate(m, template.Val)
+		} else {
+			replacement = applyFn(values["repl"], []interpreter.Item{m}, nil, env)
+		}
+		if replacement.Type() == interpreter.ERR {
+			return replacement
+		}
+		r, ok := replacement.(*interpreter.Str)
+		if !ok {
+			return newException(typeErrorClass, "expected str instance, %s found", typeName(replacement))
+		}
+		if err := allocate(int64(len(r.Val)), env); err != nil {
+			return err
+		}
+		out.WriteString(r.Val)
+		last = groups[1]
+	}
+	out.WriteString(s[last:])
+	return &interpreter.Str{Val: out.String()}
+}
+
+// expandTemplate replaces the group references in a sub template with
+// the text m matched for them.
+func expandTemplate(m *interpreter.Match, template string) interpreter.Item {
+	var out strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] != '\\' || i+1 == len(template) {
+			out.WriteByte(template[i])
+			continue
+		}
+		i++
+		var ref interpreter.Item
+		switch c := template[i]; {
+		case c >= '0' && c <= '9':
+			j := i + 1
+			if j < len(template) && template[j] >= '0' && template[j] <= '9' {
+				j++
+			}
+			n, _ := strconv.Atoi(template[i:j])
+			ref, i = &interpreter.Int{Val: int64(n)}, j-1
+		case c == 'g' && strings.HasPrefix(template[i+1:], "<"):
+			end := strings.IndexByte(template[i:], '>')
+			if end < 0 {
+				return newException(reErrorClass, "missing >, unterminated name at position %d", i+2)
+			}
+			name := template[i+2 : i+end]
+			if n, err := strconv.Atoi(name); err == nil {
+				ref = &interpreter.Int{Val: int64(n)}
+			} else {
+				ref = &interpreter.Str{Val: name}
+			}
+			i += end
+		case c == 'n':
+			out.WriteByte('\n')
+			continue
+		case c == 't':
+			out.WriteByte('\t')
+			continue
+		case c == '\\':
+			out.WriteByte('\\')
+			continue
+		default:
+			out.WriteByte('\\')
+			out.WriteByte(c)
+			continue
+		}
+		group, err := groupIndex(m, ref)
+		if err != nil {
+			return newException(reErrorClass, "invalid group reference %s", ref.Visit())
+		}
+		out.WriteString(m.Group(group, &interpreter.Str{}).(*interpreter.Str).Val)
+	}
+	return &interpreter.Str{Val: out.String()}
+}
+
+// reSplit implements split(string, maxsplit=0), including the text of any
+// groups between the pieces.
+func reSplit(env *interpreter.Environment, p *interpreter.Pattern, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	values, err := reArgs("split", args, kwargs, []string{"string"}, []string{"maxsplit"})
+	if err != nil {
+		return err
+	}
+	s, err := reString(values["string"])
+	if err != nil {
+		return err
+	}
+	limit := -1
+	if c, ok := values["maxsplit"]; ok {
+		n, ok := c.(*interpreter.Int)
+		if !ok {
+			return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(c))
+		}
+		if n.Val > 0 {
+			limit = int(n.Val)
+		}
+	}
+	pieces := &interpreter.List{}
+	last := 0
+	for _, groups := range p.Regexp.FindAllStringSubmatchIndex(s, limit) {
+		if err := allocateRepeat(slotSize, int64(1+p.Regexp.NumSubexp()), env); err != nil {
+			return err
+		}
+		pieces.Elements = append(pieces.Elements, &interpreter.Str{Val: s[last:groups[0]]})
+		m := &interpreter.Match{Pattern: p, Str: s, Groups: groups}
+		for i := 1; i <= p.Regexp.NumSubexp(); i++ {
+			pieces.Elements = append(pieces.Elements, m.Group(i, NONE))
+		}
+		last = groups[1]
+	}
+	pieces.Elements = append(pieces.Elements, &interpreter.Str{Val: s[last:]})
+	return pieces
+}
+
+// groupIndex returns the number of the group ref names, by number or name.
+func groupIndex(m *interpreter.Match, ref interpreter.Item) (int, *interpreter.Error) {
+	switch ref := ref.(type) {
+	case *interpreter.Int:
+		if ref.Val >= 0 && int(ref.Val) <= m.Pattern.Regexp.NumSubexp() {
+			return int(ref.Val), nil
+		}
+	case *interpreter.Str:
+		for i, name := range m.Pattern.Regexp.SubexpNames() {
+			if name != "" && name == ref.Val {
+				return i, nil
+			}
+		}
+	}
+	return 0, newException(indexErrorClass, "no such group")
+}
+
+func matchGroup(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	m := args[0].(*interpreter.Match)
+	refs := args[1:]
+	if len(refs) == 0 {
+		refs = []interpreter.Item{&interpreter.Int{Val: 0}}
+	}
+	groups := make([]interpreter.Item, len(refs))
+	for i, ref := range refs {
+		n, err := groupIndex(m, ref)
+		if err != nil {
+			return err
+		}
+		groups[i] = m.Group(n, NONE)
+	}
+	if len(groups) == 1 {
+		return groups[0]
+	}
+	return &interpreter.Tuple{Elements: groups}
+}
+
+// matchGroups returns all the groups, with default for those that did not
+// take part in the match.
+func matchGroups(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) > 2 {
+		return newException(typeErrorClass, "groups() takes at most 1 argument (%d given)", len(args)-1)
+	}
+	m := args[0].(*interpreter.Match)
+	def := interpreter.Item(NONE)
+	if len(args) == 2 {
+		def = args[1]
+	}
+	groups := &interpreter.Tuple{Elements: []interpreter.Item{}}
+	for i := 1; i <= m.Pattern.Regexp.NumSubexp(); i++ {
+		groups.Elements = append(groups.Elements, m.Group(i, def))
+	}
+	return groups
+}
+
+func matchGroupdict(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "groupdict() takes no arguments (%d given)", len(args)-1)
+	}
+	m := args[0].(*interpreter.Match)
+	dict := interpreter.NewDict()
+	for i, name := range m.Pattern.Regexp.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		storeKey(dict, &interpreter.Str{Val: name}, m.Group(i, NONE))
+	}
+	return dict
+}
+
+// matchPos implements start, end and span, which report character
+// positions of a group, -1 if it did not take part in the match.
+func matchPos(name string, args []interpreter.Item, result func(start, end int) interpreter.Item) interpreter.Item {
+	if len(args) > 2 {
+		return newException(typeErrorClass, "%s expected at most 1 argument, got %d", name, len(args)-1)
+	}
+	m := args[0].(*interpreter.Match)
+	n := 0
+	if len(args) == 2 {
+		var err *interpreter.Error
+		if n, err = groupIndex(m, args[1]); err != nil {
+			return err
+		}
+	}
+	start, end := m.Groups[2*n], m.Groups[2*n+1]
+	if start < 0 {
+		return result(-1, -1)
+	}
+	return result(utf8.RuneCountInString(m.Str[:start]), utf8.RuneCountInString(m.Str[:end]))
+}
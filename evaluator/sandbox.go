@@ -0,0 +1,207 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+	"strings"
+)
+
+// Capabilities says which effects on the host scripts may have, so that
+// untrusted ones can be run with their side effects disabled. Builtins
+// needing a capability the interpreter lacks raise a PermissionError.
+type Capabilities struct {
+	// AllowFiles lets scripts open files, read directories, test paths and
+	// import modules from the search path.
+	AllowFiles bool
+	// AllowNetwork lets scripts make connections. No builtin does yet.
+	AllowNetwork bool
+	// AllowEnv lets scripts read environment variables and the process's
+	// arguments.
+	AllowEnv bool
+	// AllowExec lets scripts run other programs. No builtin does yet.
+	AllowExec bool
+	// AllowStdio lets scripts read standard input, with input() and
+	// sys.stdin, and write to sys.stdout and sys.stderr. print writes to
+	// the interpreter's standard output either way.
+	AllowStdio bool
+	// AllowModules lets scripts import the modules hosts add with
+	// RegisterModule, whose members may do anything.
+	AllowModules bool
+}
+
+// AllCapabilities allows every effect, as interpreters do by default.
+var AllCapabilities = Capabilities{AllowFiles: true, AllowNetwork: true, AllowEnv: true, AllowExec: true, AllowStdio: true, AllowModules: true}
+
+// access lists the builtin functions, under "", and the members of the
+// native modules, by module, with the test of whether the capabilities
+// allow each one. It is an allowlist: an interpreter lacking any
+// capability disables the builtins and members missing from it, and won't
+// import native modules missing from it, so that new ones reaching the
+// host stay disabled until they are listed here.
+var access = map[string]map[string]func(Capabilities) bool{
+	"": {
+		"abs": always, "dir": always, "enumerate": always, "eval": always,
+		"exec": always, "filter": always, "format": always, "globals": always,
+		"hash": always, "help": always, "id": always, "isinstance": always,
+		"issubclass": always, "iter": always, "len": always, "locals": always,
+		"map": always, "max": always, "min": always, "next": always,
+		"print": always, "reversed": always, "round": always, "sorted": always,
+		"sum": always, "super": always, "vars": always, "zip": always,
+		"input": allowStdio,
+		"open": allowFiles,
+	},
+	"copy": {"copy": always, "deepcopy": always},
+	"json": {"JSONDecodeError": always, "dumps": always, "loads": always},
+	"math": {
+		"acos": always, "asin": always, "atan": always, "atan2": always,
+		"ceil": always, "copysign": always, "cos": always, "degrees": always,
+		"e": always, "exp": always, "fabs": always, "factorial": always,
+		"floor": always, "fmod": always, "gcd": always, "hypot": always,
+		"inf": always, "isfinite": always, "isinf": always, "isnan": always,
+		"log": always, "log10": always, "log2": always, "nan": always,
+		"pi": always, "pow": always, "radians": always, "sin": always,
+		"sqrt": always, "tan": always, "tau": always, "trunc": always,
+	},
+	"os": {
+		"name": always,
+		"sep": always,
+		"path": always,
+		"environ": allowEnv,
+		"getenv": allowEnv,
+		"getcwd": allowFiles,
+		"listdir": allowFiles,
+	},
+	"os.path": {
+		"join": always,
+		"basename": always,
+		"dirname": always,
+		"exists": allowFiles,
+		"isfile": allowFiles,
+		"isdir": allowFiles,
+		"abspath": allowFiles,
+	},
+	"random": {
+		"choice": always, "randint": always, "random": always, "randrange": always,
+		"seed": always, "shuffle": always, "uniform": always,
+	},
+	"re": {
+		"DOTALL": always, "I": always, "IGNORECASE": always, "M": always,
+		"MULTILINE": always, "S": always, "compile": always, "error": always,
+		"escape": always, "findall": always, "finditer": always, "fullmatch": always,
+		"match": always, "search": always, "split": always, "sub": always,
+	},
+	"sys": {
+		"exit": always,
+		"getrecursionlimit": always,
+		"setrecursionlimit": always,
+		"maxsize": always,
+		"platform": always,
+		"version": always,
+		"version_info": always,
+		"argv": allowEnv,
+		"stdin": allowStdio,
+		"stdout": allowStdio,
+		"stderr": allowStdio,
+	},
+	"time": {"monotonic": always, "sleep": always, "time": always},
+}
+
+func always(c Capabilities) bool { return true }
+func allowFiles(c Capabilities) bool { return c.AllowFiles }
+func allowEnv(c Capabilities) bool { return c.AllowEnv }
+func allowStdio(c Capabilities) bool { return c.AllowStdio }
+
+// allowed reports whether c allows the member called name of the native
+// module, or the builtin function if module is "".
+func allowed(c Capabilities, module string, name string) bool {
+	test, ok := access[module][name]
+	return c == AllCapabilities || ok && test(c)
+}
+
+// SetCapabilities limits the interpreter env belongs to, which must have
+// been made by NewEnv, to the effects c allows. It should be called before
+// the interpreter imports anything, since modules are only built once.
+// Functions hosts put in the table of builtins are left alone.
+func SetCapabilities(env *interpreter.Environment, c Capabilities) {
+	stateOf(env).caps = &c
+	table := env.Builtins()
+	for name, builtin := range builtins {
+		if table[name] == builtin && !allowed(c, "", name) {
+			table[name] = denied(name)
+		}
+	}
+}
+
+// capabilities returns the capabilities of the interpreter env belongs to.
+func capabilities(env *interpreter.Environment) Capabilities {
+	if s := stateOf(env); s.caps != nil {
+		return *s.caps
+	}
+	return AllCapabilities
+}
+
+// importAllowed reports whether c allows importing the native module
+// called name: a module a host registered needs AllowModules, and one of
+// the standard library must be listed in access.
+func importAllowed(c Capabilities, name string) bool {
+	if c == AllCapabilities {
+		return true
+	}
+	if registered[name] {
+		return c.AllowModules
+	}
+	_, ok := access[name]
+	return ok
+}
+
+// restrictModule replaces the members of the native module that c doesn't
+// allow, and those of the modules it holds: functions with ones raising a
+// PermissionError, dicts and lists with empty ones, objects with ones whose
+// methods raise a PermissionError, and anything else with None.
+func restrictModule(module *interpreter.Module, c Capabilities) {
+	for name, member := range module.Env.Locals() {
+		if inner, ok := member.(*interpreter.Module); ok && allowed(c, module.Name, name) {
+			restrictModule(inner, c)
+			continue
+		}
+		if allowed(c, module.Name, name) {
+			continue
+		}
+		qualified := module.Name + "." + name
+		switch member := member.(type) {
+		case *interpreter.Builtin:
+			module.Env.Store(name, denied(qualified))
+		case *interpreter.Dict:
+			module.Env.Store(name, interpreter.NewDict())
+		case *interpreter.List:
+			module.Env.Store(name, &interpreter.List{})
+		case *interpreter.Instance:
+			module.Env.Store(name, deniedInstance(member, qualified))
+		default:
+			module.Env.Store(name, NONE)
+		}
+	}
+}
+
+// deniedInstance returns a copy of instance, called name, whose builtin
+// methods raise a PermissionError.
+func deniedInstance(instance *interpreter.Instance, name string) *interpreter.Instance {
+	class := &interpreter.Class{Name: instance.Class.Name, Attrs: map[string]interpreter.Item{}}
+	for attr, val := range instance.Class.Attrs {
+		if _, ok := val.(*interpreter.Builtin); ok {
+			val = denied(name + "." + attr)
+		}
+		class.Attrs[attr] = val
+	}
+	return &interpreter.Instance{Class: class, Attrs: instance.Attrs}
+}
+
+// denied returns a builtin called name that always raises a
+// PermissionError.
+func denied(name string) *interpreter.Builtin {
+	return &interpreter.Builtin{
+		Name: name[strings.LastIndex(name, ".")+1:],
+		KwFn: func(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+			return newException(permissionErrorClass, "%s() is disabled in this interpreter", name)
+		},
+	}
+}
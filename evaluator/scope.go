@@ -0,0 +1,162 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+	"sort"
+)
+
+// hiddenNames are bindings the evaluator makes for itself, which scripts
+// never see in their scopes.
+var hiddenNames = map[string]bool{
+	"__super__": true,
+	"__generator__": true,
+}
+
+func init() {
+	scoped := map[string]interpreter.BuiltinFunction{
+		"locals": func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+			if len(args) != 0 {
+				return newException(typeErrorClass, "locals() takes no arguments (%d given)", len(args))
+			}
+			return scopeDict(env)
+		},
+		"globals": func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+			if len(args) != 0 {
+				return newException(typeErrorClass, "globals() takes no arguments (%d given)", len(args))
+			}
+			for env.Outer() != nil {
+				env = env.Outer()
+			}
+			return scopeDict(env)
+		},
+		"dir": func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+			if len(args) > 1 {
+				return newException(typeErrorClass, "dir expected at most 1 argument, got %d", len(args))
+			}
+			if len(args) == 0 {
+				return strList(scopeNames(env))
+			}
+			return strList(attrNames(args[0]))
+		},
+		"vars": func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+			if len(args) > 1 {
+				return newException(typeErrorClass, "vars expected at most 1 argument, got %d", len(args))
+			}
+			if len(args) == 0 {
+				return scopeDict(env)
+			}
+			return builtinVars(args[0])
+		},
+	}
+	for name, fn := range scoped {
+		registerBuiltin(name, fn)
+	}
+}
+
+// registerBuiltin adds the builtin function name to those defined in the
+// main table.
+func registerBuiltin(name string, fn interpreter.BuiltinFunction) {
+	builtins[name] = &interpreter.Builtin{Name: name, Fn: fn}
+}
+
+// scopeNames returns the sorted names bound directly in env.
+func scopeNames(env *interpreter.Environment) []string {
+	var names []string
+	for _, name := range env.Names() {
+		if !hiddenNames[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// scopeDict returns a dict of the bindings made directly in env. Changing
+// the dict does not change the scope.
+func scopeDict(env *interpreter.Environment) *interpreter.Dict {
+	dict := interpreter.NewDict()
+	locals := env.Locals()
+	for _, name := range scopeNames(env) {
+		storeKey(dict, &interpreter.Str{Val: name}, locals[name])
+	}
+	return dict
+}
+
+// attrNames returns the sorted names of the attributes of obj: those set
+// on an instance and those of its class and the classes it derives from,
+// the names bound in a module, or the methods of a builtin object.
+func attrNames(obj interpreter.Item) []string {
+	seen := map[string]bool{}
+	addClass := func(class *interpreter.Class) {
+		for ; class != nil; class = class.Base {
+			for name := range class.Attrs {
+				seen[name] = true
+			}
+		}
+	}
+	switch obj := obj.(type) {
+	case *interpreter.Instance:
+		for name := range obj.Attrs {
+			seen[name] = true
+		}
+		addClass(obj.Class)
+	case *interpreter.Class:
+		addClass(obj)
+	case *interpreter.Module:
+		return scopeNames(obj.Env)
+	default:
+		methods := map[interpreter.ItemType]map[string]*interpreter.Builtin{
+			interpreter.STR: strMethods,
+			interpreter.BYTES: bytesMethods,
+			interpreter.LIST: listMethods,
+			interpreter.DICT: dictMethods,
+			interpreter.FILE: fileMethods,
+			interpreter.GENERATOR: generatorMethods,
+			interpreter.PATTERN: patternMethods,
+			interpreter.MATCH: matchMethods,
+		}
+		for name := range methods[obj.Type()] {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// builtinVars implements vars(obj), returning the attributes set on an
+// instance or class, or the names bound in a module, as a dict.
+func builtinVars(obj interpreter.Item) interpreter.Item {
+	var attrs map[string]interpreter.Item
+	switch obj := obj.(type) {
+	case *interpreter.Instance:
+		attrs = obj.Attrs
+	case *interpreter.Class:
+		attrs = obj.Attrs
+	case *interpreter.Module:
+		return scopeDict(obj.Env)
+	default:
+		return newException(typeErrorClass, "vars() argument must have __dict__ attribute")
+	}
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	dict := interpreter.NewDict()
+	for _, name := range names {
+		storeKey(dict, &interpreter.Str{Val: name}, attrs[name])
+	}
+	return dict
+}
+
+// strList returns a list of strs.
+func strList(names []string) *interpreter.List {
+	items := make([]interpreter.Item, len(names))
+	for i, name := range names {
+		items[i] = &interpreter.Str{Val: name}
+	}
+	return &interpreter.List{Elements: items}
+}
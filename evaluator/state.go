@@ -0,0 +1,246 @@
+package evaluator
+
+import (
+	"bufio"
+	"context"
+	"gopy/ast"
+	"gopy/interpreter"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// state holds what the scopes of one interpreter share while it runs. It
+// is attached to the global scope by NewEnv and passed on to the scopes
+// that scope encloses and to the modules it imports.
+type state struct {
+	// ctx, once done, stops evaluation at the next statement. While a
+	// budget with a timeout is running it expires at the deadline, and
+	// caller holds the context given to EvaluateContext.
+	ctx context.Context
+	caller context.Context
+	budget Budget
+	running bool
+	// steps counts the nodes evaluated under the running budget, and
+	// memory the bytes it has allocated.
+	steps int
+	memory int64
+	// caps limits the effects scripts may have on the host, or is nil if
+	// they may have any.
+	caps *Capabilities
+	// modules caches the modules the interpreter has imported.
+	modules map[string]*interpreter.Module
+	// argv and searchPath replace Argv and SearchPath when not nil.
+	argv []string
+	searchPath []string
+	// stdout, stderr and stdin replace Stdout, Stderr and Stdin when set.
+	stdout io.Writer
+	stderr io.Writer
+	stdin *bufio.Reader
+	hook Hook
+	// callStack holds the calls in progress, innermost last, and
+	// maxDepth the recursion limit set by SetRecursionLimit or
+	// sys.setrecursionlimit, if any.
+	callStack []frame
+	maxDepth int
+	// nesting counts the containers being compared or deep copied, which
+	// nest as deeply as the containers do and count towards the recursion
+	// limit.
+	nesting int
+	// handling holds the errors whose handlers or finally blocks are
+	// running, innermost last, so that a bare raise can re-raise the
+	// current one and new errors can chain onto it.
+	handling []*interpreter.Error
+	// noAssert turns assert statements into no-ops.
+	noAssert bool
+	// maxLoops bounds the iterations of each while loop, if not zero.
+	maxLoops int
+	// rng is the random module's generator, made when first used.
+	rng *rand.Rand
+	// life is done once the interpreter is closed, stopping its
+	// generators.
+	life context.Context
+	end context.CancelFunc
+}
+
+func newState() *state {
+	s := &state{}
+	s.life, s.end = context.WithCancel(context.Background())
+	return s
+}
+
+// Close stops the generators of the interpreter env belongs to that are
+// paused at a yield, ending the goroutines running them; resuming them
+// afterwards raises StopIteration. The interpreter must not be used once
+// closed. Close may be called from any goroutine.
+func Close(env *interpreter.Environment) {
+	stateOf(env).end()
+}
+
+// Hook is called with each statement about to be evaluated and the scope
+// it will run in, so that hosts can trace scripts or build debuggers.
+type Hook func(node ast.Node, env *interpreter.Environment)
+
+// SetHook makes the interpreter env belongs to, which must have been made
+// by NewEnv, call h before each statement it evaluates. A nil h removes
+// the hook.
+func SetHook(env *interpreter.Environment, h Hook) {
+	stateOf(env).hook = h
+}
+
+// Budget bounds the work each call of EvaluateContext may do, so that
+// untrusted scripts can't run forever or exhaust the host's memory.
+// Running out of steps or time raises a TimeoutError that scripts can't
+// handle. Zero fields mean no limit.
+type Budget struct {
+	// MaxSteps is the most AST nodes that may be evaluated.
+	MaxSteps int
+	// Timeout is the longest evaluation may take.
+	Timeout time.Duration
+	// MaxMemory is the most bytes that strings, bytes and the elements
+	// of lists and dicts built during evaluation may take, roughly.
+	// Memory is counted as it is allocated, without regard to what is
+	// later freed. Exceeding it raises a MemoryError.
+	MaxMemory int64
+}
+
+// SetBudget sets the budget for evaluations in the interpreter env
+// belongs to, which must have been made by NewEnv.
+func SetBudget(env *interpreter.Environment, b Budget) {
+	stateOf(env).budget = b
+}
+
+// stateOf returns the state of the interpreter env belongs to. Scopes
+// made without NewEnv get one the first time it is needed, kept by their
+// global scope.
+func stateOf(env *interpreter.Environment) *state {
+	if s, ok := env.State().(*state); ok {
+		return s
+	}
+	global := env
+	for global.Outer() != nil {
+		global = global.Outer()
+	}
+	s := newState()
+	global.SetState(s)
+	return s
+}
+
+// done returns a channel closed when the evaluation in progress is
+// cancelled, or nil if it can't be.
+func (s *state) done() <-chan struct{} {
+	if s.ctx == nil {
+		return nil
+	}
+	return s.ctx.Done()
+}
+
+// newGlobalScope returns an empty global scope for the interpreter env
+// belongs to, such as a module's, with the same builtins and state.
+func newGlobalScope(env *interpreter.Environment) *interpreter.Environment {
+	scope := interpreter.NewEnv()
+	scope.SetBuiltins(env.Builtins())
+	scope.SetState(env.State())
+	return scope
+}
+
+// EvaluateContext evaluates node in env like Evaluate, checking before each
+// statement, and so each loop iteration, whether ctx is done. If it is,
+// evaluation stops with a KeyboardInterrupt that scripts can't handle,
+// carrying the reason ctx gives.
+//
+// Unless called from inside another evaluation, it also starts the budget
+// set by SetBudget. Different interpreters may evaluate at the same time,
+// but each must not be used from more than one goroutine at once.
+func EvaluateContext(ctx context.Context, node ast.Node, env *interpreter.Environment) interpreter.Item {
+	s := stateOf(env)
+	outer, outerCaller := s.ctx, s.caller
+	defer func() { s.ctx, s.caller = outer, outerCaller }()
+	s.ctx, s.caller = ctx, ctx
+	if !s.running {
+		s.running, s.steps, s.memory = true, 0, 0
+		defer func() { s.running = false }()
+		if s.budget.Timeout > 0 {
+			var cancel context.CancelFunc
+			s.ctx, cancel = context.WithTimeout(ctx, s.budget.Timeout)
+			defer cancel()
+		}
+	}
+	return Evaluate(node, env)
+}
+
+// interrupted returns the error stopping an evaluation that has been
+// cancelled or has run out of budget before node, about to be evaluated
+// in env, or nil. Steps are counted for every node, but cancellation is
+// only checked before statements, and by stepInterrupted.
+func interrupted(node ast.Node, env *interpreter.Environment) *interpreter.Error {
+	s := stateOf(env)
+	if !s.running {
+		return nil
+	}
+	if err := s.step(); err != nil {
+		return err
+	}
+	if _, ok := node.(ast.Stmt); !ok {
+		return nil
+	}
+	return s.cancelled()
+}
+
+// stepInterrupted counts a step of the evaluation in progress in env's
+// interpreter, returning the error stopping it if it has been cancelled
+// or has run out of steps or time, or nil. The in operator and builtins
+// call it for each item they step through or comparison they make, so
+// that a long loop inside one call stops like a loop of statements does.
+func stepInterrupted(env *interpreter.Environment) *interpreter.Error {
+	s := stateOf(env)
+	if !s.running {
+		return nil
+	}
+	if err := s.step(); err != nil {
+		return err
+	}
+	return s.cancelled()
+}
+
+// step counts a step under the running budget, returning the error
+// stopping the evaluation once there are too many.
+func (s *state) step() *interpreter.Error {
+	s.steps++
+	if s.budget.MaxSteps > 0 && s.steps > s.budget.MaxSteps {
+		return abort(newException(timeoutErrorClass, "evaluation exceeded %d steps", s.budget.MaxSteps))
+	}
+	return nil
+}
+
+// cancelled returns the error stopping the evaluation in progress if its
+// context is done or its time is up, or nil.
+func (s *state) cancelled() *interpreter.Error {
+	select {
+	case <-s.done():
+		if s.caller.Err() == nil {
+			return abort(newException(timeoutErrorClass, "evaluation exceeded its time limit of %v", s.budget.Timeout))
+		}
+		return abort(newException(keyboardInterruptClass, "%s", s.caller.Err()))
+	default:
+		return nil
+	}
+}
+
+// callHook calls the hook of env's interpreter, if it has one, when node
+// is a statement.
+func callHook(node ast.Node, env *interpreter.Environment) {
+	s := stateOf(env)
+	if s.hook == nil {
+		return
+	}
+	if _, ok := node.(ast.Stmt); ok {
+		s.hook(node, env)
+	}
+}
+
+// abort makes err one that scripts can't handle.
+func abort(err *interpreter.Error) *interpreter.Error {
+	err.Abort = true
+	return err
+}
@@ -0,0 +1,41 @@
+package evaluator
+
+import (
+	"bufio"
+	"gopy/interpreter"
+	"io"
+)
+
+// SetStdout makes print, the prompt of input and sys.stdout write to w
+// instead of Stdout in the interpreter env belongs to.
+func SetStdout(env *interpreter.Environment, w io.Writer) {
+	stateOf(env).stdout = w
+}
+
+// SetStderr makes sys.stderr write to w instead of Stderr in the
+// interpreter env belongs to.
+func SetStderr(env *interpreter.Environment, w io.Writer) {
+	stateOf(env).stderr = w
+}
+
+// SetStdin makes input and sys.stdin read from r instead of Stdin in the
+// interpreter env belongs to.
+func SetStdin(env *interpreter.Environment, r io.Reader) {
+	stateOf(env).stdin = bufio.NewReader(r)
+}
+
+// stdout returns where the interpreter env belongs to prints.
+func stdout(env *interpreter.Environment) io.Writer {
+	if w := stateOf(env).stdout; w != nil {
+		return w
+	}
+	return Stdout
+}
+
+// stderr returns where the interpreter env belongs to writes errors.
+func stderr(env *interpreter.Environment) io.Writer {
+	if w := stateOf(env).stderr; w != nil {
+		return w
+	}
+	return Stderr
+}
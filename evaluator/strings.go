@@ -0,0 +1,457 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// strMethods are the methods available on str objects. Each receives the
+// string itself as its first argument.
+var strMethods map[string]*interpreter.Builtin
+
+func init() {
+	strMethods = map[string]*interpreter.Builtin{
+		"upper": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return strTransform("upper", strings.ToUpper, args, env)
+			},
+		},
+		"lower": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return strTransform("lower", strings.ToLower, args, env)
+			},
+		},
+		"strip": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return strStrip("strip", strings.TrimFunc, args)
+			},
+		},
+		"lstrip": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return strStrip("lstrip", strings.TrimLeftFunc, args)
+			},
+		},
+		"rstrip": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return strStrip("rstrip", strings.TrimRightFunc, args)
+			},
+		},
+		"split": {
+			KwFn: strSplit,
+		},
+		"join": {
+			Fn: strJoin,
+		},
+		"replace": {
+			Fn: strReplace,
+		},
+		"find": {
+			Fn: strFind,
+		},
+		"startswith": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return strAffix("startswith", strings.HasPrefix, args)
+			},
+		},
+		"endswith": {
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return strAffix("endswith", strings.HasSuffix, args)
+			},
+		},
+		"format": {
+			KwFn: strFormat,
+		},
+		"encode": {
+			KwFn: func(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+				encoding, errors, err := codecArgs("encode", args, kwargs)
+				if err != nil {
+					return err
+				}
+				return encodeStr(args[0].(*interpreter.Str).Val, encoding, errors)
+			},
+		},
+	}
+	for name, method := range strMethods {
+		method.Name = name
+	}
+}
+
+// strTransform applies f to the string of a method taking no arguments.
+func strTransform(name string, f func(string) string, args []interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "str.%s() takes no arguments (%d given)", name, len(args)-1)
+	}
+	result := f(args[0].(*interpreter.Str).Val)
+	if err := allocate(int64(len(result)), env); err != nil {
+		return err
+	}
+	return &interpreter.Str{Val: result}
+}
+
+// strStrip trims whitespace, or the characters of the optional argument,
+// using trim.
+func strStrip(name string, trim func(string, func(rune) bool) string, args []interpreter.Item) interpreter.Item {
+	if len(args) > 2 {
+		return newException(typeErrorClass, "%s expected at most 1 argument, got %d", name, len(args)-1)
+	}
+	s := args[0].(*interpreter.Str).Val
+	if len(args) == 1 || args[1] == NONE {
+		return &interpreter.Str{Val: trim(s, unicode.IsSpace)}
+	}
+	chars, ok := args[1].(*interpreter.Str)
+	if !ok {
+		return newException(typeErrorClass, "%s arg must be None or str", name)
+	}
+	return &interpreter.Str{Val: trim(s, func(r rune) bool {
+		return strings.ContainsRune(chars.Val, r)
+	})}
+}
+
+// strSplit implements str.split(sep=None, maxsplit=-1). Without a
+// separator, runs of whitespace separate the words and empty strings are
+// dropped.
+func strSplit(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	s := args[0].(*interpreter.Str).Val
+	args = args[1:]
+	if len(args) > 2 {
+		return newException(typeErrorClass, "split() takes at most 2 arguments (%d given)", len(args))
+	}
+	sep, maxsplit := interpreter.Item(NONE), interpreter.Item(&interpreter.Int{Val: -1})
+	if len(args) > 0 {
+		sep = args[0]
+	}
+	if len(args) > 1 {
+		maxsplit = args[1]
+	}
+	for name, val := range kwargs {
+		switch name {
+		case "sep":
+			sep = val
+		case "maxsplit":
+			maxsplit = val
+		default:
+			return newException(typeErrorClass, "'%s' is an invalid keyword argument for split()", name)
+		}
+	}
+	limit, ok := maxsplit.(*interpreter.Int)
+	if !ok {
+		return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(maxsplit))
+	}
+	var parts []string
+	switch sep := sep.(type) {
+	case *interpreter.None:
+		parts = splitFields(s, int(limit.Val))
+	case *interpreter.Str:
+		if sep.Val == "" {
+			return newException(valueErrorClass, "empty separator")
+		}
+		n := -1
+		if limit.Val >= 0 {
+			n = int(limit.Val) + 1
+		}
+		parts = strings.SplitN(s, sep.Val, n)
+	default:
+		return newException(typeErrorClass, "must be str or None, not %s", typeName(sep))
+	}
+	if err := allocateRepeat(slotSize, int64(len(parts)), env); err != nil {
+		return err
+	}
+	if err := allocate(int64(len(s)), env); err != nil {
+		return err
+	}
+	elements := make([]interpreter.Item, len(parts))
+	for i, part := range parts {
+		elements[i] = &interpreter.Str{Val: part}
+	}
+	return &interpreter.List{Elements: elements}
+}
+
+// splitFields splits s around runs of whitespace, making at most limit
+// splits if limit is not negative. The unsplit remainder keeps its
+// trailing whitespace.
+func splitFields(s string, limit int) []string {
+	parts := []string{}
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return parts
+		}
+		if limit >= 0 && len(parts) == limit {
+			return append(parts, s)
+		}
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:end])
+		s = s[end:]
+	}
+}
+
+func strJoin(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 {
+		return newException(typeErrorClass, "str.join() takes exactly one argument (%d given)", len(args)-1)
+	}
+	items, err := iterate(args[1], env)
+	if err != nil {
+		if err.Exception != nil && isSubclass(err.Exception.Class, memoryErrorClass) {
+			return err
+		}
+		return newException(typeErrorClass, "can only join an iterable")
+	}
+	parts := make([]string, len(items))
+	for i, item := range items {
+		s, ok := item.(*interpreter.Str)
+		if !ok {
+			return newException(typeErrorClass, "sequence item %d: expected str instance, %s found", i, typeName(item))
+		}
+		parts[i] = s.Val
+	}
+	sep := args[0].(*interpreter.Str).Val
+	size := int64(len(sep) * len(parts))
+	for _, part := range parts {
+		size += int64(len(part))
+	}
+	if err := allocate(size, env); err != nil {
+		return err
+	}
+	return &interpreter.Str{Val: strings.Join(parts, sep)}
+}
+
+// strReplace implements str.replace(old, new, count=-1).
+func strReplace(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) < 3 || len(args) > 4 {
+		return newException(typeErrorClass, "replace expected 2 or 3 arguments, got %d", len(args)-1)
+	}
+	var strs [2]string
+	for i, arg := range args[1:3] {
+		s, ok := arg.(*interpreter.Str)
+		if !ok {
+			return newException(typeErrorClass, "replace() argument %d must be str, not %s", i+1, typeName(arg))
+		}
+		strs[i] = s.Val
+	}
+	n := -1
+	if len(args) == 4 {
+		count, ok := args[3].(*interpreter.Int)
+		if !ok {
+			return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(args[3]))
+		}
+		n = int(count.Val)
+	}
+	s := args[0].(*interpreter.Str).Val
+	if err := allocateRepeat(len(strs[1]), int64(strings.Count(s, strs[0])+1), env); err != nil {
+		return err
+	}
+	if err := allocate(int64(len(s)), env); err != nil {
+		return err
+	}
+	return &interpreter.Str{Val: strings.Replace(s, strs[0], strs[1], n)}
+}
+
+// strFind implements str.find(sub, start, end), returning the index of the
+// first occurrence of sub within s[start:end], or -1.
+func strFind(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) < 2 || len(args) > 4 {
+		return newException(typeErrorClass, "find expected at least 1 argument, got %d", len(args)-1)
+	}
+	sub, ok := args[1].(*interpreter.Str)
+	if !ok {
+		return newException(typeErrorClass, "must be str, not %s", typeName(args[1]))
+	}
+	runes := []rune(args[0].(*interpreter.Str).Val)
+	start, stop := 0, len(runes)
+	for i, bound := range []*int{&start, &stop} {
+		if len(args) <= i+2 || args[i+2] == NONE {
+			continue
+		}
+		n, ok := args[i+2].(*interpreter.Int)
+		if !ok {
+			return newException(typeErrorClass, "slice indices must be integers or None")
+		}
+		*bound = clampIndex(int(n.Val), len(runes))
+	}
+	if start > stop {
+		return &interpreter.Int{Val: -1}
+	}
+	i := strings.Index(string(runes[start:stop]), sub.Val)
+	if i < 0 {
+		return &interpreter.Int{Val: -1}
+	}
+	return &interpreter.Int{Val: int64(start + utf8.RuneCountInString(string(runes[start:stop])[:i]))}
+}
+
+// clampIndex converts a slice bound into a position within a sequence of
+// the given length, counting negative bounds from the end.
+func clampIndex(i int, length int) int {
+	if i < 0 {
+		i += length
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > length {
+		return length
+	}
+	return i
+}
+
+// strAffix implements startswith and endswith, which accept a single
+// affix or a tuple of alternatives.
+func strAffix(name string, has func(string, string) bool, args []interpreter.Item) interpreter.Item {
+	if len(args) != 2 {
+		return newException(typeErrorClass, "%s() takes exactly one argument (%d given)", name, len(args)-1)
+	}
+	s := args[0].(*interpreter.Str).Val
+	affixes := []interpreter.Item{args[1]}
+	if tuple, ok := args[1].(*interpreter.Tuple); ok {
+		affixes = tuple.Elements
+	}
+	for _, affix := range affixes {
+		a, ok := affix.(*interpreter.Str)
+		if !ok {
+			if _, isTuple := args[1].(*interpreter.Tuple); isTuple {
+				return newException(typeErrorClass, "tuple for %s must only contain str, not %s", name, typeName(affix))
+			}
+			return newException(typeErrorClass, "%s first arg must be str or a tuple of str, not %s", name, typeName(affix))
+		}
+		if has(s, a.Val) {
+			return TRUE
+		}
+	}
+	return FALSE
+}
+
+// strFormat implements str.format, replacing each {} field with the next
+// positional argument, {n} with the nth and {name} with a keyword
+// argument. Doubled braces stand for literal ones. A field may end with a
+// !s or !r conversion and a :spec for format(), which may itself contain
+// fields, as in "{:>{}}".
+func strFormat(env *interpreter.Environment, args []interpreter.Item, kwargs map[string]interpreter.Item) interpreter.Item {
+	format := args[0].(*interpreter.Str).Val
+	args = args[1:]
+	next, manual := 0, false
+	lookup := func(name string) (interpreter.Item, *interpreter.Error) {
+		n, err := strconv.Atoi(name)
+		if name != "" && err != nil {
+			if val, ok := kwargs[name]; ok {
+				return val, nil
+			}
+			return nil, newException(keyErrorClass, "'%s'", name)
+		}
+		if name == "" {
+			if manual {
+				return nil, newException(valueErrorClass, "cannot switch from manual field specification to automatic field numbering")
+			}
+			n = next
+			next++
+		} else {
+			if next > 0 {
+				return nil, newException(valueErrorClass, "cannot switch from automatic field numbering to manual field specification")
+			}
+			manual = true
+		}
+		if n >= len(args) {
+			return nil, newException(indexErrorClass, "Replacement index %d out of range for positional args tuple", n)
+		}
+		return args[n], nil
+	}
+	var replace func(format string, depth int) (string, *interpreter.Error)
+	replace = func(format string, depth int) (string, *interpreter.Error) {
+		var out strings.Builder
+		for i := 0; i < len(format); i++ {
+			c := format[i]
+			if c == '}' {
+				if i+1 < len(format) && format[i+1] == '}' {
+					out.WriteByte('}')
+					i++
+					continue
+				}
+				return "", newException(valueErrorClass, "Single '}' encountered in format string")
+			}
+			if c != '{' {
+				out.WriteByte(c)
+				continue
+			}
+			if i+1 < len(format) && format[i+1] == '{' {
+				out.WriteByte('{')
+				i++
+				continue
+			}
+			// Find the matching brace, skipping those of nested fields.
+			end, open := -1, 0
+			for j := i + 1; j < len(format) && end < 0; j++ {
+				switch format[j] {
+				case '{':
+					open++
+				case '}':
+					if open == 0 {
+						end = j
+					}
+					open--
+				}
+			}
+			if end < 0 {
+				return "", newException(valueErrorClass, "expected '}' before end of string")
+			}
+			field := format[i+1 : end]
+			i = end
+			if depth > 1 {
+				return "", newException(valueErrorClass, "Max string recursion exceeded")
+			}
+			var spec, conversion string
+			if colon := strings.IndexByte(field, ':'); colon >= 0 {
+				field, spec = field[:colon], field[colon+1:]
+			}
+			if bang := strings.IndexByte(field, '!'); bang >= 0 {
+				field, conversion = field[:bang], field[bang+1:]
+			}
+			val, err := lookup(field)
+			if err != nil {
+				return "", err
+			}
+			switch conversion {
+			case "":
+			case "s":
+				if val = builtinStr(env, val); val.Type() == interpreter.ERR {
+					return "", val.(*interpreter.Error)
+				}
+			case "r", "a":
+				s, err := repr(val, env)
+				if err != nil {
+					return "", err
+				}
+				val = &interpreter.Str{Val: s}
+			default:
+				return "", newException(valueErrorClass, "Unknown conversion specifier %s", conversion)
+			}
+			if strings.IndexByte(spec, '{') >= 0 {
+				if spec, err = replace(spec, depth+1); err != nil {
+					return "", err
+				}
+			}
+			s := formatValue(val, spec, env)
+			if s.Type() == interpreter.ERR {
+				return "", s.(*interpreter.Error)
+			}
+			if err := allocate(int64(len(s.(*interpreter.Str).Val)), env); err != nil {
+				return "", err
+			}
+			out.WriteString(s.(*interpreter.Str).Val)
+		}
+		return out.String(), nil
+	}
+	// The text between fields is charged up front, and each field as it is
+	// written, so that the result is never built past the budget.
+	if err := allocate(int64(len(format)), env); err != nil {
+		return err
+	}
+	s, err := replace(format, 0)
+	if err != nil {
+		return err
+	}
+	return &interpreter.Str{Val: s}
+}
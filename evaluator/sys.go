@@ -0,0 +1,206 @@
+package evaluator
+
+import (
+	"bufio"
+	"fmt"
+	"gopy/interpreter"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"runtime"
+	"unicode/utf8"
+)
+
+// Argv holds the script path followed by its arguments, as sys.argv
+// exposes them in interpreters not given arguments of their own by
+// SetArgv. It must not be changed while scripts run.
+var Argv = []string{""}
+
+// Stderr is where sys.stderr writes, for interpreters not given a writer
+// of their own by SetStderr. It must not be changed while scripts run.
+var Stderr io.Writer = os.Stderr
+
+// SetArgv makes sys.argv hold args instead of Argv in the interpreter env
+// belongs to. It takes effect for scripts that import sys afterwards.
+func SetArgv(env *interpreter.Environment, args []string) {
+	stateOf(env).argv = append([]string{}, args...)
+}
+
+func init() {
+	nativeModules["sys"] = newSysModule
+}
+
+func newSysModule(env *interpreter.Environment) *interpreter.Module {
+	args := stateOf(env).argv
+	if args == nil {
+		args = Argv
+	}
+	argv := make([]interpreter.Item, len(args))
+	for i, arg := range args {
+		argv[i] = &interpreter.Str{Val: arg}
+	}
+	return newNativeModule("sys", map[string]interpreter.Item{
+		"argv": &interpreter.List{Elements: argv},
+		"exit": &interpreter.Builtin{Fn: sysExit},
+		"stdin": newInputStream(),
+		"stdout": newOutputStream(stdout),
+		"stderr": newOutputStream(stderr),
+		"version": &interpreter.Str{Val: "3.10.0 (gopy)"},
+		"version_info": &interpreter.Tuple{Elements: []interpreter.Item{
+			&interpreter.Int{Val: 3}, &interpreter.Int{Val: 10}, &interpreter.Int{Val: 0},
+		}},
+		"platform": &interpreter.Str{Val: runtime.GOOS},
+		"maxsize": &interpreter.Int{Val: math.MaxInt64},
+		"getrecursionlimit": &interpreter.Builtin{
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 0 {
+					return newException(typeErrorClass, "getrecursionlimit() takes no arguments (%d given)", len(args))
+				}
+				return &interpreter.Int{Val: int64(stateOf(env).recursionLimit())}
+			},
+		},
+		"setrecursionlimit": &interpreter.Builtin{Fn: sysSetrecursionlimit},
+	})
+}
+
+// sysExit raises SystemExit carrying the optional exit code.
+func sysExit(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) > 1 {
+		return newException(typeErrorClass, "exit expected at most 1 argument, got %d", len(args))
+	}
+	code := interpreter.Item(NONE)
+	if len(args) == 1 {
+		code = args[0]
+	}
+	return raise(&interpreter.Instance{
+		Class: systemExitClass,
+		Attrs: map[string]interpreter.Item{
+			"args": &interpreter.Tuple{Elements: args},
+			"code": code,
+		},
+	})
+}
+
+// sysSetrecursionlimit sets the recursion limit of the interpreter it is
+// called in, which must exceed the depth of the calls already in
+// progress. Limits above MaxRecursionLimit are lowered to it.
+func sysSetrecursionlimit(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "setrecursionlimit() takes exactly one argument (%d given)", len(args))
+	}
+	limit, ok := args[0].(*interpreter.Int)
+	if !ok {
+		return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(args[0]))
+	}
+	if limit.Val < 1 {
+		return newException(valueErrorClass, "recursion limit must be greater or equal than 1")
+	}
+	s := stateOf(env)
+	if depth := len(s.callStack); limit.Val <= int64(depth) {
+		return newException(recursionErrorClass, "cannot set the recursion limit to %d at the recursion depth %d: the limit is too low", limit.Val, depth)
+	}
+	s.maxDepth = MaxRecursionLimit
+	if limit.Val < MaxRecursionLimit {
+		s.maxDepth = int(limit.Val)
+	}
+	return NONE
+}
+
+// ExitStatus reports whether err, raised in the interpreter env belongs
+// to, is an uncaught SystemExit and, if so, the status the process should
+// exit with. Like Python, a code that is neither None nor an int is
+// written to the interpreter's sys.stderr and gives status 1.
+func ExitStatus(err *interpreter.Error, env *interpreter.Environment) (int, bool) {
+	exception := exceptionOf(err)
+	if !isSubclass(exception.Class, systemExitClass) {
+		return 0, false
+	}
+	args, _ := exception.Attrs["args"].(*interpreter.Tuple)
+	if args == nil || len(args.Elements) == 0 {
+		return 0, true
+	}
+	switch code := args.Elements[0].(type) {
+	case *interpreter.None:
+		return 0, true
+	case *interpreter.Int:
+		return int(code.Val), true
+	case *interpreter.Bool:
+		return int(boolToInt(code).(*interpreter.Int).Val), true
+	default:
+		fmt.Fprintln(stderr(env), code.Visit())
+		return 1, true
+	}
+}
+
+// newOutputStream returns a file-like object whose write method writes to
+// the writer returned by w at the time of the call.
+func newOutputStream(w func(env *interpreter.Environment) io.Writer) *interpreter.Instance {
+	class := &interpreter.Class{Name: "TextIOWrapper", Attrs: map[string]interpreter.Item{
+		"write": &interpreter.Builtin{
+			Name: "write",
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 2 {
+					return newException(typeErrorClass, "write() takes exactly one argument (%d given)", len(args)-1)
+				}
+				s, ok := args[1].(*interpreter.Str)
+				if !ok {
+					return newException(typeErrorClass, "write() argument must be str, not %s", typeName(args[1]))
+				}
+				if _, err := io.WriteString(w(env), s.Val); err != nil {
+					return newException(osErrorClass, "%s", err)
+				}
+				return &interpreter.Int{Val: int64(utf8.RuneCountInString(s.Val))}
+			},
+		},
+		"flush": &interpreter.Builtin{
+			Name: "flush",
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				return NONE
+			},
+		},
+	}}
+	return &interpreter.Instance{Class: class, Attrs: map[string]interpreter.Item{}}
+}
+
+// newInputStream returns a file-like object reading from Stdin, sharing
+// its buffer with input.
+func newInputStream() *interpreter.Instance {
+	class := &interpreter.Class{Name: "TextIOWrapper", Attrs: map[string]interpreter.Item{
+		"readline": &interpreter.Builtin{
+			Name: "readline",
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "readline() takes no arguments (%d given)", len(args)-1)
+				}
+				var line string
+				var err error
+				readStdin(env, func(r *bufio.Reader) {
+					line, err = r.ReadString('\n')
+				})
+				if err != nil && err != io.EOF {
+					return newException(osErrorClass, "%s", err)
+				}
+				return &interpreter.Str{Val: line}
+			},
+		},
+		"read": &interpreter.Builtin{
+			Name: "read",
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 1 {
+					return newException(typeErrorClass, "read() takes no arguments (%d given)", len(args)-1)
+				}
+				var data []byte
+				var err error
+				readStdin(env, func(r *bufio.Reader) {
+					data, err = ioutil.ReadAll(r)
+				})
+				if err != nil {
+					return newException(osErrorClass, "%s", err)
+				}
+				return &interpreter.Str{Val: string(data)}
+			},
+		},
+	}}
+	return &interpreter.Instance{Class: class, Attrs: map[string]interpreter.Item{}}
+}
@@ -0,0 +1,85 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+	"math"
+	"time"
+)
+
+// Interrupt, once closed, makes a running time.sleep return early by
+// raising KeyboardInterrupt. Embedders close it to cancel a script that
+// is waiting.
+var Interrupt <-chan struct{}
+
+// processStart is the reference point for time.monotonic.
+var processStart = time.Now()
+
+func init() {
+	nativeModules["time"] = newTimeModule
+}
+
+func newTimeModule(*interpreter.Environment) *interpreter.Module {
+	return newNativeModule("time", map[string]interpreter.Item{
+		"time": &interpreter.Builtin{
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 0 {
+					return newException(typeErrorClass, "time() takes no arguments (%d given)", len(args))
+				}
+				return &interpreter.Float{Val: float64(time.Now().UnixNano()) / 1e9}
+			},
+		},
+		"monotonic": &interpreter.Builtin{
+			Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+				if len(args) != 0 {
+					return newException(typeErrorClass, "monotonic() takes no arguments (%d given)", len(args))
+				}
+				return &interpreter.Float{Val: time.Since(processStart).Seconds()}
+			},
+		},
+		"sleep": &interpreter.Builtin{Fn: timeSleep},
+	})
+}
+
+// timeSleep pauses for the given number of seconds, or until Interrupt is
+// closed or the evaluation is cancelled.
+func timeSleep(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	s := stateOf(env)
+	result := sleepUntil(s.done(), args, env)
+	if err := s.cancelled(); err != nil {
+		return err
+	}
+	return result
+}
+
+// sleepUntil pauses like timeSleep, or until cancel is closed.
+func sleepUntil(cancel <-chan struct{}, args []interpreter.Item, env *interpreter.Environment) interpreter.Item {
+	if len(args) != 1 {
+		return newException(typeErrorClass, "time.sleep() takes exactly one argument (%d given)", len(args))
+	}
+	secs, err := toFloat(args[0], env)
+	if err != nil {
+		return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(args[0]))
+	}
+	if math.IsNaN(secs) {
+		return newException(valueErrorClass, "Invalid value NaN (not a number)")
+	}
+	if secs < 0 {
+		return newException(valueErrorClass, "sleep length must be non-negative")
+	}
+	if secs > math.MaxInt64/1e9 {
+		return newException(overflowErrorClass, "sleep length is too large")
+	}
+	timer := time.NewTimer(time.Duration(secs * 1e9))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return NONE
+	case <-cancel:
+		return NONE
+	case <-Interrupt:
+		return raise(&interpreter.Instance{
+			Class: keyboardInterruptClass,
+			Attrs: map[string]interpreter.Item{"args": &interpreter.Tuple{Elements: []interpreter.Item{}}},
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package evaluator
+
+import (
+	"fmt"
+	"gopy/interpreter"
+	"gopy/lexer"
+	"io/ioutil"
+	"strings"
+)
+
+// FormatTraceback formats err the way Python reports an uncaught
+// exception: the calls it passed through, most recent last, each with the
+// source line it was running if the script can still be read, and then
+// the exception itself. The errors err was chained onto come first.
+func FormatTraceback(err *interpreter.Error) string {
+	var b strings.Builder
+	formatChain(&b, err, map[string][]string{}, map[*interpreter.Error]bool{})
+	return b.String()
+}
+
+// formatChain writes the traceback of err after those of its cause or,
+// unless raise ... from hid it, its context. seen guards against cycles.
+func formatChain(b *strings.Builder, err *interpreter.Error, sources map[string][]string, seen map[*interpreter.Error]bool) {
+	seen[err] = true
+	suppress := false
+	if err.Exception != nil {
+		if val, ok := err.Exception.Attrs["__suppress_context__"]; ok {
+			suppress = isTrue(val)
+		}
+	}
+	switch {
+	case err.Cause != nil && !seen[err.Cause]:
+		formatChain(b, err.Cause, sources, seen)
+		b.WriteString("\nThe above exception was the direct cause of the following exception:\n\n")
+	case err.Context != nil && !suppress && !seen[err.Context]:
+		formatChain(b, err.Context, sources, seen)
+		b.WriteString("\nDuring handling of the above exception, another exception occurred:\n\n")
+	}
+	if len(err.Traceback) > 0 {
+		b.WriteString("Traceback (most recent call last):\n")
+	}
+	for i := len(err.Traceback) - 1; i >= 0; i-- {
+		entry := err.Traceback[i]
+		if !entry.Pos.IsValid() {
+			continue
+		}
+		file := entry.Pos.Filename
+		if file == "" {
+			file = "<stdin>"
+		}
+		fmt.Fprintf(b, "  File \"%s\", line %d, in %s\n", file, entry.Pos.Line, entry.Func)
+		if line := sourceLine(sources, entry.Pos); line != "" {
+			fmt.Fprintf(b, "    %s\n", line)
+		}
+	}
+	b.WriteString(err.Visit())
+	b.WriteString("\n")
+}
+
+// sourceLine returns the line of source at pos, without indentation, or
+// the empty string if it can't be read. Files are read once into sources.
+func sourceLine(sources map[string][]string, pos lexer.Position) string {
+	if pos.Filename == "" {
+		return ""
+	}
+	lines, ok := sources[pos.Filename]
+	if !ok {
+		src, err := ioutil.ReadFile(pos.Filename)
+		if err == nil {
+			lines = strings.Split(string(src), "\n")
+		}
+		sources[pos.Filename] = lines
+	}
+	if pos.Line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[pos.Line-1])
+}
@@ -0,0 +1,324 @@
+package evaluator
+
+import (
+	"gopy/interpreter"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// builtinTypes holds the builtin types scripts can refer to by name.
+var builtinTypes = map[string]*interpreter.Type{}
+
+// itemTypes maps each kind of item to the type type() reports for it.
+var itemTypes = map[interpreter.ItemType]*interpreter.Type{}
+
+var (
+	intType = newType("int", nil, interpreter.INT)
+	boolType = newType("bool", intType, interpreter.BOOL)
+	floatType = newType("float", nil, interpreter.FLOAT)
+	strType = newType("str", nil, interpreter.STR)
+	bytesType = newType("bytes", nil, interpreter.BYTES)
+	listType = newType("list", nil, interpreter.LIST)
+	tupleType = newType("tuple", nil, interpreter.TUPLE)
+	dictType = newType("dict", nil, interpreter.DICT)
+	setType = newType("set", nil, interpreter.SET)
+	rangeType = newType("range", nil, interpreter.RANGE)
+	typeType = newType("type", nil, interpreter.TYPE, interpreter.CLASS)
+)
+
+func init() {
+	for _, t := range []*interpreter.Type{intType, boolType, floatType, strType, bytesType, listType, tupleType, dictType, setType, rangeType, typeType} {
+		builtinTypes[t.Name] = t
+	}
+	newType("NoneType", nil, interpreter.NONE)
+	newType("function", nil, interpreter.FUNCTION)
+	newType("builtin_function_or_method", nil, interpreter.BUILTIN)
+	newType("method", nil, interpreter.METHOD)
+	newType("module", nil, interpreter.MODULE)
+	newType("generator", nil, interpreter.GENERATOR)
+	newType("slice", nil, interpreter.SLICE)
+	newType("super", nil, interpreter.SUPER)
+	newType("_io.TextIOWrapper", nil, interpreter.FILE)
+	newType("re.Pattern", nil, interpreter.PATTERN)
+	newType("re.Match", nil, interpreter.MATCH)
+
+	intType.New = builtinInt
+	floatType.New = builtinFloat
+	strType.New = builtinStr
+	bytesType.New = builtinBytes
+	boolType.New = func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		if len(args) > 1 {
+			return newException(typeErrorClass, "bool expected at most 1 argument, got %d", len(args))
+		}
+		return nativeBool(len(args) == 1 && isTrue(args[0]))
+	}
+	listType.New = func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		items, err := sequenceArgs("list", args, env)
+		if err != nil {
+			return err
+		}
+		return &interpreter.List{Elements: items}
+	}
+	tupleType.New = func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		items, err := sequenceArgs("tuple", args, env)
+		if err != nil {
+			return err
+		}
+		return &interpreter.Tuple{Elements: items}
+	}
+	setType.New = builtinSet
+	rangeType.New = builtinRange
+	typeType.New = func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		if len(args) != 1 {
+			return newException(typeErrorClass, "type() takes 1 argument")
+		}
+		return typeOf(args[0])
+	}
+}
+
+// sequenceArgs returns the items of the optional iterable argument to the
+// list and tuple constructors.
+func sequenceArgs(name string, args []interpreter.Item, env *interpreter.Environment) ([]interpreter.Item, *interpreter.Error) {
+	if len(args) > 1 {
+		return nil, newException(typeErrorClass, "%s expected at most 1 argument, got %d", name, len(args))
+	}
+	if len(args) == 0 {
+		return []interpreter.Item{}, nil
+	}
+	return iterate(args[0], env)
+}
+
+func newType(name string, base *interpreter.Type, kinds ...interpreter.ItemType) *interpreter.Type {
+	t := &interpreter.Type{Name: name, Base: base}
+	for _, kind := range kinds {
+		itemTypes[kind] = t
+	}
+	return t
+}
+
+// typeOf returns the class of an instance, or the builtin type of any
+// other item.
+func typeOf(item interpreter.Item) interpreter.Item {
+	if instance, ok := item.(*interpreter.Instance); ok {
+		return instance.Class
+	}
+	if t, ok := itemTypes[item.Type()]; ok {
+		return t
+	}
+	return &interpreter.Type{Name: typeName(item)}
+}
+
+// isSubtype reports whether class, a user class or builtin type, is base
+// or derives from it. A tuple base matches if any of its elements does.
+// The bool result is false if base is not a class or tuple of classes.
+func isSubtype(class interpreter.Item, base interpreter.Item) (bool, bool) {
+	switch base := base.(type) {
+	case *interpreter.Tuple:
+		for _, elem := range base.Elements {
+			matched, ok := isSubtype(class, elem)
+			if !ok {
+				return false, false
+			}
+			if matched {
+				return true, true
+			}
+		}
+		return false, true
+	case *interpreter.Class:
+		c, ok := class.(*interpreter.Class)
+		return ok && isSubclass(c, base), true
+	case *interpreter.Type:
+		t, ok := class.(*interpreter.Type)
+		for ; ok && t != nil; t = t.Base {
+			if t == base {
+				return true, true
+			}
+		}
+		return false, true
+	}
+	return false, false
+}
+
+func builtinIsinstance(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 {
+		return newException(typeErrorClass, "isinstance expected 2 arguments, got %d", len(args))
+	}
+	matched, ok := isSubtype(typeOf(args[0]), args[1])
+	if !ok {
+		return newException(typeErrorClass, "isinstance() arg 2 must be a type or tuple of types")
+	}
+	return nativeBool(matched)
+}
+
+func builtinIssubclass(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) != 2 {
+		return newException(typeErrorClass, "issubclass expected 2 arguments, got %d", len(args))
+	}
+	switch args[0].(type) {
+	case *interpreter.Class, *interpreter.Type:
+	default:
+		return newException(typeErrorClass, "issubclass() arg 1 must be a class")
+	}
+	matched, ok := isSubtype(args[0], args[1])
+	if !ok {
+		return newException(typeErrorClass, "issubclass() arg 2 must be a class or tuple of classes")
+	}
+	return nativeBool(matched)
+}
+
+// builtinInt implements int(), int(x) and int(s, base). Floats are
+// truncated towards zero and strings are parsed after trimming spaces.
+func builtinInt(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	switch len(args) {
+	case 0:
+		return &interpreter.Int{Val: 0}
+	case 1:
+	case 2:
+		s, ok := args[0].(*interpreter.Str)
+		if !ok {
+			return newException(typeErrorClass, "int() can't convert non-string with explicit base")
+		}
+		base, ok := args[1].(*interpreter.Int)
+		if !ok {
+			return newException(typeErrorClass, "'%s' object cannot be interpreted as an integer", typeName(args[1]))
+		}
+		if base.Val != 0 && (base.Val < 2 || base.Val > 36) {
+			return newException(valueErrorClass, "int() base must be >= 2 and <= 36, or 0")
+		}
+		return parseInt(s.Val, int(base.Val))
+	default:
+		return newException(typeErrorClass, "int() takes at most 2 arguments (%d given)", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *interpreter.Int:
+		return arg
+	case *interpreter.Bool:
+		return boolToInt(arg)
+	case *interpreter.Float:
+		switch {
+		case math.IsNaN(arg.Val):
+			return newException(valueErrorClass, "cannot convert float NaN to integer")
+		case math.IsInf(arg.Val, 0):
+			return newException(overflowErrorClass, "cannot convert float infinity to integer")
+		case arg.Val >= math.MaxInt64 || arg.Val < math.MinInt64:
+			return intOverflow()
+		}
+		return &interpreter.Int{Val: int64(arg.Val)}
+	case *interpreter.Str:
+		return parseInt(arg.Val, 10)
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, arg, "__int__"); ok {
+			return result
+		}
+	}
+	return newException(typeErrorClass, "int() argument must be a string or a real number, not '%s'", typeName(args[0]))
+}
+
+// parseInt parses s as an int literal in base. Base 0 reads the base from
+// a 0b, 0o or 0x prefix like source code does.
+func parseInt(s string, base int) interpreter.Item {
+	digits := strings.TrimSpace(s)
+	parseBase := base
+	// strconv only accepts a prefix when it picks the base itself, while
+	// Python also allows the prefix matching an explicit base.
+	prefixes := map[int]string{2: "0b", 8: "0o", 16: "0x"}
+	if prefix, ok := prefixes[base]; ok && strings.HasPrefix(strings.ToLower(strings.TrimLeft(digits, "+-")), prefix) {
+		parseBase = 0
+	}
+	n, err := strconv.ParseInt(digits, parseBase, 64)
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		return intOverflow()
+	}
+	if err != nil {
+		return newException(valueErrorClass, "invalid literal for int() with base %d: '%s'", base, s)
+	}
+	return &interpreter.Int{Val: n}
+}
+
+// builtinFloat implements float() and float(x).
+func builtinFloat(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) > 1 {
+		return newException(typeErrorClass, "float expected at most 1 argument, got %d", len(args))
+	}
+	if len(args) == 0 {
+		return &interpreter.Float{Val: 0}
+	}
+	switch arg := args[0].(type) {
+	case *interpreter.Float:
+		return arg
+	case *interpreter.Int:
+		return &interpreter.Float{Val: float64(arg.Val)}
+	case *interpreter.Bool:
+		return builtinFloat(env, boolToInt(arg))
+	case *interpreter.Str:
+		text := strings.TrimSpace(arg.Val)
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil && !isRangeError(err) || strings.HasPrefix(strings.ToLower(strings.TrimLeft(text, "+-")), "0x") {
+			return newException(valueErrorClass, "could not convert string to float: '%s'", arg.Val)
+		}
+		return &interpreter.Float{Val: f}
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, arg, "__float__"); ok {
+			return result
+		}
+	}
+	return newException(typeErrorClass, "float() argument must be a string or a real number, not '%s'", typeName(args[0]))
+}
+
+// isRangeError reports whether err is strconv's out of range error, which
+// still comes with the nearest float, as Python gives.
+func isRangeError(err error) bool {
+	numErr, ok := err.(*strconv.NumError)
+	return ok && numErr.Err == strconv.ErrRange
+}
+
+// builtinStr implements str() and str(x), using __str__ if the object
+// defines it.
+func builtinStr(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+	if len(args) > 1 {
+		return newException(typeErrorClass, "str expected at most 1 argument, got %d", len(args))
+	}
+	if len(args) == 0 {
+		return &interpreter.Str{Val: ""}
+	}
+	switch arg := args[0].(type) {
+	case *interpreter.Str:
+		return arg
+	case *interpreter.Instance:
+		if result, ok := callMethod(env, arg, "__str__"); ok {
+			if result.Type() != interpreter.ERR && result.Type() != interpreter.STR {
+				return newException(typeErrorClass, "__str__ returned non-string (type %s)", typeName(result))
+			}
+			return result
+		}
+	}
+	s, err := visit(args[0], env)
+	if err != nil {
+		return err
+	}
+	if err := allocate(int64(len(s)), env); err != nil {
+		return err
+	}
+	return &interpreter.Str{Val: s}
+}
+
+// visit formats item like its Visit method, raising RecursionError if it
+// holds containers nested deeper than the recursion limit leaves room for.
+func visit(item interpreter.Item, env *interpreter.Environment) (string, *interpreter.Error) {
+	s, ok := interpreter.VisitLimit(item, depthLeft(env))
+	if !ok {
+		return "", newException(recursionErrorClass, "maximum recursion depth exceeded while getting the repr of an object")
+	}
+	return s, nil
+}
+
+// repr formats item like interpreter.Repr, raising RecursionError like
+// visit.
+func repr(item interpreter.Item, env *interpreter.Environment) (string, *interpreter.Error) {
+	s, ok := interpreter.ReprLimit(item, depthLeft(env))
+	if !ok {
+		return "", newException(recursionErrorClass, "maximum recursion depth exceeded while getting the repr of an object")
+	}
+	return s, nil
+}
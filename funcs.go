@@ -0,0 +1,101 @@
+package gopy
+
+import (
+	"fmt"
+	"gopy/evaluator"
+	"gopy/interpreter"
+	"reflect"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// RegisterFunc makes the Go function fn a builtin called name in this
+// interpreter. Scripts' arguments are converted to fn's parameter types by
+// interpreter.Unmarshal, raising a TypeError when they don't fit, and its
+// results back to items by interpreter.FromGo:
+// none gives None and several give a tuple. If fn's last result is an
+// error, a non-nil one raises a RuntimeError with its message, as does a
+// panic in fn. Other interpreters may run while fn does.
+func (interp *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func || f.IsNil() {
+		return fmt.Errorf("gopy: RegisterFunc %s: %T is not a function", name, fn)
+	}
+	if !interp.acquire() {
+		return ErrInUse
+	}
+	defer interp.release()
+	interp.env.Builtins()[name] = &interpreter.Builtin{
+		Name: name,
+		Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+			return callFunc(name, f, args)
+		},
+	}
+	return nil
+}
+
+// callFunc calls f with args converted to its parameter types. A panic
+// while converting or calling raises a RuntimeError instead of crashing
+// the host.
+func callFunc(name string, f reflect.Value, args []interpreter.Item) (result interpreter.Item) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = evaluator.NewException("RuntimeError", "%s() panicked: %v", name, r)
+		}
+	}()
+	t := f.Type()
+	params := t.NumIn()
+	if t.IsVariadic() {
+		params--
+		if len(args) < params {
+			return evaluator.NewException("TypeError", "%s() takes at least %d positional arguments but %d were given", name, params, len(args))
+		}
+	} else if len(args) != params {
+		return evaluator.NewException("TypeError", "%s() takes %d positional arguments but %d were given", name, params, len(args))
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var pt reflect.Type
+		if i < params {
+			pt = t.In(i)
+		} else {
+			pt = t.In(params).Elem()
+		}
+		v := reflect.New(pt)
+		if err := interpreter.Unmarshal(arg, v.Interface()); err != nil {
+			return evaluator.NewException("TypeError", "%s() argument %d must be %s, not %s", name, i+1, pt, evaluator.TypeName(arg))
+		}
+		in[i] = v.Elem()
+	}
+	out := f.Call(in)
+	if len(out) > 0 && t.Out(len(out)-1) == errorType {
+		if err := out[len(out)-1]; !err.IsNil() {
+			return evaluator.NewException("RuntimeError", "%s", err.Interface().(error).Error())
+		}
+		out = out[:len(out)-1]
+	}
+	switch len(out) {
+	case 0:
+		return evaluator.NONE
+	case 1:
+		return fromValue(out[0])
+	}
+	elements := make([]interpreter.Item, len(out))
+	for i, v := range out {
+		elements[i] = fromValue(v)
+		if elements[i].Type() == interpreter.ERR {
+			return elements[i]
+		}
+	}
+	return &interpreter.Tuple{Elements: elements}
+}
+
+// fromValue converts a Go result to an item. Values with no item
+// counterpart raise a TypeError.
+func fromValue(v reflect.Value) interpreter.Item {
+	item := interpreter.FromGo(v.Interface())
+	if err, ok := item.(*interpreter.Error); ok && err.Exception == nil {
+		return evaluator.NewException("TypeError", "%s", err.Err)
+	}
+	return item
+}
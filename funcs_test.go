@@ -0,0 +1,145 @@
+package gopy
+
+import (
+	"errors"
+	"fmt"
+	"gopy/interpreter"
+	"strings"
+	"testing"
+)
+
+type user struct {
+	Name string `gopy:"name"`
+	Age int `gopy:"age"`
+}
+
+type ring struct {
+	Next *ring
+}
+
+func TestRegisterFunc(t *testing.T) {
+	interp := New()
+	funcs := map[string]interface{}{
+		"add": func(a, b int) int { return a + b },
+		"scale": func(x float64, by float32) float64 { return x * float64(by) },
+		"upper": strings.ToUpper,
+		"join": func(sep string, parts ...string) string { return strings.Join(parts, sep) },
+		"total": func(counts map[string]int) (sum int) {
+			for _, n := range counts {
+				sum += n
+			}
+			return sum
+		},
+		"split": func(s string) ([]string, int) { return strings.Fields(s), len(s) },
+		"lookup": func(m map[string]int, key string) (int, error) {
+			n, ok := m[key]
+			if !ok {
+				return 0, errors.New("no such key: " + key)
+			}
+			return n, nil
+		},
+		"describe": func(v interface{}) string {
+			switch v := v.(type) {
+			case nil:
+				return "nil"
+			case int64:
+				return "int64"
+			case []interface{}:
+				return "slice of " + string(rune('0'+len(v)))
+			case map[interface{}]interface{}:
+				return "map"
+			}
+			return "other"
+		},
+		"ident": func(item interpreter.Item) interpreter.Item { return item },
+		"counts": func() map[string]int { return map[string]int{"b": 2, "a": 1} },
+		"nothing": func() {},
+		"raw": func(b []byte) int { return len(b) },
+		"newuser": func(name string) *user { return &user{Name: name, Age: 36} },
+		"greet": func(u user) string { return fmt.Sprintf("%s is %d", u.Name, u.Age) },
+		"first": func(xs []int) int { return xs[0] },
+		"fail": func() { panic(errors.New("broken")) },
+		"ring": func() *ring {
+			r := &ring{}
+			r.Next = r
+			return r
+		},
+	}
+	for name, fn := range funcs {
+		if err := interp.RegisterFunc(name, fn); err != nil {
+			t.Fatalf("RegisterFunc(%q) returned %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		input string
+		want string
+	}{
+		{"add(2, 3)", "5"},
+		{"add(True, 1)", "2"},
+		{"scale(2, 1.5)", "3.0"},
+		{"upper(\"go\")", "GO"},
+		{"join(\"-\", \"a\", \"b\", \"c\")", "a-b-c"},
+		{"join(\",\")", ""},
+		{"total({\"x\": 1, \"y\": 2})", "3"},
+		{"split(\"a b\")", "(['a', 'b'], 3)"},
+		{"lookup({\"k\": 7}, \"k\")", "7"},
+		{"describe(None)", "nil"},
+		{"describe(1)", "int64"},
+		{"describe([1, 2])", "slice of 2"},
+		{"describe({(1, 2): 3})", "map"},
+		{"ident([1])", "[1]"},
+		{"counts()", "{'a': 1, 'b': 2}"},
+		{"nothing()", "None"},
+		{"raw(b\"abc\")", "3"},
+		{"raw([1, 2])", "2"},
+		{"newuser(\"ada\").name", "ada"},
+		{"u = newuser(\"ada\")\nu.age += 1\ngreet(u)", "ada is 37"},
+		{"greet({\"name\": \"bo\", \"age\": 3})", "bo is 3"},
+	}
+
+	for _, tt := range tests {
+		got, err := interp.Eval(tt.input)
+		if err != nil {
+			t.Errorf("Eval(%q) returned error %v", tt.input, err)
+			continue
+		}
+		if got.Visit() != tt.want {
+			t.Errorf("Eval(%q); want %s; got %v", tt.input, tt.want, got.Visit())
+		}
+	}
+
+	errTests := []struct {
+		input string
+		want string
+	}{
+		{"add(1)", "TypeError: add() takes 2 positional arguments but 1 were given"},
+		{"add(1, \"2\")", "TypeError: add() argument 2 must be int, not str"},
+		{"join()", "TypeError: join() takes at least 1 positional arguments but 0 were given"},
+		{"raw([256])", "TypeError: raw() argument 1 must be []uint8, not list"},
+		{"lookup({}, \"k\")", "RuntimeError: no such key: k"},
+		{"greet({\"age\": \"old\"})", "TypeError: greet() argument 1 must be gopy.user, not dict"},
+		{"first([])", "RuntimeError: first() panicked: runtime error: index out of range [0] with length 0"},
+		{"fail()", "RuntimeError: fail() panicked: broken"},
+		{"x = []\nx.append(x)\ndescribe(x)", "TypeError: describe() argument 1 must be interface {}, not list"},
+		{"d = {}\nd[1] = [d]\ndescribe(d)", "TypeError: describe() argument 1 must be interface {}, not dict"},
+		{"ring()", "TypeError: cannot convert Go value of type *gopy.ring: it contains itself"},
+	}
+
+	for _, tt := range errTests {
+		_, err := interp.Eval(tt.input)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Eval(%q); want error %s; got %v", tt.input, tt.want, err)
+		}
+	}
+	if got, err := interp.Eval("try:\n    fail()\nexcept RuntimeError:\n    x = \"caught\"\nx"); err != nil || got.Visit() != "caught" {
+		t.Errorf("scripts should be able to handle a Go function's panic; got %v, %v", got, err)
+	}
+
+	if err := interp.RegisterFunc("bad", 1); err == nil {
+		t.Errorf("RegisterFunc of a non-function returned no error")
+	}
+	if _, err := New().Eval("add(1, 2)"); err == nil {
+		t.Errorf("functions registered on one interpreter should not be visible to another")
+	}
+}
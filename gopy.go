@@ -0,0 +1,251 @@
+// Package gopy embeds the gopy interpreter in Go programs, so that they
+// can run scripts without shelling out to the gopy command.
+package gopy
+
+import (
+	"context"
+	"errors"
+	"gopy/evaluator"
+	"gopy/interpreter"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInUse is returned by an interpreter's methods when another call is
+// already using it.
+var ErrInUse = errors.New("gopy: interpreter is already in use")
+
+// Interpreter runs scripts in a global scope of its own, which persists
+// from one call to Eval to the next.
+//
+// An interpreter runs one script at a time: calls made while it is busy,
+// from another goroutine or from a Go function the script called, fail
+// with ErrInUse. Scripts in different interpreters may run at the same
+// time from different goroutines.
+type Interpreter struct {
+	env *interpreter.Environment
+	budget evaluator.Budget
+	caps evaluator.Capabilities
+	noAssert bool
+	maxLoops int
+	argv []string
+	searchPath []string
+	recursionLimit int
+	busy int32
+	// out is 1 while the interpreter is out of the pool that made it.
+	out int32
+}
+
+// Option configures an interpreter made by New.
+type Option func(*Interpreter)
+
+// WithMaxSteps limits each call of Eval to evaluating n AST nodes.
+func WithMaxSteps(n int) Option {
+	return func(interp *Interpreter) {
+		interp.budget.MaxSteps = n
+	}
+}
+
+// WithTimeout limits each call of Eval to running for d.
+func WithTimeout(d time.Duration) Option {
+	return func(interp *Interpreter) {
+		interp.budget.Timeout = d
+	}
+}
+
+// WithMaxMemory limits each call of Eval to allocating about n bytes for
+// strings, bytes, lists and dicts. Going over raises a MemoryError.
+func WithMaxMemory(n int64) Option {
+	return func(interp *Interpreter) {
+		interp.budget.MaxMemory = n
+	}
+}
+
+// WithCapabilities limits scripts to the effects on the host that c
+// allows. Builtins needing any other raise a PermissionError, so passing
+// the zero Capabilities runs scripts with all side effects disabled.
+func WithCapabilities(c evaluator.Capabilities) Option {
+	return func(interp *Interpreter) {
+		interp.caps = c
+	}
+}
+
+// WithoutAssertions makes assert statements no-ops, like running Python
+// with -O.
+func WithoutAssertions() Option {
+	return func(interp *Interpreter) {
+		interp.noAssert = true
+	}
+}
+
+// WithMaxLoopIterations limits each while loop to running its body n
+// times. Going over raises a RuntimeError.
+func WithMaxLoopIterations(n int) Option {
+	return func(interp *Interpreter) {
+		interp.maxLoops = n
+	}
+}
+
+// WithArgv makes sys.argv hold args, the script path followed by its
+// arguments, instead of evaluator.Argv.
+func WithArgv(args ...string) Option {
+	return func(interp *Interpreter) {
+		interp.argv = append([]string{}, args...)
+	}
+}
+
+// WithSearchPath makes imports look for modules in dirs, in order,
+// instead of evaluator.SearchPath.
+func WithSearchPath(dirs ...string) Option {
+	return func(interp *Interpreter) {
+		interp.searchPath = append([]string{}, dirs...)
+	}
+}
+
+// WithRecursionLimit sets how deep calls may nest before raising a
+// RecursionError, instead of evaluator.RecursionLimit. Scripts may still
+// change it with sys.setrecursionlimit.
+func WithRecursionLimit(n int) Option {
+	return func(interp *Interpreter) {
+		interp.recursionLimit = n
+	}
+}
+
+// New returns an interpreter with an empty global scope and the default
+// builtins, configured by opts. A script running out of steps or time
+// stops with a TimeoutError it can't handle. Unless limited, scripts may
+// use files, the network, the environment, standard input and output,
+// other programs and the modules registered with RegisterModule.
+func New(opts ...Option) *Interpreter {
+	interp := &Interpreter{env: evaluator.NewEnv(), caps: evaluator.AllCapabilities}
+	for _, opt := range opts {
+		opt(interp)
+	}
+	evaluator.SetBudget(interp.env, interp.budget)
+	evaluator.SetCapabilities(interp.env, interp.caps)
+	evaluator.SetAssertions(interp.env, !interp.noAssert)
+	evaluator.SetMaxLoopIterations(interp.env, interp.maxLoops)
+	evaluator.SetRecursionLimit(interp.env, interp.recursionLimit)
+	if interp.argv != nil {
+		evaluator.SetArgv(interp.env, interp.argv)
+	}
+	if interp.searchPath != nil {
+		evaluator.SetSearchPath(interp.env, interp.searchPath)
+	}
+	return interp
+}
+
+// acquire marks the interpreter busy, reporting whether it was free.
+func (interp *Interpreter) acquire() bool {
+	return atomic.CompareAndSwapInt32(&interp.busy, 0, 1)
+}
+
+// release marks the interpreter free again, unless it has been retired
+// meanwhile.
+func (interp *Interpreter) release() {
+	atomic.CompareAndSwapInt32(&interp.busy, 1, 0)
+}
+
+// Close stops the goroutines of the generators the interpreter's scripts
+// left unfinished. Using the interpreter afterwards fails with ErrInUse.
+// Close may be called from another goroutine while a script runs.
+func (interp *Interpreter) Close() {
+	atomic.StoreInt32(&interp.busy, retired)
+	evaluator.Close(interp.env)
+}
+
+// SetStdout makes the interpreter's scripts print to w instead of the
+// process's standard output.
+func (interp *Interpreter) SetStdout(w io.Writer) {
+	evaluator.SetStdout(interp.env, w)
+}
+
+// SetStderr makes the interpreter's scripts write sys.stderr to w instead
+// of the process's standard error.
+func (interp *Interpreter) SetStderr(w io.Writer) {
+	evaluator.SetStderr(interp.env, w)
+}
+
+// SetStdin makes the interpreter's scripts read input from r instead of
+// the process's standard input.
+func (interp *Interpreter) SetStdin(r io.Reader) {
+	evaluator.SetStdin(interp.env, r)
+}
+
+// SetHook makes the interpreter call h before each statement it runs, with
+// the statement and the scope it runs in. A nil h removes the hook.
+func (interp *Interpreter) SetHook(h evaluator.Hook) {
+	evaluator.SetHook(interp.env, h)
+}
+
+// Watch calls fn each time the script binds one of names, or any name if
+// none are given, in the interpreter's global scope, including from inside
+// functions declaring it global. fn runs in the middle of evaluation and
+// must not use the interpreter. Calling the returned function stops it.
+// Neither may be called while a script runs, and both fail with ErrInUse
+// if they are.
+func (interp *Interpreter) Watch(fn interpreter.Watcher, names ...string) (stop func() error, err error) {
+	if !interp.acquire() {
+		return nil, ErrInUse
+	}
+	defer interp.release()
+	remove := interp.env.Watch(fn, names...)
+	return func() error {
+		if !interp.acquire() {
+			return ErrInUse
+		}
+		defer interp.release()
+		remove()
+		return nil
+	}, nil
+}
+
+// Snapshot encodes the interpreter's global variables that hold plain
+// data, as Environment.Snapshot describes, so that Restore can bring them
+// back later or in another interpreter.
+func (interp *Interpreter) Snapshot() ([]byte, error) {
+	if !interp.acquire() {
+		return nil, ErrInUse
+	}
+	defer interp.release()
+	return interp.env.Snapshot()
+}
+
+// Restore binds the global variables encoded in data by Snapshot.
+func (interp *Interpreter) Restore(data []byte) error {
+	if !interp.acquire() {
+		return ErrInUse
+	}
+	defer interp.release()
+	return interp.env.Restore(data)
+}
+
+// RegisterModule makes name importable by every interpreter as a module
+// holding the items in mod, so that Go packages can provide modules to
+// scripts. It takes precedence over modules on the filesystem and in the
+// standard library, and should be called from an init function.
+// Interpreters limited by WithCapabilities only import it if AllowModules
+// is set.
+func RegisterModule(name string, mod map[string]interpreter.Item) {
+	evaluator.RegisterModule(name, mod)
+}
+
+// Eval runs src in the interpreter's global scope. It returns the value of
+// the last statement of src if that is an expression, and None otherwise.
+// A syntax error, or an exception the script doesn't handle, is returned
+// as an *Error.
+func (interp *Interpreter) Eval(src string) (interpreter.Item, error) {
+	return interp.EvalContext(context.Background(), src)
+}
+
+// EvalContext runs src like Eval, but stops it once ctx is done, between
+// statements or loop iterations. The script can't handle the
+// KeyboardInterrupt this raises, which is returned like any other.
+func (interp *Interpreter) EvalContext(ctx context.Context, src string) (interpreter.Item, error) {
+	program, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return program.RunContext(ctx, interp)
+}
@@ -0,0 +1,547 @@
+package gopy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"gopy/ast"
+	"gopy/evaluator"
+	"gopy/interpreter"
+	"gopy/parser"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	interp := New()
+	tests := []struct {
+		input string
+		want string
+	}{
+		{"x = 1\nx + 2", "3"},
+		{"x", "1"},
+		{"def double(n):\n    return n * 2", "None"},
+		{"double(x + 4)", "10"},
+		{"y = 5", "None"},
+		{"", "None"},
+	}
+
+	for _, tt := range tests {
+		got, err := interp.Eval(tt.input)
+		if err != nil {
+			t.Errorf("Eval(%q) returned error %v", tt.input, err)
+			continue
+		}
+		if got.Visit() != tt.want {
+			t.Errorf("Eval(%q); want %s; got %v", tt.input, tt.want, got.Visit())
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	interp := New()
+	_, err := interp.Eval("x = 1\nreturn x")
+	syntax, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Eval of a syntax error returned %T; want *Error", err)
+	}
+	if syntax.Kind != KindSyntax || syntax.Type != "SyntaxError" || syntax.Pos.Line != 2 || syntax.Pos.Column != 1 {
+		t.Errorf("Eval of a syntax error returned %+v; want a SyntaxError on line 2", syntax)
+	}
+	if syntax.Message != "'return' outside function" {
+		t.Errorf("Eval of a syntax error returned message %q; want 'return' outside function", syntax.Message)
+	}
+	var parseErr *parser.SyntaxError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("a syntax error should wrap the *parser.SyntaxError")
+	}
+	_, err = interp.Eval("print(x")
+	if eof, ok := err.(*Error); !ok || eof.Pos.Line != 1 || eof.Pos.Column != 8 {
+		t.Errorf("Eval of an unclosed call returned %+v; want a SyntaxError at line 1, column 8", err)
+	}
+
+	_, err = interp.Eval("y = 1\ndef f():\n    return 1 / 0\nf()\ny = 2")
+	exc, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Eval of a failing script returned %T; want *Error", err)
+	}
+	if want := "ZeroDivisionError: division by zero"; exc.Error() != want {
+		t.Errorf("Eval error = %q; want %q", exc.Error(), want)
+	}
+	if exc.Kind != KindRuntime || exc.Type != "ZeroDivisionError" || exc.Message != "division by zero" || exc.Pos.Line != 3 {
+		t.Errorf("Eval of a failing script returned %+v; want a ZeroDivisionError on line 3", exc)
+	}
+	var lines []string
+	for _, entry := range exc.Traceback {
+		lines = append(lines, fmt.Sprintf("%s:%d", entry.Func, entry.Pos.Line))
+	}
+	if got, want := strings.Join(lines, " "), "<module>:4 f:3"; got != want {
+		t.Errorf("traceback = %s; want %s", got, want)
+	}
+	var raised *interpreter.Error
+	if !errors.As(err, &raised) || raised.Exception == nil {
+		t.Errorf("a runtime error should wrap the *interpreter.Error")
+	}
+	if got, _ := interp.Eval("y"); got.Visit() != "1" {
+		t.Errorf("statements after the error ran: y = %s", got.Visit())
+	}
+
+	if _, err := interp.Eval("raise ValueError"); err == nil || err.(*Error).Message != "" {
+		t.Errorf("an exception raised without arguments should have no message; got %v", err)
+	}
+
+	if got, _ := New().Eval("y = 3\ny"); got.Visit() != "3" {
+		t.Errorf("a new interpreter should have its own scope; got y = %s", got.Visit())
+	}
+
+	for _, src := range []string{"x = \"", "\"", "print(\"", "b\"", "for 0%"} {
+		if _, err := interp.Eval(src); err == nil || err.(*Error).Kind != KindSyntax {
+			t.Errorf("Eval(%q) returned %v; want a syntax *Error", src, err)
+		}
+		if _, err := Compile(src); err == nil || err.(*Error).Kind != KindSyntax {
+			t.Errorf("Compile(%q) returned %v; want a syntax *Error", src, err)
+		}
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	interp := New()
+	scripts := []string{
+		"while True:\n    pass",
+		"n = 0\nwhile True:\n    try:\n        n += 1\n    except BaseException:\n        pass",
+		"def spin():\n    while True:\n        pass\ntry:\n    spin()\nfinally:\n    done = True",
+		"import time\ntime.sleep(60)",
+		"5 in map(abs, range(-10**10, 0))",
+		"0.5 in range(10**12)",
+		"sum(range(10**12))",
+		"max(filter(None, range(0, -10**12, -1)))",
+	}
+
+	for _, src := range scripts {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		start := time.Now()
+		_, err := interp.EvalContext(ctx, src)
+		cancel()
+		if err == nil || err.Error() != "KeyboardInterrupt: context deadline exceeded" {
+			t.Errorf("EvalContext(%q) returned %v; want the deadline to stop it", src, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("EvalContext(%q) took %v to stop", src, elapsed)
+		}
+	}
+
+	if got, err := interp.Eval("1 + 1"); err != nil || got.Visit() != "2" {
+		t.Errorf("Eval after a cancelled EvalContext = %v, %v; want 2", got, err)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	steps := New(WithMaxSteps(200))
+	if got, err := steps.Eval("x = 0\nfor i in range(5):\n    x += i\nx"); err != nil || got.Visit() != "10" {
+		t.Errorf("Eval within the step budget = %v, %v; want 10", got, err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := steps.Eval("x = 0\nfor i in range(5):\n    x += i\nx"); err != nil {
+			t.Errorf("the step budget should restart on each Eval; run %d failed with %v", i, err)
+		}
+	}
+	_, err := steps.Eval("while True:\n    try:\n        pass\n    except BaseException:\n        pass")
+	if err == nil || err.Error() != "TimeoutError: evaluation exceeded 200 steps" {
+		t.Errorf("Eval of an endless loop returned %v; want the step budget to stop it", err)
+	}
+	for _, src := range []string{"sum(range(10**12))", "sorted(range(100000, 0, -1))", "sorted(bytes(1000))", "5 in map(abs, range(-10**10, 0))"} {
+		if _, err := steps.Eval(src); err == nil || err.Error() != "TimeoutError: evaluation exceeded 200 steps" {
+			t.Errorf("Eval(%q) returned %v; want the step budget to stop it", src, err)
+		}
+	}
+
+	timed := New(WithTimeout(20 * time.Millisecond))
+	for _, src := range []string{"while True:\n    pass", "import time\ntime.sleep(60)", "sum(range(10**12))", "sorted(\"dcba\" * 500000)"} {
+		start := time.Now()
+		_, err := timed.Eval(src)
+		if err == nil || err.Error() != "TimeoutError: evaluation exceeded its time limit of 20ms" {
+			t.Errorf("Eval(%q) returned %v; want the timeout to stop it", src, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("Eval(%q) took %v to time out", src, elapsed)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := timed.EvalContext(ctx, "x = 1"); err == nil || err.Error() != "KeyboardInterrupt: context canceled" {
+		t.Errorf("EvalContext with a cancelled context returned %v; want KeyboardInterrupt", err)
+	}
+}
+
+func TestMaxMemory(t *testing.T) {
+	interp := New(WithMaxMemory(1 << 16))
+	if got, err := interp.Eval("s = \"ab\" * 100\nl = []\nfor i in range(100):\n    l.append(s)\nlen(\"\".join(l))"); err != nil || got.Visit() != "20000" {
+		t.Errorf("Eval within the memory limit = %v, %v; want 20000", got, err)
+	}
+
+	scripts := []string{
+		"s = \"x\"\nwhile True:\n    s = s + s",
+		"l = []\nwhile True:\n    l.append(1)",
+		"d = {}\ni = 0\nwhile True:\n    d[i] = i\n    i += 1",
+		"\"x\" * 1000000000000",
+		"bytes(1 << 40)",
+		"list(range(1 << 40))",
+		"\"-\".join(map(str, range(20000)))",
+		"list(zip(range(1 << 40), range(1 << 40)))",
+		"tuple(map(str, range(1 << 40)))",
+		"set(zip(range(1 << 40)))",
+		"d = {}\nd.update(zip(range(1 << 40), range(1 << 40)))",
+		"\"{:>100000000}\".format(1)",
+		"format(1.5, \".100000000f\")",
+		"\"%100000000d\" % 1",
+		"\"%*s\" % (100000000, \"x\")",
+		"import json\njson.dumps([1, 2], indent=100000000)",
+		"import json\nl = [1]\nfor i in range(2000):\n    l = [l]\njson.dumps(l, indent=\"          \")",
+		"l = list(range(1000))\nm = []\nwhile True:\n    m.append(l.copy())",
+		"s = \"x\"\nwhile True:\n    s = \"{}{}\".format(s, s)",
+		"s = \"x\"\nwhile True:\n    s = \"%s%s\" % (s, s)",
+		"import re\ns = \"x\"\nwhile True:\n    s = re.sub(\"$\", s, s)",
+		"import re\ns = \"x\" * 1000\nre.sub(\"\", s, s)",
+		"(\"a\" * 40000).upper()",
+		"(\"A\" * 40000).lower()",
+		"(\"a \" * 10000).split()",
+		"import re\nre.findall(\"a\", \"a\" * 10000)",
+		"import re\nre.split(\"a\", \"a\" * 10000)",
+		"str(list(range(3500)))",
+	}
+	for _, src := range scripts {
+		_, err := interp.Eval(src)
+		if err == nil || err.Error() != "MemoryError: evaluation exceeded its memory limit of 65536 bytes" {
+			t.Errorf("Eval(%q) returned %v; want a MemoryError", src, err)
+		}
+	}
+
+	lazy := []struct {
+		input string
+		want string
+	}{
+		{"sum(range(100000))", "4999950000"},
+		{"min(range(100000, 0, -1))", "1"},
+		{"max(map(abs, range(-100000, 1)))", "100000"},
+		{"def neg(x):\n    return -x\nmax(range(100000), key=neg)", "0"},
+		{"99999 in map(abs, range(100000))", "True"},
+		{"try:\n    a, b = range(1 << 60)\nexcept ValueError as e:\n    x = str(e)\nx", "too many values to unpack (expected 2)"},
+	}
+	for _, tt := range lazy {
+		if got, err := interp.Eval(tt.input); err != nil || got.Visit() != tt.want {
+			t.Errorf("Eval(%q) = %v, %v; want %s without holding every item", tt.input, got, err, tt.want)
+		}
+	}
+
+	got, err := interp.Eval("try:\n    x = \"x\" * 100000\nexcept MemoryError:\n    x = \"caught\"\nx")
+	if err != nil || got.Visit() != "caught" {
+		t.Errorf("scripts should be able to handle MemoryError; got %v, %v", got, err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "helper.py"), []byte("x = 1\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	full := New(WithSearchPath(dir))
+	for _, src := range []string{"import os\nos.path.isdir(\".\")", "import helper\nhelper.x == 1"} {
+		if got, err := full.Eval(src); err != nil || got.Visit() != "True" {
+			t.Errorf("Eval(%q) without limits = %v, %v; want True", src, got, err)
+		}
+	}
+
+	sandbox := New(WithCapabilities(evaluator.Capabilities{}), WithSearchPath(dir))
+	tests := []struct {
+		input string
+		want string
+	}{
+		{"open(\"x\", \"w\")", "PermissionError: open() is disabled in this interpreter"},
+		{"import os\nos.getenv(\"HOME\")", "PermissionError: os.getenv() is disabled in this interpreter"},
+		{"import os\nos.listdir()", "PermissionError: os.listdir() is disabled in this interpreter"},
+		{"import os\nos.path.exists(\".\")", "PermissionError: os.path.exists() is disabled in this interpreter"},
+		{"import os\nlen(os.environ)", "0"},
+		{"import os\nos.path.join(\"a\", \"b\")", "a" + string(os.PathSeparator) + "b"},
+		{"import helper", "ModuleNotFoundError: No module named 'helper'"},
+		{"try:\n    open(\"x\")\nexcept PermissionError:\n    x = \"caught\"\nx", "caught"},
+		{"input()", "PermissionError: input() is disabled in this interpreter"},
+		{"import sys\nsys.stdin.readline()", "PermissionError: sys.stdin.readline() is disabled in this interpreter"},
+		{"import sys\nsys.stdout.write(\"x\")", "PermissionError: sys.stdout.write() is disabled in this interpreter"},
+		{"import sys\nsys.stderr.write(\"x\")", "PermissionError: sys.stderr.write() is disabled in this interpreter"},
+		{"import sys\nsys.argv", "[]"},
+		{"import sys\nsys.getrecursionlimit() > 0", "True"},
+		{"import math\nmath.floor(2.5)", "2"},
+	}
+	for _, tt := range tests {
+		got, err := sandbox.Eval(tt.input)
+		if err != nil {
+			if err.Error() != tt.want {
+				t.Errorf("Eval(%q) in the sandbox returned %v; want %s", tt.input, err, tt.want)
+			}
+		} else if got.Visit() != tt.want {
+			t.Errorf("Eval(%q) in the sandbox = %s; want %s", tt.input, got.Visit(), tt.want)
+		}
+	}
+
+	var out bytes.Buffer
+	stdio := New(WithCapabilities(evaluator.Capabilities{AllowStdio: true}))
+	stdio.SetStdout(&out)
+	stdio.SetStdin(strings.NewReader("line\n"))
+	if got, err := stdio.Eval("import sys\nsys.stdout.write(\"out\")\ninput()"); err != nil || got.Visit() != "line" || out.String() != "out" {
+		t.Errorf("stdio with AllowStdio = %v, %v and printed %q; want line and out", got, err, out.String())
+	}
+
+	env := New(WithCapabilities(evaluator.Capabilities{AllowEnv: true}))
+	if got, err := env.Eval("import os\nos.getenv(\"GOPY_UNSET_VARIABLE\", \"none\")"); err != nil || got.Visit() != "none" {
+		t.Errorf("os.getenv with AllowEnv = %v, %v; want none", got, err)
+	}
+}
+
+func TestWithoutAssertions(t *testing.T) {
+	if got, err := New(WithoutAssertions()).Eval("assert False, \"x\"\n1"); err != nil || got.Visit() != "1" {
+		t.Errorf("Eval with assertions disabled = %v, %v; want 1", got, err)
+	}
+	if _, err := New().Eval("assert False, \"x\""); err == nil || err.Error() != "AssertionError: x" {
+		t.Errorf("Eval with assertions enabled returned %v; want AssertionError: x", err)
+	}
+}
+
+func TestWithMaxLoopIterations(t *testing.T) {
+	_, err := New(WithMaxLoopIterations(3)).Eval("while True:\n    pass")
+	if err == nil || err.Error() != "RuntimeError: while loop exceeded 3 iterations" {
+		t.Errorf("Eval of an endless loop returned %v; want the iteration limit to stop it", err)
+	}
+	if got, err := New().Eval("i = 0\nwhile i < 5:\n    i += 1\ni"); err != nil || got.Visit() != "5" {
+		t.Errorf("Eval without an iteration limit = %v, %v; want 5", got, err)
+	}
+}
+
+func TestWithArgv(t *testing.T) {
+	interp := New(WithArgv("job.py", "-n", "3"), WithRecursionLimit(40))
+	got, err := interp.Eval("import sys\n(sys.argv, sys.getrecursionlimit())")
+	if err != nil || got.Visit() != "(['job.py', '-n', '3'], 40)" {
+		t.Errorf("Eval with its own argv and recursion limit = %v, %v", got, err)
+	}
+	if got, err := New().Eval("import sys\nsys.getrecursionlimit()"); err != nil || got.Visit() != "1000" {
+		t.Errorf("Eval in another interpreter = %v, %v; want the default recursion limit", got, err)
+	}
+}
+
+func TestStreams(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	interp := New()
+	interp.SetStdout(&stdout)
+	interp.SetStderr(&stderr)
+	interp.SetStdin(strings.NewReader("Ada\nrest\nof it"))
+
+	src := "import sys\nname = input(\"name? \")\nprint(\"hello\", name)\nsys.stderr.write(\"warning\n\")\n(sys.stdin.readline(), sys.stdin.read())"
+	got, err := interp.Eval(src)
+	if err != nil || got.Visit() != "('rest\n', 'of it')" {
+		t.Errorf("Eval reading stdin = %v, %v; want ('rest\\n', 'of it')", got, err)
+	}
+	if want := "name? hello Ada\n"; stdout.String() != want {
+		t.Errorf("stdout = %q; want %q", stdout.String(), want)
+	}
+	if want := "warning\n"; stderr.String() != want {
+		t.Errorf("stderr = %q; want %q", stderr.String(), want)
+	}
+
+	var other bytes.Buffer
+	New().SetStdout(&other)
+	if _, err := interp.Eval("print(\"again\")"); err != nil || stdout.String() != "name? hello Ada\nagain\n" {
+		t.Errorf("a second interpreter's stdout should not affect the first; stdout = %q, err = %v", stdout.String(), err)
+	}
+	if _, err := interp.Eval("input()"); err == nil || err.Error() != "EOFError: EOF when reading a line" {
+		t.Errorf("input() at the end of stdin returned %v; want EOFError", err)
+	}
+}
+
+func TestHook(t *testing.T) {
+	interp := New()
+	var lines []int
+	var seen []string
+	interp.SetHook(func(node ast.Node, env *interpreter.Environment) {
+		lines = append(lines, node.Pos().Line)
+		if n, ok := env.Get("n"); ok {
+			seen = append(seen, n.Visit())
+		}
+	})
+	if _, err := interp.Eval("def f(n):\n    return n * 2\nx = f(1)\nx = f(x)"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(lines), "[1 3 2 4 2]"; got != want {
+		t.Errorf("hooked lines = %s; want %s", got, want)
+	}
+	if got, want := fmt.Sprint(seen), "[1 2]"; got != want {
+		t.Errorf("values of n seen by the hook = %s; want %s", got, want)
+	}
+
+	interp.SetHook(nil)
+	lines = nil
+	if _, err := interp.Eval("x = 1"); err != nil || lines != nil {
+		t.Errorf("Eval after removing the hook = %v, called it for lines %v", err, lines)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	first := New()
+	if _, err := first.Eval("import math\ncounts = {\"a\": 1}\nnames = [\"x\", \"y\"]\ndef f():\n    return 1"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := first.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second := New()
+	if err := second.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+	got, err := second.Eval("counts[\"a\"] += 1\n(counts, names)")
+	if err != nil || got.Visit() != "({'a': 2}, ['x', 'y'])" {
+		t.Errorf("Eval after Restore = %v, %v; want ({'a': 2}, ['x', 'y'])", got, err)
+	}
+	if _, err := second.Eval("f"); err == nil || err.Error() != "NameError: name 'f' is not defined" {
+		t.Errorf("functions should be left out of snapshots; Eval(\"f\") returned %v", err)
+	}
+}
+
+func TestRegisterModule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "greeting.py"), []byte("source = True\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	hello := &interpreter.Builtin{Fn: func(env *interpreter.Environment, args ...interpreter.Item) interpreter.Item {
+		if len(args) != 1 {
+			return evaluator.NewException("TypeError", "hello() takes exactly one argument (%d given)", len(args))
+		}
+		return &interpreter.Str{Val: "hello, " + args[0].Visit()}
+	}}
+	RegisterModule("greeting", map[string]interpreter.Item{
+		"hello": hello,
+		"hi": hello,
+		"version": &interpreter.Int{Val: 2},
+	})
+
+	tests := []struct {
+		input string
+		want string
+	}{
+		{"import greeting\ngreeting.hello(\"ada\")", "hello, ada"},
+		{"from greeting import hello, version\nhello(version)", "hello, 2"},
+		{"import greeting\ngreeting.source", "AttributeError: module 'greeting' has no attribute 'source'"},
+		{"import greeting\ngreeting.hello()", "TypeError: hello() takes exactly one argument (0 given)"},
+	}
+	for _, tt := range tests {
+		got, err := New(WithSearchPath(dir)).Eval(tt.input)
+		if err != nil {
+			if err.Error() != tt.want {
+				t.Errorf("Eval(%q) returned %v; want %s", tt.input, err, tt.want)
+			}
+		} else if got.Visit() != tt.want {
+			t.Errorf("Eval(%q) = %s; want %s", tt.input, got.Visit(), tt.want)
+		}
+	}
+
+	got, err := New(WithSearchPath(dir)).Eval("import greeting\ngreeting.hi")
+	if b, ok := got.(*interpreter.Builtin); err != nil || !ok || b.Name != "hi" {
+		t.Errorf("greeting.hi = %v, %v; want the builtin named hi", got, err)
+	}
+	if hello.Name != "" {
+		t.Errorf("importing the module renamed the registered builtin to %q", hello.Name)
+	}
+
+	sandbox := New(WithCapabilities(evaluator.Capabilities{}))
+	if _, err := sandbox.Eval("import greeting"); err == nil || err.Error() != "PermissionError: import of module 'greeting' is disabled in this interpreter" {
+		t.Errorf("importing a registered module in the sandbox returned %v; want a PermissionError", err)
+	}
+	allowed := New(WithCapabilities(evaluator.Capabilities{AllowModules: true}))
+	if got, err := allowed.Eval("import greeting\ngreeting.hello(\"ada\")"); err != nil || got.Visit() != "hello, ada" {
+		t.Errorf("importing a registered module with AllowModules = %v, %v; want hello, ada", got, err)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	program, err := Compile("total = 0\nfor n in items:\n    total += n\ntotal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, items := range [][]int{{1, 2}, {3}, {}} {
+		interp := New()
+		interp.env.Store("items", interpreter.FromGo(items))
+		got, err := program.Run(interp)
+		want := 0
+		for _, n := range items {
+			want += n
+		}
+		if err != nil || got.Visit() != fmt.Sprint(want) {
+			t.Errorf("run %d = %v, %v; want %d", i, got, err, want)
+		}
+	}
+
+	interp := New()
+	if _, err := program.Run(interp); err == nil || err.Error() != "NameError: name 'items' is not defined" {
+		t.Errorf("Run in a fresh interpreter returned %v; want a NameError", err)
+	}
+	if _, err := Compile("def"); err == nil || err.(*Error).Kind != KindSyntax {
+		t.Errorf("Compile of a syntax error returned %v; want a syntax *Error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	loop, _ := Compile("while True:\n    pass")
+	if _, err := loop.RunContext(ctx, interp); err == nil || err.Error() != "KeyboardInterrupt: context canceled" {
+		t.Errorf("RunContext with a cancelled context returned %v; want KeyboardInterrupt", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	interp := New()
+	var changes []string
+	stop, err := interp.Watch(func(name string, val interpreter.Item) {
+		changes = append(changes, name+"="+val.Visit())
+	}, "level", "retries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := "level = \"info\"\nother = 1\ndef bump():\n    global retries\n    retries = 3\n    level = \"local\"\nbump()\nlevel = \"debug\""
+	if _, err := interp.Eval(src); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(changes, " "), "level=info retries=3 level=debug"; got != want {
+		t.Errorf("watched changes = %s; want %s", got, want)
+	}
+	var inner, innerStop error
+	if err := interp.RegisterFunc("watch", func() {
+		_, inner = interp.Watch(func(string, interpreter.Item) {})
+		innerStop = stop()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interp.Eval("watch()"); err != nil {
+		t.Fatal(err)
+	}
+	if inner != ErrInUse || innerStop != ErrInUse {
+		t.Errorf("Watch and stop while a script runs returned %v, %v; want ErrInUse", inner, innerStop)
+	}
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interp.Eval("level = \"warn\""); err != nil || len(changes) != 3 {
+		t.Errorf("the watcher was called after being stopped: %v, %v", changes, err)
+	}
+}
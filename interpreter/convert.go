@@ -0,0 +1,473 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+func (e *Error) ToGo() interface{} { return e }
+func (i *Int) ToGo() interface{} { return i.Val }
+func (f *Float) ToGo() interface{} { return f.Val }
+func (s *Str) ToGo() interface{} { return s.Val }
+func (b *Bytes) ToGo() interface{} { return append([]byte{}, b.Val...) }
+func (b *Bool) ToGo() interface{} { return b.Val }
+func (n *None) ToGo() interface{} { return nil }
+func (rv *ReturnValue) ToGo() interface{} { return rv.Value.ToGo() }
+func (f *Function) ToGo() interface{} { return f }
+func (c *Class) ToGo() interface{} { return c }
+func (t *Type) ToGo() interface{} { return t }
+func (i *Instance) ToGo() interface{} { return i }
+func (bm *BoundMethod) ToGo() interface{} { return bm }
+func (it *Iterator) ToGo() interface{} { return it }
+func (f *File) ToGo() interface{} { return f }
+func (p *Pattern) ToGo() interface{} { return p }
+func (m *Match) ToGo() interface{} { return m }
+func (s *Super) ToGo() interface{} { return s }
+func (m *Module) ToGo() interface{} { return m }
+func (g *Generator) ToGo() interface{} { return g }
+func (b *Builtin) ToGo() interface{} { return b }
+func (b *Break) ToGo() interface{} { return b }
+func (c *Continue) ToGo() interface{} { return c }
+func (l *List) ToGo() interface{} { return containerToGo(l) }
+func (t *Tuple) ToGo() interface{} { return containerToGo(t) }
+func (s *Slice) ToGo() interface{} { return s }
+func (r *Range) ToGo() interface{} { return r }
+func (s *Set) ToGo() interface{} { return containerToGo(s) }
+
+// ToGo returns the dict as a map. Tuple keys stay items, since the slices
+// they would become can't be map keys, and bytes keys become strings.
+func (d *Dict) ToGo() interface{} { return containerToGo(d) }
+
+// containerToGo returns the Go value for a list, tuple, set or dict, or an
+// *Error if it contains itself.
+func containerToGo(item Item) interface{} {
+	v, err := toGo(item, map[Item]bool{})
+	if err != nil {
+		return &Error{Err: err.Error()}
+	}
+	return v
+}
+
+// toGo returns what item.ToGo does, failing instead of recursing forever
+// on containers that contain themselves. seen holds the containers being
+// converted.
+func toGo(item Item, seen map[Item]bool) (interface{}, error) {
+	switch item.(type) {
+	case *List, *Tuple, *Set, *Dict:
+	default:
+		return item.ToGo(), nil
+	}
+	if err := enter(item, seen); err != nil {
+		return nil, err
+	}
+	defer delete(seen, item)
+	switch item := item.(type) {
+	case *List:
+		return toGoSlice(item.Elements, seen)
+	case *Tuple:
+		return toGoSlice(item.Elements, seen)
+	case *Set:
+		return toGoSlice(item.Elements(), seen)
+	}
+	d := item.(*Dict)
+	m := make(map[interface{}]interface{}, len(d.Keys))
+	for _, key := range d.Keys {
+		pair := d.Pairs[key]
+		var k interface{}
+		switch key := pair.Key.(type) {
+		case *Tuple:
+			k = key
+		case *Bytes:
+			k = string(key.Val)
+		default:
+			k = key.ToGo()
+		}
+		v, err := toGo(pair.Value, seen)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+func toGoSlice(items []Item, seen map[Item]bool) ([]interface{}, error) {
+	values := make([]interface{}, len(items))
+	for i, item := range items {
+		v, err := toGo(item, seen)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// enter adds the container item to those being converted, failing if it
+// already is one, which means it contains itself.
+func enter(item Item, seen map[Item]bool) error {
+	if seen[item] {
+		return fmt.Errorf("cannot convert %s that contains itself", strings.ToLower(string(item.Type())))
+	}
+	seen[item] = true
+	return nil
+}
+
+// FromGo returns the item standing for the Go value v: nil becomes None,
+// booleans, numbers and strings the matching scalars, []byte bytes, other
+// slices and arrays lists, and maps dicts, with string and number keys in
+// sorted order. A struct becomes an instance whose attributes are its
+// exported fields, named by their gopy tags if they have one; fields
+// tagged "-" are left out. Items are returned as they are and pointers
+// are followed. A value with no counterpart, or holding one, gives an
+// *Error, as does a value that contains itself.
+func FromGo(v interface{}) Item {
+	if v == nil {
+		return NoneValue
+	}
+	return fromValue(reflect.ValueOf(v), map[goRef]bool{})
+}
+
+// goRef identifies a Go pointer, map or slice being converted, so that
+// FromGo can tell when a value leads back to itself. Slices sharing an
+// array but of different lengths are different values, as are pointers to
+// a struct and to its first field.
+type goRef struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// fromValue converts v to an item. seen holds the pointers, maps and
+// slices being converted.
+func fromValue(v reflect.Value, seen map[goRef]bool) Item {
+	if !v.IsValid() {
+		return NoneValue
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return NoneValue
+		}
+	}
+	if item, ok := v.Interface().(Item); ok {
+		return item
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		key := goRef{v.Pointer(), v.Type(), 0}
+		if v.Kind() == reflect.Slice {
+			key.len = v.Len()
+		}
+		if seen[key] {
+			return &Error{Err: fmt.Sprintf("cannot convert Go value of type %s: it contains itself", v.Type())}
+		}
+		seen[key] = true
+		defer delete(seen, key)
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return TrueValue
+		}
+		return FalseValue
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Int{Val: v.Int()}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return &Error{Err: fmt.Sprintf("cannot convert Go value %d of type %s: too large for int", v.Uint(), v.Type())}
+		}
+		return &Int{Val: int64(v.Uint())}
+	case reflect.Float32, reflect.Float64:
+		return &Float{Val: v.Float()}
+	case reflect.String:
+		return &Str{Val: v.String()}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return &Bytes{Val: b}
+		}
+		elements := make([]Item, v.Len())
+		for i := range elements {
+			elements[i] = fromValue(v.Index(i), seen)
+			if elements[i].Type() == ERR {
+				return elements[i]
+			}
+		}
+		return &List{Elements: elements}
+	case reflect.Map:
+		dict := NewDict()
+		for _, k := range sortedKeys(v) {
+			key := fromValue(k, seen)
+			if key.Type() == ERR {
+				return key
+			}
+			hash, ok := Hash(key)
+			if !ok {
+				return &Error{Err: fmt.Sprintf("cannot use Go value of type %s as a dict key", k.Type())}
+			}
+			val := fromValue(v.MapIndex(k), seen)
+			if val.Type() == ERR {
+				return val
+			}
+			slot, _, _ := dict.Find(hash, key, nil)
+			dict.Set(slot, key, val)
+		}
+		return dict
+	case reflect.Struct:
+		return fromStruct(v, seen)
+	case reflect.Ptr, reflect.Interface:
+		return fromValue(v.Elem(), seen)
+	}
+	return &Error{Err: fmt.Sprintf("cannot convert Go value of type %s", v.Type())}
+}
+
+// fromStruct returns an instance holding the fields of the struct v, of a
+// class named after its type.
+func fromStruct(v reflect.Value, seen map[goRef]bool) Item {
+	t := v.Type()
+	name := t.Name()
+	if name == "" {
+		name = "object"
+	}
+	instance := &Instance{Class: &Class{Name: name, Attrs: map[string]Item{}}, Attrs: map[string]Item{}}
+	for i := 0; i < t.NumField(); i++ {
+		attr := fieldName(t.Field(i))
+		if attr == "" {
+			continue
+		}
+		val := fromValue(v.Field(i), seen)
+		if val.Type() == ERR {
+			return val
+		}
+		instance.Attrs[attr] = val
+	}
+	return instance
+}
+
+// fieldName returns the name scripts know the struct field f by, or "" if
+// it is unexported or tagged "-".
+func fieldName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	switch tag := f.Tag.Get("gopy"); tag {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	default:
+		return tag
+	}
+}
+
+// sortedKeys returns the keys of the map v, sorted when they are strings or
+// numbers so that dicts built from Go maps have a predictable order.
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	switch v.Type().Key().Kind() {
+	case reflect.String:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Int() < keys[j].Int() })
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Uint() < keys[j].Uint() })
+	case reflect.Float32, reflect.Float64:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Float() < keys[j].Float() })
+	}
+	return keys
+}
+
+// Unmarshal stores item in the value v points to, converting it to v's
+// type. It reverses FromGo: ints and bools fill integers, ints and floats
+// fill floats, lists and tuples fill slices, dicts fill maps, and a dict
+// with str keys or an instance fills a struct, setting the fields named
+// like its keys or attributes and leaving the others as they are. None
+// fills pointers, slices and maps with nil. An empty interface receives
+// what ToGo returns, and an interface that items implement the item
+// itself. A container that contains itself gives an error.
+func Unmarshal(item Item, v interface{}) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("cannot unmarshal into non-pointer %T", v)
+	}
+	return unmarshal(item, ptr.Elem(), map[Item]bool{})
+}
+
+// unmarshal stores item in the settable value v. seen holds the containers
+// being unmarshalled.
+func unmarshal(item Item, v reflect.Value, seen map[Item]bool) error {
+	t := v.Type()
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		if item.Type() == NONE {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		val, err := toGo(item, seen)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(val))
+		return nil
+	}
+	if reflect.TypeOf(item).AssignableTo(t) {
+		v.Set(reflect.ValueOf(item))
+		return nil
+	}
+	if item.Type() == NONE {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := item.(*Bool); ok {
+			v.SetBool(b.Val)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := intVal(item); ok && !v.OverflowInt(n) {
+			v.SetInt(n)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n, ok := intVal(item); ok && n >= 0 && !v.OverflowUint(uint64(n)) {
+			v.SetUint(uint64(n))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := item.(type) {
+		case *Float:
+			v.SetFloat(n.Val)
+			return nil
+		case *Int:
+			v.SetFloat(float64(n.Val))
+			return nil
+		}
+	case reflect.String:
+		if s, ok := item.(*Str); ok {
+			v.SetString(s.Val)
+			return nil
+		}
+	case reflect.Slice:
+		if b, ok := item.(*Bytes); ok && t.Elem().Kind() == reflect.Uint8 {
+			v.Set(reflect.MakeSlice(t, len(b.Val), len(b.Val)))
+			reflect.Copy(v, reflect.ValueOf(b.Val))
+			return nil
+		}
+		var elements []Item
+		switch seq := item.(type) {
+		case *List:
+			elements = seq.Elements
+		case *Tuple:
+			elements = seq.Elements
+		default:
+			return unmarshalError(item, t)
+		}
+		if err := enter(item, seen); err != nil {
+			return err
+		}
+		defer delete(seen, item)
+		v.Set(reflect.MakeSlice(t, len(elements), len(elements)))
+		for i, element := range elements {
+			if err := unmarshal(element, v.Index(i), seen); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		dict, ok := item.(*Dict)
+		if !ok {
+			break
+		}
+		if err := enter(item, seen); err != nil {
+			return err
+		}
+		defer delete(seen, item)
+		v.Set(reflect.MakeMapWithSize(t, len(dict.Keys)))
+		for _, key := range dict.Keys {
+			pair := dict.Pairs[key]
+			kv := reflect.New(t.Key()).Elem()
+			if err := unmarshal(pair.Key, kv, seen); err != nil {
+				return err
+			}
+			ev := reflect.New(t.Elem()).Elem()
+			if err := unmarshal(pair.Value, ev, seen); err != nil {
+				return err
+			}
+			v.SetMapIndex(kv, ev)
+		}
+		return nil
+	case reflect.Struct:
+		attrs, ok := structAttrs(item)
+		if !ok {
+			break
+		}
+		if err := enter(item, seen); err != nil {
+			return err
+		}
+		defer delete(seen, item)
+		for i := 0; i < t.NumField(); i++ {
+			name := fieldName(t.Field(i))
+			attr, ok := attrs[name]
+			if name == "" || !ok {
+				continue
+			}
+			if err := unmarshal(attr, v.Field(i), seen); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Ptr:
+		elem := reflect.New(t.Elem())
+		if err := unmarshal(item, elem.Elem(), seen); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	return unmarshalError(item, t)
+}
+
+// structAttrs returns the attributes of an instance, or the entries with
+// str keys of a dict, by name.
+func structAttrs(item Item) (map[string]Item, bool) {
+	switch item := item.(type) {
+	case *Instance:
+		return item.Attrs, true
+	case *Dict:
+		attrs := make(map[string]Item, len(item.Keys))
+		for _, key := range item.Keys {
+			pair := item.Pairs[key]
+			if name, ok := pair.Key.(*Str); ok {
+				attrs[name.Val] = pair.Value
+			}
+		}
+		return attrs, true
+	}
+	return nil, false
+}
+
+// intVal returns the value of an int or bool item.
+func intVal(item Item) (int64, bool) {
+	switch n := item.(type) {
+	case *Int:
+		return n.Val, true
+	case *Bool:
+		if n.Val {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func unmarshalError(item Item, t reflect.Type) error {
+	return fmt.Errorf("cannot unmarshal %s into Go value of type %s", strings.ToLower(string(item.Type())), t)
+}
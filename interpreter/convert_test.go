@@ -0,0 +1,221 @@
+package interpreter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFromGo(t *testing.T) {
+	var nilPtr *int
+	n := 7
+	tests := []struct {
+		input interface{}
+		want string
+	}{
+		{nil, "None"},
+		{true, "True"},
+		{42, "42"},
+		{uint8(255), "255"},
+		{2.5, "2.5"},
+		{float32(0.5), "0.5"},
+		{"go", "go"},
+		{[]byte("ab"), "b'ab'"},
+		{[]int{1, 2}, "[1, 2]"},
+		{[2]string{"a", "b"}, "['a', 'b']"},
+		{[]interface{}{1, "x", nil, []bool{true}}, "[1, 'x', None, [True]]"},
+		{map[string]int{"b": 2, "a": 1}, "{'a': 1, 'b': 2}"},
+		{map[int][]string{2: {"y"}, 1: nil}, "{1: None, 2: ['y']}"},
+		{nilPtr, "None"},
+		{&n, "7"},
+		{&Int{Val: 3}, "3"},
+		{[]Item{&Str{Val: "s"}}, "['s']"},
+		{func() {}, "cannot convert Go value of type func()"},
+		{[]interface{}{1, make(chan int)}, "cannot convert Go value of type chan int"},
+		{map[[1]int]int{{1}: 1}, "cannot use Go value of type [1]int as a dict key"},
+		{uint64(1 << 63 - 1), "9223372036854775807"},
+		{uint64(1 << 63), "cannot convert Go value 9223372036854775808 of type uint64: too large for int"},
+		{[]uint64{1 << 64 - 1}, "cannot convert Go value 18446744073709551615 of type uint64: too large for int"},
+	}
+
+	for _, tt := range tests {
+		if got := FromGo(tt.input); got.Visit() != tt.want {
+			t.Errorf("FromGo(%#v) = %s; want %s", tt.input, got.Visit(), tt.want)
+		}
+	}
+	if FromGo(nil) != NoneValue || FromGo(false) != FalseValue {
+		t.Errorf("FromGo should return the None and bool singletons")
+	}
+}
+
+type node struct {
+	Val int
+	Next *node
+}
+
+func TestFromGoCycle(t *testing.T) {
+	n := &node{Val: 1}
+	n.Next = n
+	m := map[string]interface{}{}
+	m["self"] = m
+	s := []interface{}{nil}
+	s[0] = s
+	tests := []struct {
+		input interface{}
+		want string
+	}{
+		{n, "cannot convert Go value of type *interpreter.node: it contains itself"},
+		{m, "cannot convert Go value of type map[string]interface {}: it contains itself"},
+		{s, "cannot convert Go value of type []interface {}: it contains itself"},
+	}
+	for _, tt := range tests {
+		if got, ok := FromGo(tt.input).(*Error); !ok || got.Err != tt.want {
+			t.Errorf("FromGo(%T holding itself) = %v; want %s", tt.input, got, tt.want)
+		}
+	}
+
+	shared := &node{Val: 2}
+	if got := FromGo([]*node{shared, shared}); got.Type() != LIST {
+		t.Errorf("FromGo of a slice holding a pointer twice = %s; want a list", got.Visit())
+	}
+}
+
+func TestToGo(t *testing.T) {
+	tuple := &Tuple{Elements: []Item{&Int{Val: 1}, &Int{Val: 2}}}
+	dict := NewDict()
+	for _, pair := range []DictPair{
+		{&Str{Val: "k"}, &List{Elements: []Item{&Float{Val: 1.5}, NoneValue}}},
+		{tuple, TrueValue},
+		{&Bytes{Val: []byte("b")}, &Int{Val: 2}},
+	} {
+		hash, _ := Hash(pair.Key)
+		slot, _, _ := dict.Find(hash, pair.Key, nil)
+		dict.Set(slot, pair.Key, pair.Value)
+	}
+	fn := &Builtin{Name: "f"}
+	tests := []struct {
+		input Item
+		want interface{}
+	}{
+		{NoneValue, nil},
+		{TrueValue, true},
+		{&Int{Val: -3}, int64(-3)},
+		{&Float{Val: 0.25}, 0.25},
+		{&Str{Val: "s"}, "s"},
+		{&Bytes{Val: []byte{1}}, []byte{1}},
+		{tuple, []interface{}{int64(1), int64(2)}},
+		{dict, map[interface{}]interface{}{"k": []interface{}{1.5, nil}, tuple: true, "b": int64(2)}},
+		{fn, fn},
+	}
+
+	for _, tt := range tests {
+		if got := tt.input.ToGo(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s.ToGo() = %#v; want %#v", tt.input.Visit(), got, tt.want)
+		}
+	}
+
+	if got := FromGo([]interface{}{"a", int64(1), 2.0, true, nil}).ToGo(); !reflect.DeepEqual(got, []interface{}{"a", int64(1), 2.0, true, nil}) {
+		t.Errorf("round trip through FromGo and ToGo gave %#v", got)
+	}
+
+	inner := &List{Elements: []Item{&Int{Val: 1}}}
+	if got := (&List{Elements: []Item{inner, inner}}).ToGo(); !reflect.DeepEqual(got, []interface{}{[]interface{}{int64(1)}, []interface{}{int64(1)}}) {
+		t.Errorf("a list holding another twice gave %#v", got)
+	}
+	loop := &List{}
+	loop.Elements = []Item{&Tuple{Elements: []Item{loop}}}
+	cyclic := NewDict()
+	hash, _ := Hash(&Str{Val: "self"})
+	slot, _, _ := cyclic.Find(hash, &Str{Val: "self"}, nil)
+	cyclic.Set(slot, &Str{Val: "self"}, cyclic)
+	for _, item := range []Item{loop, cyclic} {
+		if got, ok := item.ToGo().(*Error); !ok || !strings.HasSuffix(got.Err, "that contains itself") {
+			t.Errorf("ToGo of a container holding itself = %#v; want an error", item.ToGo())
+		}
+	}
+}
+
+type tree []tree
+
+func TestUnmarshalCycle(t *testing.T) {
+	loop := &List{}
+	loop.Elements = []Item{loop}
+	var any interface{}
+	var list []interface{}
+	var nested tree
+	for _, v := range []interface{}{&any, &list, &nested} {
+		if err := Unmarshal(loop, v); err == nil || err.Error() != "cannot convert list that contains itself" {
+			t.Errorf("Unmarshal of a list holding itself into %T returned %v", v, err)
+		}
+	}
+}
+
+type point struct {
+	X int `gopy:"x"`
+	Y int `gopy:"y"`
+	Label string
+	Secret string `gopy:"-"`
+	hidden int
+}
+
+func TestStructs(t *testing.T) {
+	item := FromGo(&point{X: 1, Y: 2, Label: "p", Secret: "s", hidden: 3})
+	instance, ok := item.(*Instance)
+	if !ok {
+		t.Fatalf("FromGo of a struct pointer = %s; want an instance", item.Visit())
+	}
+	if instance.Class.Name != "point" {
+		t.Errorf("class of the instance = %s; want point", instance.Class.Name)
+	}
+	attrs := map[string]string{}
+	for name, attr := range instance.Attrs {
+		attrs[name] = attr.Visit()
+	}
+	if want := map[string]string{"x": "1", "y": "2", "Label": "p"}; !reflect.DeepEqual(attrs, want) {
+		t.Errorf("attributes of the instance = %v; want %v", attrs, want)
+	}
+
+	var back point
+	if err := Unmarshal(item, &back); err != nil || back != (point{X: 1, Y: 2, Label: "p"}) {
+		t.Errorf("Unmarshal of the instance = %+v, %v; want the exported fields back", back, err)
+	}
+
+	dict := FromGo(map[string]interface{}{"x": 5, "Label": "q", "Secret": "no", "extra": true})
+	p := point{Y: 9}
+	if err := Unmarshal(dict, &p); err != nil || p != (point{X: 5, Y: 9, Label: "q"}) {
+		t.Errorf("Unmarshal of a dict = %+v, %v; want {X:5 Y:9 Label:q}", p, err)
+	}
+
+	var nested struct {
+		Points []point
+		Origin *point
+		Tags map[string][]string
+	}
+	src := FromGo(map[string]interface{}{
+		"Points": []map[string]int{{"x": 1}, {"y": 2}},
+		"Origin": map[string]int{"x": 0, "y": 0},
+		"Tags": map[string][]string{"a": {"b"}},
+	})
+	if err := Unmarshal(src, &nested); err != nil {
+		t.Fatal(err)
+	}
+	if len(nested.Points) != 2 || nested.Points[1].Y != 2 || nested.Origin == nil || nested.Tags["a"][0] != "b" {
+		t.Errorf("Unmarshal of nested values = %+v", nested)
+	}
+
+	errors := []struct {
+		item Item
+		into interface{}
+		want string
+	}{
+		{FromGo(map[string]string{"x": "one"}), &point{}, "cannot unmarshal str into Go value of type int"},
+		{&Int{Val: 300}, new(uint8), "cannot unmarshal int into Go value of type uint8"},
+		{&List{}, new(map[string]int), "cannot unmarshal list into Go value of type map[string]int"},
+		{&Int{Val: 1}, point{}, "cannot unmarshal into non-pointer interpreter.point"},
+	}
+	for _, tt := range errors {
+		if err := Unmarshal(tt.item, tt.into); err == nil || err.Error() != tt.want {
+			t.Errorf("Unmarshal(%s, %T) returned %v; want %s", tt.item.Visit(), tt.into, err, tt.want)
+		}
+	}
+}
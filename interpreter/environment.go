@@ -1,7 +1,35 @@
 package interpreter
 
+import "sort"
+
 type Environment struct {
 	env map[string]Item
+	outer *Environment
+	// owners maps names declared global or nonlocal in this scope to the
+	// scope that binds them.
+	owners map[string]*Environment
+	// locals holds the names a function binds somewhere in its body.
+	// Lookups of them never fall through to outer, even before they are
+	// assigned.
+	locals map[string]bool
+	// builtins holds the names lookups fall back to when no scope binds
+	// them. Only the outermost scope's is used.
+	builtins map[string]Item
+	// state is shared by the scopes of one interpreter, for the evaluator
+	// to keep what it needs while running in them.
+	state interface{}
+	// watches are told of the bindings made in this scope.
+	watches []*watch
+}
+
+// Watcher is called with a name and the value it was just bound to.
+type Watcher func(name string, val Item)
+
+// watch is a Watcher of the names in names, or of every name if it is
+// nil.
+type watch struct {
+	fn Watcher
+	names map[string]bool
 }
 
 func NewEnv() *Environment {
@@ -9,12 +37,180 @@ func NewEnv() *Environment {
 	return &Environment{env: e}
 }
 
+// NewEnclosedEnv creates a scope whose lookups fall back to outer, as used
+// for function calls and class bodies.
+func NewEnclosedEnv(outer *Environment) *Environment {
+	env := NewEnv()
+	env.outer = outer
+	env.state = outer.state
+	return env
+}
+
 func (e *Environment) Get(k string) (Item, bool) {
+	if owner, ok := e.owners[k]; ok {
+		return owner.Get(k)
+	}
 	val, ok := e.env[k]
+	if !ok && e.outer != nil && !e.locals[k] {
+		return e.outer.Get(k)
+	}
 	return val, ok
 }
 
 func (e *Environment) Store(k string, i Item) Item {
+	if owner, ok := e.owners[k]; ok {
+		return owner.Store(k, i)
+	}
 	e.env[k] = i
+	for _, w := range e.watches {
+		if w.names == nil || w.names[k] {
+			w.fn(k, i)
+		}
+	}
 	return i
-}
\ No newline at end of file
+}
+
+// Watch calls fn each time one of names, or any name if none are given,
+// is bound in this scope, including by scopes that declare it global or
+// nonlocal. It returns a function that stops the calls.
+func (e *Environment) Watch(fn Watcher, names ...string) (stop func()) {
+	w := &watch{fn: fn}
+	if len(names) > 0 {
+		w.names = make(map[string]bool, len(names))
+		for _, name := range names {
+			w.names[name] = true
+		}
+	}
+	e.watches = append(e.watches, w)
+	return func() {
+		for i, other := range e.watches {
+			if other == w {
+				e.watches = append(e.watches[:i:i], e.watches[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Delete unbinds k in this scope, reporting whether it was bound here.
+func (e *Environment) Delete(k string) bool {
+	if owner, ok := e.owners[k]; ok {
+		return owner.Delete(k)
+	}
+	if _, ok := e.env[k]; !ok {
+		return false
+	}
+	delete(e.env, k)
+	return true
+}
+
+// Has reports whether k is bound, here or in a scope that lookups of k
+// fall back to.
+func (e *Environment) Has(k string) bool {
+	_, ok := e.Get(k)
+	return ok
+}
+
+// Names returns the names bound directly in this scope, sorted.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.env))
+	for name := range e.env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Outer returns the scope lookups fall back to, or nil for the global
+// scope. Walking it shows which bindings of a name shadow others.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// SetBuiltins makes b the names that lookups in this scope, and in the
+// scopes it encloses, fall back to when no scope binds them. It should be
+// called on an outermost scope before use.
+func (e *Environment) SetBuiltins(b map[string]Item) {
+	e.builtins = b
+}
+
+// Builtins returns the builtins of the outermost scope, or nil if none
+// were set.
+func (e *Environment) Builtins() map[string]Item {
+	for e.outer != nil {
+		e = e.outer
+	}
+	return e.builtins
+}
+
+// SetState attaches the evaluator's state to this scope and to the scopes
+// it encloses from now on.
+func (e *Environment) SetState(s interface{}) {
+	e.state = s
+}
+
+// State returns the state of this scope, or of the nearest scope around
+// it that has one, or nil.
+func (e *Environment) State() interface{} {
+	for ; e != nil; e = e.outer {
+		if e.state != nil {
+			return e.state
+		}
+	}
+	return nil
+}
+
+// Locals returns the bindings made directly in this scope.
+func (e *Environment) Locals() map[string]Item {
+	return e.env
+}
+
+// DeclareLocal makes k local to this scope, hiding any binding of k in
+// the scopes around it.
+func (e *Environment) DeclareLocal(k string) {
+	if e.locals == nil {
+		e.locals = make(map[string]bool)
+	}
+	e.locals[k] = true
+}
+
+// IsLocal reports whether k was declared local to this scope.
+func (e *Environment) IsLocal(k string) bool {
+	return e.locals[k]
+}
+
+// DeclareGlobal makes k in this scope refer to the outermost scope, as
+// `global k` does.
+func (e *Environment) DeclareGlobal(k string) {
+	global := e
+	for global.outer != nil {
+		global = global.outer
+	}
+	if global != e {
+		e.declare(k, global)
+	}
+}
+
+// DeclareNonlocal makes k in this scope refer to the nearest enclosing
+// scope, other than the global one, that binds it, as `nonlocal k` does.
+// It reports whether such a scope exists.
+func (e *Environment) DeclareNonlocal(k string) bool {
+	for outer := e.outer; outer != nil && outer.outer != nil; outer = outer.outer {
+		if owner, ok := outer.owners[k]; ok {
+			e.declare(k, owner)
+			return owner.outer != nil
+		}
+		if _, ok := outer.env[k]; ok || outer.locals[k] {
+			e.declare(k, outer)
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Environment) declare(k string, owner *Environment) {
+	if e.owners == nil {
+		e.owners = make(map[string]*Environment)
+	}
+	e.owners[k] = owner
+}
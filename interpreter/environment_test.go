@@ -0,0 +1,75 @@
+package interpreter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvironmentIntrospection(t *testing.T) {
+	global := NewEnv()
+	global.Store("x", &Int{Val: 1})
+	global.Store("f", &Int{Val: 2})
+	local := NewEnclosedEnv(global)
+	local.Store("x", &Int{Val: 3})
+	local.DeclareLocal("y")
+
+	if got := local.Names(); !reflect.DeepEqual(got, []string{"x"}) {
+		t.Errorf("local.Names() = %v; want [x]", got)
+	}
+	if got := global.Names(); !reflect.DeepEqual(got, []string{"f", "x"}) {
+		t.Errorf("global.Names() = %v; want [f x]", got)
+	}
+	if !local.Has("f") || !local.Has("x") {
+		t.Errorf("local scope should see f and x")
+	}
+	if local.Has("y") {
+		t.Errorf("local y is declared but unbound, so Has(y) should be false")
+	}
+	if local.Outer() != global || global.Outer() != nil {
+		t.Errorf("Outer() should lead from the local scope to the global one and stop")
+	}
+	if _, shadowed := local.Outer().Get("x"); !shadowed {
+		t.Errorf("the global x shadowed by the local one should still be visible through Outer")
+	}
+
+	if !local.Delete("x") {
+		t.Errorf("local.Delete(x) = false; want true")
+	}
+	if val, _ := local.Get("x"); val.Visit() != "1" {
+		t.Errorf("after deleting the local x, x = %s; want the global 1", val.Visit())
+	}
+	if local.Delete("x") {
+		t.Errorf("deleting x twice from the local scope should fail")
+	}
+	local.DeclareGlobal("f")
+	if !local.Delete("f") || global.Has("f") {
+		t.Errorf("deleting a name declared global should unbind it in the global scope")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	global := NewEnv()
+	var all, some []string
+	stopAll := global.Watch(func(name string, val Item) {
+		all = append(all, name+"="+val.Visit())
+	})
+	global.Watch(func(name string, val Item) {
+		some = append(some, name+"="+val.Visit())
+	}, "x")
+
+	global.Store("x", &Int{Val: 1})
+	global.Store("y", &Int{Val: 2})
+	local := NewEnclosedEnv(global)
+	local.Store("x", &Int{Val: 3})
+	local.DeclareGlobal("x")
+	local.Store("x", &Int{Val: 4})
+	stopAll()
+	global.Store("x", &Int{Val: 5})
+
+	if want := []string{"x=1", "y=2", "x=4"}; !reflect.DeepEqual(all, want) {
+		t.Errorf("watcher of every name saw %v; want %v", all, want)
+	}
+	if want := []string{"x=1", "x=4", "x=5"}; !reflect.DeepEqual(some, want) {
+		t.Errorf("watcher of x saw %v; want %v", some, want)
+	}
+}
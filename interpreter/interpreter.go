@@ -1,28 +1,93 @@
 package interpreter
 
-import "fmt"
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gopy/ast"
+	"gopy/lexer"
+	"hash/fnv"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
 
 type Item interface {
 	Type() ItemType
 	Visit() string
+	// ToGo returns the Go value the item stands for, for hosts reading
+	// results. Items with no Go counterpart return themselves.
+	ToGo() interface{}
 }
 type ItemType string
 
 const (
 	ERR = "ERR"
 	INT = "INT"
+	FLOAT = "FLOAT"
 	STR = "STR"
+	BYTES = "BYTES"
 	BOOL = "BOOL"
 	BUILTIN = "BUILTIN"
+	LIST = "LIST"
+	TUPLE = "TUPLE"
+	DICT = "DICT"
+	SET = "SET"
+	SLICE = "SLICE"
+	RANGE = "RANGE"
+	BREAK = "BREAK"
+	CONTINUE = "CONTINUE"
+	NONE = "NONE"
+	RETURN = "RETURN"
+	FUNCTION = "FUNCTION"
+	CLASS = "CLASS"
+	TYPE = "TYPE"
+	INSTANCE = "INSTANCE"
+	METHOD = "METHOD"
+	SUPER = "SUPER"
+	MODULE = "MODULE"
+	GENERATOR = "GENERATOR"
+	ITERATOR = "ITERATOR"
+	FILE = "FILE"
+	PATTERN = "PATTERN"
+	MATCH = "MATCH"
 )
 
+// Error is a raised exception unwinding the evaluator. Exception is the
+// exception object, created on demand for errors raised by the interpreter
+// itself, and Pos is the position of the statement that raised it.
+// Traceback holds the statement each call was running when the error
+// passed through it, innermost first. Context is the error that was being
+// handled when this one was raised, and Cause the one named by raise ...
+// from.
 type Error struct {
 	Err string
+	Exception *Instance
+	Pos string
+	Traceback []TraceEntry
+	Context *Error
+	Cause *Error
+	// Abort marks errors that scripts can't handle, such as the one ending
+	// a cancelled evaluation. They unwind straight back to the host.
+	Abort bool
 }
 
 func (e *Error) Type() ItemType { return ERR }
 func (e *Error) Visit() string { return e.Err }
 
+// Error makes an uncaught exception usable as a Go error by hosts.
+func (e *Error) Error() string { return e.Err }
+
+// TraceEntry is a line of a traceback: the statement at Pos, run by the
+// function named Func, or "<module>" at the top level.
+type TraceEntry struct {
+	Pos lexer.Position
+	Func string
+}
+
 type Int struct {
 	Val int64
 }
@@ -30,6 +95,34 @@ type Int struct {
 func (i *Int) Type() ItemType { return INT }
 func (i *Int) Visit() string { return fmt.Sprintf("%d", i.Val) }
 
+type Float struct {
+	Val float64
+}
+
+func (f *Float) Type() ItemType { return FLOAT }
+
+// Visit formats f the way Python's repr does: the shortest representation
+// that reads back as the same value, always with a fractional part or an
+// exponent so it can't be mistaken for an int.
+func (f *Float) Visit() string {
+	switch {
+	case math.IsInf(f.Val, 1):
+		return "inf"
+	case math.IsInf(f.Val, -1):
+		return "-inf"
+	case math.IsNaN(f.Val):
+		return "nan"
+	}
+	if abs := math.Abs(f.Val); abs != 0 && (abs < 1e-4 || abs >= 1e16) {
+		return strconv.FormatFloat(f.Val, 'e', -1, 64)
+	}
+	s := strconv.FormatFloat(f.Val, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
 type Str struct {
 	Val string
 }
@@ -37,17 +130,754 @@ type Str struct {
 func (s *Str) Type() ItemType { return STR }
 func (s *Str) Visit() string { return s.Val }
 
+// Bytes is an immutable sequence of bytes.
+type Bytes struct {
+	Val []byte
+}
+
+func (b *Bytes) Type() ItemType { return BYTES }
+
+// Visit formats b like Python's repr: printable ASCII as is and other
+// bytes escaped, quoted with single quotes unless b holds one and no
+// double quote.
+func (b *Bytes) Visit() string {
+	quote := byte('\'')
+	if bytes.IndexByte(b.Val, '\'') >= 0 && bytes.IndexByte(b.Val, '"') < 0 {
+		quote = '"'
+	}
+	var sb strings.Builder
+	sb.WriteByte('b')
+	sb.WriteByte(quote)
+	for _, c := range b.Val {
+		switch {
+		case c == quote || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c == '\t':
+			sb.WriteString(`\t`)
+		case c == '\n':
+			sb.WriteString(`\n`)
+		case c == '\r':
+			sb.WriteString(`\r`)
+		case c < ' ' || c >= 0x7f:
+			fmt.Fprintf(&sb, `\x%02x`, c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte(quote)
+	return sb.String()
+}
+
+func (b *Bytes) Hash() (uint64, bool) {
+	h := fnv.New64a()
+	h.Write(b.Val)
+	return h.Sum64(), true
+}
+
 type Bool struct {
 	Val bool
 }
 
 func (b *Bool) Type() ItemType { return BOOL }
-func (b *Bool) Visit() string { return fmt.Sprintf("%t", b.Val) }
+func (b *Bool) Visit() string {
+	if b.Val {
+		return "True"
+	}
+	return "False"
+}
+
+type None struct{}
+
+func (n *None) Type() ItemType { return NONE }
+func (n *None) Visit() string { return "None" }
+
+// TrueValue, FalseValue and NoneValue are the only True, False and None
+// items, so that scripts can compare them with `is`.
+var (
+	TrueValue = &Bool{Val: true}
+	FalseValue = &Bool{Val: false}
+	NoneValue = &None{}
+)
+
+// ReturnValue wraps the result of a return statement while it unwinds to
+// the enclosing function call.
+type ReturnValue struct {
+	Value Item
+}
+
+func (rv *ReturnValue) Type() ItemType { return RETURN }
+func (rv *ReturnValue) Visit() string { return rv.Value.Visit() }
+
+// Function is a user defined function. Defaults holds the default value of
+// each parameter, evaluated when the function was defined, or nil. VarArgs
+// and KwArgs name the parameters collecting extra positional and keyword
+// arguments, if any. Doc is the docstring. Class is set for functions
+// defined in a class body and is the class super() starts searching above.
+// Generator is set when the body contains yield.
+type Function struct {
+	Name string
+	Params []*ast.Identifier
+	Defaults []Item
+	VarArgs *ast.Identifier
+	KwArgs *ast.Identifier
+	Body *ast.BlockStmt
+	Doc string
+	Locals []string
+	Env *Environment
+	Class *Class
+	Generator bool
+}
+
+func (f *Function) Type() ItemType { return FUNCTION }
+func (f *Function) Visit() string { return fmt.Sprintf("<function %s>", f.Name) }
+
+type Class struct {
+	Name string
+	Base *Class
+	Attrs map[string]Item
+}
+
+func (c *Class) Type() ItemType { return CLASS }
+func (c *Class) Visit() string { return fmt.Sprintf("<class '%s'>", c.Name) }
+
+// Lookup finds an attribute on the class or the nearest base class that
+// defines it.
+func (c *Class) Lookup(name string) (Item, bool) {
+	for class := c; class != nil; class = class.Base {
+		if attr, ok := class.Attrs[name]; ok {
+			return attr, true
+		}
+	}
+	return nil, false
+}
+
+// Type is a builtin type such as int or list. Base is the type it derives
+// from, if any. Calling a type calls New, which is nil for types that
+// can't be created directly.
+type Type struct {
+	Name string
+	Base *Type
+	New BuiltinFunction
+}
+
+func (t *Type) Type() ItemType { return TYPE }
+func (t *Type) Visit() string { return fmt.Sprintf("<class '%s'>", t.Name) }
+
+type Instance struct {
+	Class *Class
+	Attrs map[string]Item
+}
+
+func (i *Instance) Type() ItemType { return INSTANCE }
+func (i *Instance) Visit() string { return fmt.Sprintf("<%s object>", i.Class.Name) }
+
+// BoundMethod is a function or builtin looked up through an instance;
+// calling it passes the instance as the first argument.
+type BoundMethod struct {
+	Self Item
+	Fn Item
+}
+
+func (bm *BoundMethod) Type() ItemType { return METHOD }
+func (bm *BoundMethod) Visit() string {
+	var name string
+	switch fn := bm.Fn.(type) {
+	case *Function:
+		name = fn.Name
+	case *Builtin:
+		name = fn.Name
+	}
+	return fmt.Sprintf("<bound method %s of %s>", name, bm.Self.Visit())
+}
+
+// Iterator is an iterator implemented in Go, such as the one returned by
+// reversed. Next returns the next item, or false once there are none left.
+// An error raised while producing an item is returned in its place.
+type Iterator struct {
+	Name string
+	Next func() (Item, bool)
+}
+
+func (it *Iterator) Type() ItemType { return ITERATOR }
+func (it *Iterator) Visit() string { return fmt.Sprintf("<%s object>", it.Name) }
+
+// File is a file opened by open. Reads go through Reader so that lines
+// can be read one at a time.
+type File struct {
+	Name string
+	Mode string
+	File *os.File
+	Reader *bufio.Reader
+	Closed bool
+}
+
+func (f *File) Type() ItemType { return FILE }
+func (f *File) Visit() string {
+	if strings.Contains(f.Mode, "b") {
+		kind := "BufferedWriter"
+		if strings.Contains(f.Mode, "+") {
+			kind = "BufferedRandom"
+		} else if strings.Contains(f.Mode, "r") {
+			kind = "BufferedReader"
+		}
+		return fmt.Sprintf("<_io.%s name='%s'>", kind, f.Name)
+	}
+	return fmt.Sprintf("<_io.TextIOWrapper name='%s' mode='%s' encoding='UTF-8'>", f.Name, f.Mode)
+}
+
+// Pattern is a compiled regular expression. Source and Flags are what the
+// script compiled; Regexp is its translation to Go syntax, and Anchored
+// and Full are the same expression anchored for match and fullmatch.
+type Pattern struct {
+	Source string
+	Flags int64
+	Regexp *regexp.Regexp
+	Anchored *regexp.Regexp
+	Full *regexp.Regexp
+}
+
+func (p *Pattern) Type() ItemType { return PATTERN }
+func (p *Pattern) Visit() string { return "re.compile(" + Repr(&Str{Val: p.Source}) + ")" }
 
-type BuiltinFunction func(args ...Item) Item
+// Match is a successful regular expression match against Str. Groups holds
+// the byte offsets of each group's start and end, -1 for groups that did
+// not take part.
+type Match struct {
+	Pattern *Pattern
+	Str string
+	Groups []int
+}
+
+func (m *Match) Type() ItemType { return MATCH }
+func (m *Match) Visit() string {
+	start, end := len([]rune(m.Str[:m.Groups[0]])), len([]rune(m.Str[:m.Groups[1]]))
+	return fmt.Sprintf("<re.Match object; span=(%d, %d), match=%s>", start, end, Repr(&Str{Val: m.Str[m.Groups[0]:m.Groups[1]]}))
+}
+
+// Group returns the text matched by group n, or def if it did not take
+// part in the match.
+func (m *Match) Group(n int, def Item) Item {
+	if m.Groups[2*n] < 0 {
+		return def
+	}
+	return &Str{Val: m.Str[m.Groups[2*n]:m.Groups[2*n+1]]}
+}
+
+// Super resolves attributes on the bases of Class and binds methods to Self.
+type Super struct {
+	Class *Class
+	Self Item
+}
+
+func (s *Super) Type() ItemType { return SUPER }
+func (s *Super) Visit() string { return fmt.Sprintf("<super: <class '%s'>>", s.Class.Name) }
+
+// Module is an imported module. Its attributes are the top level names
+// bound in Env.
+type Module struct {
+	Name string
+	Env *Environment
+}
+
+func (m *Module) Type() ItemType { return MODULE }
+func (m *Module) Visit() string { return fmt.Sprintf("<module '%s'>", m.Name) }
+
+// Generator is the iterator returned by calling a generator function. The
+// body runs on its own goroutine and trades control with the caller: each
+// resumption sends a value on Resume and waits on Yield for the next
+// yielded value, or for a ReturnValue or Error once the body has finished.
+// Start launches the body and is nil once it has been called.
+type Generator struct {
+	Name string
+	Resume chan Item
+	Yield chan Item
+	Start func()
+	Done bool
+	// Stopped is closed once the generator has been stopped, by calling
+	// Stop or by its interpreter closing. Its goroutine then ends without
+	// running any more of the body. Both are nil until it starts.
+	Stopped <-chan struct{}
+	Stop func()
+}
+
+func (g *Generator) Type() ItemType { return GENERATOR }
+func (g *Generator) Visit() string { return fmt.Sprintf("<generator object %s>", g.Name) }
+
+// BuiltinFunction is a function implemented in Go. env is the scope it is
+// called from, which ties the call to the interpreter making it.
+type BuiltinFunction func(env *Environment, args ...Item) Item
+
+// KeywordFunction is a builtin that also accepts arguments by keyword.
+type KeywordFunction func(env *Environment, args []Item, kwargs map[string]Item) Item
+
+// Builtin is a function implemented in Go. Builtins taking keyword
+// arguments set KwFn instead of Fn.
 type Builtin struct {
+	Name string
 	Fn BuiltinFunction
+	KwFn KeywordFunction
 }
 
 func (b *Builtin) Type() ItemType { return BUILTIN }
-func (b *Builtin) Visit() string { return "builtin function" }
\ No newline at end of file
+func (b *Builtin) Visit() string { return "builtin function" }
+
+// Break and Continue are the signals produced by break and continue
+// statements. They stop block evaluation and unwind to the enclosing loop.
+type Break struct{}
+
+func (b *Break) Type() ItemType { return BREAK }
+func (b *Break) Visit() string { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ItemType { return CONTINUE }
+func (c *Continue) Visit() string { return "continue" }
+
+type List struct {
+	Elements []Item
+}
+
+func (l *List) Type() ItemType { return LIST }
+func (l *List) Visit() string { return newFormatter(maxNesting).visit(l) }
+
+type Tuple struct {
+	Elements []Item
+}
+
+func (t *Tuple) Type() ItemType { return TUPLE }
+func (t *Tuple) Visit() string { return newFormatter(maxNesting).visit(t) }
+
+// Slice holds the bounds of a start:stop:step subscript. Omitted bounds
+// are nil.
+type Slice struct {
+	Start Item
+	Stop Item
+	Step Item
+}
+
+func (s *Slice) Type() ItemType { return SLICE }
+func (s *Slice) Visit() string {
+	bound := func(i Item) string {
+		if i == nil {
+			return "None"
+		}
+		return i.Visit()
+	}
+	return fmt.Sprintf("slice(%s, %s, %s)", bound(s.Start), bound(s.Stop), bound(s.Step))
+}
+
+// Range is the lazy sequence returned by range(). Its elements are worked
+// out from Start, Stop and Step when asked for rather than stored.
+type Range struct {
+	Start int64
+	Stop int64
+	Step int64
+}
+
+func (r *Range) Type() ItemType { return RANGE }
+func (r *Range) Visit() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.Stop)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+
+// Len returns the number of elements in r.
+func (r *Range) Len() int64 {
+	switch {
+	case r.Step > 0 && r.Start < r.Stop:
+		return (r.Stop - r.Start + r.Step - 1) / r.Step
+	case r.Step < 0 && r.Start > r.Stop:
+		return (r.Start - r.Stop - r.Step - 1) / -r.Step
+	}
+	return 0
+}
+
+// At returns the element at index i, which must be in [0, Len()).
+func (r *Range) At(i int64) int64 {
+	return r.Start + i*r.Step
+}
+
+// Contains reports whether n is one of the elements of r.
+func (r *Range) Contains(n int64) bool {
+	if r.Step > 0 && (n < r.Start || n >= r.Stop) {
+		return false
+	}
+	if r.Step < 0 && (n > r.Start || n <= r.Stop) {
+		return false
+	}
+	return (n-r.Start)%r.Step == 0
+}
+
+// HashKey identifies a key held by a dict or set: its hash, and which of
+// the keys with that hash it is, since unequal keys may share one.
+type HashKey struct {
+	Value uint64
+	Slot int
+}
+
+// Hashable is implemented by items that can be dict keys and set members.
+// Hash reports false for values that are only sometimes hashable, like
+// tuples holding a list. Equal items hash alike, so numbers hash by value:
+// 1, 1.0 and True all hash to 1.
+type Hashable interface {
+	Item
+	Hash() (uint64, bool)
+}
+
+func (i *Int) Hash() (uint64, bool) {
+	return uint64(i.Val), true
+}
+
+// Hash hashes floats holding an integer like the int.
+func (f *Float) Hash() (uint64, bool) {
+	if f.Val == math.Trunc(f.Val) && f.Val >= math.MinInt64 && f.Val < math.MaxInt64 {
+		return uint64(int64(f.Val)), true
+	}
+	return math.Float64bits(f.Val), true
+}
+
+func (b *Bool) Hash() (uint64, bool) {
+	if b.Val {
+		return 1, true
+	}
+	return 0, true
+}
+
+func (s *Str) Hash() (uint64, bool) {
+	h := fnv.New64a()
+	h.Write([]byte(s.Val))
+	return h.Sum64(), true
+}
+
+func (n *None) Hash() (uint64, bool) {
+	return 0, true
+}
+
+// Hash combines the hashes of the elements, so a tuple is hashable only
+// if all of its elements are.
+func (t *Tuple) Hash() (uint64, bool) {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, el := range t.Elements {
+		hash, ok := Hash(el)
+		if !ok {
+			return 0, false
+		}
+		binary.LittleEndian.PutUint64(buf[:], hash)
+		h.Write(buf[:])
+	}
+	return h.Sum64(), true
+}
+
+// Hash returns the hash of items that can be used as dict keys.
+func Hash(i Item) (uint64, bool) {
+	if h, ok := i.(Hashable); ok {
+		return h.Hash()
+	}
+	return 0, false
+}
+
+// Equal reports whether two keys are equal, or fails with the error
+// comparing them raised.
+type Equal func(a, b Item) (bool, *Error)
+
+// KeysEqual is the Equal that Go code building dicts and sets of plain
+// data uses. Numbers are equal when their values are, strings, bytes and
+// tuples when their contents are, and other items only to themselves.
+func KeysEqual(a, b Item) (bool, *Error) {
+	if a == b {
+		return true, nil
+	}
+	switch a := a.(type) {
+	case *Int, *Float, *Bool:
+		if a, ok := a.(*Int); ok {
+			if b, ok := b.(*Int); ok {
+				return a.Val == b.Val, nil
+			}
+		}
+		x, xok := number(a)
+		y, yok := number(b)
+		return xok && yok && x == y, nil
+	case *Str:
+		b, ok := b.(*Str)
+		return ok && a.Val == b.Val, nil
+	case *Bytes:
+		b, ok := b.(*Bytes)
+		return ok && bytes.Equal(a.Val, b.Val), nil
+	case *Tuple:
+		b, ok := b.(*Tuple)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false, nil
+		}
+		for i := range a.Elements {
+			if equal, _ := KeysEqual(a.Elements[i], b.Elements[i]); !equal {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// number returns the value of an int, float or bool.
+func number(i Item) (float64, bool) {
+	switch i := i.(type) {
+	case *Int:
+		return float64(i.Val), true
+	case *Float:
+		return i.Val, true
+	case *Bool:
+		if i.Val {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// find looks through the keys with hash, in has, for one equal to key.
+// It returns its HashKey and true, or the HashKey for key to be stored
+// under and false. A nil equal means KeysEqual.
+func find(hash uint64, key Item, has func(HashKey) (Item, bool), equal Equal) (HashKey, bool, *Error) {
+	if equal == nil {
+		equal = KeysEqual
+	}
+	for slot := 0; ; slot++ {
+		k := HashKey{Value: hash, Slot: slot}
+		held, ok := has(k)
+		if !ok {
+			return k, false, nil
+		}
+		if same, err := equal(held, key); err != nil || same {
+			return k, same, err
+		}
+	}
+}
+
+type DictPair struct {
+	Key Item
+	Value Item
+}
+
+// Dict keeps its pairs in insertion order like Python dicts do.
+type Dict struct {
+	Pairs map[HashKey]DictPair
+	Keys []HashKey
+}
+
+func NewDict() *Dict {
+	return &Dict{Pairs: make(map[HashKey]DictPair)}
+}
+
+func (d *Dict) Type() ItemType { return DICT }
+func (d *Dict) Visit() string { return newFormatter(maxNesting).visit(d) }
+
+// Find returns the HashKey of the key in d equal to key, whose hash is
+// hash, and true, or the HashKey to store key under and false. Keys with
+// the same hash are compared with equal, or KeysEqual if it is nil.
+func (d *Dict) Find(hash uint64, key Item, equal Equal) (HashKey, bool, *Error) {
+	return find(hash, key, func(k HashKey) (Item, bool) {
+		pair, ok := d.Pairs[k]
+		return pair.Key, ok
+	}, equal)
+}
+
+func (d *Dict) Get(key HashKey) (Item, bool) {
+	pair, ok := d.Pairs[key]
+	return pair.Value, ok
+}
+
+// Set stores v under key, keeping the key item already there, if any,
+// like Python does.
+func (d *Dict) Set(key HashKey, k Item, v Item) {
+	if pair, ok := d.Pairs[key]; ok {
+		k = pair.Key
+	} else {
+		d.Keys = append(d.Keys, key)
+	}
+	d.Pairs[key] = DictPair{Key: k, Value: v}
+}
+
+// Delete removes key, reporting whether it was present. The last key
+// with the same hash moves into its slot, so that Find still sees every
+// key with that hash.
+func (d *Dict) Delete(key HashKey) bool {
+	if _, ok := d.Pairs[key]; !ok {
+		return false
+	}
+	delete(d.Pairs, key)
+	for i, k := range d.Keys {
+		if k == key {
+			d.Keys = append(d.Keys[:i], d.Keys[i+1:]...)
+			break
+		}
+	}
+	last := key
+	for {
+		next := HashKey{Value: key.Value, Slot: last.Slot + 1}
+		if _, ok := d.Pairs[next]; !ok {
+			break
+		}
+		last = next
+	}
+	if last != key {
+		d.Pairs[key] = d.Pairs[last]
+		delete(d.Pairs, last)
+		for i, k := range d.Keys {
+			if k == last {
+				d.Keys[i] = key
+				break
+			}
+		}
+	}
+	return true
+}
+
+// Set keeps its elements in insertion order so that it prints and iterates
+// deterministically.
+type Set struct {
+	Items map[HashKey]Item
+	Keys []HashKey
+}
+
+func NewSet() *Set {
+	return &Set{Items: make(map[HashKey]Item)}
+}
+
+func (s *Set) Type() ItemType { return SET }
+func (s *Set) Visit() string { return newFormatter(maxNesting).visit(s) }
+
+// Find returns the HashKey of the element of s equal to item, whose hash
+// is hash, and true, or the HashKey to add item under and false.
+// Elements with the same hash are compared with equal, or KeysEqual if
+// it is nil.
+func (s *Set) Find(hash uint64, item Item, equal Equal) (HashKey, bool, *Error) {
+	return find(hash, item, func(k HashKey) (Item, bool) {
+		held, ok := s.Items[k]
+		return held, ok
+	}, equal)
+}
+
+// Add inserts item under key unless an element is already present there.
+func (s *Set) Add(key HashKey, item Item) {
+	if _, ok := s.Items[key]; !ok {
+		s.Keys = append(s.Keys, key)
+		s.Items[key] = item
+	}
+}
+
+func (s *Set) Has(key HashKey) bool {
+	_, ok := s.Items[key]
+	return ok
+}
+
+// Elements returns the elements of s in insertion order.
+func (s *Set) Elements() []Item {
+	items := make([]Item, len(s.Keys))
+	for i, key := range s.Keys {
+		items[i] = s.Items[key]
+	}
+	return items
+}
+
+// maxNesting bounds how deeply Visit and Repr descend into containers
+// nested in one another, so that formatting them can't overflow the Go
+// stack. Containers nested deeper are shown as "...".
+const maxNesting = 20000
+
+// Repr formats an item the way it appears inside a container.
+func Repr(i Item) string {
+	return newFormatter(maxNesting).repr(i)
+}
+
+// VisitLimit formats item like its Visit method, reporting false instead
+// if it holds containers nested more than limit deep.
+func VisitLimit(item Item, limit int) (string, bool) {
+	f := newFormatter(limit)
+	s := f.visit(item)
+	return s, !f.exceeded
+}
+
+// ReprLimit formats item like Repr, reporting false instead if it holds
+// containers nested more than limit deep.
+func ReprLimit(item Item, limit int) (string, bool) {
+	f := newFormatter(limit)
+	s := f.repr(item)
+	return s, !f.exceeded
+}
+
+// formatter formats items like their Visit methods. seen holds the
+// containers being formatted, so that one holding itself prints as [...],
+// (...) or {...} there, like Python. At most limit of them may be, and
+// exceeded records that more were.
+type formatter struct {
+	seen map[Item]bool
+	limit int
+	exceeded bool
+}
+
+func newFormatter(limit int) *formatter {
+	return &formatter{seen: map[Item]bool{}, limit: limit}
+}
+
+func (f *formatter) repr(i Item) string {
+	if s, ok := i.(*Str); ok {
+		return "'" + s.Val + "'"
+	}
+	return f.visit(i)
+}
+
+func (f *formatter) visit(item Item) string {
+	switch item.(type) {
+	case *List, *Tuple, *Dict, *Set:
+	default:
+		return item.Visit()
+	}
+	if f.seen[item] {
+		switch item.(type) {
+		case *List:
+			return "[...]"
+		case *Tuple:
+			return "(...)"
+		}
+		return "{...}"
+	}
+	if len(f.seen) >= f.limit {
+		f.exceeded = true
+		return "..."
+	}
+	f.seen[item] = true
+	defer delete(f.seen, item)
+	switch item := item.(type) {
+	case *List:
+		return "[" + f.join(item.Elements) + "]"
+	case *Tuple:
+		if len(item.Elements) == 1 {
+			return "(" + f.repr(item.Elements[0]) + ",)"
+		}
+		return "(" + f.join(item.Elements) + ")"
+	case *Dict:
+		var pairs []string
+		for _, key := range item.Keys {
+			pair := item.Pairs[key]
+			pairs = append(pairs, f.repr(pair.Key)+": "+f.repr(pair.Value))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *Set:
+		if len(item.Keys) == 0 {
+			return "set()"
+		}
+		return "{" + f.join(item.Elements()) + "}"
+	}
+	return ""
+}
+
+func (f *formatter) join(items []Item) string {
+	var parts []string
+	for _, item := range items {
+		parts = append(parts, f.repr(item))
+	}
+	return strings.Join(parts, ", ")
+}
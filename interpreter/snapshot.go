@@ -0,0 +1,242 @@
+package interpreter
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// snapshot is the encoded form of a scope's bindings. Items are kept in a
+// table and refer to their elements by index, so that values bound to
+// several names, or held by several containers, or by themselves, come
+// back shared the same way.
+type snapshot struct {
+	Bindings map[string]int
+	Items []snapshotItem
+}
+
+// snapshotItem is one item of a snapshot. Refs holds the elements of a
+// list, tuple or set, or a dict's keys and values in turn.
+type snapshotItem struct {
+	Kind ItemType
+	Int int64
+	Float float64
+	Str string
+	Bytes []byte
+	Refs []int
+}
+
+// Snapshot encodes the bindings made directly in this scope so that
+// Restore can bring them back, in this process or another. Only None,
+// bools, numbers, strings and bytes, and lists, tuples, sets and dicts of
+// them, are kept; names bound to anything else, such as functions,
+// classes, instances and modules, or to containers holding them, are left
+// out.
+func (e *Environment) Snapshot() ([]byte, error) {
+	enc := snapshotEncoder{index: map[Item]int{}}
+	snap := snapshot{Bindings: map[string]int{}}
+	for _, name := range e.Names() {
+		val := e.env[name]
+		if !snapshotable(val, map[Item]bool{}) {
+			continue
+		}
+		snap.Bindings[name] = enc.encode(val)
+	}
+	snap.Items = enc.items
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Restore binds the names in data, made by Snapshot, in this scope,
+// replacing any existing bindings of them. Other bindings are left alone.
+func (e *Environment) Restore(data []byte) error {
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return fmt.Errorf("invalid snapshot: %v", err)
+	}
+	items, err := decodeSnapshot(snap.Items)
+	if err != nil {
+		return err
+	}
+	for name, i := range snap.Bindings {
+		if i < 0 || i >= len(items) {
+			return fmt.Errorf("invalid snapshot: binding of %s refers to item %d of %d", name, i, len(items))
+		}
+	}
+	for name, i := range snap.Bindings {
+		e.Store(name, items[i])
+	}
+	return nil
+}
+
+// snapshotable reports whether item, and everything it holds, can be
+// encoded. seen holds the containers being checked, so that cycles end.
+func snapshotable(item Item, seen map[Item]bool) bool {
+	var elements []Item
+	switch item := item.(type) {
+	case *None, *Bool, *Int, *Float, *Str, *Bytes:
+		return true
+	case *List:
+		elements = item.Elements
+	case *Tuple:
+		elements = item.Elements
+	case *Set:
+		elements = item.Elements()
+	case *Dict:
+		for _, key := range item.Keys {
+			pair := item.Pairs[key]
+			elements = append(elements, pair.Key, pair.Value)
+		}
+	default:
+		return false
+	}
+	if seen[item] {
+		return true
+	}
+	seen[item] = true
+	for _, element := range elements {
+		if !snapshotable(element, seen) {
+			return false
+		}
+	}
+	return true
+}
+
+type snapshotEncoder struct {
+	items []snapshotItem
+	index map[Item]int
+}
+
+// encode adds item, and the items it holds, to the table unless they are
+// already in it, and returns its index.
+func (enc *snapshotEncoder) encode(item Item) int {
+	if i, ok := enc.index[item]; ok {
+		return i
+	}
+	i := len(enc.items)
+	enc.index[item] = i
+	enc.items = append(enc.items, snapshotItem{Kind: item.Type()})
+	var elements []Item
+	switch item := item.(type) {
+	case *Bool:
+		if item.Val {
+			enc.items[i].Int = 1
+		}
+	case *Int:
+		enc.items[i].Int = item.Val
+	case *Float:
+		enc.items[i].Float = item.Val
+	case *Str:
+		enc.items[i].Str = item.Val
+	case *Bytes:
+		enc.items[i].Bytes = item.Val
+	case *List:
+		elements = item.Elements
+	case *Tuple:
+		elements = item.Elements
+	case *Set:
+		elements = item.Elements()
+	case *Dict:
+		for _, key := range item.Keys {
+			pair := item.Pairs[key]
+			elements = append(elements, pair.Key, pair.Value)
+		}
+	}
+	refs := make([]int, len(elements))
+	for j, element := range elements {
+		refs[j] = enc.encode(element)
+	}
+	enc.items[i].Refs = refs
+	return i
+}
+
+// decodeSnapshot returns the items of a snapshot's table. Containers are
+// made first and filled afterwards, since they may refer to each other;
+// lists and tuples are filled before sets and dicts hash their elements.
+func decodeSnapshot(table []snapshotItem) ([]Item, error) {
+	items := make([]Item, len(table))
+	for i, si := range table {
+		switch si.Kind {
+		case NONE:
+			items[i] = NoneValue
+		case BOOL:
+			items[i] = FalseValue
+			if si.Int != 0 {
+				items[i] = TrueValue
+			}
+		case INT:
+			items[i] = &Int{Val: si.Int}
+		case FLOAT:
+			items[i] = &Float{Val: si.Float}
+		case STR:
+			items[i] = &Str{Val: si.Str}
+		case BYTES:
+			items[i] = &Bytes{Val: si.Bytes}
+		case LIST:
+			items[i] = &List{}
+		case TUPLE:
+			items[i] = &Tuple{}
+		case SET:
+			items[i] = NewSet()
+		case DICT:
+			items[i] = NewDict()
+		default:
+			return nil, fmt.Errorf("invalid snapshot: item %d has kind %s", i, si.Kind)
+		}
+	}
+	elements := func(si snapshotItem) ([]Item, error) {
+		refs := make([]Item, len(si.Refs))
+		for j, ref := range si.Refs {
+			if ref < 0 || ref >= len(items) {
+				return nil, fmt.Errorf("invalid snapshot: reference to item %d of %d", ref, len(items))
+			}
+			refs[j] = items[ref]
+		}
+		return refs, nil
+	}
+	for i, si := range table {
+		refs, err := elements(si)
+		if err != nil {
+			return nil, err
+		}
+		switch item := items[i].(type) {
+		case *List:
+			item.Elements = refs
+		case *Tuple:
+			item.Elements = refs
+		}
+	}
+	for i, si := range table {
+		refs, err := elements(si)
+		if err != nil {
+			return nil, err
+		}
+		switch item := items[i].(type) {
+		case *Set:
+			for _, element := range refs {
+				hash, ok := Hash(element)
+				if !ok {
+					return nil, fmt.Errorf("invalid snapshot: set %d holds an unhashable %s", i, element.Type())
+				}
+				slot, _, _ := item.Find(hash, element, nil)
+				item.Add(slot, element)
+			}
+		case *Dict:
+			if len(refs)%2 != 0 {
+				return nil, fmt.Errorf("invalid snapshot: dict %d has a key without a value", i)
+			}
+			for j := 0; j < len(refs); j += 2 {
+				hash, ok := Hash(refs[j])
+				if !ok {
+					return nil, fmt.Errorf("invalid snapshot: dict %d has an unhashable %s key", i, refs[j].Type())
+				}
+				slot, _, _ := item.Find(hash, refs[j], nil)
+				item.Set(slot, refs[j], refs[j+1])
+			}
+		}
+	}
+	return items, nil
+}
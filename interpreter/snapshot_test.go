@@ -0,0 +1,71 @@
+package interpreter
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	env := NewEnv()
+	shared := &List{Elements: []Item{&Int{Val: 1}}}
+	shared.Elements = append(shared.Elements, shared)
+	dict := FromGo(map[string]interface{}{"f": 1.5, "b": []byte("x")}).(*Dict)
+	key := &Tuple{Elements: []Item{&Str{Val: "k"}, NoneValue}}
+	hash, _ := Hash(key)
+	slot, _, _ := dict.Find(hash, key, nil)
+	dict.Set(slot, key, TrueValue)
+	set := NewSet()
+	for _, element := range []Item{key, &Int{Val: 2}} {
+		hash, _ := Hash(element)
+		slot, _, _ := set.Find(hash, element, nil)
+		set.Add(slot, element)
+	}
+	env.Store("a", shared)
+	env.Store("b", &Tuple{Elements: []Item{shared, FalseValue}})
+	env.Store("d", dict)
+	env.Store("s", set)
+	env.Store("nan", &Float{Val: math.NaN()})
+	env.Store("fn", &Builtin{Name: "fn"})
+	env.Store("held", &List{Elements: []Item{&Builtin{Name: "fn"}}})
+
+	data, err := env.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewEnv()
+	restored.Store("fn", &Str{Val: "kept"})
+	if err := restored.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"d": "{'b': b'x', 'f': 1.5, ('k', None): True}",
+		"s": "{('k', None), 2}",
+		"nan": "nan",
+		"fn": "kept",
+	}
+	for name, visit := range want {
+		item, ok := restored.Get(name)
+		if !ok || item.Visit() != visit {
+			t.Errorf("restored %s = %v; want %s", name, item, visit)
+		}
+	}
+	if restored.Has("held") {
+		t.Errorf("a list holding a builtin should be left out of the snapshot")
+	}
+	a, _ := restored.Get("a")
+	b, _ := restored.Get("b")
+	if list := a.(*List); list.Elements[0].Visit() != "1" || list.Elements[1] != list || b.(*Tuple).Elements[0] != list {
+		t.Errorf("shared and cyclic references should be restored shared")
+	}
+	if d, _ := restored.Get("d"); d.(*Dict).Keys[2] != slot {
+		t.Errorf("restored dict keys should hash as before")
+	}
+	if item, _ := restored.Get("b"); item.(*Tuple).Elements[1] != FalseValue {
+		t.Errorf("restored bools should be the singletons")
+	}
+
+	if err := NewEnv().Restore([]byte("not a snapshot")); err == nil {
+		t.Errorf("Restore of garbage returned no error")
+	}
+}
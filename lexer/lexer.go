@@ -3,12 +3,17 @@ package lexer
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"unicode"
 )
 
 type tokenKey int
 type TokenType string
 
+// Unterminated is the value of the ILLEGAL token the lexer makes of a
+// string literal missing its closing quote.
+const Unterminated = "unterminated string literal"
+
 const (
 	ILLEGAL TokenType = "ILLEGAL"
 	EOF = "EOF"
@@ -22,22 +27,50 @@ const (
 	WHILE = "WHILE"
 	FOR = "FOR"
 	IN = "IN"
-	PRINT = "PRINT"
-	INT = "INT"
-	STR = "STR"
+	IS = "IS"
 	AND = "AND"
 	OR = "OR"
+	NOT = "NOT"
+	PASS = "PASS"
+	BREAK = "BREAK"
+	CONTINUE = "CONTINUE"
+	DEF = "DEF"
+	RETURN = "RETURN"
+	CLASS = "CLASS"
+	IMPORT = "IMPORT"
+	FROM = "FROM"
+	AS = "AS"
+	TRY = "TRY"
+	EXCEPT = "EXCEPT"
+	FINALLY = "FINALLY"
+	RAISE = "RAISE"
+	WITH = "WITH"
+	ASSERT = "ASSERT"
+	DEL = "DEL"
+	GLOBAL = "GLOBAL"
+	NONLOCAL = "NONLOCAL"
+	YIELD = "YIELD"
+	TRUE = "TRUE"
+	FALSE = "FALSE"
+	NONE = "NONE"
 
 	// Literals
 	STRING = "STRING"
+	BYTES = "BYTES"
 	NUM = "NUM"
+	FLOAT = "FLOAT"
 
 	// Punctuation
 	LEFTPAREN = "("
 	RIGHTPAREN = ")"
+	LEFTBRACKET = "["
+	RIGHTBRACKET = "]"
+	LEFTBRACE = "{"
+	RIGHTBRACE = "}"
 	COLON = ":"
 	EQUALS = "="
 	COMMA = ","
+	DOT = "."
 	INDENT = "INDENT"
 
 	// Operations
@@ -46,8 +79,14 @@ const (
 	MULT = "*"
 	DIV = "/"
 	MOD = "%"
-	POW = "^"
-	NOT = "!"
+	POW = "**"
+	FLOORDIV = "//"
+	BITOR = "|"
+	BITXOR = "^"
+	BITAND = "&"
+	LSHIFT = "<<"
+	RSHIFT = ">>"
+	INVERT = "~"
 
 	// Operation Assignment
 	ADDEQ = "+="
@@ -55,7 +94,7 @@ const (
 	MULTEQ = "*="
 	DIVEQ = "/="
 	MODEQ = "%="
-	POWEQ = "^="
+	POWEQ = "**="
 
 	// Comparison
 	LESS = "<"
@@ -66,9 +105,43 @@ const (
 	NOTEQ = "!="
 )
 
+var keywords = map[string]TokenType{
+	"if": IF,
+	"elif": ELIF,
+	"else": ELSE,
+	"while": WHILE,
+	"for": FOR,
+	"in": IN,
+	"is": IS,
+	"and": AND,
+	"or": OR,
+	"not": NOT,
+	"pass": PASS,
+	"break": BREAK,
+	"continue": CONTINUE,
+	"def": DEF,
+	"return": RETURN,
+	"class": CLASS,
+	"import": IMPORT,
+	"from": FROM,
+	"as": AS,
+	"try": TRY,
+	"except": EXCEPT,
+	"finally": FINALLY,
+	"raise": RAISE,
+	"with": WITH,
+	"assert": ASSERT,
+	"del": DEL,
+	"global": GLOBAL,
+	"nonlocal": NONLOCAL,
+	"yield": YIELD,
+	"True": TRUE,
+	"False": FALSE,
+	"None": NONE,
+}
+
 const (
 	_ tokenKey = iota
-	TokenKeyword
 	TokenPunct
 	TokenIdent
 	TokenInt
@@ -81,9 +154,36 @@ type Token struct {
 	Pos  tokenPos
 }
 
+// tokenPos records where a token was lexed. offset and end are the byte
+// offsets of its first character and of the character following it.
 type tokenPos struct {
+	file string
 	row int
 	col int
+	offset int
+	end int
+}
+
+// Position is a location in source code. Line and Column count from 1 and
+// Offset is a byte offset from the start of the source. Filename is empty
+// when the source has no name.
+type Position struct {
+	Filename string
+	Line int
+	Column int
+	Offset int
+}
+
+func (p Position) String() string {
+	if p.Filename == "" {
+		return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s, line %d, column %d", p.Filename, p.Line, p.Column)
+}
+
+// IsValid reports whether the position was recorded by the lexer.
+func (p Position) IsValid() bool {
+	return p.Line > 0
 }
 
 func (t Token) GetPosition() string {
@@ -94,6 +194,17 @@ func (t Token) GetCol() int {
 	return t.Pos.col
 }
 
+// Start returns the position of the token's first character.
+func (t Token) Start() Position {
+	return Position{Filename: t.Pos.file, Line: t.Pos.row, Column: t.Pos.col, Offset: t.Pos.offset}
+}
+
+// End returns the position just past the token's last character.
+func (t Token) End() Position {
+	width := t.Pos.end - t.Pos.offset
+	return Position{Filename: t.Pos.file, Line: t.Pos.row, Column: t.Pos.col + width, Offset: t.Pos.end}
+}
+
 type Lexer struct {
 	index int
 	input string
@@ -102,35 +213,89 @@ type Lexer struct {
 	current rune
 	currentType tokenKey
 	tokens []Token
+	// depth counts open brackets; newlines and tabs inside them are
+	// implicit line joins and produce no tokens.
+	depth int
+	// end is where the EOF token goes: just past the last character
+	// lexed that isn't whitespace or part of a comment.
+	end tokenPos
 }
 
 func StartLex(input string) []Token {
+	return LexFile("", input)
+}
+
+// LexFile lexes input, recording filename in the position of every token.
+func LexFile(filename string, input string) []Token {
 	l := &Lexer{
 		input: input,
 		line: 1,
-		column: 0,
+		column: 1,
+		current: ' ',
+		tokens: []Token{},
+	}
+	return finish(l, filename)
+}
+
+// LexFrom lexes input from the start of the line holding pos, as if no
+// brackets were open there, so that a parser can pick up again after an
+// unclosed bracket. The tokens start with the newline ending the line
+// before.
+func LexFrom(filename string, input string, pos Position) []Token {
+	start := strings.LastIndexByte(input[:pos.Offset], '\n') + 1
+	l := &Lexer{
+		index: start,
+		input: input,
+		line: pos.Line,
+		column: 1,
 		current: ' ',
 		tokens: []Token{},
 	}
+	if start > 0 {
+		l.tokens = append(l.tokens, Token{Name: NL, Val: NL, Pos: tokenPos{row: pos.Line - 1, offset: start - 1, end: start}})
+	}
+	return finish(l, filename)
+}
+
+// finish lexes the rest of l's input and ends the tokens with EOF.
+func finish(l *Lexer, filename string) []Token {
+	l.end = l.pos(0)
 	lex(l)
 	eof := Token{
 		Name: EOF,
 		Val:  "",
-		Pos:  tokenPos{l.line+1, 0},
+		Pos:  l.end,
 	}
 	l.tokens = append(l.tokens, eof)
+	for i := range l.tokens {
+		l.tokens[i].Pos.file = filename
+	}
 	return l.tokens
 }
 
 func lex(l *Lexer) {
-	for ok := true; ok; ok = l.index < len(l.input) {
+	for l.index < len(l.input) {
 		l.current = rune(l.input[l.index])
 		switch l.current {
 		case '\n':
-			l.lexNL()
+			if l.depth == 0 {
+				l.lexNL()
+			}
 			l.nextLine()
 		case '\t':
-			l.lexTab()
+			if l.depth == 0 {
+				l.lexTab(1)
+			} else {
+				l.column += 3
+			}
+		case ' ':
+			// Four spaces of leading indentation count as a tab.
+			if l.depth == 0 && l.atLineStart() && strings.HasPrefix(l.input[l.index:], "    ") {
+				l.lexTab(4)
+				l.index += 3
+			} else {
+				l.currentType = -1
+			}
 		case '=':
 			if nextChar, err := l.peek(); nextChar == '=' && err == nil {
 				l.lexPunct(EQ, "==")
@@ -144,6 +309,10 @@ func lex(l *Lexer) {
 				l.lexPunct(LESSEQ, "<=")
 				l.index++
 				l.column++
+			} else if nextChar == '<' && err == nil {
+				l.lexPunct(LSHIFT, "<<")
+				l.index++
+				l.column++
 			} else {
 				l.lexPunct(LESS, "<")
 			}
@@ -152,21 +321,41 @@ func lex(l *Lexer) {
 				l.lexPunct(GREATEQ, ">=")
 				l.index++
 				l.column++
+			} else if nextChar == '>' && err == nil {
+				l.lexPunct(RSHIFT, ">>")
+				l.index++
+				l.column++
 			} else {
 				l.lexPunct(GREAT, ">")
 			}
 		case '!':
 			if nextChar, err := l.peek(); nextChar == '=' && err == nil {
 				l.lexPunct(NOTEQ, "!=")
+				l.index++
+				l.column++
 			} else {
-				l.lexPunct(NOT, "!")
+				l.tokens = append(l.tokens, Token{Name: ILLEGAL, Val: "!"})
 			}
 		case ':':
 			l.lexPunct(COLON, ":")
 		case '(':
+			l.depth++
 			l.lexPunct(LEFTPAREN, "(")
 		case ')':
+			l.closeBracket()
 			l.lexPunct(RIGHTPAREN, ")")
+		case '[':
+			l.depth++
+			l.lexPunct(LEFTBRACKET, "[")
+		case ']':
+			l.closeBracket()
+			l.lexPunct(RIGHTBRACKET, "]")
+		case '{':
+			l.depth++
+			l.lexPunct(LEFTBRACE, "{")
+		case '}':
+			l.closeBracket()
+			l.lexPunct(RIGHTBRACE, "}")
 		case '+':
 			if nextChar, err := l.peek(); nextChar == '=' && err == nil {
 				l.lexPunct(ADDEQ, "+=")
@@ -184,7 +373,17 @@ func lex(l *Lexer) {
 				l.lexPunct(SUB, "-")
 			}
 		case '*':
-			if nextChar, err := l.peek(); nextChar == '=' && err == nil {
+			if nextChar, err := l.peek(); nextChar == '*' && err == nil {
+				if l.index+2 < len(l.input) && l.input[l.index+2] == '=' {
+					l.lexPunct(POWEQ, "**=")
+					l.index += 2
+					l.column += 2
+				} else {
+					l.lexPunct(POW, "**")
+					l.index++
+					l.column++
+				}
+			} else if nextChar == '=' && err == nil {
 				l.lexPunct(MULTEQ, "*=")
 				l.index++
 				l.column++
@@ -196,17 +395,21 @@ func lex(l *Lexer) {
 				l.lexPunct(DIVEQ, "/=")
 				l.index++
 				l.column++
-			} else {
-				l.lexPunct(DIV, "/")
-			}
-		case '^':
-			if nextChar, err := l.peek(); nextChar == '=' && err == nil {
-				l.lexPunct(POWEQ, "^=")
+			} else if nextChar == '/' && err == nil {
+				l.lexPunct(FLOORDIV, "//")
 				l.index++
 				l.column++
 			} else {
-				l.lexPunct(POW, "^")
+				l.lexPunct(DIV, "/")
 			}
+		case '^':
+			l.lexPunct(BITXOR, "^")
+		case '|':
+			l.lexPunct(BITOR, "|")
+		case '&':
+			l.lexPunct(BITAND, "&")
+		case '~':
+			l.lexPunct(INVERT, "~")
 		case '%':
 			if nextChar, err := l.peek(); nextChar == '=' && err == nil {
 				l.lexPunct(MODEQ, "%=")
@@ -217,198 +420,232 @@ func lex(l *Lexer) {
 			}
 		case ',':
 			l.lexPunct(COMMA, ",")
+		case '.':
+			if next, err := l.peek(); err == nil && unicode.IsDigit(next) && l.currentType != TokenIdent {
+				l.lexNumber()
+			} else {
+				l.lexPunct(DOT, ".")
+			}
 		case '"':
-			l.lexString()
+			if l.currentType == TokenIdent && l.tokens[len(l.tokens)-1].Val == "b" {
+				l.lexBytes()
+			} else {
+				l.lexString()
+			}
+		case '#':
+			// A line holding only a comment produces no tokens at all,
+			// while a comment following code still ends its line.
+			if l.atLineStart() {
+				l.dropIndents()
+				l.nextLine()
+			} else {
+				l.skipComment()
+			}
 		default:
 			if unicode.IsSpace(l.current) {
 				l.currentType = -1
-			} else if l.current == '\n' || l.current == '#' {
-				l.nextLine()
 			} else if unicode.IsDigit(l.current) {
-				if l.currentType == TokenIdent || l.currentType == TokenKeyword {
+				if l.currentType == TokenIdent {
 					l.lexText(string(l.current))
 				} else {
-					l.lexInt()
+					l.lexNumber()
 				}
 			} else if unicode.IsLetter(l.current) || l.current == '_' {
 				l.lexText(string(l.current))
 			} else {
-				l.tokens = append(l.tokens, Token{Name: ILLEGAL, Val: "nil"})
+				l.tokens = append(l.tokens, Token{Name: ILLEGAL, Val: "nil", Pos: l.pos(1)})
 			}
 		}
 		l.index++
 		l.column++
+		if l.current != '#' && !unicode.IsSpace(rune(l.input[l.index-1])) {
+			l.end = l.pos(0)
+		}
 	}
 }
 
 func (l *Lexer) peek() (rune, error) {
-	if l.index+1 != len(l.input) {
+	if l.index+1 < len(l.input) {
 		return rune(l.input[l.index+1]), nil
 	}
 	return ' ', errors.New("end of input")
 }
 
-func (l *Lexer) lexTab() {
+func (l *Lexer) closeBracket() {
+	if l.depth > 0 {
+		l.depth--
+	}
+}
+
+// lexTab emits an INDENT token for one level of indentation written with
+// width characters.
+func (l *Lexer) lexTab(width int) {
 	var tok Token
 	tok.Name = INDENT
 	tok.Val = INDENT
-	tok.Pos = tokenPos{
-		row: l.line,
-		col: l.column,
-	}
+	tok.Pos = l.pos(width)
 	l.column += 3
 	l.tokens = append(l.tokens, tok)
 }
 
+// atLineStart reports whether only whitespace precedes the current
+// character on its line.
+func (l *Lexer) atLineStart() bool {
+	for i := l.index - 1; i >= 0 && l.input[i] != '\n'; i-- {
+		if l.input[i] != ' ' && l.input[i] != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// pos returns the position of a token of width bytes starting at the
+// current character.
+func (l *Lexer) pos(width int) tokenPos {
+	return tokenPos{row: l.line, col: l.column, offset: l.index, end: l.index + width}
+}
+
 func (l *Lexer) lexPunct(name TokenType, val string) {
 	var tok Token
 	l.currentType = TokenPunct
 	tok.Name = name
 	tok.Val = val
-	tok.Pos = tokenPos{
-		row: l.line,
-		col: l.column,
-	}
+	tok.Pos = l.pos(len(val))
 	l.tokens = append(l.tokens, tok)
 }
 
 func (l *Lexer) lexText(val string) {
-	reserved := []string{
-		"if",
-		"elif",
-		"else",
-		"while",
-		"for",
-		"in",
-		"print",
-		"int",
-		"str",
-		"and",
-		"or",
-	}
-
 	var tok Token
-	if l.currentType == TokenKeyword {
-		l.currentType = TokenIdent
-		tok = l.tokens[len(l.tokens)-1]
-		l.tokens = l.tokens[:len(l.tokens)-1]
-		tok.Name = IDENT
-		tok.Val += val
-	} else if l.currentType == TokenIdent {
+	if l.currentType == TokenIdent {
 		tok = l.tokens[len(l.tokens)-1]
 		l.tokens = l.tokens[:len(l.tokens)-1]
 		tok.Val += val
+		tok.Pos.end = l.index + len(val)
 	} else {
 		l.currentType = TokenIdent
-		tok.Name = IDENT
 		tok.Val = val
-		tok.Pos = tokenPos{
-			row: l.line,
-			col: l.column,
-		}
-	}
-	for _, keyword := range reserved {
-		if tok.Val == keyword {
-			switch tok.Val {
-			case "if":
-				tok.Name = IF
-			case "elif":
-				tok.Name = ELIF
-			case "else":
-				tok.Name = ELSE
-			case "while":
-				tok.Name = WHILE
-			case "for":
-				tok.Name = FOR
-			case "in":
-				tok.Name = IN
-			case "print":
-				tok.Name = PRINT
-			case "int":
-				tok.Name = INT
-			case "str":
-				tok.Name = STR
-			case "and":
-				tok.Name = AND
-			case "or":
-				tok.Name = OR
-			}
-		}
+		tok.Pos = l.pos(len(val))
 	}
+	// The word is re-classified on every character so that identifiers
+	// which merely start with a keyword, like "interval", stay identifiers.
+	tok.Name = LookupIdent(tok.Val)
 	l.tokens = append(l.tokens, tok)
 }
 
+// LookupIdent returns the keyword token type for word, or IDENT.
+func LookupIdent(word string) TokenType {
+	if name, ok := keywords[word]; ok {
+		return name
+	}
+	return IDENT
+}
+
+// lexString lexes a string literal. One missing its closing quote gives an
+// ILLEGAL token holding Unterminated.
 func (l *Lexer) lexString() {
 	var tok Token
-	l.index++
-	start := l.index
+	tok.Pos = l.pos(1)
 	l.currentType = TokenString
-	next, err := l.peek()
-	if err != nil {
+	start := l.index + 1
+	end := strings.IndexByte(l.input[start:], '"')
+	if end < 0 {
 		tok.Name = ILLEGAL
-		tok.Val = ""
-		tok.Pos = tokenPos{
-			row: l.line,
-			col: l.column,
-		}
+		tok.Val = Unterminated
+		l.index = len(l.input) - 1
+		tok.Pos.end = len(l.input)
+		l.column += l.index - tok.Pos.offset
 		l.tokens = append(l.tokens, tok)
 		return
 	}
-	for next != '"' {
-		l.index++
-		if next, err = l.peek(); err != nil {
-			break
-		}
-	}
 	tok.Name = STRING
-	tok.Val = l.input[start:l.index+1]
-	tok.Pos = tokenPos{
-		row: l.line,
-		col: l.column,
-	}
+	tok.Val = l.input[start:start+end]
+	l.index = start + end
+	tok.Pos.end = l.index + 1
+	l.column += l.index - tok.Pos.offset
 	l.tokens = append(l.tokens, tok)
-	l.index++
 }
 
-func (l *Lexer) lexInt() {
+// lexBytes lexes a b"..." literal. The b has already been lexed as an
+// identifier, so its token is replaced by the literal.
+func (l *Lexer) lexBytes() {
+	prefix := l.tokens[len(l.tokens)-1]
+	l.tokens = l.tokens[:len(l.tokens)-1]
+	l.lexString()
+	tok := &l.tokens[len(l.tokens)-1]
+	if tok.Name == STRING {
+		tok.Name = BYTES
+	}
+	tok.Pos.col = prefix.Pos.col
+	tok.Pos.offset = prefix.Pos.offset
+}
+
+// lexNumber lexes an integer, or a float if the digits have a fractional
+// part or an exponent.
+func (l *Lexer) lexNumber() {
 	var tok Token
+	tok.Pos = l.pos(1)
 	start := l.index
 	l.currentType = TokenInt
-	next, err := l.peek()
-	if err != nil {
-		tok.Name = NUM
-		tok.Val = string(l.input[start])
-		tok.Pos = tokenPos{
-			row: l.line,
-			col: l.column,
-		}
-		l.tokens = append(l.tokens, tok)
-		return
-	}
-	for unicode.IsDigit(next) {
+	tok.Name = NUM
+	l.skipDigits()
+	if l.input[start] == '.' {
+		tok.Name = FLOAT
+	} else if next, err := l.peek(); err == nil && next == '.' {
+		tok.Name = FLOAT
 		l.index++
-		if next, err = l.peek(); err != nil {
-			break
+		l.skipDigits()
+	}
+	if next, err := l.peek(); err == nil && (next == 'e' || next == 'E') {
+		digits := l.index + 2
+		if digits < len(l.input) && (l.input[digits] == '+' || l.input[digits] == '-') {
+			digits++
+		}
+		if digits < len(l.input) && unicode.IsDigit(rune(l.input[digits])) {
+			tok.Name = FLOAT
+			l.index = digits
+			l.skipDigits()
 		}
 	}
-	tok.Name = NUM
 	tok.Val = l.input[start:l.index+1]
-	tok.Pos = tokenPos{
-		row: l.line,
-		col: l.column,
-	}
+	tok.Pos.end = l.index + 1
+	l.column += l.index - start
 	l.tokens = append(l.tokens, tok)
 }
 
+// skipDigits advances over the digits following the current character.
+func (l *Lexer) skipDigits() {
+	next, err := l.peek()
+	for err == nil && unicode.IsDigit(next) {
+		l.index++
+		next, err = l.peek()
+	}
+}
+
 func (l *Lexer) lexNL() {
 	tok := Token{
 		Name: NL,
 		Val:  NL,
-		Pos:  tokenPos{l.line, l.column},
+		Pos:  l.pos(1),
 	}
 	l.tokens = append(l.tokens, tok)
 }
 
+// dropIndents removes the indentation tokens lexed on the current line.
+func (l *Lexer) dropIndents() {
+	for n := len(l.tokens); n > 0 && l.tokens[n-1].Name == INDENT && l.tokens[n-1].Pos.row == l.line; n-- {
+		l.tokens = l.tokens[:n-1]
+	}
+}
+
+// skipComment moves to the last character of a comment, leaving the
+// newline that ends it to be lexed.
+func (l *Lexer) skipComment() {
+	l.currentType = -1
+	for l.index+1 < len(l.input) && l.input[l.index+1] != '\n' {
+		l.index++
+	}
+}
+
 func (l *Lexer) nextLine() {
 	l.line++
 	l.column = 0
@@ -75,4 +75,234 @@ print("EQ2: "+str(eq2))
 	}
 	t.Logf("lex(\"a\"); got {%s, %s, {%d,%d}}", got.Name, got.Val, got.Pos.row, got.Pos.col)
 	t.Log(tokens)
-}
\ No newline at end of file
+}
+
+func TestLexNotEq(t *testing.T) {
+	tokens := StartLex("a != b")
+	want := []TokenType{IDENT, NOTEQ, IDENT, EOF}
+	if len(tokens) != len(want) {
+		t.Fatalf("lex(\"a != b\"); want %d tokens; got %v", len(want), tokens)
+	}
+	for i, tok := range tokens {
+		if tok.Name != want[i] {
+			t.Errorf("token %d; want %s; got %s", i, want[i], tok.Name)
+		}
+	}
+}
+
+func TestLexKeywords(t *testing.T) {
+	tests := []struct {
+		input string
+		want  TokenType
+	}{
+		{"not", NOT},
+		{"nothing", IDENT},
+		{"interval", IDENT},
+		{"in", IN},
+		{"is", IS},
+		{"island", IDENT},
+		{"order", IDENT},
+		{"if2", IDENT},
+		{"True", TRUE},
+		{"False", FALSE},
+		{"None", NONE},
+		{"true", IDENT},
+		{"int", IDENT},
+		{"str", IDENT},
+	}
+	for _, tt := range tests {
+		got := StartLex(tt.input)[0]
+		if got.Name != tt.want || got.Val != tt.input {
+			t.Errorf("lex(%q); want %s; got %s %q", tt.input, tt.want, got.Name, got.Val)
+		}
+	}
+}
+
+func TestLexPow(t *testing.T) {
+	tokens := StartLex("a ** b **= c * d")
+	want := []TokenType{IDENT, POW, IDENT, POWEQ, IDENT, MULT, IDENT, EOF}
+	if len(tokens) != len(want) {
+		t.Fatalf("want %d tokens; got %v", len(want), tokens)
+	}
+	for i, tok := range tokens {
+		if tok.Name != want[i] {
+			t.Errorf("token %d; want %s; got %s", i, want[i], tok.Name)
+		}
+	}
+}
+
+func TestLexNumbers(t *testing.T) {
+	tests := []struct {
+		input string
+		want  TokenType
+	}{
+		{"12", NUM},
+		{"1.5", FLOAT},
+		{"2.", FLOAT},
+		{".25", FLOAT},
+		{"1e3", FLOAT},
+		{"1.5E-2", FLOAT},
+		{"3e+4", FLOAT},
+	}
+	for _, tt := range tests {
+		tokens := StartLex(tt.input)
+		if len(tokens) != 2 || tokens[0].Name != tt.want || tokens[0].Val != tt.input {
+			t.Errorf("lex(%q); want %s %q; got %v", tt.input, tt.want, tt.input, tokens)
+		}
+	}
+	tokens := StartLex("x.y 1e")
+	want := []TokenType{IDENT, DOT, IDENT, NUM, IDENT, EOF}
+	if len(tokens) != len(want) {
+		t.Fatalf("want %d tokens; got %v", len(want), tokens)
+	}
+	for i, tok := range tokens {
+		if tok.Name != want[i] {
+			t.Errorf("token %d; want %s; got %s", i, want[i], tok.Name)
+		}
+	}
+}
+
+func TestLexBitwise(t *testing.T) {
+	tokens := StartLex("a | b ^ c & d << e >> f // g ~h")
+	want := []TokenType{IDENT, BITOR, IDENT, BITXOR, IDENT, BITAND, IDENT, LSHIFT, IDENT, RSHIFT, IDENT, FLOORDIV, IDENT, INVERT, IDENT, EOF}
+	if len(tokens) != len(want) {
+		t.Fatalf("want %d tokens; got %v", len(want), tokens)
+	}
+	for i, tok := range tokens {
+		if tok.Name != want[i] {
+			t.Errorf("token %d; want %s; got %s", i, want[i], tok.Name)
+		}
+	}
+}
+
+func TestLexPositions(t *testing.T) {
+	input := "x = \"hi\" + 12\nif x:\n    y\n"
+	tokens := StartLex(input)
+	tests := []struct {
+		index int
+		text string
+		line int
+		column int
+	}{
+		{0, "x", 1, 1},
+		{2, "\"hi\"", 1, 5},
+		{3, "+", 1, 10},
+		{4, "12", 1, 12},
+		{6, "if", 2, 1},
+		{10, "    ", 3, 1},
+		{11, "y", 3, 5},
+	}
+	for _, tt := range tests {
+		start, end := tokens[tt.index].Start(), tokens[tt.index].End()
+		if got := input[start.Offset:end.Offset]; got != tt.text {
+			t.Errorf("token %d; want text %q; got %q", tt.index, tt.text, got)
+		}
+		if start.Line != tt.line || start.Column != tt.column {
+			t.Errorf("token %d; want line %d, column %d; got %s", tt.index, tt.line, tt.column, start)
+		}
+	}
+}
+
+func TestLexFrom(t *testing.T) {
+	input := "f(\nx = 1\n"
+	joined := StartLex(input)
+	if len(joined) != 6 || joined[2].Name != IDENT || joined[5].Name != EOF {
+		t.Fatalf("want the lines after an open bracket joined; got %v", joined)
+	}
+	tokens := LexFrom("", input, joined[2].Start())
+	want := []TokenType{NL, IDENT, EQUALS, NUM, NL, EOF}
+	if len(tokens) != len(want) {
+		t.Fatalf("want %d tokens; got %v", len(want), tokens)
+	}
+	for i, tok := range tokens {
+		if tok.Name != want[i] {
+			t.Errorf("token %d; want %s; got %s", i, want[i], tok.Name)
+		}
+	}
+	if start := tokens[1].Start(); start != joined[2].Start() {
+		t.Errorf("want x at %s as before; got %s", joined[2].Start(), start)
+	}
+}
+
+func TestLexEmptyString(t *testing.T) {
+	input := `f("", "a")`
+	tokens := StartLex(input)
+	want := []TokenType{IDENT, LEFTPAREN, STRING, COMMA, STRING, RIGHTPAREN, EOF}
+	if len(tokens) != len(want) {
+		t.Fatalf("want %d tokens; got %v", len(want), tokens)
+	}
+	for i, tok := range tokens {
+		if tok.Name != want[i] {
+			t.Errorf("token %d; want %s; got %s", i, want[i], tok.Name)
+		}
+	}
+	if start, end := tokens[2].Start(), tokens[2].End(); input[start.Offset:end.Offset] != `""` || tokens[2].Val != "" {
+		t.Errorf("empty string; want text %q; got %q with value %q", `""`, input[start.Offset:end.Offset], tokens[2].Val)
+	}
+	if got := tokens[3].Start().Column; got != 5 {
+		t.Errorf("comma; want column 5; got %d", got)
+	}
+}
+
+func TestLexUnterminatedString(t *testing.T) {
+	for _, input := range []string{`"`, `x = "ab`, `b"`, `f("`} {
+		tokens := StartLex(input)
+		tok := tokens[len(tokens)-2]
+		if tok.Name != ILLEGAL || tok.Val != Unterminated {
+			t.Errorf("StartLex(%q); want an unterminated string before EOF; got %v", input, tokens)
+		}
+		if end := tok.End().Offset; end != len(input) {
+			t.Errorf("StartLex(%q); want the string to end at %d; got %d", input, len(input), end)
+		}
+	}
+}
+
+func TestLexComments(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []TokenType
+	}{
+		{"a # note\nb", []TokenType{IDENT, NL, IDENT, EOF}},
+		{"a\n\t# note\nb", []TokenType{IDENT, NL, IDENT, EOF}},
+		{"# note\na", []TokenType{IDENT, EOF}},
+		{"a #", []TokenType{IDENT, EOF}},
+		{"\"#\" # note", []TokenType{STRING, EOF}},
+	}
+	for _, tt := range tests {
+		tokens := StartLex(tt.input)
+		if len(tokens) != len(tt.want) {
+			t.Errorf("lex(%q); want %d tokens; got %v", tt.input, len(tt.want), tokens)
+			continue
+		}
+		for i, tok := range tokens {
+			if tok.Name != tt.want[i] {
+				t.Errorf("lex(%q) token %d; want %s; got %s", tt.input, i, tt.want[i], tok.Name)
+			}
+		}
+	}
+}
+
+func TestLexBytes(t *testing.T) {
+	input := `x = b"ab" + b""`
+	tokens := StartLex(input)
+	want := []TokenType{IDENT, EQUALS, BYTES, ADD, BYTES, EOF}
+	if len(tokens) != len(want) {
+		t.Fatalf("want %d tokens; got %v", len(want), tokens)
+	}
+	for i, tok := range tokens {
+		if tok.Name != want[i] {
+			t.Errorf("token %d; want %s; got %s", i, want[i], tok.Name)
+		}
+	}
+	if start, end := tokens[2].Start(), tokens[2].End(); input[start.Offset:end.Offset] != `b"ab"` || tokens[2].Val != "ab" {
+		t.Errorf("bytes; want text %q; got %q with value %q", `b"ab"`, input[start.Offset:end.Offset], tokens[2].Val)
+	}
+	if got := tokens[3].Start().Column; got != 11 {
+		t.Errorf("plus; want column 11; got %d", got)
+	}
+	for _, tok := range StartLex(`ab"x" b "x"`)[:2] {
+		if tok.Name == BYTES {
+			t.Errorf("only a lone b directly before a string makes bytes; got %v", tok)
+		}
+	}
+}
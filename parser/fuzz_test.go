@@ -0,0 +1,26 @@
+//go:build go1.18
+// +build go1.18
+
+package parser
+
+import "testing"
+
+// FuzzParse checks that no source makes the parser panic.
+func FuzzParse(f *testing.F) {
+	for _, src := range []string{
+		"x = \"",
+		"\"",
+		"print(\"",
+		"b\"",
+		"for 0%",
+		"x = 1 % = 2",
+		"with f as 1 +:\n\tpass",
+		"def f(:\n\tpass",
+		"del 0% ",
+	} {
+		f.Add([]byte(src))
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		Parse(src)
+	})
+}
@@ -3,53 +3,87 @@ package parser
 import (
 	"fmt"
 	"gopy/ast"
+	"io"
 	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"gopy/lexer"
 )
 
+// Binding powers from loosest to tightest, following Python's operator
+// precedence table.
 const (
 	_ int = iota
 	LOWEST
-	EQUALS
-	GTLT
+	TERNARY
+	OR
+	AND
+	NOT
+	COMPARE
+	BITOR
+	BITXOR
+	BITAND
+	SHIFT
 	SUM
 	PRODUCT
 	PREFIX
+	POWER
 	CALL
-	AND
 )
 var precedence = map[lexer.TokenType]int{
-	lexer.EQ: EQUALS,
-	lexer.NOTEQ: EQUALS,
-	lexer.LESS: GTLT,
-	lexer.LESSEQ: GTLT,
-	lexer.GREAT: GTLT,
-	lexer.GREATEQ: GTLT,
-	lexer.AND: GTLT,
-	lexer.OR: GTLT,
+	lexer.IF: TERNARY,
+	lexer.OR: OR,
+	lexer.AND: AND,
+	lexer.EQ: COMPARE,
+	lexer.NOTEQ: COMPARE,
+	lexer.LESS: COMPARE,
+	lexer.LESSEQ: COMPARE,
+	lexer.GREAT: COMPARE,
+	lexer.GREATEQ: COMPARE,
+	lexer.IS: COMPARE,
+	lexer.IN: COMPARE,
+	lexer.NOT: COMPARE,
+	lexer.BITOR: BITOR,
+	lexer.BITXOR: BITXOR,
+	lexer.BITAND: BITAND,
+	lexer.LSHIFT: SHIFT,
+	lexer.RSHIFT: SHIFT,
 	lexer.ADD: SUM,
-	lexer.ADDEQ: SUM,
 	lexer.SUB: SUM,
-	lexer.SUBEQ: SUM,
-	lexer.DIV: PRODUCT,
-	lexer.DIVEQ: PRODUCT,
 	lexer.MULT: PRODUCT,
-	lexer.MULTEQ: PRODUCT,
+	lexer.DIV: PRODUCT,
+	lexer.FLOORDIV: PRODUCT,
+	lexer.MOD: PRODUCT,
+	lexer.POW: POWER,
 	lexer.LEFTPAREN: CALL,
+	lexer.LEFTBRACKET: CALL,
+	lexer.DOT: CALL,
 }
 
 type Parser struct {
 	tokens         []lexer.Token
 	index          int
 	statements     []ast.Stmt
-	errors         []string
+	errors         []Error
 	prefixParseFns map[lexer.TokenType]prefixParseFn
 	infixParseFns  map[lexer.TokenType]infixParseFn
 	indentLevel    int
+	loopDepth      int
+	funcDepth      int
+	// yields records whether the function body being parsed contains yield.
+	yields         bool
+	// recovered counts the errors after which parsing resynchronized.
+	recovered      int
+	// stmtErrs counts the errors recorded before the statement being
+	// parsed began.
+	stmtErrs       int
+	// name and src are the source being parsed, lexed again from where
+	// parsing resynchronizes inside an unclosed bracket.
+	name           string
+	src            string
 }
 
 type (
@@ -57,79 +91,210 @@ type (
 	infixParseFn func(expr ast.Expr) ast.Expr
 )
 
-func StartParse(path string) []ast.Stmt {
-	file, _ := os.Open(path)
+// SyntaxError lists the syntax errors found in a program, in source order.
+// Filename names the source in messages when it is known.
+type SyntaxError struct {
+	Filename string
+	Errors []Error
+}
+
+// Error is one syntax error: where it was found and what is wrong there.
+type Error struct {
+	Pos lexer.Position
+	Msg string
+}
+
+// String returns the error as the parser reports it, without the file name.
+// Errors with no position are reported by message alone.
+func (e Error) String() string {
+	if !e.Pos.IsValid() {
+		return "error: " + e.Msg
+	}
+	return fmt.Sprintf("error at line %d, column %d: %s", e.Pos.Line, e.Pos.Column, e.Msg)
+}
+
+func (e *SyntaxError) Error() string {
+	prefix := ""
+	if e.Filename != "" {
+		prefix = e.Filename + ": "
+	}
+	lines := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		lines[i] = prefix + err.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Messages returns the errors as the parser reports them.
+func (e *SyntaxError) Messages() []string {
+	return messages(e.Errors)
+}
+
+// Parse parses a whole program. If src has syntax errors the error is a
+// *SyntaxError reporting all of them.
+func Parse(src []byte) (*ast.Program, error) {
+	return parseSource("", string(src))
+}
+
+// ParseReader parses the program read from r. name identifies the source
+// in error messages, like a file name.
+func ParseReader(name string, r io.Reader) (*ast.Program, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", name, err)
+	}
+	return parseSource(name, string(src))
+}
+
+// ParseFile reads and parses the program at path.
+func ParseFile(path string) (*ast.Program, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
 	defer file.Close()
-	fileContents, _ := ioutil.ReadFile(path)
-	formattedContents := strings.ReplaceAll(string(fileContents), "    ", "\t")
-	tokens := lexer.StartLex(formattedContents)
-	if len(tokens) == 0 {
-		panic("no tokens to parse")
+	return ParseReader(path, file)
+}
+
+// ParseExpr parses a single expression, such as a formula read from a
+// config file. Surrounding blank lines are ignored, but statements and
+// anything else following the expression are syntax errors.
+func ParseExpr(src string) (ast.Expr, error) {
+	p := newParser("", src)
+	for p.checkCurrent(lexer.NL) || p.checkCurrent(lexer.INDENT) {
+		p.next()
+	}
+	var expr ast.Expr
+	switch p.current().Name {
+	case lexer.EOF, lexer.IF, lexer.WHILE:
+		p.unexpected(p.current())
+	default:
+		expr = p.parseTupleOrExpr()
+	}
+	if len(p.errors) == 0 {
+		for p.checkPeek(lexer.NL) || p.checkPeek(lexer.INDENT) {
+			p.next()
+		}
+		if !p.checkPeek(lexer.EOF) {
+			p.unexpected(p.peek())
+		}
+	}
+	if len(p.errors) != 0 {
+		return nil, &SyntaxError{Errors: p.errors}
 	}
+	return expr, nil
+}
 
-	p := Parser{
-		tokens:     tokens,
-		index:      0,
-		statements: []ast.Stmt{},
-		errors:     []string{},
-		indentLevel: 0,
+func parseSource(name string, src string) (*ast.Program, error) {
+	p := newParser(name, src)
+	parse(p)
+	if len(p.errors) != 0 {
+		return nil, &SyntaxError{Filename: name, Errors: p.errors}
 	}
-	p.registerFixes()
-	stmts := parse(&p)
-	return stmts
+	return &ast.Program{Stmts: p.statements, Doc: docstring(p.statements)}, nil
+}
+
+// StartParse parses the program at path, ignoring errors.
+//
+// Deprecated: use ParseFile, which reports unreadable files and syntax
+// errors.
+func StartParse(path string) []ast.Stmt {
+	fileContents, _ := ioutil.ReadFile(path)
+	return parse(newParser(path, string(fileContents)))
 }
 
+// StartParseRepl parses input, leaving its errors on the returned parser.
+//
+// Deprecated: use Parse.
 func StartParseRepl(input string) (*Parser, ast.Program) {
-	formatted := strings.ReplaceAll(input, "    ", "\t")
-	tokens := lexer.StartLex(formatted)
-	if len(tokens) == 0 {
-		panic("no tokens to parse")
-	}
-	p := Parser{
-		tokens:     tokens,
+	p := newParser("", input)
+	parse(p)
+	return p, ast.Program{Stmts: p.statements, Doc: docstring(p.statements)}
+}
+
+func newParser(name string, src string) *Parser {
+	p := &Parser{
+		tokens:     lexer.LexFile(name, src),
 		index:      0,
 		statements: []ast.Stmt{},
-		errors:     []string{},
+		errors:     []Error{},
+		name:       name,
+		src:        src,
 	}
 	p.registerFixes()
-	parse(&p)
-	return &p, ast.Program{Stmts: p.statements}
+	return p
+}
+
+// docstring returns the string literal opening a body, or "" if it does not
+// start with one.
+func docstring(stmts []ast.Stmt) string {
+	if len(stmts) == 0 {
+		return ""
+	}
+	if stmt, ok := stmts[0].(*ast.ExprStmt); ok {
+		if str, ok := stmt.Expr.(*ast.StrLiteral); ok {
+			return str.Value
+		}
+	}
+	return ""
 }
 
 func (p *Parser) registerFixes() {
 	p.prefixParseFns = make(map[lexer.TokenType]prefixParseFn)
 	p.registerPrefix(lexer.IDENT, p.parseIdent)
 	p.registerPrefix(lexer.NUM, p.parseIntLiteral)
+	p.registerPrefix(lexer.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(lexer.STRING, p.parseStrLiteral)
+	p.registerPrefix(lexer.BYTES, p.parseBytesLiteral)
 	p.registerPrefix(lexer.SUB, p.parsePrefixExpr)
+	p.registerPrefix(lexer.ADD, p.parsePrefixExpr)
+	p.registerPrefix(lexer.INVERT, p.parsePrefixExpr)
+	p.registerPrefix(lexer.MULT, p.parseStarredExpr)
+	p.registerPrefix(lexer.NOT, p.parseNotExpr)
 	p.registerPrefix(lexer.LEFTPAREN, p.parseGroupingExpr)
+	p.registerPrefix(lexer.LEFTBRACKET, p.parseListLiteral)
+	p.registerPrefix(lexer.LEFTBRACE, p.parseDictLiteral)
 	p.registerPrefix(lexer.IF, p.parseIfExpr)
 	p.registerPrefix(lexer.WHILE, p.parseWhileExpr)
-	p.registerPrefix(lexer.STR, p.parseStrLiteral)
-	p.registerPrefix(lexer.INT, p.parseIntLiteral)
+	p.registerPrefix(lexer.YIELD, p.parseYieldExpr)
+	p.registerPrefix(lexer.TRUE, p.parseBoolLiteral)
+	p.registerPrefix(lexer.FALSE, p.parseBoolLiteral)
+	p.registerPrefix(lexer.NONE, p.parseNoneLiteral)
 
 	p.infixParseFns = make(map[lexer.TokenType]infixParseFn)
-	p.registerInfix(lexer.EQ, p.parseInfixExpr)
-	p.registerInfix(lexer.NOTEQ, p.parseInfixExpr)
+	p.registerInfix(lexer.EQ, p.parseCompareExpr)
+	p.registerInfix(lexer.NOTEQ, p.parseCompareExpr)
 	p.registerInfix(lexer.ADD, p.parseInfixExpr)
-	p.registerInfix(lexer.ADDEQ, p.parseInfixExpr)
 	p.registerInfix(lexer.SUB, p.parseInfixExpr)
-	p.registerInfix(lexer.SUBEQ, p.parseInfixExpr)
 	p.registerInfix(lexer.DIV, p.parseInfixExpr)
-	p.registerInfix(lexer.DIVEQ, p.parseInfixExpr)
 	p.registerInfix(lexer.MULT, p.parseInfixExpr)
-	p.registerInfix(lexer.MULTEQ, p.parseInfixExpr)
-	p.registerInfix(lexer.GREAT, p.parseInfixExpr)
-	p.registerInfix(lexer.GREATEQ, p.parseInfixExpr)
-	p.registerInfix(lexer.LESSEQ, p.parseInfixExpr)
+	p.registerInfix(lexer.FLOORDIV, p.parseInfixExpr)
+	p.registerInfix(lexer.MOD, p.parseInfixExpr)
+	p.registerInfix(lexer.POW, p.parsePowerExpr)
+	p.registerInfix(lexer.BITOR, p.parseInfixExpr)
+	p.registerInfix(lexer.BITXOR, p.parseInfixExpr)
+	p.registerInfix(lexer.BITAND, p.parseInfixExpr)
+	p.registerInfix(lexer.LSHIFT, p.parseInfixExpr)
+	p.registerInfix(lexer.RSHIFT, p.parseInfixExpr)
+	p.registerInfix(lexer.GREAT, p.parseCompareExpr)
+	p.registerInfix(lexer.GREATEQ, p.parseCompareExpr)
+	p.registerInfix(lexer.LESS, p.parseCompareExpr)
+	p.registerInfix(lexer.LESSEQ, p.parseCompareExpr)
+	p.registerInfix(lexer.IS, p.parseCompareExpr)
+	p.registerInfix(lexer.IN, p.parseCompareExpr)
+	p.registerInfix(lexer.NOT, p.parseCompareExpr)
 	p.registerInfix(lexer.AND, p.parseInfixExpr)
+	p.registerInfix(lexer.OR, p.parseInfixExpr)
 	p.registerInfix(lexer.LEFTPAREN, p.parseCallExpr)
+	p.registerInfix(lexer.LEFTBRACKET, p.parseIndexExpr)
+	p.registerInfix(lexer.DOT, p.parseAttributeExpr)
+	p.registerInfix(lexer.IF, p.parseTernaryExpr)
 }
 
 func parse(p *Parser) []ast.Stmt {
 	for !p.end() {
 		//statement, err := p.declaration()
-		stmt := p.parseStmt()
+		stmt := p.parseStmtOrSync()
 		if stmt != nil {
 			p.statements = append(p.statements, stmt)
 		}
@@ -138,206 +303,1475 @@ func parse(p *Parser) []ast.Stmt {
 	return p.statements
 }
 
+// parseStmtOrSync parses a statement. A statement that fails to parse is
+// dropped and the parser synchronizes on the next line at the current
+// indentation, so each mistake is reported once and parsing carries on to
+// find the rest. Tokens left over after a complete statement are an error.
+func (p *Parser) parseStmtOrSync() ast.Stmt {
+	errs, recovered, stmtErrs := len(p.errors), p.recovered, p.stmtErrs
+	p.stmtErrs = errs
+	stmt := p.parseStmt()
+	p.stmtErrs = stmtErrs
+	if len(p.errors) == errs && !p.checkCurrent(lexer.NL) && !p.checkPeek(lexer.NL) && !p.checkPeek(lexer.EOF) && !p.end() {
+		p.unexpected(p.peek())
+	}
+	// Errors from statements nested in this one's blocks have already been
+	// recovered from.
+	nested := p.recovered - recovered
+	if len(p.errors)-errs == nested {
+		return stmt
+	}
+	if nested == 0 {
+		// Later errors in the same statement are usually fallout from the
+		// first one.
+		p.errors = p.errors[:errs+1]
+	}
+	p.recovered = recovered + len(p.errors) - errs
+	p.synchronize()
+	return nil
+}
+
+// synchronize skips the rest of the current line and any lines indented
+// beneath it, stopping just before the newline that ends them. An unclosed
+// bracket makes the lexer join the lines after it, so a statement starting
+// on a later line ends the skipping too, and the source is lexed again from
+// there.
+func (p *Parser) synchronize() {
+	for next := p.nextLineStart(); p.tokens[next].Name != lexer.EOF && 4*(p.indentLevel+1) <= p.tokens[next].GetCol(); next = p.nextLineStart() {
+		p.index = next
+	}
+	for !p.end() && !p.checkCurrent(lexer.NL) && !p.checkPeek(lexer.NL) && !p.checkPeek(lexer.EOF) {
+		if p.startsStmt(p.index + 1) {
+			tokens := append([]lexer.Token{}, p.tokens[:p.index+1]...)
+			p.tokens = append(tokens, lexer.LexFrom(p.name, p.src, p.tokens[p.index+1].Start())...)
+			return
+		}
+		p.next()
+	}
+}
+
+// startsStmt reports whether the token at index i begins a line, at most
+// as indented as the block being parsed, with a name or keyword, like a
+// statement would.
+func (p *Parser) startsStmt(i int) bool {
+	tok := p.tokens[i]
+	return tok.Start().Line > p.tokens[i-1].Start().Line && tok.GetCol() <= 4*p.indentLevel+1 && tok.Name == lexer.LookupIdent(tok.Val)
+}
+
 func (p *Parser) parseStmt() ast.Stmt {
 	switch p.current().Name {
 	case lexer.IDENT:
+		if p.isMatchHeader() {
+			return p.parseMatchStmt()
+		}
 		if p.peek().Name == lexer.EQUALS {
 			return p.parseVarStmt()
 		} else {
 			return p.parseExprStmt()
 		}
-	case lexer.NL:
+	case lexer.PASS:
+		return &ast.PassStmt{Token: p.current()}
+	case lexer.BREAK, lexer.CONTINUE:
+		return p.parseLoopControlStmt()
+	case lexer.DEF:
+		return p.parseFunctionDef()
+	case lexer.FOR:
+		return p.parseForStmt()
+	case lexer.RETURN:
+		return p.parseReturnStmt()
+	case lexer.CLASS:
+		return p.parseClassDef()
+	case lexer.IMPORT:
+		stmt := &ast.ImportStmt{Token: p.current()}
+		stmt.Names, stmt.Aliases = p.parseImportNames()
+		if stmt.Names == nil {
+			return nil
+		}
+		return stmt
+	case lexer.FROM:
+		return p.parseFromImportStmt()
+	case lexer.TRY:
+		return p.parseTryStmt()
+	case lexer.WITH:
+		return p.parseWithStmt()
+	case lexer.DEL:
+		return p.parseDelStmt()
+	case lexer.GLOBAL:
+		stmt := &ast.GlobalStmt{Token: p.current()}
+		if stmt.Names = p.parseNameList(); stmt.Names == nil {
+			return nil
+		}
+		return stmt
+	case lexer.NONLOCAL:
+		stmt := &ast.NonlocalStmt{Token: p.current()}
+		if p.funcDepth == 0 {
+			p.errorf(p.current(), "nonlocal declaration not allowed at module level")
+			return nil
+		}
+		if stmt.Names = p.parseNameList(); stmt.Names == nil {
+			return nil
+		}
+		return stmt
+	case lexer.ASSERT:
+		stmt := &ast.AssertStmt{Token: p.current()}
+		p.next()
+		stmt.Cond = p.parseExpr(LOWEST)
+		if p.checkPeek(lexer.COMMA) {
+			p.next()
+			p.next()
+			stmt.Msg = p.parseExpr(LOWEST)
+		}
+		return stmt
+	case lexer.RAISE:
+		stmt := &ast.RaiseStmt{Token: p.current()}
+		if !p.checkPeek(lexer.NL) && !p.checkPeek(lexer.EOF) {
+			p.next()
+			stmt.Exception = p.parseExpr(LOWEST)
+			if p.checkPeek(lexer.FROM) {
+				p.next()
+				p.next()
+				stmt.Cause = p.parseExpr(LOWEST)
+			}
+		}
+		return stmt
+	case lexer.NL, lexer.INDENT:
 		return nil
 	default:
 		return p.parseExprStmt()
 	}
 }
 
-func (p *Parser) parseVarStmt() *ast.VarStmt {
-	stmt := &ast.VarStmt{Token: p.current()}
-	stmt.Ident = &ast.Identifier{Token: p.current(), Val: p.current().Val}
-	if !p.expectPeek(lexer.EQUALS) {
+func (p *Parser) parseFunctionDef() ast.Stmt {
+	def := &ast.FunctionDef{Token: p.current()}
+	if !p.expectPeek(lexer.IDENT) {
 		return nil
 	}
-	p.next()
-	stmt.Value = p.parseExpr(LOWEST)
-	for !p.checkCurrent(lexer.NL) && !p.end() {
-		p.next()
+	def.Name = &ast.Identifier{Token: p.current(), Val: p.current().Val}
+	if !p.expectPeek(lexer.LEFTPAREN) {
+		return nil
 	}
-	return stmt
-}
-
-func (p *Parser) parseExprStmt() *ast.ExprStmt {
-	stmt := &ast.ExprStmt{Token: p.current()}
-	stmt.Expr = p.parseExpr(LOWEST)
-	return stmt
-}
-
-func (p *Parser) parseExpr(precedence int) ast.Expr {
-	pre := p.prefixParseFns[p.current().Name]
-	if pre == nil {
+	if !p.parseParams(def) {
 		return nil
 	}
-	left := pre()
-	for !p.checkCurrent(lexer.NL) && precedence < p.peekPrec() {
-		in := p.infixParseFns[p.peek().Name]
-		if in == nil {
-			return left
-		}
-		p.next()
-		left = in(left)
+	if !p.expectPeek(lexer.COLON) {
+		return nil
 	}
-	return left
-}
-
-func (p *Parser) parseIdent() ast.Expr {
-	return &ast.Identifier{Token: p.current(), Val: p.current().Val}
-}
-
-func (p *Parser) parseIntLiteral() ast.Expr {
-	il := &ast.IntLiteral{Token: p.current()}
-	val, err := strconv.ParseInt(p.current().Val, 0, 64)
-	if err != nil {
-		err := fmt.Sprintf("could not parse %q as int", p.current().Val)
-		p.errors = append(p.errors, err)
+	if !p.expectPeek(lexer.NL) {
 		return nil
 	}
-	il.Value = val
-	return il
-}
-
-func (p *Parser) parseStrLiteral() ast.Expr {
-	return &ast.StrLiteral{Token: p.current(), Value: p.current().Val}
+	// Loops enclosing the def do not extend into its body.
+	loopDepth, yields := p.loopDepth, p.yields
+	p.loopDepth, p.yields = 0, false
+	p.funcDepth++
+	def.Body = p.parseBlockStmt()
+	def.Doc = docstring(def.Body.Stmts)
+	def.Locals = localNames(def)
+	def.Generator = p.yields
+	p.funcDepth--
+	p.loopDepth, p.yields = loopDepth, yields
+	return def
 }
 
-func (p *Parser) parsePrefixExpr() ast.Expr {
-	expr := &ast.PrefixExpr{Token: p.current(), Op: p.current().Val}
+// parseParams parses the parameter list of def up to the closing
+// parenthesis.
+func (p *Parser) parseParams(def *ast.FunctionDef) bool {
+	def.Params = []*ast.Identifier{}
+	def.Defaults = []ast.Expr{}
+	for !p.checkPeek(lexer.RIGHTPAREN) {
+		p.next()
+		if def.KwArgs != nil {
+			p.errorf(p.current(), "arguments cannot follow var-keyword argument")
+			return false
+		}
+		switch p.current().Name {
+		case lexer.MULT:
+			if def.VarArgs != nil {
+				p.errorf(p.current(), "* argument may appear only once")
+				return false
+			}
+			if !p.expectPeek(lexer.IDENT) {
+				return false
+			}
+			def.VarArgs = &ast.Identifier{Token: p.current(), Val: p.current().Val}
+		case lexer.POW:
+			if !p.expectPeek(lexer.IDENT) {
+				return false
+			}
+			def.KwArgs = &ast.Identifier{Token: p.current(), Val: p.current().Val}
+		case lexer.IDENT:
+			if def.VarArgs != nil {
+				p.errorf(p.current(), "keyword-only parameters are not supported")
+				return false
+			}
+			param := &ast.Identifier{Token: p.current(), Val: p.current().Val}
+			var value ast.Expr
+			if p.checkPeek(lexer.EQUALS) {
+				p.next()
+				p.next()
+				value = p.parseExpr(LOWEST)
+			} else if n := len(def.Defaults); n > 0 && def.Defaults[n-1] != nil {
+				p.errorf(p.current(), "non-default argument follows default argument")
+				return false
+			}
+			def.Params = append(def.Params, param)
+			def.Defaults = append(def.Defaults, value)
+		default:
+			p.errorf(p.current(), "invalid parameter %s", p.current().Val)
+			return false
+		}
+		if !p.checkPeek(lexer.RIGHTPAREN) && !p.expectPeek(lexer.COMMA) {
+			return false
+		}
+	}
 	p.next()
-	expr.Expr = p.parseExpr(PREFIX)
-	return expr
+	return true
 }
 
-func (p *Parser) parseInfixExpr(l ast.Expr) ast.Expr {
-	expr := &ast.InfixExpr{Token: p.current(), Op: p.current().Val, Left: l}
-	prec := p.currentPrec()
+func (p *Parser) parseReturnStmt() ast.Stmt {
+	stmt := &ast.ReturnStmt{Token: p.current()}
+	if p.funcDepth == 0 {
+		p.errorf(p.current(), "'return' outside function")
+		return nil
+	}
+	if p.checkPeek(lexer.NL) || p.checkPeek(lexer.EOF) {
+		return stmt
+	}
 	p.next()
-	expr.Right = p.parseExpr(prec)
-	return expr
+	stmt.Value = p.parseTupleOrExpr()
+	return stmt
 }
 
-func (p *Parser) parseGroupingExpr() ast.Expr {
-	p.next()
-	expr := p.parseExpr(LOWEST)
-	if !p.expectPeek(lexer.RIGHTPAREN) {
+func (p *Parser) parseClassDef() ast.Stmt {
+	class := &ast.ClassDef{Token: p.current()}
+	if !p.expectPeek(lexer.IDENT) {
 		return nil
 	}
-	return expr
-}
-
-func (p *Parser) parseIfExpr() ast.Expr {
-	expr := &ast.IfExpr{Token: p.current()}
-	p.next()
-	expr.Cond = p.parseExpr(LOWEST)
+	class.Name = &ast.Identifier{Token: p.current(), Val: p.current().Val}
+	if p.checkPeek(lexer.LEFTPAREN) {
+		p.next()
+		bases := p.parseExprList(lexer.RIGHTPAREN)
+		if len(bases) > 1 {
+			p.errorf(p.current(), "multiple inheritance is not supported")
+			return nil
+		}
+		if len(bases) == 1 {
+			class.Base = bases[0]
+		}
+	}
 	if !p.expectPeek(lexer.COLON) {
 		return nil
 	}
 	if !p.expectPeek(lexer.NL) {
 		return nil
 	}
-	expr.Pass = p.parseBlockStmt()
-	if !p.expectCurrent(lexer.ELSE) {
-		return expr
-	} else {
-		expr.Fail = p.parseBlockStmt()
+	// A class body is not a function body even when nested in one.
+	loopDepth, funcDepth := p.loopDepth, p.funcDepth
+	p.loopDepth, p.funcDepth = 0, 0
+	class.Body = p.parseBlockStmt()
+	class.Doc = docstring(class.Body.Stmts)
+	p.loopDepth, p.funcDepth = loopDepth, funcDepth
+	return class
+}
+
+func (p *Parser) parseFromImportStmt() ast.Stmt {
+	stmt := &ast.FromImportStmt{Token: p.current()}
+	if !p.expectPeek(lexer.IDENT) {
+		return nil
 	}
-	return expr
+	stmt.Module = &ast.Identifier{Token: p.current(), Val: p.current().Val}
+	if !p.expectPeek(lexer.IMPORT) {
+		return nil
+	}
+	stmt.Names, stmt.Aliases = p.parseImportNames()
+	if stmt.Names == nil {
+		return nil
+	}
+	return stmt
 }
 
-func (p *Parser) parseBlockStmt() *ast.BlockStmt {
-	p.next()
-	for p.checkPeek(lexer.INDENT) {
+// parseImportNames parses `name [as alias], ...` following an import
+// keyword.
+func (p *Parser) parseImportNames() ([]*ast.Identifier, []*ast.Identifier) {
+	var names, aliases []*ast.Identifier
+	for {
+		if !p.expectPeek(lexer.IDENT) {
+			return nil, nil
+		}
+		names = append(names, &ast.Identifier{Token: p.current(), Val: p.current().Val})
+		var alias *ast.Identifier
+		if p.checkPeek(lexer.AS) {
+			p.next()
+			if !p.expectPeek(lexer.IDENT) {
+				return nil, nil
+			}
+			alias = &ast.Identifier{Token: p.current(), Val: p.current().Val}
+		}
+		aliases = append(aliases, alias)
+		if !p.checkPeek(lexer.COMMA) {
+			return names, aliases
+		}
 		p.next()
 	}
-	p.next()
-	p.indentLevel++
-	b := &ast.BlockStmt{Token: p.current()}
-	b.Stmts = []ast.Stmt{}
-	for 4*p.indentLevel <= p.current().GetCol() && !p.end() {
-		stmt := p.parseStmt()
-		if stmt != nil {
-			b.Stmts = append(b.Stmts, stmt)
+}
+
+func (p *Parser) parseTryStmt() ast.Stmt {
+	stmt := &ast.TryStmt{Token: p.current()}
+	stmt.Body = p.parseClauseBlock()
+	if stmt.Body == nil {
+		return nil
+	}
+	for p.nextClause(lexer.EXCEPT) {
+		if n := len(stmt.Handlers); n > 0 && stmt.Handlers[n-1].Type == nil {
+			p.errorf(p.current(), "default 'except:' must be last")
+			return nil
 		}
-		p.next()
-		for p.checkCurrent(lexer.NL) || p.checkCurrent(lexer.INDENT) {
-			p.next()
+		handler := p.parseExceptClause()
+		if handler == nil {
+			return nil
 		}
+		stmt.Handlers = append(stmt.Handlers, handler)
 	}
-	p.indentLevel--
-	return b
+	if len(stmt.Handlers) > 0 && p.nextClause(lexer.ELSE) {
+		if stmt.Else = p.parseClauseBlock(); stmt.Else == nil {
+			return nil
+		}
+	}
+	if p.nextClause(lexer.FINALLY) {
+		if stmt.Finally = p.parseClauseBlock(); stmt.Finally == nil {
+			return nil
+		}
+	}
+	if len(stmt.Handlers) == 0 && stmt.Finally == nil {
+		p.errorf(stmt.Token, "expected 'except' or 'finally' block")
+		return nil
+	}
+	return stmt
 }
 
-func (p *Parser) parseCallExpr(fn ast.Expr) ast.Expr {
-	expr := &ast.CallExpr{Token: p.current(), Func: fn}
-	expr.Args = p.parseCallArgs()
-	return expr
+func (p *Parser) parseDelStmt() ast.Stmt {
+	stmt := &ast.DelStmt{Token: p.current()}
+	p.next()
+	targets := p.parseTupleOrExpr()
+	if tuple, ok := targets.(*ast.TupleLiteral); ok {
+		stmt.Targets = tuple.Elements
+	} else {
+		stmt.Targets = []ast.Expr{targets}
+	}
+	for _, target := range stmt.Targets {
+		if !p.checkDelTarget(target) {
+			return nil
+		}
+	}
+	return stmt
 }
 
-func (p *Parser) parseCallArgs() []ast.Expr {
-	var args []ast.Expr
-	if p.checkPeek(lexer.RIGHTPAREN) {
-		p.next()
-		return args
+// checkDelTarget reports whether expr can be deleted, recording an error
+// if it cannot. Like checkTarget, it rejects expr without looking further
+// once the statement has failed.
+func (p *Parser) checkDelTarget(expr ast.Expr) bool {
+	if len(p.errors) > p.stmtErrs {
+		return false
 	}
-	p.next()
-	args = append(args, p.parseExpr(LOWEST))
-	for p.checkPeek(lexer.COMMA) {
+	switch expr := expr.(type) {
+	case *ast.Identifier, *ast.IndexExpr, *ast.AttributeExpr:
+		return true
+	case *ast.TupleLiteral:
+		for _, element := range expr.Elements {
+			if !p.checkDelTarget(element) {
+				return false
+			}
+		}
+		return true
+	case *ast.ListLiteral:
+		for _, element := range expr.Elements {
+			if !p.checkDelTarget(element) {
+				return false
+			}
+		}
+		return true
+	}
+	name := "expression"
+	if expr != nil {
+		name = expr.String()
+	}
+	p.errorf(p.current(), "cannot delete %s", name)
+	return false
+}
+
+func (p *Parser) parseWithStmt() ast.Stmt {
+	stmt := &ast.WithStmt{Token: p.current()}
+	for {
 		p.next()
+		stmt.Contexts = append(stmt.Contexts, p.parseExpr(LOWEST))
+		var target ast.Expr
+		if p.checkPeek(lexer.AS) {
+			p.next()
+			p.next()
+			if target = p.parseExpr(LOWEST); !p.checkTarget(target) {
+				return nil
+			}
+		}
+		stmt.Targets = append(stmt.Targets, target)
+		if !p.checkPeek(lexer.COMMA) {
+			break
+		}
 		p.next()
-		args = append(args, p.parseExpr(LOWEST))
 	}
-	if !p.expectPeek(lexer.RIGHTPAREN) {
+	if stmt.Body = p.parseClauseBlock(); stmt.Body == nil {
 		return nil
 	}
-	return args
+	return stmt
 }
 
-func (p *Parser) parseWhileExpr() ast.Expr {
-	expr := &ast.WhileExpr{Token: p.current()}
-	p.next()
-	expr.Cond = p.parseExpr(LOWEST)
+func (p *Parser) parseExceptClause() *ast.ExceptClause {
+	clause := &ast.ExceptClause{Token: p.current()}
+	if !p.checkPeek(lexer.COLON) {
+		p.next()
+		clause.Type = p.parseExpr(LOWEST)
+		if p.checkPeek(lexer.AS) {
+			p.next()
+			if !p.expectPeek(lexer.IDENT) {
+				return nil
+			}
+			clause.Name = &ast.Identifier{Token: p.current(), Val: p.current().Val}
+		}
+	}
+	if clause.Body = p.parseClauseBlock(); clause.Body == nil {
+		return nil
+	}
+	return clause
+}
+
+// parseClauseBlock parses the `:` ending a clause header such as try or
+// finally and the block that follows it.
+func (p *Parser) parseClauseBlock() *ast.BlockStmt {
 	if !p.expectPeek(lexer.COLON) {
 		return nil
 	}
 	if !p.expectPeek(lexer.NL) {
 		return nil
 	}
-	expr.Body = p.parseBlockStmt()
-	return expr
+	return p.parseBlockStmt()
 }
 
-func (p *Parser) expectCurrent(t lexer.TokenType) bool {
-	if p.checkCurrent(t) {
-		p.next()
-		return true
-	} else {
+// nextClause moves to the next line if it starts with a t keyword that
+// continues the current compound statement, like except after a try block.
+func (p *Parser) nextClause(t lexer.TokenType) bool {
+	next := p.nextLineStart()
+	if !p.inBlock(next) || p.tokens[next].Name != t {
 		return false
 	}
+	p.index = next
+	return true
 }
 
-func (p *Parser) expectPeek(t lexer.TokenType) bool {
-	if p.checkPeek(t) {
+// parseNameList parses the comma separated identifiers following a global
+// or nonlocal keyword.
+func (p *Parser) parseNameList() []*ast.Identifier {
+	var names []*ast.Identifier
+	for {
+		if !p.expectPeek(lexer.IDENT) {
+			return nil
+		}
+		names = append(names, &ast.Identifier{Token: p.current(), Val: p.current().Val})
+		if !p.checkPeek(lexer.COMMA) {
+			return names
+		}
 		p.next()
-		return true
-	} else {
-		p.peekError(t)
-		return false
 	}
 }
 
-func (p *Parser) checkCurrent(t lexer.TokenType) bool {
-	if p.end() { return false }
-	return p.current().Name == t
-}
+func (p *Parser) parseLoopControlStmt() ast.Stmt {
+	if p.loopDepth == 0 {
+		p.errorf(p.current(), "'%s' outside loop", p.current().Val)
+		return nil
+	}
+	if p.checkCurrent(lexer.BREAK) {
+		return &ast.BreakStmt{Token: p.current()}
+	}
+	return &ast.ContinueStmt{Token: p.current()}
+}
+
+func (p *Parser) parseVarStmt() ast.Stmt {
+	stmt := &ast.VarStmt{Token: p.current()}
+	stmt.Ident = &ast.Identifier{Token: p.current(), Val: p.current().Val}
+	if !p.expectPeek(lexer.EQUALS) {
+		return nil
+	}
+	p.next()
+	stmt.Value = p.parseTupleOrExpr()
+	if p.checkPeek(lexer.EQUALS) {
+		return p.parseAssignStmt([]ast.Expr{stmt.Ident, stmt.Value})
+	}
+	return stmt
+}
+
+func (p *Parser) parseExprStmt() ast.Stmt {
+	stmt := &ast.ExprStmt{Token: p.current()}
+	stmt.Expr = p.parseTupleOrExpr()
+	if p.checkPeek(lexer.EQUALS) {
+		return p.parseAssignStmt([]ast.Expr{stmt.Expr})
+	}
+	if _, ok := augAssignOps[p.peek().Name]; ok {
+		return p.parseAugAssignStmt(stmt.Expr)
+	}
+	return stmt
+}
+
+// parseAssignStmt continues a chain of `target = ... = value` given the
+// expressions parsed so far. Every expression but the last is a target.
+func (p *Parser) parseAssignStmt(exprs []ast.Expr) ast.Stmt {
+	tok := p.peek()
+	for p.checkPeek(lexer.EQUALS) {
+		p.next()
+		p.next()
+		exprs = append(exprs, p.parseTupleOrExpr())
+	}
+	targets, value := exprs[:len(exprs)-1], exprs[len(exprs)-1]
+	for _, target := range targets {
+		if !p.checkTarget(target) {
+			return nil
+		}
+	}
+	if index, ok := targets[0].(*ast.IndexExpr); ok && len(targets) == 1 {
+		return &ast.IndexAssignStmt{Token: tok, Target: index, Value: value}
+	}
+	return &ast.AssignStmt{Token: tok, Targets: targets, Value: value}
+}
+
+// checkTarget reports whether expr can be assigned to, recording an error
+// if it cannot. A statement that has already failed may have left expr
+// incomplete, and only its first error is kept anyway, so it is rejected
+// without looking further.
+func (p *Parser) checkTarget(expr ast.Expr) bool {
+	if len(p.errors) > p.stmtErrs {
+		return false
+	}
+	switch expr := expr.(type) {
+	case *ast.Identifier, *ast.IndexExpr, *ast.AttributeExpr:
+		return true
+	case *ast.TupleLiteral:
+		return p.checkTargets(expr.Elements)
+	case *ast.ListLiteral:
+		return p.checkTargets(expr.Elements)
+	case *ast.StarredExpr:
+		p.errorf(expr.Token, "starred assignment target must be in a list or tuple")
+		return false
+	}
+	name := "expression"
+	if expr != nil {
+		name = expr.String()
+	}
+	p.errorf(p.current(), "cannot assign to %s", name)
+	return false
+}
+
+// checkTargets checks the elements of a tuple or list target, of which at
+// most one may be starred.
+func (p *Parser) checkTargets(exprs []ast.Expr) bool {
+	starred := false
+	for _, expr := range exprs {
+		if star, ok := expr.(*ast.StarredExpr); ok {
+			if starred {
+				p.errorf(star.Token, "multiple starred expressions in assignment")
+				return false
+			}
+			starred = true
+			expr = star.Value
+		}
+		if !p.checkTarget(expr) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTupleOrExpr parses an expression and, if commas follow, the rest of
+// an unparenthesized tuple such as `a, b`.
+func (p *Parser) parseTupleOrExpr() ast.Expr {
+	tok := p.current()
+	expr := p.parseExpr(LOWEST)
+	if !p.checkPeek(lexer.COMMA) {
+		return expr
+	}
+	tuple := &ast.TupleLiteral{Token: tok, Elements: []ast.Expr{expr}}
+	for p.checkPeek(lexer.COMMA) {
+		p.next()
+		if p.checkPeek(lexer.NL) || p.checkPeek(lexer.EQUALS) || p.checkPeek(lexer.EOF) {
+			break
+		}
+		p.next()
+		tuple.Elements = append(tuple.Elements, p.parseExpr(LOWEST))
+	}
+	return tuple
+}
+
+// augAssignOps maps each augmented assignment token to the binary operator
+// it applies.
+var augAssignOps = map[lexer.TokenType]string{
+	lexer.ADDEQ: "+",
+	lexer.SUBEQ: "-",
+	lexer.MULTEQ: "*",
+	lexer.DIVEQ: "/",
+	lexer.MODEQ: "%",
+	lexer.POWEQ: "**",
+}
+
+func (p *Parser) parseAugAssignStmt(target ast.Expr) ast.Stmt {
+	p.next()
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpr, *ast.AttributeExpr:
+	default:
+		p.errorf(p.current(), "illegal target for augmented assignment")
+		return nil
+	}
+	stmt := &ast.AugAssignStmt{Token: p.current(), Target: target, Op: augAssignOps[p.current().Name]}
+	p.next()
+	stmt.Value = p.parseExpr(LOWEST)
+	return stmt
+}
+
+func (p *Parser) parseExpr(precedence int) ast.Expr {
+	pre := p.prefixParseFns[p.current().Name]
+	if pre == nil {
+		p.unexpected(p.current())
+		return nil
+	}
+	left := pre()
+	for !p.checkCurrent(lexer.NL) && precedence < p.peekPrec() {
+		in := p.infixParseFns[p.peek().Name]
+		if in == nil {
+			return left
+		}
+		p.next()
+		left = in(left)
+	}
+	return left
+}
+
+func (p *Parser) parseIdent() ast.Expr {
+	return &ast.Identifier{Token: p.current(), Val: p.current().Val}
+}
+
+func (p *Parser) parseIntLiteral() ast.Expr {
+	il := &ast.IntLiteral{Token: p.current()}
+	val, err := strconv.ParseInt(p.current().Val, 0, 64)
+	if err != nil {
+		p.errorf(p.current(), "could not parse %q as int", p.current().Val)
+		return nil
+	}
+	il.Value = val
+	return il
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expr {
+	fl := &ast.FloatLiteral{Token: p.current()}
+	// Out of range literals become infinity or zero, as in Python.
+	val, err := strconv.ParseFloat(p.current().Val, 64)
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err != strconv.ErrRange {
+		p.errorf(p.current(), "could not parse %q as float", p.current().Val)
+		return nil
+	}
+	fl.Value = val
+	return fl
+}
+
+func (p *Parser) parseStrLiteral() ast.Expr {
+	return &ast.StrLiteral{Token: p.current(), Value: p.current().Val}
+}
+
+// parseBytesLiteral parses a b"..." literal, which may only hold ASCII
+// characters. Backslash escapes are decoded into the bytes they stand for.
+func (p *Parser) parseBytesLiteral() ast.Expr {
+	for _, r := range p.current().Val {
+		if r > unicode.MaxASCII {
+			p.errorf(p.current(), "bytes can only contain ASCII literal characters")
+			return nil
+		}
+	}
+	value, err := unescapeBytes(p.current().Val)
+	if err != nil {
+		p.errorf(p.current(), "%s", err)
+		return nil
+	}
+	return &ast.BytesLiteral{Token: p.current(), Value: value}
+}
+
+// byteEscapes maps the single character escapes to the bytes they stand for.
+var byteEscapes = map[byte]byte{
+	'\\': '\\', '\'': '\'', '"': '"', 'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+}
+
+// unescapeBytes decodes the escapes in the body of a bytes literal as
+// Python does: \xhh and up to three octal digits give a byte by value, a
+// backslash before a newline joins the lines, and unknown escapes are kept
+// as they are.
+func unescapeBytes(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		c := s[i]
+		if esc, ok := byteEscapes[c]; ok {
+			b.WriteByte(esc)
+			continue
+		}
+		switch {
+		case c == '\n':
+		case c == 'x':
+			if i+2 >= len(s) || !isHexDigit(s[i+1]) || !isHexDigit(s[i+2]) {
+				return "", fmt.Errorf("invalid \\x escape at position %d", i-1)
+			}
+			n, _ := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			b.WriteByte(byte(n))
+			i += 2
+		case '0' <= c && c <= '7':
+			n := 0
+			j := i
+			for ; j < len(s) && j < i+3 && '0' <= s[j] && s[j] <= '7'; j++ {
+				n = n*8 + int(s[j]-'0')
+			}
+			b.WriteByte(byte(n))
+			i = j - 1
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func (p *Parser) parseBoolLiteral() ast.Expr {
+	return &ast.BoolLiteral{Token: p.current(), Value: p.current().Name == lexer.TRUE}
+}
+
+func (p *Parser) parseNoneLiteral() ast.Expr {
+	return &ast.NoneLiteral{Token: p.current()}
+}
+
+func (p *Parser) parsePrefixExpr() ast.Expr {
+	expr := &ast.PrefixExpr{Token: p.current(), Op: p.current().Val}
+	p.next()
+	expr.Expr = p.parseExpr(PREFIX)
+	return expr
+}
+
+// parseStarredExpr parses `*value`. Outside of call arguments it is only
+// valid as an assignment target, which checkTarget enforces.
+func (p *Parser) parseStarredExpr() ast.Expr {
+	expr := &ast.StarredExpr{Token: p.current()}
+	p.next()
+	expr.Value = p.parseExpr(BITOR)
+	return expr
+}
+
+// parseNotExpr parses `not x`, which binds looser than comparisons so that
+// `not a == b` negates the whole comparison.
+func (p *Parser) parseNotExpr() ast.Expr {
+	expr := &ast.PrefixExpr{Token: p.current(), Op: p.current().Val}
+	p.next()
+	expr.Expr = p.parseExpr(NOT)
+	return expr
+}
+
+func (p *Parser) parseYieldExpr() ast.Expr {
+	expr := &ast.YieldExpr{Token: p.current()}
+	if p.funcDepth == 0 {
+		p.errorf(p.current(), "'yield' outside function")
+		return nil
+	}
+	p.yields = true
+	switch p.peek().Name {
+	case lexer.NL, lexer.EOF, lexer.RIGHTPAREN:
+		return expr
+	}
+	p.next()
+	expr.Value = p.parseTupleOrExpr()
+	return expr
+}
+
+func (p *Parser) parseInfixExpr(l ast.Expr) ast.Expr {
+	expr := &ast.InfixExpr{Token: p.current(), Op: p.current().Val, Left: l}
+	prec := p.currentPrec()
+	p.next()
+	expr.Right = p.parseExpr(prec)
+	return expr
+}
+
+// parsePowerExpr parses a ** b, which associates to the right so that
+// a ** b ** c is a ** (b ** c).
+func (p *Parser) parsePowerExpr(l ast.Expr) ast.Expr {
+	expr := &ast.InfixExpr{Token: p.current(), Op: p.current().Val, Left: l}
+	p.next()
+	expr.Right = p.parseExpr(POWER - 1)
+	return expr
+}
+
+// parseCompareExpr collects a chain of comparisons such as a < b <= c into a
+// single node so the evaluator can compare pairwise and stop early.
+func (p *Parser) parseCompareExpr(left ast.Expr) ast.Expr {
+	expr := &ast.CompareExpr{Token: p.current(), Left: left}
+	for {
+		op := p.current().Val
+		switch {
+		case p.checkCurrent(lexer.IS) && p.checkPeek(lexer.NOT):
+			p.next()
+			op = "is not"
+		case p.checkCurrent(lexer.NOT):
+			if !p.expectPeek(lexer.IN) {
+				return nil
+			}
+			op = "not in"
+		}
+		expr.Ops = append(expr.Ops, op)
+		p.next()
+		expr.Comparators = append(expr.Comparators, p.parseExpr(COMPARE))
+		if !isComparison(p.peek().Name) {
+			break
+		}
+		p.next()
+	}
+	return expr
+}
+
+func isComparison(t lexer.TokenType) bool {
+	switch t {
+	case lexer.EQ, lexer.NOTEQ, lexer.LESS, lexer.LESSEQ, lexer.GREAT, lexer.GREATEQ, lexer.IS, lexer.IN, lexer.NOT:
+		return true
+	}
+	return false
+}
+
+// parseTernaryExpr parses `pass if cond else fail`. The condition binds
+// tighter than another conditional so that nested forms associate to the
+// right.
+func (p *Parser) parseTernaryExpr(pass ast.Expr) ast.Expr {
+	expr := &ast.TernaryExpr{Token: p.current(), Pass: pass}
+	p.next()
+	expr.Cond = p.parseExpr(TERNARY)
+	if !p.expectPeek(lexer.ELSE) {
+		return nil
+	}
+	p.next()
+	expr.Fail = p.parseExpr(LOWEST)
+	return expr
+}
+
+func (p *Parser) parseGroupingExpr() ast.Expr {
+	tuple := &ast.TupleLiteral{Token: p.current()}
+	if p.checkPeek(lexer.RIGHTPAREN) {
+		p.next()
+		tuple.Rparen = p.current()
+		return tuple
+	}
+	p.next()
+	expr := p.parseExpr(LOWEST)
+	if !p.checkPeek(lexer.COMMA) {
+		if !p.expectPeek(lexer.RIGHTPAREN) {
+			return nil
+		}
+		return expr
+	}
+	tuple.Elements = []ast.Expr{expr}
+	for p.checkPeek(lexer.COMMA) {
+		p.next()
+		if p.checkPeek(lexer.RIGHTPAREN) {
+			break
+		}
+		p.next()
+		tuple.Elements = append(tuple.Elements, p.parseExpr(LOWEST))
+	}
+	if !p.expectPeek(lexer.RIGHTPAREN) {
+		return nil
+	}
+	tuple.Rparen = p.current()
+	return tuple
+}
+
+func (p *Parser) parseListLiteral() ast.Expr {
+	list := &ast.ListLiteral{Token: p.current()}
+	list.Elements = p.parseExprList(lexer.RIGHTBRACKET)
+	list.Rbracket = p.current()
+	return list
+}
+
+func (p *Parser) parseDictLiteral() ast.Expr {
+	dict := &ast.DictLiteral{Token: p.current()}
+	for !p.checkPeek(lexer.RIGHTBRACE) {
+		p.next()
+		key := p.parseExpr(LOWEST)
+		if !p.expectPeek(lexer.COLON) {
+			return nil
+		}
+		p.next()
+		dict.Keys = append(dict.Keys, key)
+		dict.Values = append(dict.Values, p.parseExpr(LOWEST))
+		if !p.checkPeek(lexer.RIGHTBRACE) && !p.expectPeek(lexer.COMMA) {
+			return nil
+		}
+	}
+	p.next()
+	dict.Rbrace = p.current()
+	return dict
+}
+
+func (p *Parser) parseAttributeExpr(object ast.Expr) ast.Expr {
+	expr := &ast.AttributeExpr{Token: p.current(), Object: object}
+	if !p.expectPeek(lexer.IDENT) {
+		return nil
+	}
+	expr.Attr = &ast.Identifier{Token: p.current(), Val: p.current().Val}
+	return expr
+}
+
+func (p *Parser) parseIndexExpr(left ast.Expr) ast.Expr {
+	expr := &ast.IndexExpr{Token: p.current(), Left: left}
+	p.next()
+	if p.checkCurrent(lexer.COLON) {
+		expr.Index = p.parseSliceExpr(nil)
+	} else {
+		expr.Index = p.parseExpr(LOWEST)
+		if p.checkPeek(lexer.COLON) {
+			p.next()
+			expr.Index = p.parseSliceExpr(expr.Index)
+		}
+	}
+	if !p.expectPeek(lexer.RIGHTBRACKET) {
+		return nil
+	}
+	expr.Rbracket = p.current()
+	return expr
+}
+
+// parseSliceExpr parses the remainder of start:stop:step with the current
+// token on the first colon.
+func (p *Parser) parseSliceExpr(start ast.Expr) ast.Expr {
+	slice := &ast.SliceExpr{Token: p.current(), Start: start}
+	if !p.checkPeek(lexer.COLON) && !p.checkPeek(lexer.RIGHTBRACKET) {
+		p.next()
+		slice.Stop = p.parseExpr(LOWEST)
+	}
+	if p.checkPeek(lexer.COLON) {
+		p.next()
+		if !p.checkPeek(lexer.RIGHTBRACKET) {
+			p.next()
+			slice.Step = p.parseExpr(LOWEST)
+		}
+	}
+	return slice
+}
+
+func (p *Parser) parseIfExpr() ast.Expr {
+	expr := &ast.IfExpr{Token: p.current()}
+	p.next()
+	expr.Cond = p.parseExpr(LOWEST)
+	if !p.expectPeek(lexer.COLON) {
+		return nil
+	}
+	if !p.expectPeek(lexer.NL) {
+		return nil
+	}
+	expr.Pass = p.parseBlockStmt()
+	next := p.nextLineStart()
+	switch {
+	case !p.inBlock(next):
+	case p.tokens[next].Name == lexer.ELSE:
+		p.index = next
+		if !p.expectPeek(lexer.COLON) {
+			return nil
+		}
+		if !p.expectPeek(lexer.NL) {
+			return nil
+		}
+		expr.Fail = p.parseBlockStmt()
+	case p.tokens[next].Name == lexer.ELIF:
+		p.index = next
+		elif := &ast.ExprStmt{Token: p.current(), Expr: p.parseIfExpr()}
+		expr.Fail = &ast.BlockStmt{Token: p.current(), Stmts: []ast.Stmt{elif}}
+	}
+	return expr
+}
+
+// parseBlockStmt parses the indented lines following a header that ends in
+// a colon. The current token is the newline ending the header; on return it
+// is the last token of the block, so the line after the block is left for
+// the caller.
+func (p *Parser) parseBlockStmt() *ast.BlockStmt {
+	p.indentLevel++
+	b := &ast.BlockStmt{Token: p.current()}
+	b.Stmts = []ast.Stmt{}
+	lines := 0
+	for next := p.nextLineStart(); p.inBlock(next); next = p.nextLineStart() {
+		p.index = next
+		lines++
+		stmt := p.parseStmtOrSync()
+		if stmt != nil {
+			b.Stmts = append(b.Stmts, stmt)
+		}
+	}
+	if lines == 0 {
+		p.errorf(p.peek(), "expected an indented block")
+	}
+	p.indentLevel--
+	return b
+}
+
+// nextLineStart returns the index of the first token on the next line that
+// is not blank, skipping the rest of the current line.
+func (p *Parser) nextLineStart() int {
+	i := p.index
+	for i < len(p.tokens)-1 && p.tokens[i].Name != lexer.NL {
+		i++
+	}
+	for i < len(p.tokens)-1 && (p.tokens[i].Name == lexer.NL || p.tokens[i].Name == lexer.INDENT) {
+		i++
+	}
+	return i
+}
+
+// inBlock reports whether the token at index i is indented deeply enough to
+// belong to the block currently being parsed.
+func (p *Parser) inBlock(i int) bool {
+	tok := p.tokens[i]
+	return tok.Name != lexer.EOF && 4*p.indentLevel <= tok.GetCol()
+}
+
+func (p *Parser) parseCallExpr(fn ast.Expr) ast.Expr {
+	expr := &ast.CallExpr{Token: p.current(), Func: fn}
+	if !p.parseCallArgs(expr) {
+		return nil
+	}
+	expr.Rparen = p.current()
+	return expr
+}
+
+// parseCallArgs parses positional and keyword arguments up to and
+// including the closing parenthesis.
+func (p *Parser) parseCallArgs(expr *ast.CallExpr) bool {
+	for !p.checkPeek(lexer.RIGHTPAREN) {
+		p.next()
+		if p.checkCurrent(lexer.POW) {
+			p.next()
+			expr.Keywords = append(expr.Keywords, nil)
+			expr.KeywordValues = append(expr.KeywordValues, p.parseExpr(LOWEST))
+		} else if p.checkCurrent(lexer.MULT) {
+			starred := &ast.StarredExpr{Token: p.current()}
+			p.next()
+			starred.Value = p.parseExpr(LOWEST)
+			expr.Args = append(expr.Args, starred)
+		} else if p.checkCurrent(lexer.IDENT) && p.checkPeek(lexer.EQUALS) {
+			keyword := &ast.Identifier{Token: p.current(), Val: p.current().Val}
+			for _, other := range expr.Keywords {
+				if other != nil && other.Val == keyword.Val {
+					p.errorf(p.current(), "keyword argument repeated: %s", keyword.Val)
+					return false
+				}
+			}
+			p.next()
+			p.next()
+			expr.Keywords = append(expr.Keywords, keyword)
+			expr.KeywordValues = append(expr.KeywordValues, p.parseExpr(LOWEST))
+		} else if len(expr.Keywords) > 0 {
+			p.errorf(p.current(), "positional argument follows keyword argument")
+			return false
+		} else {
+			expr.Args = append(expr.Args, p.parseExpr(LOWEST))
+		}
+		if !p.checkPeek(lexer.RIGHTPAREN) && !p.expectPeek(lexer.COMMA) {
+			return false
+		}
+	}
+	p.next()
+	return true
+}
+
+// parseExprList parses comma separated expressions up to and including the
+// end token. A trailing comma before end is allowed.
+func (p *Parser) parseExprList(end lexer.TokenType) []ast.Expr {
+	var list []ast.Expr
+	if p.checkPeek(end) {
+		p.next()
+		return list
+	}
+	p.next()
+	list = append(list, p.parseExpr(LOWEST))
+	for p.checkPeek(lexer.COMMA) {
+		p.next()
+		if p.checkPeek(end) {
+			break
+		}
+		p.next()
+		list = append(list, p.parseExpr(LOWEST))
+	}
+	if !p.expectPeek(end) {
+		return nil
+	}
+	return list
+}
+
+func (p *Parser) parseWhileExpr() ast.Expr {
+	expr := &ast.WhileExpr{Token: p.current()}
+	p.next()
+	expr.Cond = p.parseExpr(LOWEST)
+	if !p.expectPeek(lexer.COLON) {
+		return nil
+	}
+	if !p.expectPeek(lexer.NL) {
+		return nil
+	}
+	p.loopDepth++
+	expr.Body = p.parseBlockStmt()
+	p.loopDepth--
+	if p.nextClause(lexer.ELSE) {
+		if expr.Else = p.parseClauseBlock(); expr.Else == nil {
+			return nil
+		}
+	}
+	return expr
+}
+
+// parseForStmt parses a for loop and its optional else clause. The else
+// block is outside the loop, so break and continue there belong to an
+// enclosing loop.
+func (p *Parser) parseForStmt() ast.Stmt {
+	stmt := &ast.ForStmt{Token: p.current()}
+	p.next()
+	stmt.Target = p.parseForTarget()
+	if !p.checkTarget(stmt.Target) {
+		return nil
+	}
+	if !p.expectPeek(lexer.IN) {
+		return nil
+	}
+	p.next()
+	stmt.Iter = p.parseTupleOrExpr()
+	p.loopDepth++
+	stmt.Body = p.parseClauseBlock()
+	p.loopDepth--
+	if stmt.Body == nil {
+		return nil
+	}
+	if p.nextClause(lexer.ELSE) {
+		if stmt.Else = p.parseClauseBlock(); stmt.Else == nil {
+			return nil
+		}
+	}
+	return stmt
+}
+
+// isMatchHeader reports whether the current line opens a match statement.
+// match is a soft keyword: it only starts one when followed by a subject
+// and a colon ending the line.
+func (p *Parser) isMatchHeader() bool {
+	if p.current().Val != "match" {
+		return false
+	}
+	i := p.index + 1
+	for i < len(p.tokens)-1 && p.tokens[i+1].Name != lexer.NL && p.tokens[i+1].Name != lexer.EOF {
+		i++
+	}
+	return i > p.index+1 && p.tokens[i].Name == lexer.COLON
+}
+
+func (p *Parser) parseMatchStmt() ast.Stmt {
+	stmt := &ast.MatchStmt{Token: p.current()}
+	p.next()
+	stmt.Subject = p.parseTupleOrExpr()
+	if !p.expectPeek(lexer.COLON) {
+		return nil
+	}
+	if !p.expectPeek(lexer.NL) {
+		return nil
+	}
+	p.indentLevel++
+	ok := p.parseMatchCases(stmt)
+	p.indentLevel--
+	if !ok {
+		return nil
+	}
+	return stmt
+}
+
+// parseMatchCases parses the indented case clauses of a match statement.
+func (p *Parser) parseMatchCases(stmt *ast.MatchStmt) bool {
+	for next := p.nextLineStart(); p.inBlock(next); next = p.nextLineStart() {
+		p.index = next
+		if !p.checkCurrent(lexer.IDENT) || p.current().Val != "case" {
+			p.errorf(p.current(), "expected 'case', got %s", p.current().Name)
+			return false
+		}
+		if n := len(stmt.Cases); n > 0 {
+			if reason := irrefutable(stmt.Cases[n-1].Pattern); reason != "" {
+				p.errorf(stmt.Cases[n-1].Token, "%s makes remaining patterns unreachable", reason)
+				return false
+			}
+		}
+		c := p.parseMatchCase()
+		if c == nil {
+			return false
+		}
+		stmt.Cases = append(stmt.Cases, c)
+	}
+	if len(stmt.Cases) == 0 {
+		p.errorf(p.peek(), "expected an indented block")
+		return false
+	}
+	return true
+}
+
+// irrefutable describes pattern if it matches every subject, and returns
+// "" otherwise.
+func irrefutable(pattern ast.Pattern) string {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return "wildcard"
+	case *ast.CapturePattern:
+		return fmt.Sprintf("name capture '%s'", pattern.Name.Val)
+	}
+	return ""
+}
+
+func (p *Parser) parseMatchCase() *ast.MatchCase {
+	c := &ast.MatchCase{Token: p.current()}
+	p.next()
+	if c.Pattern = p.parseOpenSequencePattern(); c.Pattern == nil {
+		return nil
+	}
+	if !p.checkCaptures(c.Pattern) {
+		return nil
+	}
+	if c.Body = p.parseClauseBlock(); c.Body == nil {
+		return nil
+	}
+	return c
+}
+
+// checkCaptures reports whether pattern binds each name at most once,
+// recording an error if it does not.
+func (p *Parser) checkCaptures(pattern ast.Pattern) bool {
+	seen := map[string]bool{}
+	ok := true
+	ast.Inspect(pattern, func(node ast.Node) bool {
+		var name *ast.Identifier
+		switch node := node.(type) {
+		case *ast.CapturePattern:
+			name = node.Name
+		case *ast.StarPattern:
+			name = node.Name
+		}
+		if !ok || name == nil || name.Val == "_" {
+			return ok
+		}
+		if seen[name.Val] {
+			p.errorf(name.Token, "multiple assignments to name '%s' in pattern", name.Val)
+			ok = false
+		}
+		seen[name.Val] = true
+		return ok
+	})
+	return ok
+}
+
+// parseOpenSequencePattern parses the pattern of a case clause, where a
+// sequence pattern may be written without brackets, as in `case a, b:`.
+func (p *Parser) parseOpenSequencePattern() ast.Pattern {
+	tok := p.current()
+	pattern := p.parseSequenceElement()
+	if pattern == nil {
+		return nil
+	}
+	if !p.checkPeek(lexer.COMMA) {
+		if _, ok := pattern.(*ast.StarPattern); ok {
+			p.errorf(tok, "can't use starred name here")
+			return nil
+		}
+		return pattern
+	}
+	seq := &ast.SequencePattern{Token: tok, Patterns: []ast.Pattern{pattern}}
+	for p.checkPeek(lexer.COMMA) {
+		p.next()
+		if p.checkPeek(lexer.COLON) {
+			break
+		}
+		p.next()
+		if pattern = p.parseSequenceElement(); pattern == nil {
+			return nil
+		}
+		seq.Patterns = append(seq.Patterns, pattern)
+	}
+	if !p.checkStars(seq) {
+		return nil
+	}
+	return seq
+}
+
+// parsePattern parses a literal, capture, wildcard or bracketed sequence
+// pattern.
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.current().Name {
+	case lexer.NUM:
+		if value := p.parseIntLiteral(); value != nil {
+			return &ast.LiteralPattern{Value: value}
+		}
+		return nil
+	case lexer.STRING:
+		return &ast.LiteralPattern{Value: p.parseStrLiteral()}
+	case lexer.TRUE, lexer.FALSE:
+		return &ast.LiteralPattern{Value: p.parseBoolLiteral()}
+	case lexer.NONE:
+		return &ast.LiteralPattern{Value: p.parseNoneLiteral()}
+	case lexer.SUB:
+		expr := &ast.PrefixExpr{Token: p.current(), Op: p.current().Val}
+		if !p.expectPeek(lexer.NUM) {
+			return nil
+		}
+		if expr.Expr = p.parseIntLiteral(); expr.Expr == nil {
+			return nil
+		}
+		return &ast.LiteralPattern{Value: expr}
+	case lexer.IDENT:
+		if p.current().Val == "_" {
+			return &ast.WildcardPattern{Token: p.current()}
+		}
+		return &ast.CapturePattern{Name: &ast.Identifier{Token: p.current(), Val: p.current().Val}}
+	case lexer.LEFTBRACKET:
+		return p.parseSequencePattern(lexer.RIGHTBRACKET)
+	case lexer.LEFTPAREN:
+		return p.parseGroupPattern()
+	}
+	p.errorf(p.current(), "invalid pattern: unexpected %s", p.current().Name)
+	return nil
+}
+
+// parseGroupPattern parses a parenthesized pattern, which is a sequence
+// pattern when it is empty or contains a comma.
+func (p *Parser) parseGroupPattern() ast.Pattern {
+	start := p.index
+	if p.checkPeek(lexer.RIGHTPAREN) {
+		return p.parseSequencePattern(lexer.RIGHTPAREN)
+	}
+	p.next()
+	pattern := p.parseSequenceElement()
+	if pattern == nil {
+		return nil
+	}
+	if _, ok := pattern.(*ast.StarPattern); !ok && p.checkPeek(lexer.RIGHTPAREN) {
+		p.next()
+		return pattern
+	}
+	p.index = start
+	return p.parseSequencePattern(lexer.RIGHTPAREN)
+}
+
+// parseSequencePattern parses the elements of a sequence pattern up to and
+// including the close bracket.
+func (p *Parser) parseSequencePattern(close lexer.TokenType) ast.Pattern {
+	seq := &ast.SequencePattern{Token: p.current(), Patterns: []ast.Pattern{}}
+	for !p.checkPeek(close) {
+		p.next()
+		pattern := p.parseSequenceElement()
+		if pattern == nil {
+			return nil
+		}
+		seq.Patterns = append(seq.Patterns, pattern)
+		if !p.checkPeek(lexer.COMMA) {
+			break
+		}
+		p.next()
+	}
+	if !p.expectPeek(close) {
+		return nil
+	}
+	seq.Close = p.current()
+	if !p.checkStars(seq) {
+		return nil
+	}
+	return seq
+}
+
+// parseSequenceElement parses an element of a sequence pattern, which may
+// be a star pattern.
+func (p *Parser) parseSequenceElement() ast.Pattern {
+	if !p.checkCurrent(lexer.MULT) {
+		return p.parsePattern()
+	}
+	star := &ast.StarPattern{Token: p.current()}
+	if !p.expectPeek(lexer.IDENT) {
+		return nil
+	}
+	star.Name = &ast.Identifier{Token: p.current(), Val: p.current().Val}
+	return star
+}
+
+// checkStars reports whether seq has at most one star pattern, recording
+// an error if it does not.
+func (p *Parser) checkStars(seq *ast.SequencePattern) bool {
+	stars := 0
+	for _, pattern := range seq.Patterns {
+		if _, ok := pattern.(*ast.StarPattern); ok {
+			stars++
+		}
+	}
+	if stars > 1 {
+		p.errorf(seq.Token, "multiple starred names in sequence pattern")
+		return false
+	}
+	return true
+}
+
+// parseForTarget parses the targets of a for loop, binding tighter than
+// comparisons so that the `in` ending them is not read as an operator.
+func (p *Parser) parseForTarget() ast.Expr {
+	tok := p.current()
+	expr := p.parseExpr(COMPARE)
+	if !p.checkPeek(lexer.COMMA) {
+		return expr
+	}
+	tuple := &ast.TupleLiteral{Token: tok, Elements: []ast.Expr{expr}}
+	for p.checkPeek(lexer.COMMA) {
+		p.next()
+		if p.checkPeek(lexer.IN) {
+			break
+		}
+		p.next()
+		tuple.Elements = append(tuple.Elements, p.parseExpr(COMPARE))
+	}
+	return tuple
+}
+
+func (p *Parser) expectCurrent(t lexer.TokenType) bool {
+	if p.checkCurrent(t) {
+		p.next()
+		return true
+	} else {
+		return false
+	}
+}
+
+func (p *Parser) expectPeek(t lexer.TokenType) bool {
+	if p.checkPeek(t) {
+		p.next()
+		return true
+	} else {
+		p.peekError(t)
+		return false
+	}
+}
+
+func (p *Parser) checkCurrent(t lexer.TokenType) bool {
+	if p.end() { return false }
+	return p.current().Name == t
+}
 
 func (p *Parser) checkPeek(t lexer.TokenType) bool {
 	if p.end() { return false }
@@ -345,13 +1779,38 @@ func (p *Parser) checkPeek(t lexer.TokenType) bool {
 }
 
 func (p *Parser) Errors() []string {
-	return p.errors
+	return messages(p.errors)
+}
+
+func messages(errs []Error) []string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.String()
+	}
+	return msgs
+}
+
+// errorf records a syntax error found at tok.
+func (p *Parser) errorf(tok lexer.Token, format string, args ...interface{}) {
+	p.errors = append(p.errors, Error{Pos: tok.Start(), Msg: fmt.Sprintf(format, args...)})
 }
 
 func (p *Parser) peekError(t lexer.TokenType) {
-	err := fmt.Sprintf("error at %s: expected next token to be %s, got %s instead",
-		p.peek().GetPosition(), t, p.peek().Name)
-	p.errors = append(p.errors, err)
+	if p.peek().Name == lexer.ILLEGAL && p.peek().Val == lexer.Unterminated {
+		p.unexpected(p.peek())
+		return
+	}
+	p.errorf(p.peek(), "expected next token to be %s, got %s instead", t, p.peek().Name)
+}
+
+// unexpected records that tok can't appear where it does, or what the
+// lexer found wrong with it.
+func (p *Parser) unexpected(tok lexer.Token) {
+	if tok.Name == lexer.ILLEGAL && tok.Val == lexer.Unterminated {
+		p.errorf(tok, "%s", tok.Val)
+		return
+	}
+	p.errorf(tok, "invalid syntax: unexpected %s", tok.Name)
 }
 
 func (p *Parser) next() {
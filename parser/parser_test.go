@@ -1,6 +1,13 @@
 package parser
 
 import (
+	"errors"
+	"gopy/ast"
+	"gopy/lexer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -12,3 +19,734 @@ func TestStartParse(t *testing.T) {
 	}
 	return
 }
+
+func TestParseIndexExpr(t *testing.T) {
+	p, program := StartParseRepl("a[1][\"k\"]\nb[0] = [1, 2]\n")
+	if len(p.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors())
+	}
+	if len(program.Stmts) != 2 {
+		t.Fatalf("want 2 statements; got %d", len(program.Stmts))
+	}
+	stmt := program.Stmts[0].(*ast.ExprStmt)
+	if _, ok := stmt.Expr.(*ast.IndexExpr); !ok {
+		t.Errorf("want *ast.IndexExpr; got %T", stmt.Expr)
+	}
+	if _, ok := program.Stmts[1].(*ast.IndexAssignStmt); !ok {
+		t.Errorf("want *ast.IndexAssignStmt; got %T", program.Stmts[1])
+	}
+}
+
+func TestParseTernaryExpr(t *testing.T) {
+	p, program := StartParseRepl("x = a if b else c if d else e\n")
+	if len(p.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors())
+	}
+	got := program.Stmts[0].(*ast.VarStmt).Value.String()
+	want := "(a if b else (c if d else e))"
+	if got != want {
+		t.Errorf("want %s; got %s", want, got)
+	}
+}
+
+func TestParseOperatorPrecedence(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1 < x < 10", "(1 < x < 10)"},
+		{"a == b != c", "(a == b != c)"},
+		{"a is not None is b", "(a is not None is b)"},
+		{"not a is b", "(not (a is b))"},
+		{"a not in b or c in d", "((a not in b) or (c in d))"},
+		{"x + 1 in y < z", "((x + 1) in y < z)"},
+		{"(a < b) < c", "((a < b) < c)"},
+		{"a + 1 > b * 2", "((a + 1) > (b * 2))"},
+		{"a or b and c", "(a or (b and c))"},
+		{"not a and b or c", "(((not a) and b) or c)"},
+		{"a < b and b < c", "((a < b) and (b < c))"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a & b << 1", "(a & (b << 1))"},
+		{"a << b + c", "(a << (b + c))"},
+		{"a + b % c // d", "(a + ((b % c) // d))"},
+		{"-a ** b", "(-(a ** b))"},
+		{"a ** b ** c", "(a ** (b ** c))"},
+		{"a ** -b", "(a ** (-b))"},
+		{"~a * b", "((~a) * b)"},
+		{"a | b == c", "((a | b) == c)"},
+		{"not a | b", "(not (a | b))"},
+		{"f(x).y ** 2", "(f(x).y ** 2)"},
+	}
+	for _, tt := range tests {
+		p, program := StartParseRepl(tt.input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("unexpected errors: %v", p.Errors())
+		}
+		if got := program.String(); got != tt.want {
+			t.Errorf("parse(%q); want %s; got %s", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestParseAugAssignStmt(t *testing.T) {
+	ops := []string{"+=", "-=", "*=", "/=", "%=", "**="}
+	for _, op := range ops {
+		input := "x " + op + " 2"
+		p, program := StartParseRepl(input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parse(%q): unexpected errors: %v", input, p.Errors())
+		}
+		stmt, ok := program.Stmts[0].(*ast.AugAssignStmt)
+		if !ok {
+			t.Fatalf("parse(%q); want *ast.AugAssignStmt; got %T", input, program.Stmts[0])
+		}
+		if stmt.String() != input {
+			t.Errorf("parse(%q); got %s", input, stmt.String())
+		}
+	}
+}
+
+func TestParseAssignStmt(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"a, b = 1, 2", "(a, b) = (1, 2)"},
+		{"a = b = 0", "a = b = 0"},
+		{"a, b = b, a", "(a, b) = (b, a)"},
+		{"x[0], y = 1, 2", "(x[0], y) = (1, 2)"},
+		{"first, *rest = items", "(first, *rest) = items"},
+		{"[a, *b.c] = items", "[a, *b.c] = items"},
+	}
+	for _, tt := range tests {
+		p, program := StartParseRepl(tt.input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parse(%q): unexpected errors: %v", tt.input, p.Errors())
+		}
+		stmt, ok := program.Stmts[0].(*ast.AssignStmt)
+		if !ok {
+			t.Fatalf("parse(%q); want *ast.AssignStmt; got %T", tt.input, program.Stmts[0])
+		}
+		if stmt.String() != tt.want {
+			t.Errorf("parse(%q); want %s; got %s", tt.input, tt.want, stmt.String())
+		}
+	}
+	errors := []struct {
+		input string
+		want  string
+	}{
+		{"1 + a = 2", "cannot assign to (1 + a)"},
+		{"*a = items", "starred assignment target must be in a list or tuple"},
+		{"*a, *b = items", "multiple starred expressions in assignment"},
+		{"a, *1 = items", "cannot assign to 1"},
+	}
+	for _, tt := range errors {
+		p, _ := StartParseRepl(tt.input)
+		if len(p.Errors()) != 1 || !strings.Contains(p.Errors()[0], tt.want) {
+			t.Errorf("parse(%q); want an error containing %q; got %v", tt.input, tt.want, p.Errors())
+		}
+	}
+}
+
+func TestParseBlockStmt(t *testing.T) {
+	input := "if a:\n\tpass\nelif b:\n\tx = 1\n\n\ty = 2\nelse:\n\tif c:\n\t\tz = 3\n\tw = 4\nv = 5\n"
+	p, program := StartParseRepl(input)
+	if len(p.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors())
+	}
+	if len(program.Stmts) != 2 {
+		t.Fatalf("want 2 statements; got %d: %s", len(program.Stmts), program.String())
+	}
+	ifExpr := program.Stmts[0].(*ast.ExprStmt).Expr.(*ast.IfExpr)
+	if _, ok := ifExpr.Pass.Stmts[0].(*ast.PassStmt); !ok {
+		t.Errorf("want *ast.PassStmt; got %T", ifExpr.Pass.Stmts[0])
+	}
+	elif := ifExpr.Fail.Stmts[0].(*ast.ExprStmt).Expr.(*ast.IfExpr)
+	if len(elif.Pass.Stmts) != 2 {
+		t.Errorf("elif block; want 2 statements; got %d", len(elif.Pass.Stmts))
+	}
+	if elif.Fail == nil || len(elif.Fail.Stmts) != 2 {
+		t.Errorf("else block; want 2 statements; got %v", elif.Fail)
+	}
+}
+
+func TestParseBlankAndCommentLines(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"def f():\n\n\tx = 1\n\t\n    \n\t\t\n\treturn x\n", "def f(): x x = 1return x"},
+		{"def f(): # header\n\t# first\n\tx = 1 # trailing\n# column one\n\t\t# deeper\n   # odd\n\treturn x\n", "def f(): x x = 1return x"},
+		{"if a:\n\tb()\n\t# last line of the block\nc()\n", "ifa : b()c()"},
+		{"x = [1, # one\n\t2]\ny = 3 #", "x x = [1, 2]y y = 3"},
+		{"# leading\n\n# comments\nx()\n# trailing", "x()"},
+	}
+	for _, tt := range tests {
+		p, program := StartParseRepl(tt.input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parse(%q): unexpected errors: %v", tt.input, p.Errors())
+		}
+		if got := program.String(); got != tt.want {
+			t.Errorf("parse(%q); want %q; got %q", tt.input, tt.want, got)
+		}
+	}
+	p, _ := StartParseRepl("def f():\n\t# only a comment\nx = 1\n")
+	if len(p.Errors()) != 1 || !strings.Contains(p.Errors()[0], "expected an indented block") {
+		t.Errorf("want an expected an indented block error; got %v", p.Errors())
+	}
+}
+
+func TestParseLoopControlStmt(t *testing.T) {
+	p, program := StartParseRepl("while a:\n\tif b:\n\t\tbreak\n\tcontinue\n")
+	if len(p.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors())
+	}
+	body := program.Stmts[0].(*ast.ExprStmt).Expr.(*ast.WhileExpr).Body
+	if _, ok := body.Stmts[1].(*ast.ContinueStmt); !ok {
+		t.Errorf("want *ast.ContinueStmt; got %T", body.Stmts[1])
+	}
+	for _, input := range []string{"break", "if a:\n\tcontinue\n"} {
+		p, _ := StartParseRepl(input)
+		if len(p.Errors()) == 0 {
+			t.Errorf("parse(%q); want an error", input)
+		}
+	}
+}
+
+func TestParseLoopElse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"for x in xs:\n\tpass\n", "for x in xs: pass"},
+		{"for k, v in pairs:\n\tbreak\nelse:\n\tpass\n", "for (k, v) in pairs: break else: pass"},
+		{"for x in 1, 2:\n\tpass\n", "for x in (1, 2): pass"},
+		{"for a.b in xs:\n\tcontinue\n", "for a.b in xs: continue"},
+	}
+	for _, tt := range tests {
+		p, program := StartParseRepl(tt.input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parse(%q): unexpected errors: %v", tt.input, p.Errors())
+		}
+		if got := program.String(); got != tt.want {
+			t.Errorf("parse(%q); want %q; got %q", tt.input, tt.want, got)
+		}
+	}
+
+	p, program := StartParseRepl("while a:\n\tpass\nelse:\n\tb()\nc = 2\n")
+	if len(p.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors())
+	}
+	if len(program.Stmts) != 2 {
+		t.Fatalf("want 2 statements; got %d", len(program.Stmts))
+	}
+	loop := program.Stmts[0].(*ast.ExprStmt).Expr.(*ast.WhileExpr)
+	if loop.Else == nil || loop.Else.String() != "b()" {
+		t.Errorf("while else; want b(); got %v", loop.Else)
+	}
+
+	for _, input := range []string{"for x in xs:\n\tpass\nelse:\n\tbreak\n", "for 1 in xs:\n\tpass\n", "for x of xs:\n\tpass\n"} {
+		p, _ := StartParseRepl(input)
+		if len(p.Errors()) == 0 {
+			t.Errorf("parse(%q); want an error", input)
+		}
+	}
+}
+
+func TestParseMatchStmt(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"match x:\n\tcase 1:\n\t\tpass\n\tcase _:\n\t\tpass\n", "match x: case 1: pass case _: pass"},
+		{"match p:\n\tcase (a, b):\n\t\tpass\n\tcase [first, *rest]:\n\t\tpass\n", "match p: case [a, b]: pass case [first, *rest]: pass"},
+		{"match p:\n\tcase a, -2:\n\t\tpass\n\tcase (\"s\"):\n\t\tpass\n\tcase ():\n\t\tpass\n", "match p: case [a, (-2)]: pass case s: pass case []: pass"},
+		{"match(x)\nmatch.y = 1\n", "match(x)match.y = 1"},
+	}
+	for _, tt := range tests {
+		p, program := StartParseRepl(tt.input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parse(%q): unexpected errors: %v", tt.input, p.Errors())
+		}
+		if got := program.String(); got != tt.want {
+			t.Errorf("parse(%q); want %q; got %q", tt.input, tt.want, got)
+		}
+	}
+
+	errors := []struct {
+		input string
+		want  string
+	}{
+		{"match x:\n\tcase _:\n\t\tpass\n\tcase 1:\n\t\tpass\n", "wildcard makes remaining patterns unreachable"},
+		{"match x:\n\tcase y:\n\t\tpass\n\tcase 1:\n\t\tpass\n", "name capture 'y' makes remaining patterns unreachable"},
+		{"match x:\n\tcase [a, a]:\n\t\tpass\n", "multiple assignments to name 'a' in pattern"},
+		{"match x:\n\tcase [*a, *b]:\n\t\tpass\n", "multiple starred names in sequence pattern"},
+		{"match x:\n\tcase *a:\n\t\tpass\n", "can't use starred name here"},
+		{"match x:\n\tcase a + 1:\n\t\tpass\n", "expected next token to be :"},
+		{"match x:\n\tcase {}:\n\t\tpass\n", "invalid pattern: unexpected {"},
+		{"match x:\n\tpass\n", "expected 'case', got PASS"},
+	}
+	for _, tt := range errors {
+		p, _ := StartParseRepl(tt.input)
+		if len(p.Errors()) != 1 || !strings.Contains(p.Errors()[0], tt.want) {
+			t.Errorf("parse(%q); want an error containing %q; got %v", tt.input, tt.want, p.Errors())
+		}
+	}
+}
+
+func TestParseClassDef(t *testing.T) {
+	input := "class B(A):\n\tdef f(self, x):\n\t\treturn x\n\tdef g(self):\n\t\treturn\n"
+	p, program := StartParseRepl(input)
+	if len(p.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors())
+	}
+	class, ok := program.Stmts[0].(*ast.ClassDef)
+	if !ok {
+		t.Fatalf("want *ast.ClassDef; got %T", program.Stmts[0])
+	}
+	if class.Base.String() != "A" || len(class.Body.Stmts) != 2 {
+		t.Errorf("unexpected class: %s", class.String())
+	}
+	fn := class.Body.Stmts[0].(*ast.FunctionDef)
+	if len(fn.Params) != 2 || fn.Params[1].Val != "x" {
+		t.Errorf("unexpected params: %v", fn.Params)
+	}
+	for _, input := range []string{"return 1", "class C(A, B):\n\tpass\n", "def f():\n\tclass C:\n\t\treturn\n"} {
+		p, _ := StartParseRepl(input)
+		if len(p.Errors()) == 0 {
+			t.Errorf("parse(%q); want an error", input)
+		}
+	}
+}
+
+func TestParseImportStmt(t *testing.T) {
+	tests := []string{
+		"import a",
+		"import a as b, c",
+		"from a import b, c as d",
+	}
+	for _, input := range tests {
+		p, program := StartParseRepl(input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parse(%q): unexpected errors: %v", input, p.Errors())
+		}
+		if got := program.String(); got != input {
+			t.Errorf("parse(%q); got %s", input, got)
+		}
+	}
+}
+
+func TestParseTryStmt(t *testing.T) {
+	input := "try:\n\tx\nexcept E as e:\n\ty\nexcept:\n\tpass\nelse:\n\tz\nfinally:\n\tw\n"
+	p, program := StartParseRepl(input)
+	if len(p.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors())
+	}
+	want := "try: x except E as e: y except: pass else: z finally: w"
+	if got := program.String(); got != want {
+		t.Errorf("parse(%q); want %s; got %s", input, want, got)
+	}
+	for _, input := range []string{
+		"try:\n\tx\ny\n",
+		"try:\n\tx\nexcept:\n\ty\nexcept E:\n\tz\n",
+		"try:\n\tx\nelse:\n\ty\n",
+	} {
+		p, _ := StartParseRepl(input)
+		if len(p.Errors()) == 0 {
+			t.Errorf("parse(%q); want an error", input)
+		}
+	}
+}
+
+func TestParseRaiseStmt(t *testing.T) {
+	for _, input := range []string{"raise", "raise ValueError(x)", "raise KeyError from e"} {
+		p, program := StartParseRepl(input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parse(%q): unexpected errors: %v", input, p.Errors())
+		}
+		if _, ok := program.Stmts[0].(*ast.RaiseStmt); !ok {
+			t.Fatalf("parse(%q); want *ast.RaiseStmt; got %T", input, program.Stmts[0])
+		}
+		if got := program.String(); got != input {
+			t.Errorf("parse(%q); got %s", input, got)
+		}
+	}
+}
+
+func TestParseWithStmt(t *testing.T) {
+	input := "with open(p) as f, lock:\n\tf.read()\n"
+	p, program := StartParseRepl(input)
+	if len(p.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors())
+	}
+	want := "with open(p) as f, lock: f.read()"
+	if got := program.String(); got != want {
+		t.Errorf("parse(%q); want %s; got %s", input, want, got)
+	}
+	if p, _ := StartParseRepl("with a as 1:\n\tpass\n"); len(p.Errors()) == 0 {
+		t.Errorf("want an error for an invalid target")
+	}
+}
+
+func TestParseAssertStmt(t *testing.T) {
+	for _, input := range []string{"assert x", "assert (x > 1), msg"} {
+		p, program := StartParseRepl(input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parse(%q): unexpected errors: %v", input, p.Errors())
+		}
+		if got := program.String(); got != input {
+			t.Errorf("parse(%q); got %s", input, got)
+		}
+	}
+}
+
+func TestParseDelStmt(t *testing.T) {
+	input := "del a, b[0], c.d"
+	p, program := StartParseRepl(input)
+	if len(p.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors())
+	}
+	if got := program.String(); got != input {
+		t.Errorf("parse(%q); got %s", input, got)
+	}
+	if p, _ := StartParseRepl("del 1"); len(p.Errors()) == 0 {
+		t.Errorf("parse(\"del 1\"); want an error")
+	}
+}
+
+func TestParseScopeDeclarations(t *testing.T) {
+	input := "def f():\n\tglobal a, b\n\tnonlocal c\n"
+	p, program := StartParseRepl(input)
+	if len(p.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors())
+	}
+	if want, got := "def f(): global a, bnonlocal c", program.String(); got != want {
+		t.Errorf("parse(%q); want %s; got %s", input, want, got)
+	}
+	if p, _ := StartParseRepl("nonlocal x"); len(p.Errors()) == 0 {
+		t.Errorf("parse(\"nonlocal x\"); want an error")
+	}
+}
+
+func TestParseYieldExpr(t *testing.T) {
+	p, program := StartParseRepl("def f():\n\tx = yield 1\n\tyield\ndef g():\n\treturn 1\n")
+	if len(p.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors())
+	}
+	if f := program.Stmts[0].(*ast.FunctionDef); !f.Generator {
+		t.Errorf("want f to be a generator")
+	}
+	if g := program.Stmts[1].(*ast.FunctionDef); g.Generator {
+		t.Errorf("want g not to be a generator")
+	}
+	if p, _ := StartParseRepl("yield 1"); len(p.Errors()) == 0 {
+		t.Errorf("parse(\"yield 1\"); want an error")
+	}
+}
+
+func TestParseCallArguments(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"def f(a, b=2):\n\ta\n", "def f(a, b=2): a"},
+		{"f(1, x=(a == b), y=2)", "f(1, x=(a == b), y=2)"},
+		{"def f(a, *args, **kwargs):\n\ta\n", "def f(a, *args, **kwargs): a"},
+		{"f(*xs, k=1, **opts)", "f(*xs, k=1, **opts)"},
+	}
+	for _, tt := range tests {
+		p, program := StartParseRepl(tt.input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parse(%q): unexpected errors: %v", tt.input, p.Errors())
+		}
+		if got := program.String(); got != tt.want {
+			t.Errorf("parse(%q); want %s; got %s", tt.input, tt.want, got)
+		}
+	}
+	for _, input := range []string{"def f(a=1, b):\n\tpass\n", "f(a=1, 2)", "f(a=1, a=2)",
+		"def f(**kw, a):\n\tpass\n", "def f(*a, *b):\n\tpass\n", "def f(*a, b):\n\tpass\n"} {
+		if p, _ := StartParseRepl(input); len(p.Errors()) == 0 {
+			t.Errorf("parse(%q); want an error", input)
+		}
+	}
+}
+
+func TestParseDocstrings(t *testing.T) {
+	input := "\"Module.\"\ndef f():\n\t\"Function.\"\n\tpass\nclass C:\n\t\"Class.\"\n\tpass\n"
+	p, program := StartParseRepl(input)
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse(%q): unexpected errors: %v", input, p.Errors())
+	}
+	if program.Doc != "Module." {
+		t.Errorf("program.Doc; want Module.; got %q", program.Doc)
+	}
+	if doc := program.Stmts[1].(*ast.FunctionDef).Doc; doc != "Function." {
+		t.Errorf("FunctionDef.Doc; want Function.; got %q", doc)
+	}
+	if doc := program.Stmts[2].(*ast.ClassDef).Doc; doc != "Class." {
+		t.Errorf("ClassDef.Doc; want Class.; got %q", doc)
+	}
+}
+
+func TestParseFunctionLocals(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"def f(a):\n\treturn a", ""},
+		{"def f():\n\tx = 1\n\ty, [z, w] = 2, [3, 4]\n\tx += 1", "x y z w"},
+		{"def f():\n\tglobal g\n\tg = 1\n\th = 2", "h"},
+		{"def f():\n\tdef inner():\n\t\tx = 1\n\tclass C:\n\t\ty = 2", "inner C"},
+		{"def f():\n\timport os as o\n\tfrom m import a\n\tdel b", "o a b"},
+		{"def f():\n\ttry:\n\t\tpass\n\texcept E as e:\n\t\twith c as v:\n\t\t\tpass", "e v"},
+	}
+	for _, tt := range tests {
+		p, program := StartParseRepl(tt.input)
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parse(%q): unexpected errors: %v", tt.input, p.Errors())
+		}
+		got := strings.Join(program.Stmts[0].(*ast.FunctionDef).Locals, " ")
+		if got != tt.want {
+			t.Errorf("parse(%q) locals; want %q; got %q", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestParseErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input string
+		errors []string
+		want string
+	}{
+		{
+			"x = )\ny = 1\nz = ]\n",
+			[]string{
+				"error at line 1, column 5: invalid syntax: unexpected )",
+				"error at line 3, column 5: invalid syntax: unexpected ]",
+			},
+			"y y = 1",
+		},
+		{
+			"def f(a=1, b):\n\treturn a\nx = 1 2\n",
+			[]string{
+				"error at line 1, column 12: non-default argument follows default argument",
+				"error at line 3, column 7: invalid syntax: unexpected NUM",
+			},
+			"",
+		},
+		{
+			"def f():\n\tx = )\n\ty = 2\n\tz = ]\nf()",
+			[]string{
+				"error at line 2, column 9: invalid syntax: unexpected )",
+				"error at line 4, column 9: invalid syntax: unexpected ]",
+			},
+			"def f(): y y = 2f()",
+		},
+		{
+			"def f(:\nz = ]\n",
+			[]string{
+				"error at line 1, column 7: invalid parameter :",
+				"error at line 2, column 5: invalid syntax: unexpected ]",
+			},
+			"",
+		},
+		{
+			"def f(:\n\treturn 1\ny = 2\n",
+			[]string{
+				"error at line 1, column 7: invalid parameter :",
+			},
+			"y y = 2",
+		},
+		{
+			"if True:\n\tx = (1\n\ty = 2\n\tz = ]\n",
+			[]string{
+				"error at line 3, column 5: expected next token to be ), got IDENT instead",
+				"error at line 4, column 9: invalid syntax: unexpected ]",
+			},
+			"ifTrue : y y = 2",
+		},
+	}
+	for _, tt := range tests {
+		p, program := StartParseRepl(tt.input)
+		errors := p.Errors()
+		if len(errors) != len(tt.errors) {
+			t.Errorf("parse(%q); want errors %q; got %q", tt.input, tt.errors, errors)
+			continue
+		}
+		for i, err := range errors {
+			if err != tt.errors[i] {
+				t.Errorf("parse(%q) error %d; want %s; got %s", tt.input, i, tt.errors[i], err)
+			}
+		}
+		if got := program.String(); got != tt.want {
+			t.Errorf("parse(%q); want %s; got %s", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, input := range []string{"", "\n\n"} {
+		program, err := Parse([]byte(input))
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", input, err)
+		} else if len(program.Stmts) != 0 {
+			t.Errorf("Parse(%q); want no statements; got %s", input, program.String())
+		}
+	}
+	program, err := Parse([]byte("x = 1\ny = x"))
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	if got := program.String(); got != "x x = 1y y = x" {
+		t.Errorf("Parse; want x x = 1y y = x; got %s", got)
+	}
+	_, err = Parse([]byte("x = )\ny = ]"))
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || len(syntaxErr.Errors) != 2 {
+		t.Errorf("Parse; want a SyntaxError with 2 errors; got %v", err)
+	}
+
+	malformed := []struct {
+		input string
+		want string
+	}{
+		{"x = \"", "error at line 1, column 5: unterminated string literal"},
+		{"\"", "error at line 1, column 1: unterminated string literal"},
+		{"print(\"", "error at line 1, column 7: unterminated string literal"},
+		{"b\"", "error at line 1, column 1: unterminated string literal"},
+		{"x = 1 % = 2", "error at line 1, column 9: invalid syntax: unexpected ="},
+		{"print(x", "error at line 1, column 8: expected next token to be ,, got EOF instead"},
+		{"x = (1\n\n", "error at line 1, column 7: expected next token to be ), got EOF instead"},
+		{"if x:\n\tprint(x  # note\n\n", "error at line 2, column 12: expected next token to be ,, got EOF instead"},
+	}
+	for _, tt := range malformed {
+		if _, err := Parse([]byte(tt.input)); err == nil || err.Error() != tt.want {
+			t.Errorf("Parse(%q); want %s; got %v", tt.input, tt.want, err)
+		}
+	}
+	for _, input := range []string{"for 0%", "del 0% "} {
+		if _, err := Parse([]byte(input)); !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q); want a SyntaxError; got %v", input, err)
+		}
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "prog.py")
+	if err := ioutil.WriteFile(path, []byte("x = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFile(path); err != nil {
+		t.Errorf("ParseFile(%q): unexpected error: %v", path, err)
+	}
+	if _, err := ParseFile(filepath.Join(dir, "missing.py")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ParseFile of a missing file; want an error wrapping os.ErrNotExist; got %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte("x = )\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var syntaxErr *SyntaxError
+	if _, err := ParseFile(path); !errors.As(err, &syntaxErr) {
+		t.Errorf("ParseFile of a bad file; want a SyntaxError; got %v", err)
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	program, err := ParseReader("<string>", strings.NewReader("x = 1\n"))
+	if err != nil {
+		t.Fatalf("ParseReader: unexpected error: %v", err)
+	}
+	if got := program.String(); got != "x x = 1" {
+		t.Errorf("ParseReader; want x x = 1; got %s", got)
+	}
+	_, err = ParseReader("gen.py", strings.NewReader("x = )\ny = ]"))
+	want := "gen.py: error at line 1, column 5: invalid syntax: unexpected )\n" +
+		"gen.py: error at line 2, column 5: invalid syntax: unexpected ]"
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseReader; want error %q; got %v", want, err)
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1 + 2 * x", "(1 + (2 * x))"},
+		{"\n  price * (1 - discount)\n\n", "(price * (1 - discount))"},
+		{"f(a, b=1)[0].c", "f(a, b=1)[0].c"},
+		{"a if b else c", "(a if b else c)"},
+		{"1, 2", "(1, 2)"},
+		{`b"ab" + x`, `(b"ab" + x)`},
+	}
+	for _, tt := range tests {
+		expr, err := ParseExpr(tt.input)
+		if err != nil {
+			t.Fatalf("ParseExpr(%q): unexpected error: %v", tt.input, err)
+		}
+		if got := expr.String(); got != tt.want {
+			t.Errorf("ParseExpr(%q); want %s; got %s", tt.input, tt.want, got)
+		}
+	}
+
+	errors := []struct {
+		input string
+		want  string
+	}{
+		{"", "error at line 1, column 1: invalid syntax: unexpected EOF"},
+		{"x = 1", "error at line 1, column 3: invalid syntax: unexpected ="},
+		{"a\nb", "error at line 2, column 1: invalid syntax: unexpected IDENT"},
+		{"if a:\n\tb", "error at line 1, column 1: invalid syntax: unexpected IF"},
+		{"1 +", "error at line 1, column 4: invalid syntax: unexpected EOF"},
+		{`x + b"café"`, "error at line 1, column 5: bytes can only contain ASCII literal characters"},
+		{`b"\x4"`, "error at line 1, column 1: invalid \\x escape at position 0"},
+	}
+	for _, tt := range errors {
+		_, err := ParseExpr(tt.input)
+		if _, ok := err.(*SyntaxError); !ok || err.Error() != tt.want {
+			t.Errorf("ParseExpr(%q); want error %q; got %v", tt.input, tt.want, err)
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	src := "x = f(1, \"ab\")[0]\ndef g(a):\n    return a + 1\n"
+	program, err := ParseReader("pos.py", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("ParseReader: unexpected error: %v", err)
+	}
+	assign := program.Stmts[0].(*ast.VarStmt)
+	def := program.Stmts[1].(*ast.FunctionDef)
+	ret := def.Body.Stmts[0].(*ast.ReturnStmt)
+	tests := []struct {
+		node ast.Node
+		text string
+	}{
+		{assign, "x = f(1, \"ab\")[0]"},
+		{assign.Value, "f(1, \"ab\")[0]"},
+		{assign.Value.(*ast.IndexExpr).Left, "f(1, \"ab\")"},
+		{assign.Value.(*ast.IndexExpr).Left.(*ast.CallExpr).Args[1], "\"ab\""},
+		{def, "def g(a):\n    return a + 1"},
+		{ret, "return a + 1"},
+		{ret.Value, "a + 1"},
+		{program, src[:len(src)-1]},
+	}
+	for _, tt := range tests {
+		start, end := tt.node.Pos(), tt.node.End()
+		if got := src[start.Offset:end.Offset]; got != tt.text {
+			t.Errorf("source of %s; want %q; got %q", tt.node.String(), tt.text, got)
+		}
+	}
+	pos := ret.Value.Pos()
+	if want := (lexer.Position{Filename: "pos.py", Line: 3, Column: 12, Offset: 39}); pos != want {
+		t.Errorf("position of %s; want %+v; got %+v", ret.Value.String(), want, pos)
+	}
+	if want := "pos.py, line 3, column 12"; pos.String() != want {
+		t.Errorf("want %s; got %s", want, pos.String())
+	}
+}
@@ -0,0 +1,102 @@
+package parser
+
+import "gopy/ast"
+
+// localNames returns the names bound anywhere in the body of def, in the
+// order they first appear. Like Python, a name bound in a function is local
+// to all of it, so these never fall through to an enclosing scope. Names
+// declared global or nonlocal are left out, and nested functions and
+// classes contribute only their own names.
+func localNames(def *ast.FunctionDef) []string {
+	var names []string
+	seen := map[string]bool{}
+	declared := map[string]bool{}
+	bind := func(ident *ast.Identifier) {
+		if ident != nil && !seen[ident.Val] {
+			seen[ident.Val] = true
+			names = append(names, ident.Val)
+		}
+	}
+	var bindTarget func(target ast.Expr)
+	bindTarget = func(target ast.Expr) {
+		switch target := target.(type) {
+		case *ast.Identifier:
+			bind(target)
+		case *ast.TupleLiteral:
+			for _, elem := range target.Elements {
+				bindTarget(elem)
+			}
+		case *ast.ListLiteral:
+			for _, elem := range target.Elements {
+				bindTarget(elem)
+			}
+		case *ast.StarredExpr:
+			bindTarget(target.Value)
+		}
+	}
+	bindImports := func(imported []*ast.Identifier, aliases []*ast.Identifier) {
+		for i, name := range imported {
+			if aliases[i] != nil {
+				bind(aliases[i])
+			} else {
+				bind(name)
+			}
+		}
+	}
+	ast.Inspect(def.Body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionDef:
+			bind(node.Name)
+			return false
+		case *ast.ClassDef:
+			bind(node.Name)
+			return false
+		case *ast.VarStmt:
+			bind(node.Ident)
+		case *ast.AssignStmt:
+			for _, target := range node.Targets {
+				bindTarget(target)
+			}
+		case *ast.AugAssignStmt:
+			bindTarget(node.Target)
+		case *ast.ForStmt:
+			bindTarget(node.Target)
+		case *ast.DelStmt:
+			for _, target := range node.Targets {
+				bindTarget(target)
+			}
+		case *ast.ImportStmt:
+			bindImports(node.Names, node.Aliases)
+		case *ast.FromImportStmt:
+			bindImports(node.Names, node.Aliases)
+		case *ast.ExceptClause:
+			bind(node.Name)
+		case *ast.CapturePattern:
+			bind(node.Name)
+		case *ast.StarPattern:
+			if node.Name.Val != "_" {
+				bind(node.Name)
+			}
+		case *ast.WithStmt:
+			for _, target := range node.Targets {
+				bindTarget(target)
+			}
+		case *ast.GlobalStmt:
+			for _, name := range node.Names {
+				declared[name.Val] = true
+			}
+		case *ast.NonlocalStmt:
+			for _, name := range node.Names {
+				declared[name.Val] = true
+			}
+		}
+		return true
+	})
+	locals := names[:0]
+	for _, name := range names {
+		if !declared[name] {
+			locals = append(locals, name)
+		}
+	}
+	return locals
+}
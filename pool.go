@@ -0,0 +1,74 @@
+package gopy
+
+import (
+	"context"
+	"gopy/evaluator"
+	"sync/atomic"
+)
+
+// retired marks an interpreter returned to its pool.
+const retired = 2
+
+// Pool hands out interpreters configured alike, for servers running
+// scripts from many goroutines. Every interpreter it hands out is new, so
+// scripts never see each other's variables or modules, and is prepared by
+// the pool's setup function, such as to register Go functions.
+type Pool struct {
+	opts []Option
+	setup func(*Interpreter) error
+	// slots holds a value for each interpreter out of the pool, or is nil
+	// if there is no limit.
+	slots chan struct{}
+}
+
+// NewPool returns a pool handing out at most size interpreters at a time,
+// or any number if size isn't positive. They are made by New with opts and
+// then passed to setup, unless it is nil.
+func NewPool(size int, setup func(*Interpreter) error, opts ...Option) *Pool {
+	p := &Pool{opts: opts, setup: setup}
+	if size > 0 {
+		p.slots = make(chan struct{}, size)
+	}
+	return p
+}
+
+// Get returns a new interpreter once fewer than the pool's size are out,
+// or the error from ctx if it is done first, or from setup if that fails.
+// The interpreter should be given back with Put when done with.
+func (p *Pool) Get(ctx context.Context) (*Interpreter, error) {
+	if p.slots != nil {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	interp := New(p.opts...)
+	if p.setup != nil {
+		if err := p.setup(interp); err != nil {
+			p.free()
+			return nil, err
+		}
+	}
+	interp.out = 1
+	return interp, nil
+}
+
+// Put gives back an interpreter from Get, making room for another, and
+// closes it. Using it afterwards fails with ErrInUse. Putting an
+// interpreter already closed makes room too, and putting one twice has no
+// further effect.
+func (p *Pool) Put(interp *Interpreter) {
+	if !atomic.CompareAndSwapInt32(&interp.out, 1, 0) {
+		return
+	}
+	atomic.StoreInt32(&interp.busy, retired)
+	evaluator.Close(interp.env)
+	p.free()
+}
+
+func (p *Pool) free() {
+	if p.slots != nil {
+		<-p.slots
+	}
+}
@@ -0,0 +1,172 @@
+package gopy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	pool := NewPool(4, func(interp *Interpreter) error {
+		return interp.RegisterFunc("double", func(n int) int { return n * 2 })
+	}, WithMaxSteps(100000))
+
+	src := `import time
+def fact(n):
+    if n <= 1:
+        time.sleep(0.001)
+        return 1
+    return n * fact(n - 1)
+def check(n):
+    try:
+        raise ValueError(n)
+    except ValueError:
+        time.sleep(0.001)
+        raise
+try:
+    check(job)
+except ValueError as e:
+    caught = e.args[0]
+(double(fact(5)), caught)`
+	program, err := Compile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			interp, err := pool.Get(context.Background())
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer pool.Put(interp)
+			if got, err := interp.Eval(fmt.Sprintf("job = %d", i)); err != nil || got.Visit() != "None" {
+				errs <- fmt.Errorf("setting job in interpreter %d: %v", i, err)
+				return
+			}
+			got, err := program.Run(interp)
+			if want := fmt.Sprintf("(240, %d)", i); err != nil || got.Visit() != want {
+				errs <- fmt.Errorf("interpreter %d returned %v, %v; want %s", i, got, err, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	interp, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interp.Eval("job"); err == nil || err.Error() != "NameError: name 'job' is not defined" {
+		t.Errorf("interpreters from the pool should start empty; Eval(\"job\") returned %v", err)
+	}
+	pool.Put(interp)
+	pool.Put(interp)
+	if _, err := interp.Eval("1"); err != ErrInUse {
+		t.Errorf("Eval after Put returned %v; want ErrInUse", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	interp := New()
+	if _, err := interp.Eval("def gen():\n    yield 1\n    yield 2\ng = gen()\nnext(g)"); err != nil {
+		t.Fatal(err)
+	}
+	interp.Close()
+	if _, err := interp.Eval("next(g)"); err != ErrInUse {
+		t.Errorf("Eval after Close returned %v; want ErrInUse", err)
+	}
+}
+
+func TestPoolSize(t *testing.T) {
+	pool := NewPool(1, nil)
+	first, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Get from a full pool returned %v; want context.DeadlineExceeded", err)
+	}
+	pool.Put(first)
+	second, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get after Put returned %v", err)
+	}
+	second.Close()
+	pool.Put(second)
+	pool.Put(second)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	third, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get after Close and Put returned %v", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx); err != context.DeadlineExceeded {
+		t.Errorf("putting an interpreter twice should free its slot once; Get returned %v", err)
+	}
+	pool.Put(third)
+
+	failing := NewPool(1, func(*Interpreter) error { return fmt.Errorf("setup failed") })
+	for i := 0; i < 2; i++ {
+		if _, err := failing.Get(context.Background()); err == nil || err.Error() != "setup failed" {
+			t.Errorf("Get with a failing setup returned %v", err)
+		}
+	}
+}
+
+func TestParallel(t *testing.T) {
+	busy := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := busy.EvalContext(ctx, "while True:\n    pass")
+		done <- err
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	interp := New()
+	start := time.Now()
+	short, stop := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer stop()
+	got, err := interp.EvalContext(short, "sum(range(1000))")
+	if err != nil || got.Visit() != "499500" {
+		t.Errorf("EvalContext while another interpreter runs returned %v, %v; want 499500", got, err)
+	}
+	if _, err := interp.EvalContext(short, "while True:\n    pass"); err == nil {
+		t.Errorf("EvalContext of an endless loop returned no error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("interpreters running at the same time took %v; want them not to wait for each other", elapsed)
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	interp := New()
+	var inner error
+	if err := interp.RegisterFunc("reenter", func() { _, inner = interp.Eval("1") }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interp.Eval("reenter()"); err != nil {
+		t.Fatal(err)
+	}
+	if inner != ErrInUse {
+		t.Errorf("Eval from a Go function the interpreter called returned %v; want ErrInUse", inner)
+	}
+}
@@ -0,0 +1,57 @@
+package gopy
+
+import (
+	"context"
+	"fmt"
+	"gopy/ast"
+	"gopy/evaluator"
+	"gopy/interpreter"
+	"gopy/parser"
+)
+
+// Program is a parsed script. It can be run any number of times, in any
+// interpreter, without being parsed again.
+type Program struct {
+	program *ast.Program
+}
+
+// Compile parses src for running later. A syntax error is returned as an
+// *Error, as is a panic in the parser, so that no source can crash the
+// host.
+func Compile(src string) (p *Program, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			p, err = nil, syntaxError(&parser.SyntaxError{Errors: []parser.Error{{Msg: fmt.Sprintf("parser panicked: %v", r)}}})
+		}
+	}()
+	program, err := parser.Parse([]byte(src))
+	if err != nil {
+		return nil, syntaxError(err.(*parser.SyntaxError))
+	}
+	return &Program{program: program}, nil
+}
+
+// Run runs the program in interp's global scope, returning what Eval of
+// its source would.
+func (p *Program) Run(interp *Interpreter) (interpreter.Item, error) {
+	return p.RunContext(context.Background(), interp)
+}
+
+// RunContext runs the program like Run, but stops it once ctx is done, as
+// EvalContext does.
+func (p *Program) RunContext(ctx context.Context, interp *Interpreter) (interpreter.Item, error) {
+	if !interp.acquire() {
+		return nil, ErrInUse
+	}
+	defer interp.release()
+	result := evaluator.EvaluateContext(ctx, p.program, interp.env)
+	if err, ok := result.(*interpreter.Error); ok {
+		return nil, runtimeError(err)
+	}
+	if n := len(p.program.Stmts); n == 0 || result == nil {
+		return evaluator.NONE, nil
+	} else if _, ok := p.program.Stmts[n-1].(*ast.ExprStmt); !ok {
+		return evaluator.NONE, nil
+	}
+	return result, nil
+}
@@ -11,7 +11,7 @@ import (
 
 func Run(w *bufio.Writer, r *bufio.Reader) {
 	scanner := bufio.NewScanner(r)
-	environment := interpreter.NewEnv()
+	environment := evaluator.NewEnv()
 	for {
 		fmt.Printf("REPL> ")
 		input := scanner.Scan()
@@ -19,14 +19,18 @@ func Run(w *bufio.Writer, r *bufio.Reader) {
 			return
 		}
 		line := scanner.Text()
-		p, program := parser.StartParseRepl(line)
-		if len(p.Errors()) != 0 {
-			printParserErrors(w, p.Errors())
+		program, err := parser.Parse([]byte(line))
+		if err != nil {
+			printParserErrors(w, err.(*parser.SyntaxError).Messages())
 			continue
 		}
 		io.WriteString(w, program.String())
-		eval := evaluator.Evaluate(&program, environment)
-		if eval != nil {
+		eval := evaluator.Evaluate(program, environment)
+		if err, ok := eval.(*interpreter.Error); ok {
+			fmt.Print(evaluator.FormatTraceback(err))
+			continue
+		}
+		if eval != nil && eval.Type() != interpreter.NONE {
 			fmt.Printf("%v\n", eval.Visit())
 		}
 	}